@@ -53,6 +53,10 @@ import (
 // Change below variables to serve metrics on different host or port.
 var metricsPort = 8383
 
+// gracefulShutdownTimeout is the graceful shutdown timeout of the manager, the manager waits for all of its
+// runnables, e.g. the controllers and their in-flight reconciles, to stop within it when it is shutting down
+var gracefulShutdownTimeout = 30 * time.Second
+
 var (
 	scheme   = k8sruntime.NewScheme()
 	setupLog = ctrl.Log.WithName("setup")
@@ -71,6 +75,8 @@ func init() {
 func main() {
 	pflag.CommandLine.SetNormalizeFunc(utilflag.WordSepNormalizeFunc)
 	features.DefaultMutableFeatureGate.AddFlag(pflag.CommandLine)
+	pflag.CommandLine.DurationVar(&gracefulShutdownTimeout, "graceful-shutdown-timeout", gracefulShutdownTimeout,
+		"The time to wait for the controllers to stop when the manager is shutting down.")
 	pflag.Parse()
 
 	logs.InitLogs()
@@ -135,10 +141,11 @@ func main() {
 
 	// Create controller-runtime manager
 	mgr, err := ctrl.NewManager(cfg, manager.Options{
-		Scheme:             scheme,
-		MetricsBindAddress: fmt.Sprintf(":%d", metricsPort),
-		LeaderElection:     true,
-		LeaderElectionID:   "managedcluster-import-controller.open-cluster-management.io",
+		Scheme:                  scheme,
+		MetricsBindAddress:      fmt.Sprintf(":%d", metricsPort),
+		LeaderElection:          true,
+		LeaderElectionID:        "managedcluster-import-controller.open-cluster-management.io",
+		GracefulShutdownTimeout: &gracefulShutdownTimeout,
 	})
 	if err != nil {
 		setupLog.Error(err, "failed to create manager")
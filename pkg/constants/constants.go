@@ -13,6 +13,38 @@ const AutoImportSecretName string = "auto-import-secret"
 // AutoImportRetryName is the secret data key of auto import retry
 const AutoImportRetryName string = "autoImportRetry"
 
+// AutoImportExpectedClusterIDName is the secret data key of the expected cluster id, it is optional, if it is set,
+// the UID of the kube-system namespace of the target cluster must match it, otherwise the import will be refused
+const AutoImportExpectedClusterIDName string = "expectedClusterID"
+
+// AutoImportBootstrapServiceAccountName is the secret data key of the bootstrap service account, it is optional, the
+// value is "<namespace>/<name>" or "<name>" of a service account in the kube-system namespace of the target cluster.
+// If it is set, the credentials of the secret are only used to request a token of the service account, and the
+// cluster is imported with the token
+const AutoImportBootstrapServiceAccountName string = "bootstrapSA"
+
+// AutoImportSecretLabel is the label of the additional auto import secrets in the managed cluster namespace, e.g. a
+// short-lived token as the fallback of a long-lived admin kubeconfig, the labeled secrets are tried after the
+// auto-import-secret in the order of their priority until the clients of the managed cluster are generated
+const AutoImportSecretLabel string = "import.open-cluster-management.io/auto-import-secret"
+
+// AutoImportSecretPriorityAnnotation is the annotation of the priority of an additional auto import secret, the
+// secrets with the smaller priority are tried first, the secrets without a valid priority are tried last
+const AutoImportSecretPriorityAnnotation string = "import.open-cluster-management.io/auto-import-secret-priority"
+
+// ExpectedServerURLAnnotation is the managed cluster annotation of the expected API server URL of the cluster, it is
+// optional, if it is set, the server of the auto-import-secret must match it, otherwise the import will be refused
+const ExpectedServerURLAnnotation string = "import.open-cluster-management.io/expected-server-url"
+
+// ClusterIDAnnotation is the managed cluster annotation of the unique id of the cluster, it is set when the cluster is
+// imported, the value is the UID of the kube-system namespace of the cluster
+const ClusterIDAnnotation string = "import.open-cluster-management.io/cluster-id"
+
+// ClusterFingerprintAnnotation is the managed cluster annotation of the fingerprint of the cluster, it is set when the
+// cluster is auto imported, the value is built from the server and the CA of the auto import secret, it is used to
+// refuse importing the same cluster with another managed cluster
+const ClusterFingerprintAnnotation string = "import.open-cluster-management.io/cluster-fingerprint"
+
 const PodNamespaceEnvVarName = "POD_NAMESPACE"
 
 const ImportFinalizer string = "managedcluster-import-controller.open-cluster-management.io/cleanup"
@@ -22,6 +54,10 @@ const SelfManagedLabel string = "local-cluster"
 const (
 	ClusterImportSecretLabel = "managedcluster-import-controller.open-cluster-management.io/import-secret"
 	KlusterletWorksLabel     = "import.open-cluster-management.io/klusterlet-works"
+
+	// AddonWorksLabel is the label of the manifest works that are created by the addon framework for the addons,
+	// the value of the label is the addon name
+	AddonWorksLabel = "open-cluster-management.io/addon-name"
 )
 
 const (
@@ -57,6 +93,43 @@ const (
 	// In the Hosted mode, this namespace still exists on the managed cluster to contain
 	// necessary resources, like service accounts, roles and rolebindings.
 	KlusterletNamespaceAnnotation string = "import.open-cluster-management.io/klusterlet-namespace"
+
+	// DefaultKlusterletNamespace is the default namespace to deploy the agent on the managed cluster
+	DefaultKlusterletNamespace string = "open-cluster-management-agent"
+
+	// BootstrapCABundleAnnotation is used to customize the CA bundle of the hub in the bootstrap hub kubeconfig
+	// of the klusterlet, e.g. the managed cluster accesses the hub through a TLS-terminating proxy. The value is
+	// a base64 encoded PEM CA bundle, if it is set, the CA of the hub kube-apiserver is overridden.
+	BootstrapCABundleAnnotation string = "import.open-cluster-management.io/bootstrap-ca-bundle"
+
+	// KlusterletImageOverrideAnnotation is used to pin the image of the klusterlet operator on the managed cluster,
+	// e.g. the managed cluster is in an air-gapped environment and pulls the images from a mirror registry. If it
+	// is set, the image of the klusterlet deployment in the klusterlet manifest work is replaced with it.
+	KlusterletImageOverrideAnnotation string = "import.open-cluster-management.io/klusterlet-image"
+
+	// DetachingAnnotation is used to uninstall the klusterlet from a Default mode managed cluster without deleting
+	// the managed cluster. If its value is true, the addons and the manifest works of the managed cluster are
+	// deleted in the same sequence as the managed cluster is deleted, then the annotation is removed. Note: once
+	// the annotation is removed, the managed cluster will be imported again.
+	DetachingAnnotation string = "import.open-cluster-management.io/detaching"
+
+	// AdoptKlusterletAnnotation is used to import a cluster that already runs a klusterlet, e.g. the cluster was
+	// managed by a previous hub. If its value is true and the klusterlet of the same cluster name exists on the
+	// cluster, only the bootstrap hub kubeconfig secret of the klusterlet is updated in place, otherwise the cluster
+	// is imported with the whole import.yaml.
+	AdoptKlusterletAnnotation string = "import.open-cluster-management.io/adopt-existing-klusterlet"
+
+	// KlusterletLabelAnnotationPrefix is the prefix of the managed cluster annotations that are propagated to the
+	// Klusterlet CR in the klusterlet manifest work as labels, the label key is the name of the annotation after
+	// the prefix, e.g. the annotation "klusterlet-label.import.open-cluster-management.io/team: a" adds the label
+	// "team: a" to the Klusterlet CR. Note: the label key cannot have a prefix.
+	KlusterletLabelAnnotationPrefix string = "klusterlet-label.import.open-cluster-management.io/"
+
+	// KlusterletWorkDeletePropagationPolicyAnnotation is used to customize the delete propagation policy of the
+	// klusterlet manifest work, the value can be Orphan, Foreground or SelectivelyOrphan, if it is not set, Orphan
+	// is used. With Foreground, the resources of the klusterlet are deleted from the managed cluster before the
+	// manifest work disappears; with SelectivelyOrphan, only the Klusterlet CR is deleted.
+	KlusterletWorkDeletePropagationPolicyAnnotation string = "import.open-cluster-management.io/klusterlet-work-delete-propagation-policy"
 )
 
 const (
@@ -78,6 +151,7 @@ const (
 	ManifestWorkFinalizer = "managedcluster-import-controller.open-cluster-management.io/manifestwork-cleanup"
 
 	// PostponeDeletionAnnotation is used to delete the manifest work with this annotation until 10 min after the cluster is deleted.
+	// The value of the annotation can be a duration, e.g. 30m, to postpone the deletion for a different period.
 	PostponeDeletionAnnotation = "open-cluster-management/postpone-delete"
 
 	// ManifestWorkPostponeDeleteTime is the postponed time to delete manifest work with postpone-delete annotation
@@ -88,3 +162,51 @@ const (
 	KlusterletSuffix     = "klusterlet"
 	KlusterletCRDsSuffix = "klusterlet-crds"
 )
+
+// ImportSucceededConditionType is the type of the managed cluster condition that shows whether the managed cluster
+// is imported by the import controllers.
+const ImportSucceededConditionType = "ManagedClusterImportSucceeded"
+
+// KlusterletAppliedConditionType is the type of the managed cluster condition that mirrors the status of the
+// klusterlet manifest work, it shows whether the klusterlet is applied on the managed cluster.
+const KlusterletAppliedConditionType = "KlusterletApplied"
+
+// KlusterletWorksConflictedConditionType is the type of the managed cluster condition that shows whether the
+// klusterlet manifest works of the managed cluster are applied to more than one namespace.
+const KlusterletWorksConflictedConditionType = "KlusterletWorksConflicted"
+
+// KlusterletCRDsSkippedConditionType is the type of the managed cluster condition that shows whether the klusterlet
+// crds are skipped in the import of the managed cluster, because the managed cluster already has the newer crds.
+const KlusterletCRDsSkippedConditionType = "KlusterletCRDsSkipped"
+
+// PostImportHookSucceededConditionType is the type of the managed cluster condition that shows whether the
+// post-import hook of the managed cluster succeeded.
+const PostImportHookSucceededConditionType = "ManagedClusterPostImportHookSucceeded"
+
+// ImportSecretHashAnnotation records the hash of the import secret data that the debug ConfigMap of the import
+// manifests is built from, it is used to find out which import secret data the ConfigMap is built from.
+const ImportSecretHashAnnotation = "import.open-cluster-management.io/import-secret-hash"
+
+// ManifestWorkSpecHashAnnotation records the hash of the spec of a manifest work when it is applied, the manifest
+// work is not written again if the hash of the required spec, the spec and the metadata are unchanged.
+const ManifestWorkSpecHashAnnotation = "import.open-cluster-management.io/manifestwork-spec-hash"
+
+// ControllerVersionAnnotation records the version of the controller that builds a manifest work, it is used to
+// troubleshoot the hubs that run the controllers of different versions.
+const ControllerVersionAnnotation = "import.open-cluster-management.io/controller-version"
+
+const (
+	// PostImportHookConfigMapAnnotation is used to specify a ConfigMap in the managed cluster namespace, the
+	// manifests in the data of the ConfigMap will be applied to the managed cluster by a manifest work once
+	// the managed cluster is imported. The ConfigMap name must be one of the ConfigMap names that are configured
+	// by the POST_IMPORT_HOOK_CONFIGMAPS env of the controller.
+	PostImportHookConfigMapAnnotation = "import.open-cluster-management.io/post-import-hook-configmap"
+
+	// PostImportHookWebhookAnnotation is used to specify a webhook URL, the webhook will be called with the
+	// managed cluster name once the managed cluster is imported. The URL must be one of the webhook URLs that are
+	// configured by the POST_IMPORT_HOOK_WEBHOOK_URLS env of the controller.
+	PostImportHookWebhookAnnotation = "import.open-cluster-management.io/post-import-hook-webhook"
+
+	// PostImportHookSuffix is a suffix of the post-import hook manifest work name.
+	PostImportHookSuffix = "post-import-hook"
+)
@@ -5,20 +5,23 @@ package autoimport
 
 import (
 	"context"
+	goerrors "errors"
 	"fmt"
+	"time"
 
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
-	workv1 "open-cluster-management.io/api/work/v1"
 
 	"github.com/stolostron/managedcluster-import-controller/pkg/constants"
 	"github.com/stolostron/managedcluster-import-controller/pkg/helpers"
 
 	"github.com/openshift/library-go/pkg/operator/events"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/kubernetes"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -28,11 +31,17 @@ import (
 
 var log = logf.Log.WithName(controllerName)
 
+// duplicatedImportRequeuePeriod is the period to retry the import of a managed cluster whose cluster is imported by
+// another managed cluster
+const duplicatedImportRequeuePeriod = 5 * time.Minute
+
 // ReconcileAutoImport reconciles the managed cluster auto import secret to import the managed cluster
 type ReconcileAutoImport struct {
-	client     client.Client
-	kubeClient kubernetes.Interface
-	recorder   events.Recorder
+	client            client.Client
+	kubeClient        kubernetes.Interface
+	recorder          events.Recorder
+	clientHolderCache *helpers.ClientHolderCache
+	fingerprints      *helpers.ClusterFingerprintRegistry
 }
 
 // blank assignment to verify that ReconcileAutoImport implements reconcile.Reconciler
@@ -52,6 +61,7 @@ func (r *ReconcileAutoImport) Reconcile(ctx context.Context, request reconcile.R
 	err := r.client.Get(ctx, types.NamespacedName{Name: managedClusterName}, managedCluster)
 	if errors.IsNotFound(err) {
 		// the managed cluster could have been deleted, do nothing
+		r.fingerprints.Unregister(managedClusterName)
 		return reconcile.Result{}, nil
 	}
 	if err != nil {
@@ -63,14 +73,15 @@ func (r *ReconcileAutoImport) Reconcile(ctx context.Context, request reconcile.R
 	}
 
 	// TODO: we will use lister instead of get to reduce the request in the future
-	autoImportSecret, err := r.kubeClient.CoreV1().Secrets(managedClusterName).Get(ctx, constants.AutoImportSecretName, metav1.GetOptions{})
-	if errors.IsNotFound(err) {
-		// the auto import secret could have been deleted, do nothing
-		return reconcile.Result{}, nil
-	}
+	autoImportSecrets, err := helpers.ListAutoImportSecrets(ctx, r.kubeClient, managedClusterName)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
+	if len(autoImportSecrets) == 0 {
+		// the auto import secrets could have been deleted, do nothing
+		r.fingerprints.Unregister(managedClusterName)
+		return reconcile.Result{}, nil
+	}
 
 	importSecretName := fmt.Sprintf("%s-%s", managedClusterName, constants.ImportSecretNameSuffix)
 	importSecret, err := r.kubeClient.CoreV1().Secrets(managedClusterName).Get(ctx, importSecretName, metav1.GetOptions{})
@@ -83,57 +94,159 @@ func (r *ReconcileAutoImport) Reconcile(ctx context.Context, request reconcile.R
 	}
 
 	// ensure the klusterlet manifest works exist
-	listOpts := &client.ListOptions{
-		Namespace:     managedClusterName,
-		LabelSelector: labels.SelectorFromSet(map[string]string{constants.KlusterletWorksLabel: "true"}),
-	}
-	manifestWorks := &workv1.ManifestWorkList{}
-	if err := r.client.List(ctx, manifestWorks, listOpts); err != nil {
+	crdsWork, klusterletWork, err := helpers.ListKlusterletManifestWorks(ctx, r.client, managedClusterName)
+	if err != nil {
 		return reconcile.Result{}, err
 	}
-	if len(manifestWorks.Items) != 2 {
+	if crdsWork == nil || klusterletWork == nil {
 		reqLogger.Info(fmt.Sprintf("Waiting for klusterlet manifest works for managed cluster %s", managedClusterName))
 		return reconcile.Result{}, nil
 	}
 
 	importCondition := metav1.Condition{
-		Type:    "ManagedClusterImportSucceeded",
+		Type:    constants.ImportSucceededConditionType,
 		Status:  metav1.ConditionTrue,
 		Message: "Import succeeded",
 		Reason:  "ManagedClusterImported",
 	}
 
-	importClient, restMapper, importErr := helpers.GenerateClientFromSecret(autoImportSecret)
+	var importResult *helpers.ImportResult
+	importClient, restMapper, usedSecret, importErr := helpers.GenerateClientFromAutoImportSecrets(autoImportSecrets,
+		func(secret *corev1.Secret) (*helpers.ClientHolder, meta.RESTMapper, error) {
+			// refuse to use the secret if its cluster is imported or being imported by another managed cluster
+			if err := r.fingerprints.ValidateNotDuplicated(ctx, r.client, managedClusterName, secret); err != nil {
+				return nil, nil, err
+			}
+			return r.clientHolderCache.Get(managedClusterName, secret)
+		})
+	if goerrors.Is(importErr, helpers.ErrClusterImportDuplicated) {
+		// the auto import secrets are kept and the retry times are not reduced, the import is retried once the
+		// other managed cluster releases the cluster
+		reqLogger.Info(fmt.Sprintf("The cluster of the managed cluster %s is duplicated, %v", managedClusterName, importErr))
+		return reconcile.Result{RequeueAfter: duplicatedImportRequeuePeriod}, helpers.UpdateManagedClusterStatus(
+			r.client,
+			r.recorder,
+			managedClusterName,
+			metav1.Condition{
+				Type:   constants.ImportSucceededConditionType,
+				Status: metav1.ConditionFalse,
+				Message: fmt.Sprintf("Unable to import managed cluster %s with auto-import-secret: %v",
+					managedClusterName, importErr),
+				Reason: "ManagedClusterImportDuplicated",
+			},
+		)
+	}
+
 	switch {
 	case importErr != nil:
 		// failed to generate import client with auto-import sercet, will reduce the auto-import secret retry times and reconcile again
 	case importErr == nil:
-		importErr = helpers.ImportManagedClusterFromSecret(importClient, restMapper, r.recorder, importSecret)
+		// the used secret and its format are logged for troubleshooting
+		secretType, _ := helpers.DetectSecretType(usedSecret)
+		reqLogger.Info("Importing the managed cluster with the auto import secret",
+			"secret", usedSecret.Name, "secretType", secretType)
+
+		// make sure the target cluster is the expected one before importing it
+		importErr = helpers.ValidateExpectedServerURL(managedCluster, usedSecret)
+		if importErr != nil {
+			break
+		}
+
+		importErr = helpers.ValidateClusterIdentity(ctx, importClient.KubeClient, usedSecret)
+		if importErr != nil {
+			break
+		}
+
+		// make sure the credentials are able to apply all of the resources, so the import is not partially applied
+		importErr = helpers.ValidateImportPermissions(ctx, importClient.KubeClient, importSecret)
+		if importErr != nil {
+			break
+		}
+
+		// the klusterlet of a cluster that is managed by another hub is only taken over if the cluster is adopted
+		if helpers.GetImportScope(managedCluster) != helpers.ImportScopeAdopt {
+			importErr = helpers.ValidateNotManagedByAnotherHub(ctx, importClient.KubeClient, importSecret)
+			if importErr != nil {
+				break
+			}
+		}
+
+		importResult, importErr = helpers.ImportManagedClusterFromSecretWithResult(importClient, restMapper, r.recorder,
+			importSecret, helpers.GetImportScope(managedCluster))
 	}
 
 	if importErr != nil {
 		importCondition.Status = metav1.ConditionFalse
 		importCondition.Message = fmt.Sprintf("Unable to import managed cluster %s with auto-import-secret: %s", managedClusterName, importErr.Error())
 		importCondition.Reason = "ManagedClusterNotImported"
+		if goerrors.Is(importErr, helpers.ErrClusterIdentityMismatch) {
+			importCondition.Reason = "ManagedClusterIdentityMismatch"
+		}
+		if goerrors.Is(importErr, helpers.ErrServerURLMismatch) {
+			importCondition.Reason = "ManagedClusterServerURLMismatch"
+		}
+		if goerrors.Is(importErr, helpers.ErrInsufficientPermissions) {
+			importCondition.Reason = "ManagedClusterInsufficientPermissions"
+		}
+		if goerrors.Is(importErr, helpers.ErrManagedByAnotherHub) {
+			importCondition.Reason = "ManagedClusterManagedByAnotherHub"
+		}
+
+		// the cached clients may be out of date, rebuild them in the next retry
+		r.clientHolderCache.Invalidate(managedClusterName)
 
 		if err := helpers.UpdateManagedClusterStatus(r.client, r.recorder, managedClusterName, importCondition); err != nil {
 			return reconcile.Result{}, err
 		}
 
-		// failed to apply the import secrect, reduce the retry times and reconcile again
-		return reconcile.Result{}, helpers.UpdateAutoImportRetryTimes(ctx, r.kubeClient, r.recorder, autoImportSecret.DeepCopy())
+		// failed to apply the import secrect, reduce the retry times of the used secret and reconcile again, if
+		// the clients cannot be generated from any of the secrets, all of them are tried and their retry times are
+		// reduced
+		failedSecrets := autoImportSecrets
+		if usedSecret != nil {
+			failedSecrets = []*corev1.Secret{usedSecret}
+		}
+		errs := []error{}
+		for _, secret := range failedSecrets {
+			if err := helpers.UpdateAutoImportRetryTimes(ctx, r.kubeClient, r.recorder, secret.DeepCopy()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return reconcile.Result{}, utilerrors.NewAggregate(errs)
 	}
 
 	// TODO enhancment: check klusterlet status from managed cluster
 
-	if err := helpers.UpdateManagedClusterStatus(r.client, r.recorder, managedClusterName, importCondition); err != nil {
+	if err := helpers.UpdateManagedClusterStatusConditions(r.client, r.recorder, managedClusterName, importCondition,
+		helpers.KlusterletCRDsSkippedCondition(importResult)); err != nil {
 		return reconcile.Result{}, err
 	}
 
-	if err := helpers.DeleteAutoImportSecret(ctx, r.kubeClient, autoImportSecret); err != nil {
+	if err := helpers.EnsureClusterIDAnnotation(ctx, r.client, r.recorder, importClient.KubeClient,
+		managedCluster); err != nil {
 		return reconcile.Result{}, err
 	}
 
+	// the fingerprint is persisted to refuse importing the cluster with another managed cluster
+	if err := helpers.EnsureClusterFingerprintAnnotation(ctx, r.client, r.recorder, managedCluster,
+		usedSecret); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	r.recorder.Eventf("AutoImportSecretUsed",
+		"The managed cluster %s is imported with the auto import secret %s", managedClusterName, usedSecret.Name)
+
+	// the managed cluster is imported, none of its auto import secrets is required
+	for _, secret := range autoImportSecrets {
+		if err := helpers.DeleteAutoImportSecret(ctx, r.kubeClient, secret); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	// the managed cluster is imported, its clients and in-flight fingerprint are no longer needed
+	r.clientHolderCache.Invalidate(managedClusterName)
+	r.fingerprints.Unregister(managedClusterName)
+
 	r.recorder.Eventf("AutoImportSecretDeleted",
 		fmt.Sprintf("The managed cluster %s is imported, delete its auto import secret", managedClusterName))
 	return reconcile.Result{}, nil
@@ -5,9 +5,12 @@ package autoimport
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stolostron/managedcluster-import-controller/pkg/constants"
+	"github.com/stolostron/managedcluster-import-controller/pkg/helpers"
 	testinghelpers "github.com/stolostron/managedcluster-import-controller/pkg/helpers/testing"
 
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
@@ -16,6 +19,7 @@ import (
 	"github.com/openshift/library-go/pkg/operator/events/eventstesting"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -32,6 +36,7 @@ var testscheme = scheme.Scheme
 
 func init() {
 	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedClusterList{})
 	testscheme.AddKnownTypes(workv1.SchemeGroupVersion, &workv1.ManifestWork{})
 	testscheme.AddKnownTypes(workv1.SchemeGroupVersion, &workv1.ManifestWorkList{})
 }
@@ -134,9 +139,11 @@ func TestReconcile(t *testing.T) {
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
 			r := &ReconcileAutoImport{
-				client:     fake.NewClientBuilder().WithScheme(testscheme).WithObjects(c.objs...).Build(),
-				kubeClient: kubefake.NewSimpleClientset(c.secrets...),
-				recorder:   eventstesting.NewTestingEventRecorder(t),
+				client:            fake.NewClientBuilder().WithScheme(testscheme).WithObjects(c.objs...).Build(),
+				kubeClient:        kubefake.NewSimpleClientset(c.secrets...),
+				recorder:          eventstesting.NewTestingEventRecorder(t),
+				clientHolderCache: helpers.NewClientHolderCache(),
+				fingerprints:      helpers.NewClusterFingerprintRegistry(),
 			}
 
 			req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "test"}}
@@ -150,3 +157,255 @@ func TestReconcile(t *testing.T) {
 		})
 	}
 }
+
+func TestReconcileDuplicatedCluster(t *testing.T) {
+	newAutoImportSecret := func(namespace string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      constants.AutoImportSecretName,
+				Namespace: namespace,
+			},
+			Data: map[string][]byte{
+				"autoImportRetry": []byte("0"),
+				"token":           []byte("test"),
+				"server":          []byte("https://127.0.0.1:6443"),
+			},
+		}
+	}
+
+	newKlusterletWork := func(name string) *workv1.ManifestWork {
+		return &workv1.ManifestWork{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "cluster2",
+				Labels: map[string]string{
+					constants.KlusterletWorksLabel: "true",
+				},
+			},
+		}
+	}
+
+	fingerprint, err := helpers.ClusterFingerprint(newAutoImportSecret("cluster1"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cases := []struct {
+		name         string
+		objs         []client.Object
+		fingerprints func() *helpers.ClusterFingerprintRegistry
+	}{
+		{
+			name: "the cluster is being imported by another managed cluster",
+			objs: []client.Object{},
+			fingerprints: func() *helpers.ClusterFingerprintRegistry {
+				fingerprints := helpers.NewClusterFingerprintRegistry()
+				fingerprints.Register("cluster1", fingerprint)
+				return fingerprints
+			},
+		},
+		{
+			name: "the cluster is imported by another managed cluster",
+			objs: []client.Object{
+				&clusterv1.ManagedCluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "cluster1",
+						Annotations: map[string]string{
+							constants.ClusterFingerprintAnnotation: fingerprint,
+						},
+					},
+				},
+			},
+			fingerprints: helpers.NewClusterFingerprintRegistry,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			objs := append([]client.Object{
+				&clusterv1.ManagedCluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "cluster2",
+					},
+				},
+				newKlusterletWork("cluster2-klusterlet-crds"),
+				newKlusterletWork("cluster2-klusterlet"),
+			}, c.objs...)
+
+			r := &ReconcileAutoImport{
+				client: fake.NewClientBuilder().WithScheme(testscheme).WithObjects(objs...).Build(),
+				kubeClient: kubefake.NewSimpleClientset(
+					newAutoImportSecret("cluster2"), testinghelpers.GetImportSecret("cluster2")),
+				recorder:          eventstesting.NewTestingEventRecorder(t),
+				clientHolderCache: helpers.NewClientHolderCache(),
+				fingerprints:      c.fingerprints(),
+			}
+
+			req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "cluster2"}}
+			result, err := r.Reconcile(context.TODO(), req)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if result.RequeueAfter != duplicatedImportRequeuePeriod {
+				t.Errorf("expected the import is requeued after %v, but got %v",
+					duplicatedImportRequeuePeriod, result.RequeueAfter)
+			}
+
+			managedCluster := &clusterv1.ManagedCluster{}
+			if err := r.client.Get(context.TODO(), types.NamespacedName{Name: "cluster2"}, managedCluster); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			cond := meta.FindStatusCondition(managedCluster.Status.Conditions, constants.ImportSucceededConditionType)
+			if cond == nil || cond.Reason != "ManagedClusterImportDuplicated" {
+				t.Errorf("expected the import is refused as duplicated, but got %v", cond)
+			}
+
+			// the auto import secret of the duplicated cluster is kept and its retry times are not reduced
+			secret, err := r.kubeClient.CoreV1().Secrets("cluster2").Get(
+				context.TODO(), constants.AutoImportSecretName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(secret.Data["autoImportRetry"]) != "0" {
+				t.Errorf("expected the retry times are not reduced, but got %s", secret.Data["autoImportRetry"])
+			}
+		})
+	}
+}
+
+func TestReconcileRetryTimes(t *testing.T) {
+	// a fake kube apiserver that only serves the discovery of the core group
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api":
+			_, _ = w.Write([]byte(`{"kind":"APIVersions","versions":["v1"]}`))
+		case "/apis":
+			_, _ = w.Write([]byte(`{"kind":"APIGroupList","groups":[]}`))
+		case "/api/v1":
+			_, _ = w.Write([]byte(`{"kind":"APIResourceList","groupVersion":"v1","resources":[]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	newAutoImportSecret := func(name, server string) *corev1.Secret {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "test",
+				Labels: map[string]string{
+					constants.AutoImportSecretLabel: "true",
+				},
+			},
+			Data: map[string][]byte{
+				"autoImportRetry": []byte("2"),
+			},
+		}
+		if len(server) > 0 {
+			secret.Data["token"] = []byte("test")
+			secret.Data["server"] = []byte(server)
+		}
+		return secret
+	}
+
+	cases := []struct {
+		name            string
+		secrets         []runtime.Object
+		expectedReason  string
+		expectedRetries map[string]string
+	}{
+		{
+			name: "the retry times of the used secret are reduced",
+			secrets: []runtime.Object{
+				newAutoImportSecret(constants.AutoImportSecretName, ""),
+				newAutoImportSecret("fallback", server.URL),
+			},
+			expectedReason: "ManagedClusterServerURLMismatch",
+			expectedRetries: map[string]string{
+				constants.AutoImportSecretName: "2",
+				"fallback":                     "1",
+			},
+		},
+		{
+			name: "the retry times of all of the tried secrets are reduced",
+			secrets: []runtime.Object{
+				newAutoImportSecret(constants.AutoImportSecretName, ""),
+				newAutoImportSecret("fallback", ""),
+			},
+			expectedReason: "ManagedClusterNotImported",
+			expectedRetries: map[string]string{
+				constants.AutoImportSecretName: "1",
+				"fallback":                     "1",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			objs := []client.Object{
+				&clusterv1.ManagedCluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test",
+						Annotations: map[string]string{
+							// the import fails after the clients are generated from the used secret
+							constants.ExpectedServerURLAnnotation: "https://127.0.0.1:6443",
+						},
+					},
+				},
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-klusterlet-crds",
+						Namespace: "test",
+						Labels: map[string]string{
+							constants.KlusterletWorksLabel: "true",
+						},
+					},
+				},
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-klusterlet",
+						Namespace: "test",
+						Labels: map[string]string{
+							constants.KlusterletWorksLabel: "true",
+						},
+					},
+				},
+			}
+
+			r := &ReconcileAutoImport{
+				client:            fake.NewClientBuilder().WithScheme(testscheme).WithObjects(objs...).Build(),
+				kubeClient:        kubefake.NewSimpleClientset(append(c.secrets, testinghelpers.GetImportSecret("test"))...),
+				recorder:          eventstesting.NewTestingEventRecorder(t),
+				clientHolderCache: helpers.NewClientHolderCache(),
+				fingerprints:      helpers.NewClusterFingerprintRegistry(),
+			}
+
+			req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "test"}}
+			if _, err := r.Reconcile(context.TODO(), req); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			managedCluster := &clusterv1.ManagedCluster{}
+			if err := r.client.Get(context.TODO(), types.NamespacedName{Name: "test"}, managedCluster); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			cond := meta.FindStatusCondition(managedCluster.Status.Conditions, constants.ImportSucceededConditionType)
+			if cond == nil || cond.Reason != c.expectedReason {
+				t.Errorf("expected the import is failed with %s, but got %v", c.expectedReason, cond)
+			}
+
+			for name, expected := range c.expectedRetries {
+				secret, err := r.kubeClient.CoreV1().Secrets("test").Get(context.TODO(), name, metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if string(secret.Data["autoImportRetry"]) != expected {
+					t.Errorf("expected the retry times of %s is %s, but got %s",
+						name, expected, secret.Data["autoImportRetry"])
+				}
+			}
+		})
+	}
+}
@@ -4,7 +4,10 @@
 package autoimport
 
 import (
+	"context"
+	"fmt"
 	"strings"
+	"time"
 
 	workv1 "open-cluster-management.io/api/work/v1"
 
@@ -14,7 +17,10 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	informerscorev1 "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -33,23 +39,27 @@ const controllerName = "autoimport-controller"
 // The Manager will set fields on the Controller and Start it when the Manager is Started.
 func Add(mgr manager.Manager, clientHolder *helpers.ClientHolder,
 	importSecretInformer, autoImportSecretInformer cache.SharedIndexInformer) (string, error) {
-	return controllerName, add(importSecretInformer, autoImportSecretInformer, mgr, newReconciler(clientHolder))
+	return controllerName, add(importSecretInformer, autoImportSecretInformer, mgr, clientHolder.KubeClient,
+		newReconciler(clientHolder))
 }
 
 // newReconciler returns a new reconcile.Reconciler
 func newReconciler(clientHolder *helpers.ClientHolder) reconcile.Reconciler {
 	return &ReconcileAutoImport{
-		client:     clientHolder.RuntimeClient,
-		kubeClient: clientHolder.KubeClient,
-		recorder:   helpers.NewEventRecorder(clientHolder.KubeClient, controllerName),
+		client:            clientHolder.RuntimeClient,
+		kubeClient:        clientHolder.KubeClient,
+		recorder:          helpers.NewEventRecorder(clientHolder.KubeClient, controllerName),
+		clientHolderCache: helpers.NewClientHolderCache(),
+		fingerprints:      helpers.NewClusterFingerprintRegistry(),
 	}
 }
 
 // adds a new Controller to mgr with r as the reconcile.Reconciler
-func add(importSecretInformer, autoImportSecretInformer cache.SharedIndexInformer, mgr manager.Manager, r reconcile.Reconciler) error {
+func add(importSecretInformer, autoImportSecretInformer cache.SharedIndexInformer, mgr manager.Manager,
+	kubeClient kubernetes.Interface, r reconcile.Reconciler) error {
 	c, err := controller.New(controllerName, mgr, controller.Options{
 		Reconciler:              r,
-		MaxConcurrentReconciles: helpers.GetMaxConcurrentReconciles(),
+		MaxConcurrentReconciles: helpers.GetMaxConcurrentReconcilesFor(controllerName),
 	})
 	if err != nil {
 		return err
@@ -76,27 +86,39 @@ func add(importSecretInformer, autoImportSecretInformer cache.SharedIndexInforme
 		return err
 	}
 
-	// watch the auto-import secrets
-	if err := c.Watch(
-		source.NewAutoImportSecretSource(autoImportSecretInformer),
-		&source.ManagedClusterSecretEventHandler{},
-		predicate.Predicate(predicate.Funcs{
-			GenericFunc: func(e event.GenericEvent) bool { return false },
-			DeleteFunc:  func(e event.DeleteEvent) bool { return false },
-			CreateFunc:  func(e event.CreateEvent) bool { return true },
-			UpdateFunc: func(e event.UpdateEvent) bool {
-				new, okNew := e.ObjectNew.(*corev1.Secret)
-				old, okOld := e.ObjectOld.(*corev1.Secret)
-				if okNew && okOld {
-					return !equality.Semantic.DeepEqual(old.Data, new.Data)
-				}
-				return false
-			},
-		}),
-	); err != nil {
+	// the auto-import-secret is watched by the auto import secret informer, the additional auto import secrets with
+	// the AutoImportSecretLabel are watched by their own informer
+	labeledAutoImportSecretInformer := newLabeledAutoImportSecretInformer(kubeClient)
+	if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		labeledAutoImportSecretInformer.Run(ctx.Done())
+		return nil
+	})); err != nil {
 		return err
 	}
 
+	// watch the auto-import secrets
+	for _, informer := range []cache.SharedIndexInformer{autoImportSecretInformer, labeledAutoImportSecretInformer} {
+		if err := c.Watch(
+			source.NewAutoImportSecretSource(informer),
+			&source.ManagedClusterSecretEventHandler{},
+			predicate.Predicate(predicate.Funcs{
+				GenericFunc: func(e event.GenericEvent) bool { return false },
+				DeleteFunc:  func(e event.DeleteEvent) bool { return false },
+				CreateFunc:  func(e event.CreateEvent) bool { return true },
+				UpdateFunc: func(e event.UpdateEvent) bool {
+					new, okNew := e.ObjectNew.(*corev1.Secret)
+					old, okOld := e.ObjectOld.(*corev1.Secret)
+					if okNew && okOld {
+						return !equality.Semantic.DeepEqual(old.Data, new.Data)
+					}
+					return false
+				},
+			}),
+		); err != nil {
+			return err
+		}
+	}
+
 	// watch the klusterlet manifest works
 	if err := c.Watch(
 		&runtimesource.Kind{Type: &workv1.ManifestWork{}},
@@ -146,3 +168,17 @@ func add(importSecretInformer, autoImportSecretInformer cache.SharedIndexInforme
 
 	return nil
 }
+
+// newLabeledAutoImportSecretInformer returns an informer of the auto import secrets that have the
+// AutoImportSecretLabel in all of the managed cluster namespaces
+func newLabeledAutoImportSecretInformer(kubeClient kubernetes.Interface) cache.SharedIndexInformer {
+	return informerscorev1.NewFilteredSecretInformer(
+		kubeClient,
+		metav1.NamespaceAll,
+		10*time.Minute,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+		func(listOptions *metav1.ListOptions) {
+			listOptions.LabelSelector = fmt.Sprintf("%s=true", constants.AutoImportSecretLabel)
+		},
+	)
+}
@@ -8,7 +8,6 @@ import (
 	"fmt"
 
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
-	workv1 "open-cluster-management.io/api/work/v1"
 
 	hivev1 "github.com/openshift/hive/apis/hive/v1"
 	"github.com/openshift/library-go/pkg/operator/events"
@@ -20,7 +19,6 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/kubernetes"
@@ -35,9 +33,10 @@ var log = logf.Log.WithName(controllerName)
 // ReconcileClusterDeployment reconciles the clusterdeployment that is in the managed cluster namespace
 // to import the managed cluster
 type ReconcileClusterDeployment struct {
-	client     client.Client
-	kubeClient kubernetes.Interface
-	recorder   events.Recorder
+	client            client.Client
+	kubeClient        kubernetes.Interface
+	recorder          events.Recorder
+	clientHolderCache *helpers.ClientHolderCache
 }
 
 // blank assignment to verify that ReconcileClusterDeployment implements reconcile.Reconciler
@@ -65,6 +64,7 @@ func (r *ReconcileClusterDeployment) Reconcile(ctx context.Context, request reco
 	if !clusterDeployment.DeletionTimestamp.IsZero() {
 		// the clusterdeployment is deleting, its managed cluster may already be detached (the managed cluster has been deleted,
 		// but the namespace is remained), if it has import finalizer, we remove its namespace
+		r.clientHolderCache.Invalidate(clusterName)
 		return reconcile.Result{}, r.removeImportFinalizer(ctx, clusterDeployment)
 	}
 
@@ -108,7 +108,7 @@ func (r *ReconcileClusterDeployment) Reconcile(ctx context.Context, request reco
 	if err != nil {
 		return reconcile.Result{}, err
 	}
-	hiveClient, restMapper, err := helpers.GenerateClientFromSecret(hiveSecret)
+	hiveClient, restMapper, err := r.clientHolderCache.Get(clusterName, hiveSecret)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
@@ -123,37 +123,43 @@ func (r *ReconcileClusterDeployment) Reconcile(ctx context.Context, request reco
 	}
 
 	// ensure the klusterlet manifest works exist
-	listOpts := &client.ListOptions{
-		Namespace:     clusterName,
-		LabelSelector: labels.SelectorFromSet(map[string]string{constants.KlusterletWorksLabel: "true"}),
-	}
-	manifestWorks := &workv1.ManifestWorkList{}
-	if err := r.client.List(ctx, manifestWorks, listOpts); err != nil {
+	crdsWork, klusterletWork, err := helpers.ListKlusterletManifestWorks(ctx, r.client, clusterName)
+	if err != nil {
 		return reconcile.Result{}, err
 	}
-	if len(manifestWorks.Items) != 2 {
+	if crdsWork == nil || klusterletWork == nil {
 		reqLogger.Info(fmt.Sprintf("Waiting for klusterlet manifest works for managed cluster %s", clusterName))
 		return reconcile.Result{}, nil
 	}
 
 	importCondition := metav1.Condition{
-		Type:    "ManagedClusterImportSucceeded",
+		Type:    constants.ImportSucceededConditionType,
 		Status:  metav1.ConditionTrue,
 		Message: "Import succeeded",
 		Reason:  "ManagedClusterImported",
 	}
 
 	errs := []error{}
-	err = helpers.ImportManagedClusterFromSecret(hiveClient, restMapper, r.recorder, importSecret)
+	conditions := []metav1.Condition{}
+	importResult, err := helpers.ImportManagedClusterFromSecretWithResult(hiveClient, restMapper, r.recorder,
+		importSecret, helpers.GetImportScope(managedCluster))
 	if err != nil {
 		errs = append(errs, err)
 
 		importCondition.Status = metav1.ConditionFalse
 		importCondition.Message = fmt.Sprintf("Unable to import %s: %s", clusterName, err.Error())
 		importCondition.Reason = "ManagedClusterNotImported"
+		conditions = append(conditions, importCondition)
+	} else {
+		conditions = append(conditions, importCondition, helpers.KlusterletCRDsSkippedCondition(importResult))
+
+		if err := helpers.EnsureClusterIDAnnotation(ctx, r.client, r.recorder, hiveClient.KubeClient,
+			managedCluster); err != nil {
+			errs = append(errs, err)
+		}
 	}
 
-	if err := helpers.UpdateManagedClusterStatus(r.client, r.recorder, clusterName, importCondition); err != nil {
+	if err := helpers.UpdateManagedClusterStatusConditions(r.client, r.recorder, clusterName, conditions...); err != nil {
 		errs = append(errs, err)
 	}
 
@@ -14,6 +14,7 @@ import (
 	"github.com/openshift/library-go/pkg/operator/events/eventstesting"
 
 	"github.com/stolostron/managedcluster-import-controller/pkg/constants"
+	"github.com/stolostron/managedcluster-import-controller/pkg/helpers"
 	testinghelpers "github.com/stolostron/managedcluster-import-controller/pkg/helpers/testing"
 
 	corev1 "k8s.io/api/core/v1"
@@ -172,9 +173,10 @@ func TestReconcile(t *testing.T) {
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
 			r := &ReconcileClusterDeployment{
-				client:     fake.NewClientBuilder().WithScheme(testscheme).WithObjects(c.objs...).Build(),
-				kubeClient: kubefake.NewSimpleClientset(c.secrets...),
-				recorder:   eventstesting.NewTestingEventRecorder(t),
+				client:            fake.NewClientBuilder().WithScheme(testscheme).WithObjects(c.objs...).Build(),
+				kubeClient:        kubefake.NewSimpleClientset(c.secrets...),
+				recorder:          eventstesting.NewTestingEventRecorder(t),
+				clientHolderCache: helpers.NewClientHolderCache(),
 			}
 
 			_, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}})
@@ -41,17 +41,21 @@ func Add(mgr manager.Manager, clientHolder *helpers.ClientHolder,
 // newReconciler returns a new reconcile.Reconciler
 func newReconciler(clientHolder *helpers.ClientHolder) reconcile.Reconciler {
 	return &ReconcileClusterDeployment{
-		client:     clientHolder.RuntimeClient,
-		kubeClient: clientHolder.KubeClient,
-		recorder:   helpers.NewEventRecorder(clientHolder.KubeClient, controllerName),
+		client:            clientHolder.RuntimeClient,
+		kubeClient:        clientHolder.KubeClient,
+		recorder:          helpers.NewEventRecorder(clientHolder.KubeClient, controllerName),
+		clientHolderCache: helpers.NewClientHolderCache(),
 	}
 }
 
 // adds a new Controller to mgr with r as the reconcile.Reconciler
 func add(importSecretInformer cache.SharedIndexInformer, mgr manager.Manager, r reconcile.Reconciler) error {
+	// back off the failed imports by the class of their errors
+	rateLimiter := helpers.NewImportRateLimiter()
 	c, err := controller.New(controllerName, mgr, controller.Options{
-		Reconciler:              r,
-		MaxConcurrentReconciles: helpers.GetMaxConcurrentReconciles(),
+		Reconciler:              rateLimiter.Reconciler(r),
+		MaxConcurrentReconciles: helpers.GetMaxConcurrentReconcilesFor(controllerName),
+		RateLimiter:             rateLimiter,
 	})
 	if err != nil {
 		return err
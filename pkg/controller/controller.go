@@ -18,6 +18,7 @@ import (
 	"github.com/stolostron/managedcluster-import-controller/pkg/controller/importconfig"
 	"github.com/stolostron/managedcluster-import-controller/pkg/controller/managedcluster"
 	"github.com/stolostron/managedcluster-import-controller/pkg/controller/manifestwork"
+	"github.com/stolostron/managedcluster-import-controller/pkg/controller/postimport"
 	"github.com/stolostron/managedcluster-import-controller/pkg/controller/selfmanagedcluster"
 	"github.com/stolostron/managedcluster-import-controller/pkg/features"
 	"github.com/stolostron/managedcluster-import-controller/pkg/helpers"
@@ -40,6 +41,7 @@ var AddToManagerFuncs = []AddToManagerFunc{
 	selfmanagedcluster.Add,
 	autoimport.Add,
 	clusterdeployment.Add,
+	postimport.Add,
 }
 
 // AddToManager adds all controllers to the manager
@@ -20,6 +20,7 @@ import (
 	workv1 "open-cluster-management.io/api/work/v1"
 
 	"github.com/ghodss/yaml"
+	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -36,8 +37,6 @@ var manifestFiles embed.FS
 
 var klusterletHostedExternalKubeconfig = "manifests/external_managed_secret.yaml"
 
-var log = logf.Log.WithName(controllerName)
-
 // ReconcileHosted reconciles the Hosted mode ManagedClusters of the ManifestWorks object
 type ReconcileHosted struct {
 	clientHolder *helpers.ClientHolder
@@ -61,7 +60,8 @@ var _ reconcile.Reconciler = &ReconcileHosted{}
 // Note: The Controller will requeue the Request to be processed again if the returned error is non-nil or
 // Result.Requeue is true, otherwise upon completion it will remove the work from the queue.
 func (r *ReconcileHosted) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
-	reqLogger := log.WithValues("Request.Name", request.Name)
+	// the logger of the reconcile context has the controller name and the request
+	reqLogger := logf.FromContext(ctx, "Request.Name", request.Name)
 
 	managedClusterName := request.Name
 	managedCluster := &clusterv1.ManagedCluster{}
@@ -77,11 +77,28 @@ func (r *ReconcileHosted) Reconcile(ctx context.Context, request reconcile.Reque
 	if helpers.DetermineKlusterletMode(managedCluster) != constants.KlusterletDeployModeHosted {
 		return reconcile.Result{}, nil
 	}
-	// TODO(zhujian7): check if annotation hosting cluster is provided, check if the hosting cluster
-	// is a managed cluster of hub, and check its status.
+	// TODO(zhujian7): check if the hosting cluster is a managed cluster of hub, and check its status.
 
 	reqLogger.Info("Reconciling the manifest works of the hosted mode managed cluster")
 
+	// the hosted manifest works are in the namespace of the hosting cluster, they cannot be applied until the hosting
+	// cluster annotation is fixed, the managed cluster is reconciled again once its annotation is changed
+	if _, err := helpers.GetHostingClusterName(managedCluster); helpers.IsInvalidHostingCluster(err) &&
+		managedCluster.DeletionTimestamp.IsZero() {
+		reqLogger.Info(fmt.Sprintf("The hosting cluster of the managed cluster is invalid: %v", err))
+		return reconcile.Result{}, helpers.UpdateManagedClusterStatus(
+			r.clientHolder.RuntimeClient,
+			r.recorder,
+			managedClusterName,
+			metav1.Condition{
+				Type:    constants.ImportSucceededConditionType,
+				Status:  metav1.ConditionFalse,
+				Message: fmt.Sprintf("Unable to import %s: %s", managedClusterName, err.Error()),
+				Reason:  "ManagedClusterHostingClusterInvalid",
+			},
+		)
+	}
+
 	listOpts := &client.ListOptions{Namespace: managedClusterName}
 	manifestWorks := &workv1.ManifestWorkList{}
 	if err := r.clientHolder.RuntimeClient.List(ctx, manifestWorks, listOpts); err != nil {
@@ -93,14 +110,26 @@ func (r *ReconcileHosted) Reconcile(ctx context.Context, request reconcile.Reque
 		return reconcile.Result{}, err
 	}
 
+	countWorks := func(ctx context.Context) (int, error) {
+		works, err := helpers.NamespacedManifestWorksCounter(r.clientHolder.RuntimeClient, managedClusterName)(ctx)
+		if err != nil {
+			return 0, err
+		}
+
+		hostedWorks, err := r.getAllHostedManifestWorks(ctx, managedCluster)
+		if err != nil {
+			return 0, err
+		}
+		return works + len(hostedWorks), nil
+	}
 	if err := helpers.AssertManifestWorkFinalizer(ctx, r.clientHolder.RuntimeClient, r.recorder,
-		managedCluster, len(manifestWorks.Items)+len(hostedManifestWorks)); err != nil {
+		managedCluster, countWorks); err != nil {
 		return reconcile.Result{}, err
 	}
 
 	if !managedCluster.DeletionTimestamp.IsZero() {
 		// the managed cluster is deleting, delete its addons and manifestworks
-		return r.deleteAddonsAndWorks(ctx, managedCluster, manifestWorks.Items, hostedManifestWorks)
+		return r.deleteAddonsAndWorks(ctx, reqLogger, managedCluster, manifestWorks.Items, hostedManifestWorks)
 	}
 
 	// apply klusterlet manifest works klustelet to the management namespace from import secret to trigger the joining process.
@@ -118,17 +147,29 @@ func (r *ReconcileHosted) Reconcile(ctx context.Context, request reconcile.Reque
 		return reconcile.Result{}, err
 	}
 
-	managementCluster, err := helpers.GetHostingCluster(managedCluster)
+	manifestWork, err := createHostedManifestWork(managedCluster, importSecret)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
-
-	manifestWork := createHostedManifestWork(managedCluster.Name, importSecret, managementCluster)
 	err = helpers.ApplyResources(r.clientHolder, r.recorder, r.scheme, managedCluster, manifestWork)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
 
+	// the klusterlet manifest works may be left in the managed cluster namespace after the klusterlet mode is changed
+	conflict, err := helpers.DetectKlusterletWorksConflict(ctx, r.clientHolder.RuntimeClient, managedCluster)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if err := helpers.CleanUpStaleKlusterletWorks(ctx, r.clientHolder.RuntimeClient, r.recorder, conflict); err != nil {
+		return reconcile.Result{}, err
+	}
+	err = helpers.UpdateManagedClusterStatus(r.clientHolder.RuntimeClient, r.recorder, managedClusterName,
+		helpers.KlusterletWorksConflictCondition(conflict))
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
 	autoImportSecret, err := r.clientHolder.KubeClient.CoreV1().Secrets(managedClusterName).Get(ctx, constants.AutoImportSecretName, metav1.GetOptions{})
 	if errors.IsNotFound(err) {
 		// the auto import secret has not be created or has been deleted, do nothing
@@ -138,11 +179,7 @@ func (r *ReconcileHosted) Reconcile(ctx context.Context, request reconcile.Reque
 		return reconcile.Result{}, err
 	}
 
-	manifestWork, err = createManagedKubeconfigManifestWork(managedCluster.Name, autoImportSecret, managementCluster)
-	if err != nil {
-		return reconcile.Result{}, err
-	}
-	err = helpers.ApplyResources(r.clientHolder, r.recorder, r.scheme, managedCluster, manifestWork)
+	err = r.applyManagedKubeconfigManifestWork(managedCluster, autoImportSecret)
 	if err != nil {
 		errStatus := helpers.UpdateManagedClusterStatus(r.clientHolder.RuntimeClient, r.recorder, managedClusterName, metav1.Condition{
 			Type:    "ExternalManagedKubeconfigCreatedSucceeded",
@@ -180,23 +217,36 @@ func (r *ReconcileHosted) Reconcile(ctx context.Context, request reconcile.Reque
 
 }
 
+// applyManagedKubeconfigManifestWork rebuilds the managed kubeconfig manifest work from the current kubeconfig in the
+// given secret and applies it, so the external managed kubeconfig secret on the hosting cluster is refreshed once the
+// kubeconfig of the managed cluster is rotated
+func (r *ReconcileHosted) applyManagedKubeconfigManifestWork(managedCluster *clusterv1.ManagedCluster,
+	kubeconfigSecret *corev1.Secret) error {
+	manifestWork, err := createManagedKubeconfigManifestWork(managedCluster, kubeconfigSecret)
+	if err != nil {
+		return err
+	}
+
+	return helpers.ApplyResources(r.clientHolder, r.recorder, r.scheme, managedCluster, manifestWork)
+}
+
 func klusterletNamespace(managedCluster string) string {
 	return fmt.Sprintf("klusterlet-%s", managedCluster)
 }
 
 // getHostedManifestWorks gets klusterlet and managed kubeconfig manifest works in the management cluster namespace
 func (r *ReconcileHosted) getAllHostedManifestWorks(ctx context.Context, cluster *clusterv1.ManagedCluster) ([]workv1.ManifestWork, error) {
-	managementCluster, err := helpers.GetHostingCluster(cluster)
+	managementCluster, err := helpers.GetHostingClusterName(cluster)
 	if err != nil {
 		return nil, err
 	}
 
-	klusterletManifestWork, err := r.getHostedManifestWork(ctx, managementCluster, hostedKlusterletManifestWorkName(cluster.Name))
+	klusterletManifestWork, err := r.getHostedManifestWork(ctx, managementCluster, helpers.HostedKlusterletWorkName(cluster.Name))
 	if err != nil {
 		return nil, err
 	}
 
-	kubeconfigManifestWork, err := r.getHostedManifestWork(ctx, managementCluster, hostedManagedKubeconfigManifestWorkName(cluster.Name))
+	kubeconfigManifestWork, err := r.getHostedManifestWork(ctx, managementCluster, helpers.HostedManagedKubeconfigWorkName(cluster.Name))
 	if err != nil {
 		return nil, err
 	}
@@ -219,18 +269,22 @@ func (r *ReconcileHosted) getHostedManifestWork(ctx context.Context, namespace,
 }
 
 func (r *ReconcileHosted) deleteAddonsAndWorks(
-	ctx context.Context, cluster *clusterv1.ManagedCluster, works, hostedWorks []workv1.ManifestWork) (
+	ctx context.Context, reqLogger logr.Logger, cluster *clusterv1.ManagedCluster, works, hostedWorks []workv1.ManifestWork) (
 	reconcile.Result, error) {
 	errs := make([]error, 0)
 
-	err := helpers.DeleteManagedClusterAddons(ctx, r.clientHolder.RuntimeClient, r.recorder, cluster)
+	// the deletion helpers report the resources that they delete to the detach report
+	report := helpers.NewDetachReport(r.recorder)
+	defer report.Summarize(reqLogger, cluster.Name)
+
+	err := helpers.DeleteManagedClusterAddons(ctx, r.clientHolder.RuntimeClient, report, cluster)
 	if err != nil {
 		// continue to delete manifestworks
 		errs = append(errs, err)
 	}
 
 	// the managed cluster is deleting, delete its manifestworks
-	result, err := r.deleteManifestWorks(ctx, cluster, works, hostedWorks)
+	result, err := r.deleteManifestWorks(ctx, reqLogger, report, cluster, works, hostedWorks)
 	if err != nil {
 		errs = append(errs, err)
 	}
@@ -240,12 +294,14 @@ func (r *ReconcileHosted) deleteAddonsAndWorks(
 // deleteManifestWorks deletes manifest works when a managed cluster is deleting
 // If the managed cluster is unavailable, we will force delete all manifest works
 // If the managed cluster is available, we will
-//   1. delete the manifest work with the postpone-delete annotation until 10 min
-//      after the cluster is deleted.
+//   1. delete the manifest work with the postpone-delete annotation until its postpone time
+//      (10 min by default) after the cluster is deleted.
 //   2. delete the manifest works that do not include klusterlet addon works
 //   3. delete the klusterlet and managed kubeconfig manifest works
 func (r *ReconcileHosted) deleteManifestWorks(
 	ctx context.Context,
+	reqLogger logr.Logger,
+	recorder events.Recorder,
 	cluster *clusterv1.ManagedCluster,
 	works, hostedWorks []workv1.ManifestWork) (
 	reconcile.Result, error) {
@@ -256,7 +312,7 @@ func (r *ReconcileHosted) deleteManifestWorks(
 	if helpers.IsClusterUnavailable(cluster) {
 		// the managed cluster is offline, force delete all manifest works
 		return reconcile.Result{}, helpers.ForceDeleteAllManifestWorks(
-			ctx, r.clientHolder.RuntimeClient, r.recorder, append(works, hostedWorks...))
+			ctx, r.clientHolder.RuntimeClient, recorder, append(works, hostedWorks...))
 	}
 
 	// delete works that do not include klusterlet works and klusterlet addon works, the addon works were removed
@@ -272,7 +328,7 @@ func (r *ReconcileHosted) deleteManifestWorks(
 		}
 		return true
 	}
-	err := helpers.DeleteManifestWorkWithSelector(ctx, r.clientHolder.RuntimeClient, r.recorder, cluster, works, ignoreAddons)
+	err := helpers.DeleteManifestWorkWithSelector(ctx, r.clientHolder.RuntimeClient, recorder, cluster, works, ignoreAddons)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
@@ -287,7 +343,7 @@ func (r *ReconcileHosted) deleteManifestWorks(
 	}
 
 	ignoreNothing := func(_ string, _ workv1.ManifestWork) bool { return false }
-	noPending, err := helpers.NoPendingManifestWorks(ctx, r.clientHolder.RuntimeClient, log, cluster.GetName(), ignoreNothing)
+	noPending, err := helpers.NoPendingManifestWorks(ctx, r.clientHolder.RuntimeClient, reqLogger, cluster.GetName(), ignoreNothing)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
@@ -296,47 +352,49 @@ func (r *ReconcileHosted) deleteManifestWorks(
 		return reconcile.Result{}, nil
 	}
 
-	return reconcile.Result{}, r.deleteHostedManifestWorks(ctx, r.clientHolder.RuntimeClient, r.recorder, cluster)
+	return reconcile.Result{}, r.deleteHostedManifestWorks(ctx, r.clientHolder.RuntimeClient, recorder, cluster)
 }
 
 // deleteHostedManifestWorks delete klusterlet and managed kubeconfig manifest works in the hosting cluster namespace
 func (r *ReconcileHosted) deleteHostedManifestWorks(ctx context.Context, runtimeClient client.Client,
 	recorder events.Recorder, cluster *clusterv1.ManagedCluster) error {
-	managementCluster, err := helpers.GetHostingCluster(cluster)
+	managementCluster, err := helpers.GetHostingClusterName(cluster)
 	if err != nil {
 		return err
 	}
 
-	err = helpers.DeleteManifestWork(ctx, runtimeClient, recorder, managementCluster, hostedKlusterletManifestWorkName(cluster.Name))
+	err = helpers.DeleteManifestWork(ctx, runtimeClient, recorder, managementCluster, helpers.HostedKlusterletWorkName(cluster.Name))
 	if err != nil {
 		return err
 	}
 
-	return helpers.DeleteManifestWork(ctx, runtimeClient, recorder, managementCluster, hostedManagedKubeconfigManifestWorkName(cluster.Name))
+	return helpers.DeleteManifestWork(ctx, runtimeClient, recorder, managementCluster, helpers.HostedManagedKubeconfigWorkName(cluster.Name))
 }
 
-// createHostedManifestWork creates a manifestwork from import secret for hosted mode cluster
-func createHostedManifestWork(managedClusterName string,
-	importSecret *corev1.Secret, manifestWorkNamespace string) *workv1.ManifestWork {
-	manifests := []workv1.Manifest{}
-	importYaml := importSecret.Data[constants.ImportSecretImportYamlKey]
-	for _, yamlData := range helpers.SplitYamls(importYaml) {
-		jsonData, err := yaml.YAMLToJSON(yamlData)
-		if err != nil {
-			panic(err)
-		}
-		manifests = append(manifests, workv1.Manifest{
-			RawExtension: runtime.RawExtension{Raw: jsonData},
-		})
+// createHostedManifestWork creates a manifestwork from import secret for hosted mode cluster, the manifestwork is
+// created in the namespace of the hosting cluster
+func createHostedManifestWork(managedCluster *clusterv1.ManagedCluster,
+	importSecret *corev1.Secret) (*workv1.ManifestWork, error) {
+	hostingClusterName, err := helpers.GetHostingClusterName(managedCluster)
+	if err != nil {
+		return nil, err
+	}
+
+	manifests, err := helpers.BuildManifests(importSecret.Data[constants.ImportSecretImportYamlKey])
+	if err != nil {
+		return nil, err
 	}
 
 	// For hosted mode, the klusterletManifestWork only contains a klusterlet CR
 	// and a bootstrap secret, delete it in foreground.
-	return &workv1.ManifestWork{
+	mw := &workv1.ManifestWork{
 		TypeMeta: metav1.TypeMeta{},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      hostedKlusterletManifestWorkName(managedClusterName),
-			Namespace: manifestWorkNamespace,
+			Name:      helpers.HostedKlusterletWorkName(managedCluster.Name),
+			Namespace: hostingClusterName,
+			Labels: map[string]string{
+				constants.KlusterletWorksLabel: "true",
+			},
 		},
 		Spec: workv1.ManifestWorkSpec{
 			Workload: workv1.ManifestsTemplate{
@@ -347,10 +405,21 @@ func createHostedManifestWork(managedClusterName string,
 			},
 		},
 	}
+	helpers.SetControllerVersionAnnotation(mw)
+
+	return mw, nil
 }
 
-func createManagedKubeconfigManifestWork(managedClusterName string, importSecret *corev1.Secret,
-	manifestWorkNamespace string) (*workv1.ManifestWork, error) {
+// createManagedKubeconfigManifestWork creates a manifestwork to deliver the managed cluster kubeconfig in the
+// auto-import-secret to the klusterlet namespace on the hosting cluster, the manifestwork is created in the
+// namespace of the hosting cluster
+func createManagedKubeconfigManifestWork(managedCluster *clusterv1.ManagedCluster,
+	importSecret *corev1.Secret) (*workv1.ManifestWork, error) {
+	hostingClusterName, err := helpers.GetHostingClusterName(managedCluster)
+	if err != nil {
+		return nil, err
+	}
+
 	kubeconfig := importSecret.Data["kubeconfig"]
 	if len(kubeconfig) == 0 {
 		return nil, fmt.Errorf("import secret invalid, the field kubeconfig must exist in the secret for hosted mode")
@@ -360,7 +429,7 @@ func createManagedKubeconfigManifestWork(managedClusterName string, importSecret
 		KlusterletNamespace       string
 		ExternalManagedKubeconfig string
 	}{
-		KlusterletNamespace:       klusterletNamespace(managedClusterName),
+		KlusterletNamespace:       klusterletNamespace(managedCluster.Name),
 		ExternalManagedKubeconfig: base64.StdEncoding.EncodeToString(kubeconfig),
 	}
 
@@ -368,7 +437,10 @@ func createManagedKubeconfigManifestWork(managedClusterName string, importSecret
 	if err != nil {
 		return nil, err
 	}
-	externalKubeYAML := helpers.MustCreateAssetFromTemplate(klusterletHostedExternalKubeconfig, template, config)
+	externalKubeYAML, err := helpers.CreateAssetFromTemplate(klusterletHostedExternalKubeconfig, template, config)
+	if err != nil {
+		return nil, err
+	}
 	externalKubeJSON, err := yaml.YAMLToJSON(externalKubeYAML)
 	if err != nil {
 		return nil, err
@@ -383,8 +455,8 @@ func createManagedKubeconfigManifestWork(managedClusterName string, importSecret
 	mw := &workv1.ManifestWork{
 		TypeMeta: metav1.TypeMeta{},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      hostedManagedKubeconfigManifestWorkName(managedClusterName),
-			Namespace: manifestWorkNamespace,
+			Name:      helpers.HostedManagedKubeconfigWorkName(managedCluster.Name),
+			Namespace: hostingClusterName,
 		},
 		Spec: workv1.ManifestWorkSpec{
 			Workload: workv1.ManifestsTemplate{
@@ -399,14 +471,7 @@ func createManagedKubeconfigManifestWork(managedClusterName string, importSecret
 			},
 		},
 	}
+	helpers.SetControllerVersionAnnotation(mw)
 
 	return mw, nil
 }
-
-func hostedKlusterletManifestWorkName(managedClusterName string) string {
-	return fmt.Sprintf("%s-%s", managedClusterName, constants.HostedKlusterletManifestworkSuffix)
-}
-
-func hostedManagedKubeconfigManifestWorkName(managedClusterName string) string {
-	return fmt.Sprintf("%s-%s", managedClusterName, constants.HostedManagedKubeconfigManifestworkSuffix)
-}
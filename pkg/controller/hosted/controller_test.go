@@ -5,6 +5,7 @@ package hosted
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"strings"
 	"testing"
@@ -14,6 +15,7 @@ import (
 	"github.com/stolostron/managedcluster-import-controller/pkg/constants"
 	"github.com/stolostron/managedcluster-import-controller/pkg/helpers"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -109,8 +111,18 @@ func TestReconcile(t *testing.T) {
 			kubeObjs: []runtime.Object{},
 			request:  reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}}, // managedcluster name
 			vaildateFunc: func(t *testing.T, reconcileResult reconcile.Result, reconcileErr error, ch *helpers.ClientHolder) {
-				if reconcileErr == nil || !strings.Contains(reconcileErr.Error(), fmt.Sprintf("annotation %s not found", constants.HostingClusterNameAnnotation)) {
-					t.Errorf("expect err annotation %s not found, but get %v", constants.HostingClusterNameAnnotation, reconcileErr)
+				if reconcileErr != nil {
+					t.Errorf("unexpected error: %v", reconcileErr)
+				}
+
+				managedCluster := &clusterv1.ManagedCluster{}
+				if err := ch.RuntimeClient.Get(context.TODO(), types.NamespacedName{Name: "test"}, managedCluster); err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				cond := meta.FindStatusCondition(managedCluster.Status.Conditions, constants.ImportSucceededConditionType)
+				if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != "ManagedClusterHostingClusterInvalid" ||
+					!strings.Contains(cond.Message, fmt.Sprintf("annotation %s not found", constants.HostingClusterNameAnnotation)) {
+					t.Errorf("expect the hosting cluster invalid condition, but get %v", cond)
 				}
 			},
 		},
@@ -366,3 +378,119 @@ metadata:
 		})
 	}
 }
+
+func TestCreateHostedManifestWorks(t *testing.T) {
+	managedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+			Annotations: map[string]string{
+				constants.KlusterletDeployModeAnnotation: constants.KlusterletDeployModeHosted,
+				constants.HostingClusterNameAnnotation:   "cluster1",
+			},
+		},
+	}
+	importSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-import",
+			Namespace: "test",
+		},
+		Data: map[string][]byte{
+			constants.ImportSecretImportYamlKey: []byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: klusterlet-test\n"),
+		},
+	}
+	autoImportSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      constants.AutoImportSecretName,
+			Namespace: "test",
+		},
+		Data: map[string][]byte{
+			"kubeconfig": []byte("test"),
+		},
+	}
+
+	klusterletWork, err := createHostedManifestWork(managedCluster, importSecret)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if klusterletWork.Name != fmt.Sprintf("test-%s", constants.HostedKlusterletManifestworkSuffix) ||
+		klusterletWork.Namespace != "cluster1" {
+		t.Errorf("unexpected klusterlet manifest work %s/%s", klusterletWork.Namespace, klusterletWork.Name)
+	}
+	if len(klusterletWork.Spec.Workload.Manifests) != 1 {
+		t.Errorf("expected one manifest, but got %d", len(klusterletWork.Spec.Workload.Manifests))
+	}
+
+	kubeconfigWork, err := createManagedKubeconfigManifestWork(managedCluster, autoImportSecret)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if kubeconfigWork.Name != fmt.Sprintf("test-%s", constants.HostedManagedKubeconfigManifestworkSuffix) ||
+		kubeconfigWork.Namespace != "cluster1" {
+		t.Errorf("unexpected managed kubeconfig manifest work %s/%s", kubeconfigWork.Namespace, kubeconfigWork.Name)
+	}
+	if len(kubeconfigWork.Spec.Workload.Manifests) != 1 ||
+		!strings.Contains(string(kubeconfigWork.Spec.Workload.Manifests[0].Raw), "external-managed-kubeconfig") {
+		t.Errorf("expected the managed kubeconfig secret in the manifest work, but got %v",
+			kubeconfigWork.Spec.Workload.Manifests)
+	}
+
+	// the hosting cluster is required
+	delete(managedCluster.Annotations, constants.HostingClusterNameAnnotation)
+	if _, err := createHostedManifestWork(managedCluster, importSecret); !helpers.IsInvalidHostingCluster(err) {
+		t.Errorf("expected invalid hosting cluster error, but got %v", err)
+	}
+	if _, err := createManagedKubeconfigManifestWork(managedCluster, autoImportSecret); !helpers.IsInvalidHostingCluster(err) {
+		t.Errorf("expected invalid hosting cluster error, but got %v", err)
+	}
+}
+
+func TestApplyManagedKubeconfigManifestWork(t *testing.T) {
+	managedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+			Annotations: map[string]string{
+				constants.KlusterletDeployModeAnnotation: constants.KlusterletDeployModeHosted,
+				constants.HostingClusterNameAnnotation:   "cluster1",
+			},
+		},
+	}
+	r := &ReconcileHosted{
+		clientHolder: &helpers.ClientHolder{
+			RuntimeClient: fake.NewClientBuilder().WithScheme(testscheme).WithObjects(managedCluster).Build(),
+			KubeClient:    kubefake.NewSimpleClientset(),
+		},
+		recorder: eventstesting.NewTestingEventRecorder(t),
+		scheme:   testscheme,
+	}
+
+	for _, kubeconfig := range []string{"kubeconfig1", "kubeconfig2"} {
+		kubeconfigSecret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      constants.AutoImportSecretName,
+				Namespace: "test",
+			},
+			Data: map[string][]byte{
+				"kubeconfig": []byte(kubeconfig),
+			},
+		}
+		if err := r.applyManagedKubeconfigManifestWork(managedCluster, kubeconfigSecret); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		manifestWork := &workv1.ManifestWork{}
+		err := r.clientHolder.RuntimeClient.Get(context.TODO(), types.NamespacedName{
+			Namespace: "cluster1",
+			Name:      helpers.HostedManagedKubeconfigWorkName("test"),
+		}, manifestWork)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		encoded := base64.StdEncoding.EncodeToString([]byte(kubeconfig))
+		if len(manifestWork.Spec.Workload.Manifests) != 1 ||
+			!strings.Contains(string(manifestWork.Spec.Workload.Manifests[0].Raw), encoded) {
+			t.Errorf("expected the manifest work contains the kubeconfig %s, but got %s",
+				kubeconfig, string(manifestWork.Spec.Workload.Manifests[0].Raw))
+		}
+	}
+}
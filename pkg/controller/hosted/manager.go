@@ -49,7 +49,7 @@ func newReconciler(mgr manager.Manager, clientHolder *helpers.ClientHolder) reco
 func add(importSecretInformer, autoImportSecretInformer cache.SharedIndexInformer, mgr manager.Manager, r reconcile.Reconciler) error {
 	c, err := controller.New(controllerName, mgr, controller.Options{
 		Reconciler:              r,
-		MaxConcurrentReconciles: helpers.GetMaxConcurrentReconciles(),
+		MaxConcurrentReconciles: helpers.GetMaxConcurrentReconcilesFor(controllerName),
 	})
 	if err != nil {
 		return err
@@ -4,6 +4,7 @@
 package importconfig
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"crypto/x509"
@@ -27,6 +28,7 @@ import (
 	"k8s.io/client-go/kubernetes"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	clientcmdlatest "k8s.io/client-go/tools/clientcmd/api/latest"
+	certutil "k8s.io/client-go/util/cert"
 
 	"k8s.io/apiserver/pkg/storage/names"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -84,12 +86,7 @@ func getBootstrapSecret(ctx context.Context, kubeClient kubernetes.Interface, ma
 
 // getKubeAPIServerAddress get the kube-apiserver URL from ocp infrastructure
 func getKubeAPIServerAddress(ctx context.Context, client client.Client) (string, error) {
-	infraConfig := &ocinfrav1.Infrastructure{}
-	if err := client.Get(ctx, types.NamespacedName{Name: "cluster"}, infraConfig); err != nil {
-		return "", err
-	}
-
-	return infraConfig.Status.APIServerURL, nil
+	return helpers.GetKubeAPIServerAddress(ctx, client)
 }
 
 // getKubeAPIServerSecretName iterate through all named certificates from apiserver
@@ -231,8 +228,9 @@ func getValidCertificatesFromURL(serverURL string, rootCAs *x509.CertPool) ([]*x
 	return retCerts, nil
 }
 
-// create kubeconfig from bootstrap secret
-func createKubeconfigData(ctx context.Context, clientHolder *helpers.ClientHolder, bootStrapSecret *corev1.Secret) ([]byte, error) {
+// create kubeconfig from bootstrap secret, if the caBundle is specified, it is used as the CA of the hub
+func createKubeconfigData(ctx context.Context, clientHolder *helpers.ClientHolder, bootStrapSecret *corev1.Secret,
+	caBundle []byte) ([]byte, error) {
 	saToken := bootStrapSecret.Data["token"]
 
 	kubeAPIServer, err := getKubeAPIServerAddress(ctx, clientHolder.RuntimeClient)
@@ -240,8 +238,9 @@ func createKubeconfigData(ctx context.Context, clientHolder *helpers.ClientHolde
 		return nil, err
 	}
 
-	var certData []byte
-	if u, err := url.Parse(kubeAPIServer); err == nil {
+	// the custom CA bundle overrides the CA of the kube-apiserver
+	certData := caBundle
+	if u, err := url.Parse(kubeAPIServer); err == nil && len(certData) == 0 {
 		// get the ca cert from ocp apiserver firstly
 		apiServerCertSecretName, err := getKubeAPIServerSecretName(ctx, clientHolder.RuntimeClient, u.Hostname())
 		if err != nil {
@@ -286,6 +285,16 @@ func createKubeconfigData(ctx context.Context, clientHolder *helpers.ClientHolde
 		}
 	}
 
+	// the secondary hub CA is trusted in addition to the CA bundle, if there is no CA bundle, the system CAs are
+	// trusted, the secondary hub CA is not appended to avoid distrusting them
+	if len(certData) != 0 {
+		secondaryCA, err := getSecondaryHubCA(ctx, clientHolder.KubeClient)
+		if err != nil {
+			return nil, err
+		}
+		certData = appendCABundle(certData, secondaryCA)
+	}
+
 	bootstrapConfig := clientcmdapi.Config{
 		// Define a cluster stanza based on the bootstrap kubeconfig.
 		Clusters: map[string]*clientcmdapi.Cluster{"default-cluster": {
@@ -309,6 +318,51 @@ func createKubeconfigData(ctx context.Context, clientHolder *helpers.ClientHolde
 	return runtime.Encode(clientcmdlatest.Codec, &bootstrapConfig)
 }
 
+// getSecondaryHubCA gets the secondary hub CA from the secret that is specified by the env, if the env is not set, the
+// secret does not exist or the secret does not have the CA, return nil
+func getSecondaryHubCA(ctx context.Context, kubeClient kubernetes.Interface) ([]byte, error) {
+	secretName := os.Getenv(secondaryHubCASecretEnvVarName)
+	if secretName == "" {
+		return nil, nil
+	}
+
+	ns := os.Getenv(constants.PodNamespaceEnvVarName)
+	secret, err := kubeClient.CoreV1().Secrets(ns).Get(ctx, secretName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		// the secondary hub CA is not created yet or is removed after the certificate rotation
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	secondaryCA := secret.Data["ca.crt"]
+	if len(bytes.TrimSpace(secondaryCA)) == 0 {
+		return nil, nil
+	}
+
+	if _, err := certutil.ParseCertsPEM(secondaryCA); err != nil {
+		return nil, fmt.Errorf("invalid secondary hub CA in the secret %s/%s, %v", ns, secretName, err)
+	}
+	return secondaryCA, nil
+}
+
+// appendCABundle appends the additional CAs to the CA bundle, the CA bundle is returned as it is if the additional
+// CAs are empty or already in it
+func appendCABundle(caBundle, additional []byte) []byte {
+	additional = bytes.TrimSpace(additional)
+	if len(additional) == 0 || bytes.Contains(caBundle, additional) {
+		return caBundle
+	}
+
+	appended := append([]byte{}, caBundle...)
+	if len(appended) != 0 && !bytes.HasSuffix(appended, []byte("\n")) {
+		appended = append(appended, '\n')
+	}
+	appended = append(appended, additional...)
+	return append(appended, '\n')
+}
+
 func getBootstrapSAName(clusterName string) string {
 	bootstrapSAName := fmt.Sprintf("%s-%s", clusterName, bootstrapSASuffix)
 	if len(bootstrapSAName) > 63 {
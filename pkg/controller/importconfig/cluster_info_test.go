@@ -13,6 +13,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/stolostron/managedcluster-import-controller/pkg/constants"
 	"github.com/stolostron/managedcluster-import-controller/pkg/helpers"
 	"github.com/stolostron/managedcluster-import-controller/pkg/helpers/imageregistry"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
@@ -26,6 +27,7 @@ import (
 	kubefake "k8s.io/client-go/kubernetes/fake"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	clientcmdlatest "k8s.io/client-go/tools/clientcmd/api/latest"
+	certutil "k8s.io/client-go/util/cert"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -503,7 +505,7 @@ func TestCreateKubeconfigData(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Logf("Test name: %s", tt.name)
-			kubeconfigData, err := createKubeconfigData(context.Background(), tt.args.clientHolder, tt.args.secret)
+			kubeconfigData, err := createKubeconfigData(context.Background(), tt.args.clientHolder, tt.args.secret, nil)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("createKubeconfigData() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -562,6 +564,115 @@ func TestCreateKubeconfigData(t *testing.T) {
 
 }
 
+func TestCreateKubeconfigDataWithSecondaryHubCA(t *testing.T) {
+	primaryCA, _, err := certutil.GenerateSelfSignedCertKey("hub-old", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondaryCA, _, err := certutil.GenerateSelfSignedCertKey("hub-new", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	infraConfig := &ocinfrav1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+		Status:     ocinfrav1.InfrastructureStatus{APIServerURL: "https://127.0.0.1:6443"},
+	}
+	tokenSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-sa-token", Namespace: "test-namespace"},
+		Data: map[string][]byte{
+			"token":  []byte("fake-token"),
+			"ca.crt": primaryCA,
+		},
+	}
+	newSecondaryCASecret := func(ca []byte) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "secondary-hub-ca", Namespace: "open-cluster-management"},
+			Data:       map[string][]byte{"ca.crt": ca},
+		}
+	}
+
+	cases := []struct {
+		name             string
+		secretName       string
+		secrets          []runtime.Object
+		expectedCertData []byte
+		expectedErr      bool
+	}{
+		{
+			name:             "the secondary hub CA is not configured",
+			expectedCertData: primaryCA,
+		},
+		{
+			name:             "the secondary hub CA is appended",
+			secretName:       "secondary-hub-ca",
+			secrets:          []runtime.Object{newSecondaryCASecret(secondaryCA)},
+			expectedCertData: append(append([]byte{}, primaryCA...), secondaryCA...),
+		},
+		{
+			name:             "the secondary hub CA is empty",
+			secretName:       "secondary-hub-ca",
+			secrets:          []runtime.Object{newSecondaryCASecret(nil)},
+			expectedCertData: primaryCA,
+		},
+		{
+			name:             "the secondary hub CA is already trusted",
+			secretName:       "secondary-hub-ca",
+			secrets:          []runtime.Object{newSecondaryCASecret(primaryCA)},
+			expectedCertData: primaryCA,
+		},
+		{
+			name:        "the secondary hub CA is invalid",
+			secretName:  "secondary-hub-ca",
+			secrets:     []runtime.Object{newSecondaryCASecret([]byte("invalid"))},
+			expectedErr: true,
+		},
+		{
+			name:             "the secondary hub CA secret is not found",
+			secretName:       "secondary-hub-ca",
+			expectedCertData: primaryCA,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			os.Setenv(constants.PodNamespaceEnvVarName, "open-cluster-management")
+			defer os.Unsetenv(constants.PodNamespaceEnvVarName)
+			if len(c.secretName) != 0 {
+				os.Setenv(secondaryHubCASecretEnvVarName, c.secretName)
+				defer os.Unsetenv(secondaryHubCASecretEnvVarName)
+			}
+
+			clientHolder := &helpers.ClientHolder{
+				RuntimeClient: fake.NewClientBuilder().WithScheme(testscheme).WithObjects(infraConfig).Build(),
+				KubeClient:    kubefake.NewSimpleClientset(c.secrets...),
+			}
+			kubeconfigData, err := createKubeconfigData(context.Background(), clientHolder, tokenSecret, nil)
+			if c.expectedErr {
+				if err == nil {
+					t.Errorf("expected error, but failed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			bootstrapConfig := &clientcmdapi.Config{}
+			if err := runtime.DecodeInto(clientcmdlatest.Codec, kubeconfigData, bootstrapConfig); err != nil {
+				t.Fatalf("failed to decode the kubeconfig: %v", err)
+			}
+			certData := bootstrapConfig.Clusters["default-cluster"].CertificateAuthorityData
+			if !reflect.DeepEqual(certData, c.expectedCertData) {
+				t.Errorf("expected cert data %s, but got %s", c.expectedCertData, certData)
+			}
+			if _, err := certutil.ParseCertsPEM(certData); err != nil {
+				t.Errorf("expected the valid cert data, but got %v", err)
+			}
+		})
+	}
+}
+
 func TestGetValidCertificatesFromURL(t *testing.T) {
 	serverStopped := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintln(w, "Hello, client")
@@ -0,0 +1,112 @@
+// Copyright (c) Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package importconfig
+
+import (
+	"fmt"
+
+	"github.com/ghodss/yaml"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	"github.com/stolostron/managedcluster-import-controller/pkg/constants"
+	"github.com/stolostron/managedcluster-import-controller/pkg/features"
+	"github.com/stolostron/managedcluster-import-controller/pkg/helpers"
+)
+
+const (
+	importConfigurationConfigMapSuffix = "import-config"
+	importConfigurationKey             = "import-config.yaml"
+)
+
+// ImportConfiguration is the resolved import configuration of a managed cluster
+type ImportConfiguration struct {
+	Mode                      string              `json:"mode"`
+	KlusterletNamespace       string              `json:"klusterletNamespace"`
+	NodeSelector              map[string]string   `json:"nodeSelector,omitempty"`
+	Tolerations               []corev1.Toleration `json:"tolerations,omitempty"`
+	PriorityClassName         string              `json:"priorityClassName,omitempty"`
+	RegistrationOperatorImage string              `json:"registrationOperatorImage,omitempty"`
+	RegistrationImage         string              `json:"registrationImage"`
+	WorkImage                 string              `json:"workImage"`
+	FeatureGates              map[string]bool     `json:"featureGates"`
+}
+
+// resolveImportConfiguration resolves the import configuration of a managed cluster from its annotations,
+// labels, the controller environments and the feature gates
+func resolveImportConfiguration(managedCluster *clusterv1.ManagedCluster) (*ImportConfiguration, error) {
+	mode := helpers.DetermineKlusterletMode(managedCluster)
+
+	registrationOperatorImageName, priorityClassName := "", ""
+	if mode == constants.KlusterletDeployModeDefault {
+		// the klusterlet operator is only deployed on the managed cluster in the default mode
+		image, err := getImage(managedCluster, registrationOperatorImageEnvVarName)
+		if err != nil {
+			return nil, err
+		}
+		registrationOperatorImageName = image
+
+		priorityClassName, err = helpers.GetPriorityClassName(managedCluster)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	registrationImageName, err := getImage(managedCluster, registrationImageEnvVarName)
+	if err != nil {
+		return nil, err
+	}
+
+	workImageName, err := getImage(managedCluster, workImageEnvVarName)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeSelector, err := helpers.GetNodeSelectorWithDefaults(managedCluster)
+	if err != nil {
+		return nil, err
+	}
+
+	tolerations, err := helpers.GetTolerationsWithDefaults(managedCluster)
+	if err != nil {
+		return nil, err
+	}
+
+	featureGates := map[string]bool{}
+	for feature := range features.DefaultMutableFeatureGate.GetAll() {
+		featureGates[string(feature)] = features.DefaultMutableFeatureGate.Enabled(feature)
+	}
+
+	return &ImportConfiguration{
+		Mode:                      mode,
+		KlusterletNamespace:       helpers.GetKlusterletNamespace(managedCluster),
+		NodeSelector:              nodeSelector,
+		Tolerations:               tolerations,
+		PriorityClassName:         priorityClassName,
+		RegistrationOperatorImage: registrationOperatorImageName,
+		RegistrationImage:         registrationImageName,
+		WorkImage:                 workImageName,
+		FeatureGates:              featureGates,
+	}, nil
+}
+
+// createImportConfigurationConfigMap creates a ConfigMap to present the resolved import configuration
+func createImportConfigurationConfigMap(managedCluster *clusterv1.ManagedCluster,
+	config *ImportConfiguration) (*corev1.ConfigMap, error) {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%s", managedCluster.Name, importConfigurationConfigMapSuffix),
+			Namespace: managedCluster.Name,
+		},
+		Data: map[string]string{
+			importConfigurationKey: string(data),
+		},
+	}, nil
+}
@@ -0,0 +1,137 @@
+// Copyright (c) Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package importconfig
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/openshift/library-go/pkg/operator/events/eventstesting"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	configv1 "github.com/openshift/api/config/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/stolostron/managedcluster-import-controller/pkg/constants"
+	"github.com/stolostron/managedcluster-import-controller/pkg/features"
+	"github.com/stolostron/managedcluster-import-controller/pkg/helpers"
+	"github.com/stolostron/managedcluster-import-controller/pkg/helpers/imageregistry"
+)
+
+func TestImportConfigurationConfigMap(t *testing.T) {
+	if err := features.DefaultMutableFeatureGate.Set(
+		fmt.Sprintf("%s=true", features.ImportConfigurationConfigMap)); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := features.DefaultMutableFeatureGate.Set(
+			fmt.Sprintf("%s=false", features.ImportConfigurationConfigMap)); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	managedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+			Annotations: map[string]string{
+				constants.KlusterletNamespaceAnnotation: "open-cluster-management-agent-test",
+				"open-cluster-management/nodeSelector":  "{\"kubernetes.io/os\":\"linux\"}",
+			},
+		},
+	}
+
+	kubeClient := kubefake.NewSimpleClientset(
+		&corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-bootstrap-sa",
+				Namespace: "test",
+			},
+			Secrets: []corev1.ObjectReference{
+				{
+					Name:      "test-bootstrap-sa-token-5pw5c",
+					Namespace: "test",
+				},
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-bootstrap-sa-token-5pw5c",
+				Namespace: "test",
+			},
+			Data: map[string][]byte{
+				"token": []byte("fake-token"),
+			},
+			Type: corev1.SecretTypeServiceAccountToken,
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      os.Getenv("DEFAULT_IMAGE_PULL_SECRET"),
+				Namespace: os.Getenv("POD_NAMESPACE"),
+			},
+			Data: map[string][]byte{
+				corev1.DockerConfigJsonKey: []byte("fake-token"),
+			},
+			Type: corev1.SecretTypeDockerConfigJson,
+		},
+	)
+	clientHolder := &helpers.ClientHolder{
+		KubeClient: kubeClient,
+		RuntimeClient: fake.NewClientBuilder().WithScheme(testscheme).WithObjects(
+			managedCluster,
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test"}},
+			&configv1.Infrastructure{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}},
+		).Build(),
+		ImageRegistryClient: imageregistry.NewClient(kubeClient),
+	}
+
+	r := &ReconcileImportConfig{
+		clientHolder:  clientHolder,
+		scheme:        testscheme,
+		recorder:      eventstesting.NewTestingEventRecorder(t),
+		workerFactory: &workerFactory{clientHolder: clientHolder},
+	}
+
+	if _, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	configMap, err := kubeClient.CoreV1().ConfigMaps("test").Get(context.TODO(), "test-import-config", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(configMap.OwnerReferences) != 1 || configMap.OwnerReferences[0].Name != managedCluster.Name {
+		t.Errorf("expected the configmap is owned by the managed cluster, but got %v", configMap.OwnerReferences)
+	}
+
+	expected, err := resolveImportConfiguration(managedCluster)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	actual := &ImportConfiguration{}
+	if err := yaml.Unmarshal([]byte(configMap.Data[importConfigurationKey]), actual); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !equality.Semantic.DeepEqual(expected, actual) {
+		t.Errorf("expected %v, but got %v", expected, actual)
+	}
+
+	if actual.KlusterletNamespace != "open-cluster-management-agent-test" ||
+		actual.NodeSelector["kubernetes.io/os"] != "linux" ||
+		actual.Mode != constants.KlusterletDeployModeDefault ||
+		!actual.FeatureGates[string(features.ImportConfigurationConfigMap)] {
+		t.Errorf("unexpected import configuration %v", actual)
+	}
+}
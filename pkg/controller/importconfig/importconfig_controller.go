@@ -16,7 +16,7 @@ import (
 	"github.com/openshift/library-go/pkg/operator/events"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
 
-	"github.com/stolostron/managedcluster-import-controller/pkg/constants"
+	"github.com/stolostron/managedcluster-import-controller/pkg/features"
 	"github.com/stolostron/managedcluster-import-controller/pkg/helpers"
 )
 
@@ -28,9 +28,12 @@ const (
 	registrationImageEnvVarName         = "REGISTRATION_IMAGE"
 	workImageEnvVarName                 = "WORK_IMAGE"
 	defaultImagePullSecretEnvVarName    = "DEFAULT_IMAGE_PULL_SECRET"
-)
 
-const defaultKlusterletNamespace = "open-cluster-management-agent"
+	// secondaryHubCASecretEnvVarName is the env of the name of a secret in the namespace of this controller, the
+	// CA in its ca.crt is appended to the CA bundle of the bootstrap hub kubeconfig, e.g. the new CA of the hub
+	// during the certificate rotation, so the klusterlets trust both the old and the new CAs
+	secondaryHubCASecretEnvVarName = "SECONDARY_HUB_CA_SECRET"
+)
 
 const managedClusterImagePullSecretName = "open-cluster-management-image-pull-credentials"
 
@@ -131,17 +134,46 @@ func (r *ReconcileImportConfig) Reconcile(ctx context.Context, request reconcile
 		return reconcile.Result{}, err
 	}
 
-	if err := helpers.ApplyResources(r.clientHolder, r.recorder, r.scheme, managedCluster, importSecret); err != nil {
+	results, err := helpers.ApplyResourcesWithOptions(r.clientHolder, r.recorder, r.scheme, managedCluster,
+		helpers.ApplyOptions{}, importSecret)
+	if err != nil {
 		return reconcile.Result{}, err
 	}
 
+	// the well-known labels of the nodeSelector are validated for the warnings only, the import is not blocked, the
+	// invalid nodeSelector is reported when the import secret is generated. The warning is only reported once the
+	// import secret is regenerated, so it is not repeated in every reconcile.
+	if len(results) == 1 && results[0].Action != helpers.ApplyActionUnchanged {
+		if nodeSelector, err := helpers.GetNodeSelectorWithDefaults(managedCluster); err == nil {
+			if err := helpers.ValidateNodeSelectorWellKnownLabels(nodeSelector); err != nil {
+				reqLogger.Info("The nodeSelector of the managed cluster may not match any node", "reason", err.Error())
+				r.recorder.Warningf("NodeSelectorMayNotMatch", "The nodeSelector of the managed cluster %s: %v",
+					managedCluster.Name, err)
+			}
+		}
+	}
+
+	if features.DefaultMutableFeatureGate.Enabled(features.ImportConfigurationConfigMap) {
+		// present the resolved import configuration in a ConfigMap for debugging
+		if err := r.applyImportConfigurationConfigMap(managedCluster); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
 	return reconcile.Result{}, nil
 }
 
-func klusterletNamespace(managedCluster *clusterv1.ManagedCluster) string {
-	if klusterletNamespace, ok := managedCluster.Annotations[constants.KlusterletNamespaceAnnotation]; ok {
-		return klusterletNamespace
+func (r *ReconcileImportConfig) applyImportConfigurationConfigMap(managedCluster *clusterv1.ManagedCluster) error {
+	config, err := resolveImportConfiguration(managedCluster)
+	if err != nil {
+		return err
+	}
+
+	configMap, err := createImportConfigurationConfigMap(managedCluster, config)
+	if err != nil {
+		return err
 	}
 
-	return defaultKlusterletNamespace
+	// the ConfigMap is owned by the managed cluster, so it will be deleted with the managed cluster
+	return helpers.ApplyResources(r.clientHolder, r.recorder, r.scheme, managedCluster, configMap)
 }
@@ -14,6 +14,7 @@ import (
 	"github.com/stolostron/managedcluster-import-controller/pkg/helpers/imageregistry"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
 
+	"github.com/openshift/library-go/pkg/operator/events"
 	"github.com/openshift/library-go/pkg/operator/events/eventstesting"
 
 	configv1 "github.com/openshift/api/config/v1"
@@ -26,6 +27,7 @@ import (
 	"k8s.io/client-go/kubernetes"
 	kubefake "k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/kubernetes/scheme"
+	clienttesting "k8s.io/client-go/testing"
 
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -160,8 +162,8 @@ func TestReconcile(t *testing.T) {
 					if !ok {
 						t.Errorf("import secret data %s, the first element is not namespace", constants.ImportSecretImportYamlKey)
 					}
-					if ns.Name != defaultKlusterletNamespace {
-						t.Errorf("import secret data %s, the namespace name %s is not %s", constants.ImportSecretImportYamlKey, ns.Name, defaultKlusterletNamespace)
+					if ns.Name != constants.DefaultKlusterletNamespace {
+						t.Errorf("import secret data %s, the namespace name %s is not %s", constants.ImportSecretImportYamlKey, ns.Name, constants.DefaultKlusterletNamespace)
 					}
 					pullSecret, ok := objs[8].(*corev1.Secret)
 					if !ok {
@@ -474,3 +476,136 @@ func TestReconcile(t *testing.T) {
 		})
 	}
 }
+
+// newTestReconciler returns a ReconcileImportConfig with the bootstrap credentials and the image pull secret that
+// are required to generate the import secret of the managed cluster test
+func newTestReconciler(managedCluster *clusterv1.ManagedCluster, recorder events.Recorder) *ReconcileImportConfig {
+	kubeClient := kubefake.NewSimpleClientset(
+		&corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-bootstrap-sa",
+				Namespace: "test",
+			},
+			Secrets: []corev1.ObjectReference{
+				{
+					Name:      "test-bootstrap-sa-token-5pw5c",
+					Namespace: "test",
+				},
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-bootstrap-sa-token-5pw5c",
+				Namespace: "test",
+			},
+			Data: map[string][]byte{
+				"token": []byte("fake-token"),
+			},
+			Type: corev1.SecretTypeServiceAccountToken,
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      os.Getenv("DEFAULT_IMAGE_PULL_SECRET"),
+				Namespace: os.Getenv("POD_NAMESPACE"),
+			},
+			Data: map[string][]byte{
+				corev1.DockerConfigJsonKey: []byte("fake-token"),
+			},
+			Type: corev1.SecretTypeDockerConfigJson,
+		},
+	)
+	// the secret type is defaulted by the kube apiserver, otherwise the import secret is always updated
+	kubeClient.PrependReactor("create", "secrets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		secret := action.(clienttesting.CreateAction).GetObject().(*corev1.Secret)
+		if len(secret.Type) == 0 {
+			secret.Type = corev1.SecretTypeOpaque
+		}
+		return false, nil, nil
+	})
+	clientHolder := &helpers.ClientHolder{
+		KubeClient: kubeClient,
+		RuntimeClient: fake.NewClientBuilder().WithScheme(testscheme).WithObjects(
+			managedCluster,
+			&configv1.Infrastructure{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "cluster",
+				},
+			},
+		).Build(),
+		ImageRegistryClient: imageregistry.NewClient(kubeClient),
+	}
+
+	return &ReconcileImportConfig{
+		clientHolder:  clientHolder,
+		scheme:        testscheme,
+		recorder:      recorder,
+		workerFactory: &workerFactory{clientHolder: clientHolder},
+	}
+}
+
+func TestReconcileNodeSelectorWarning(t *testing.T) {
+	recorder := events.NewInMemoryRecorder("test")
+	r := newTestReconciler(&clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+			Annotations: map[string]string{
+				"open-cluster-management/nodeSelector": "{\"kubernetes.io/os\":\"darwin\"}",
+			},
+		},
+	}, recorder)
+
+	// the warning is reported when the import secret is generated, but not repeated when the import secret
+	// is not changed
+	for i := 0; i < 2; i++ {
+		if _, err := r.Reconcile(context.TODO(), reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: "test"}}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	warnings := 0
+	for _, event := range recorder.Events() {
+		if event.Reason == "NodeSelectorMayNotMatch" && event.Type == corev1.EventTypeWarning {
+			warnings++
+		}
+	}
+	if warnings != 1 {
+		t.Errorf("expected the nodeSelector warning is reported once, but got %d", warnings)
+	}
+}
+
+func TestReconcileImportSecretRegenerated(t *testing.T) {
+	r := newTestReconciler(&clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+	}, eventstesting.NewTestingEventRecorder(t))
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}}
+	if _, err := r.Reconcile(context.TODO(), request); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the import secret is deleted unexpectedly, it is regenerated in the next reconcile
+	kubeClient := r.clientHolder.KubeClient
+	if err := kubeClient.CoreV1().Secrets("test").Delete(
+		context.TODO(), "test-import", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := r.Reconcile(context.TODO(), request); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	importSecret, err := kubeClient.CoreV1().Secrets("test").Get(context.TODO(), "test-import", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the import secret is regenerated, but got %v", err)
+	}
+	for _, key := range []string{
+		constants.ImportSecretImportYamlKey,
+		constants.ImportSecretCRDSYamlKey,
+		constants.ImportSecretCRDSV1beta1YamlKey,
+	} {
+		if len(importSecret.Data[key]) == 0 {
+			t.Errorf("expected the %s in the regenerated import secret, but failed", key)
+		}
+	}
+}
@@ -4,6 +4,11 @@
 package importconfig
 
 import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/stolostron/managedcluster-import-controller/pkg/constants"
 	"github.com/stolostron/managedcluster-import-controller/pkg/helpers"
 	"github.com/stolostron/managedcluster-import-controller/pkg/source"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
@@ -11,8 +16,14 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	informerscorev1 "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -28,7 +39,7 @@ const controllerName = "importconfig-controller"
 // The Manager will set fields on the Controller and Start it when the Manager is Started.
 func Add(mgr manager.Manager, clientHolder *helpers.ClientHolder,
 	importSecretInformer, autoImportSecretInformer cache.SharedIndexInformer) (string, error) {
-	return controllerName, add(importSecretInformer, mgr, newReconciler(mgr, clientHolder))
+	return controllerName, add(importSecretInformer, mgr, clientHolder.KubeClient, newReconciler(mgr, clientHolder))
 }
 
 // newReconciler returns a new reconcile.Reconciler
@@ -44,10 +55,11 @@ func newReconciler(mgr manager.Manager, clientHolder *helpers.ClientHolder) reco
 }
 
 // adds a new Controller to mgr with r as the reconcile.Reconciler
-func add(importSecretInformer cache.SharedIndexInformer, mgr manager.Manager, r reconcile.Reconciler) error {
+func add(importSecretInformer cache.SharedIndexInformer, mgr manager.Manager, kubeClient kubernetes.Interface,
+	r reconcile.Reconciler) error {
 	c, err := controller.New(controllerName, mgr, controller.Options{
 		Reconciler:              r,
-		MaxConcurrentReconciles: helpers.GetMaxConcurrentReconciles(),
+		MaxConcurrentReconciles: helpers.GetMaxConcurrentReconcilesFor(controllerName),
 	})
 	if err != nil {
 		return err
@@ -114,5 +126,72 @@ func add(importSecretInformer cache.SharedIndexInformer, mgr manager.Manager, r
 		return err
 	}
 
+	// the import secrets of all of the managed clusters are regenerated once the secondary hub CA is changed
+	if secretName := os.Getenv(secondaryHubCASecretEnvVarName); len(secretName) != 0 {
+		secondaryHubCASecretInformer := newSecondaryHubCASecretInformer(kubeClient, secretName)
+		if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			secondaryHubCASecretInformer.Run(ctx.Done())
+			return nil
+		})); err != nil {
+			return err
+		}
+
+		if err := c.Watch(
+			source.NewSecondaryHubCASecretSource(secondaryHubCASecretInformer),
+			handler.EnqueueRequestsFromMapFunc(managedClustersMapFunc(mgr.GetClient())),
+			predicate.Predicate(predicate.Funcs{
+				GenericFunc: func(e event.GenericEvent) bool { return false },
+				CreateFunc:  func(e event.CreateEvent) bool { return true },
+				DeleteFunc:  func(e event.DeleteEvent) bool { return true },
+				UpdateFunc: func(e event.UpdateEvent) bool {
+					new, okNew := e.ObjectNew.(*corev1.Secret)
+					old, okOld := e.ObjectOld.(*corev1.Secret)
+					if okNew && okOld {
+						return !equality.Semantic.DeepEqual(old.Data, new.Data)
+					}
+
+					return false
+				},
+			}),
+		); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
+
+// newSecondaryHubCASecretInformer returns an informer of the secondary hub CA secret in the namespace of this
+// controller
+func newSecondaryHubCASecretInformer(kubeClient kubernetes.Interface, secretName string) cache.SharedIndexInformer {
+	return informerscorev1.NewFilteredSecretInformer(
+		kubeClient,
+		os.Getenv(constants.PodNamespaceEnvVarName),
+		10*time.Minute,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+		func(listOptions *metav1.ListOptions) {
+			listOptions.FieldSelector = fields.OneTermEqualSelector("metadata.name", secretName).String()
+		},
+	)
+}
+
+// managedClustersMapFunc maps an object to the requests of all of the managed clusters
+func managedClustersMapFunc(reader client.Reader) handler.MapFunc {
+	return func(o client.Object) []reconcile.Request {
+		managedClusters := &clusterv1.ManagedClusterList{}
+		if err := reader.List(context.TODO(), managedClusters); err != nil {
+			log.Error(err, "Failed to list the managed clusters")
+			return nil
+		}
+
+		requests := []reconcile.Request{}
+		for _, managedCluster := range managedClusters.Items {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name: managedCluster.Name,
+				},
+			})
+		}
+		return requests
+	}
+}
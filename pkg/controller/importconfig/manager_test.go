@@ -0,0 +1,43 @@
+// Copyright (c) Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package importconfig
+
+import (
+	"reflect"
+	"testing"
+
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestManagedClustersMapFunc(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clusterv1.Install(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}},
+		&clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster2"}},
+	).Build()
+
+	requests := managedClustersMapFunc(reader)(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "secondary-hub-ca", Namespace: "open-cluster-management"},
+	})
+
+	expected := []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: "cluster1"}},
+		{NamespacedName: types.NamespacedName{Name: "cluster2"}},
+	}
+	if !reflect.DeepEqual(requests, expected) {
+		t.Errorf("expected requests %v, but got %v", expected, requests)
+	}
+}
@@ -29,7 +29,12 @@ func (w *defaultWorker) generateImportSecret(ctx context.Context, managedCluster
 		return nil, err
 	}
 
-	bootstrapKubeconfigData, err := createKubeconfigData(ctx, w.clientHolder, bootStrapSecret)
+	caBundle, err := helpers.GetBootstrapCABundle(managedCluster)
+	if err != nil {
+		return nil, err
+	}
+
+	bootstrapKubeconfigData, err := createKubeconfigData(ctx, w.clientHolder, bootStrapSecret, caBundle)
 	if err != nil {
 		return nil, err
 	}
@@ -76,12 +81,17 @@ func (w *defaultWorker) generateImportSecret(ctx context.Context, managedCluster
 		return nil, err
 	}
 
-	nodeSelector, err := helpers.GetNodeSelector(managedCluster)
+	nodeSelector, err := helpers.GetNodeSelectorWithDefaults(managedCluster)
+	if err != nil {
+		return nil, err
+	}
+
+	tolerations, err := helpers.GetTolerationsWithDefaults(managedCluster)
 	if err != nil {
 		return nil, err
 	}
 
-	tolerations, err := helpers.GetTolerations(managedCluster)
+	priorityClassName, err := helpers.GetPriorityClassName(managedCluster)
 	if err != nil {
 		return nil, err
 	}
@@ -98,12 +108,13 @@ func (w *defaultWorker) generateImportSecret(ctx context.Context, managedCluster
 	config := DefaultRenderConfig{
 		KlusterletRenderConfig: KlusterletRenderConfig{
 			ManagedClusterNamespace: managedCluster.Name,
-			KlusterletNamespace:     klusterletNamespace(managedCluster),
+			KlusterletNamespace:     helpers.GetKlusterletNamespace(managedCluster),
 			BootstrapKubeconfig:     base64.StdEncoding.EncodeToString(bootstrapKubeconfigData),
 			RegistrationImageName:   registrationImageName,
 			WorkImageName:           workImageName,
 			NodeSelector:            nodeSelector,
 			Tolerations:             tolerations,
+			PriorityClassName:       priorityClassName,
 			InstallMode:             string(operatorv1.InstallModeDefault),
 		},
 
@@ -130,7 +141,11 @@ func (w *defaultWorker) generateImportSecret(ctx context.Context, managedCluster
 			// this should not happen, if happened, panic here
 			panic(err)
 		}
-		raw := helpers.MustCreateAssetFromTemplate(file, template, config)
+		// the config includes the values from the managed cluster annotations, so return the error instead of panic
+		raw, err := helpers.CreateAssetFromTemplate(file, template, config)
+		if err != nil {
+			return nil, err
+		}
 		importYAML.WriteString(fmt.Sprintf("%s%s", constants.YamlSperator, string(raw)))
 	}
 
@@ -0,0 +1,252 @@
+// Copyright (c) Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package importconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"testing"
+
+	"github.com/stolostron/managedcluster-import-controller/pkg/constants"
+	"github.com/stolostron/managedcluster-import-controller/pkg/helpers"
+	"github.com/stolostron/managedcluster-import-controller/pkg/helpers/imageregistry"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	configv1 "github.com/openshift/api/config/v1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/clientcmd"
+	certutil "k8s.io/client-go/util/cert"
+
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestGenerateImportSecretWithBootstrapCABundle(t *testing.T) {
+	customCA, _, err := certutil.GenerateSelfSignedCertKey("proxy.example.com", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name       string
+		cluster    *clusterv1.ManagedCluster
+		expectedCA []byte
+		expectErr  bool
+	}{
+		{
+			name: "use the default CA",
+			cluster: &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test",
+				},
+			},
+			expectedCA: []byte("default-ca"),
+		},
+		{
+			name: "override the CA",
+			cluster: &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test",
+					Annotations: map[string]string{
+						constants.BootstrapCABundleAnnotation: base64.StdEncoding.EncodeToString(customCA),
+					},
+				},
+			},
+			expectedCA: customCA,
+		},
+		{
+			name: "the CA is not PEM",
+			cluster: &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test",
+					Annotations: map[string]string{
+						constants.BootstrapCABundleAnnotation: base64.StdEncoding.EncodeToString([]byte("invalid")),
+					},
+				},
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			kubeClient := kubefake.NewSimpleClientset(
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-bootstrap-sa-token-5pw5c",
+						Namespace: "test",
+					},
+					Data: map[string][]byte{
+						"token":  []byte("fake-token"),
+						"ca.crt": []byte("default-ca"),
+					},
+					Type: corev1.SecretTypeServiceAccountToken,
+				},
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      os.Getenv(defaultImagePullSecretEnvVarName),
+						Namespace: os.Getenv(constants.PodNamespaceEnvVarName),
+					},
+					Data: map[string][]byte{
+						corev1.DockerConfigJsonKey: []byte("fake-token"),
+					},
+					Type: corev1.SecretTypeDockerConfigJson,
+				},
+			)
+			worker := &defaultWorker{
+				clientHolder: &helpers.ClientHolder{
+					KubeClient: kubeClient,
+					RuntimeClient: fake.NewClientBuilder().WithScheme(testscheme).WithObjects(
+						&configv1.Infrastructure{
+							ObjectMeta: metav1.ObjectMeta{
+								Name: "cluster",
+							},
+							Status: configv1.InfrastructureStatus{
+								APIServerURL: "https://api.example.com:6443",
+							},
+						},
+					).Build(),
+					ImageRegistryClient: imageregistry.NewClient(kubeClient),
+				},
+			}
+
+			importSecret, err := worker.generateImportSecret(context.TODO(), c.cluster)
+			if c.expectErr {
+				if err == nil {
+					t.Errorf("expected error, but failed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			caData := getBootstrapKubeconfigCA(t, importSecret)
+			if !bytes.Equal(caData, c.expectedCA) {
+				t.Errorf("expected CA %q, but got %q", string(c.expectedCA), string(caData))
+			}
+		})
+	}
+}
+
+func getBootstrapKubeconfigCA(t *testing.T, importSecret *corev1.Secret) []byte {
+	for _, yaml := range helpers.SplitYamls(importSecret.Data[constants.ImportSecretImportYamlKey]) {
+		secret, ok := helpers.MustCreateObject(yaml).(*corev1.Secret)
+		if !ok || secret.Name != "bootstrap-hub-kubeconfig" {
+			continue
+		}
+
+		config, err := clientcmd.Load(secret.Data["kubeconfig"])
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return config.Clusters["default-cluster"].CertificateAuthorityData
+	}
+
+	t.Fatalf("the bootstrap hub kubeconfig secret is not found")
+	return nil
+}
+
+func TestGenerateImportSecretWithPriorityClass(t *testing.T) {
+	cases := []struct {
+		name                      string
+		annotations               map[string]string
+		expectedPriorityClassName string
+		expectErr                 bool
+	}{
+		{
+			name: "no priority class",
+		},
+		{
+			name:                      "set the priority class",
+			annotations:               map[string]string{"open-cluster-management/klusterlet-priority-class": "klusterlet-critical"},
+			expectedPriorityClassName: "klusterlet-critical",
+		},
+		{
+			name:        "invalid priority class name",
+			annotations: map[string]string{"open-cluster-management/klusterlet-priority-class": "Klusterlet_Critical"},
+			expectErr:   true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			kubeClient := kubefake.NewSimpleClientset(
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-bootstrap-sa-token-5pw5c",
+						Namespace: "test",
+					},
+					Data: map[string][]byte{
+						"token":  []byte("fake-token"),
+						"ca.crt": []byte("default-ca"),
+					},
+					Type: corev1.SecretTypeServiceAccountToken,
+				},
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      os.Getenv(defaultImagePullSecretEnvVarName),
+						Namespace: os.Getenv(constants.PodNamespaceEnvVarName),
+					},
+					Data: map[string][]byte{
+						corev1.DockerConfigJsonKey: []byte("fake-token"),
+					},
+					Type: corev1.SecretTypeDockerConfigJson,
+				},
+			)
+			worker := &defaultWorker{
+				clientHolder: &helpers.ClientHolder{
+					KubeClient: kubeClient,
+					RuntimeClient: fake.NewClientBuilder().WithScheme(testscheme).WithObjects(
+						&configv1.Infrastructure{
+							ObjectMeta: metav1.ObjectMeta{
+								Name: "cluster",
+							},
+							Status: configv1.InfrastructureStatus{
+								APIServerURL: "https://api.example.com:6443",
+							},
+						},
+					).Build(),
+					ImageRegistryClient: imageregistry.NewClient(kubeClient),
+				},
+			}
+
+			importSecret, err := worker.generateImportSecret(context.TODO(), &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Annotations: c.annotations},
+			})
+			if c.expectErr {
+				if err == nil {
+					t.Errorf("expected error, but failed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			deployment := getKlusterletDeployment(t, importSecret)
+			if deployment.Spec.Template.Spec.PriorityClassName != c.expectedPriorityClassName {
+				t.Errorf("expected priority class %q, but got %q",
+					c.expectedPriorityClassName, deployment.Spec.Template.Spec.PriorityClassName)
+			}
+		})
+	}
+}
+
+func getKlusterletDeployment(t *testing.T, importSecret *corev1.Secret) *appsv1.Deployment {
+	for _, yaml := range helpers.SplitYamls(importSecret.Data[constants.ImportSecretImportYamlKey]) {
+		deployment, ok := helpers.MustCreateObject(yaml).(*appsv1.Deployment)
+		if ok && deployment.Name == "klusterlet" {
+			return deployment
+		}
+	}
+
+	t.Fatalf("the klusterlet deployment is not found")
+	return nil
+}
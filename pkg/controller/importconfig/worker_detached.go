@@ -29,7 +29,12 @@ func (w *hostedWorker) generateImportSecret(ctx context.Context, managedCluster
 		return nil, err
 	}
 
-	bootstrapKubeconfigData, err := createKubeconfigData(ctx, w.clientHolder, bootStrapSecret)
+	caBundle, err := helpers.GetBootstrapCABundle(managedCluster)
+	if err != nil {
+		return nil, err
+	}
+
+	bootstrapKubeconfigData, err := createKubeconfigData(ctx, w.clientHolder, bootStrapSecret, caBundle)
 	if err != nil {
 		return nil, err
 	}
@@ -44,19 +49,19 @@ func (w *hostedWorker) generateImportSecret(ctx context.Context, managedCluster
 		return nil, err
 	}
 
-	nodeSelector, err := helpers.GetNodeSelector(managedCluster)
+	nodeSelector, err := helpers.GetNodeSelectorWithDefaults(managedCluster)
 	if err != nil {
 		return nil, err
 	}
 
-	tolerations, err := helpers.GetTolerations(managedCluster)
+	tolerations, err := helpers.GetTolerationsWithDefaults(managedCluster)
 	if err != nil {
 		return nil, err
 	}
 
 	config := KlusterletRenderConfig{
 		ManagedClusterNamespace: managedCluster.Name,
-		KlusterletNamespace:     klusterletNamespace(managedCluster),
+		KlusterletNamespace:     helpers.GetKlusterletNamespace(managedCluster),
 		BootstrapKubeconfig:     base64.StdEncoding.EncodeToString(bootstrapKubeconfigData),
 		RegistrationImageName:   registrationImageName,
 		WorkImageName:           workImageName,
@@ -73,7 +78,11 @@ func (w *hostedWorker) generateImportSecret(ctx context.Context, managedCluster
 			// this should not happen, if happened, panic here
 			panic(err)
 		}
-		raw := helpers.MustCreateAssetFromTemplate(file, template, config)
+		// the config includes the values from the managed cluster annotations, so return the error instead of panic
+		raw, err := helpers.CreateAssetFromTemplate(file, template, config)
+		if err != nil {
+			return nil, err
+		}
 		importYAML.WriteString(fmt.Sprintf("%s%s", constants.YamlSperator, string(raw)))
 	}
 
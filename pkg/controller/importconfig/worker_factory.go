@@ -49,5 +49,6 @@ type KlusterletRenderConfig struct {
 	WorkImageName           string
 	NodeSelector            map[string]string
 	Tolerations             []corev1.Toleration
+	PriorityClassName       string
 	InstallMode             string
 }
@@ -22,6 +22,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
@@ -46,8 +47,9 @@ var log = logf.Log.WithName(controllerName)
 
 // ReconcileManagedCluster reconciles a ManagedCluster object
 type ReconcileManagedCluster struct {
-	client   client.Client
-	recorder events.Recorder
+	client     client.Client
+	kubeClient kubernetes.Interface
+	recorder   events.Recorder
 }
 
 // blank assignment to verify that ReconcileManagedCluster implements reconcile.Reconciler
@@ -67,7 +69,8 @@ func (r *ReconcileManagedCluster) Reconcile(ctx context.Context, request reconci
 	managedCluster := &clusterv1.ManagedCluster{}
 	err := r.client.Get(ctx, types.NamespacedName{Name: request.Name}, managedCluster)
 	if errors.IsNotFound(err) {
-		// the managed cluster could have been deleted, do nothing
+		// the managed cluster could have been deleted or not be created yet, e.g. the auto-import-secret is created
+		// before the managed cluster, do nothing
 		return reconcile.Result{}, nil
 	}
 	if err != nil {
@@ -124,6 +127,11 @@ func (r *ReconcileManagedCluster) Reconcile(ctx context.Context, request reconci
 		return reconcile.Result{}, err
 	}
 
+	// the namespace may be kept, ensure the import secrets of the managed cluster are removed
+	if err = helpers.DeleteImportSecrets(ctx, r.kubeClient, r.recorder, managedCluster.Name); err != nil {
+		return reconcile.Result{}, err
+	}
+
 	return reconcile.Result{}, helpers.RemoveManagedClusterFinalizer(ctx, r.client, r.recorder, managedCluster, constants.ImportFinalizer)
 }
 
@@ -19,6 +19,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	kubefake "k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/kubernetes/scheme"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -279,8 +280,9 @@ func TestReconcile(t *testing.T) {
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
 			r := &ReconcileManagedCluster{
-				client:   fake.NewClientBuilder().WithScheme(testscheme).WithObjects(c.startObjs...).Build(),
-				recorder: eventstesting.NewTestingEventRecorder(t),
+				client:     fake.NewClientBuilder().WithScheme(testscheme).WithObjects(c.startObjs...).Build(),
+				kubeClient: kubefake.NewSimpleClientset(),
+				recorder:   eventstesting.NewTestingEventRecorder(t),
 			}
 
 			_, err := r.Reconcile(context.TODO(), c.request)
@@ -292,3 +294,71 @@ func TestReconcile(t *testing.T) {
 		})
 	}
 }
+
+func TestReconcileImportSecrets(t *testing.T) {
+	now := metav1.Now()
+
+	cases := []struct {
+		name            string
+		startObjs       []client.Object
+		expectedSecrets int
+	}{
+		{
+			name:            "the managed cluster does not exist yet",
+			startObjs:       []client.Object{},
+			expectedSecrets: 2,
+		},
+		{
+			name: "the managed cluster is deleting",
+			startObjs: []client.Object{
+				&clusterv1.ManagedCluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:              "test",
+						Finalizers:        []string{constants.ImportFinalizer},
+						DeletionTimestamp: &now,
+					},
+				},
+			},
+			expectedSecrets: 0,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			kubeClient := kubefake.NewSimpleClientset(
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-import",
+						Namespace: "test",
+					},
+				},
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      constants.AutoImportSecretName,
+						Namespace: "test",
+					},
+				},
+			)
+
+			r := &ReconcileManagedCluster{
+				client:     fake.NewClientBuilder().WithScheme(testscheme).WithObjects(c.startObjs...).Build(),
+				kubeClient: kubeClient,
+				recorder:   eventstesting.NewTestingEventRecorder(t),
+			}
+
+			if _, err := r.Reconcile(context.TODO(), reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: "test"},
+			}); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			secrets, err := kubeClient.CoreV1().Secrets("test").List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if len(secrets.Items) != c.expectedSecrets {
+				t.Errorf("expected %d secrets, but got %d", c.expectedSecrets, len(secrets.Items))
+			}
+		})
+	}
+}
@@ -32,8 +32,9 @@ func Add(mgr manager.Manager, clientHolder *helpers.ClientHolder,
 // newReconciler returns a new reconcile.Reconciler
 func newReconciler(clientHolder *helpers.ClientHolder) reconcile.Reconciler {
 	return &ReconcileManagedCluster{
-		client:   clientHolder.RuntimeClient,
-		recorder: helpers.NewEventRecorder(clientHolder.KubeClient, controllerName),
+		client:     clientHolder.RuntimeClient,
+		kubeClient: clientHolder.KubeClient,
+		recorder:   helpers.NewEventRecorder(clientHolder.KubeClient, controllerName),
 	}
 }
 
@@ -41,7 +42,7 @@ func newReconciler(clientHolder *helpers.ClientHolder) reconcile.Reconciler {
 func add(mgr manager.Manager, r reconcile.Reconciler) error {
 	c, err := controller.New(controllerName, mgr, controller.Options{
 		Reconciler:              r,
-		MaxConcurrentReconciles: helpers.GetMaxConcurrentReconciles(),
+		MaxConcurrentReconciles: helpers.GetMaxConcurrentReconcilesFor(controllerName),
 	})
 	if err != nil {
 		return err
@@ -4,6 +4,7 @@
 package manifestwork
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/stolostron/managedcluster-import-controller/pkg/constants"
@@ -42,6 +43,8 @@ func newReconciler(mgr manager.Manager, clientHolder *helpers.ClientHolder) reco
 		clientHolder: clientHolder,
 		scheme:       mgr.GetScheme(),
 		recorder:     helpers.NewEventRecorder(clientHolder.KubeClient, controllerName),
+
+		clusterUnavailableGracePeriod: helpers.GetClusterUnavailableGracePeriod(),
 	}
 }
 
@@ -49,7 +52,7 @@ func newReconciler(mgr manager.Manager, clientHolder *helpers.ClientHolder) reco
 func add(importSecretInformer cache.SharedIndexInformer, mgr manager.Manager, r reconcile.Reconciler) error {
 	c, err := controller.New(controllerName, mgr, controller.Options{
 		Reconciler:              r,
-		MaxConcurrentReconciles: helpers.GetMaxConcurrentReconciles(),
+		MaxConcurrentReconciles: helpers.GetMaxConcurrentReconcilesFor(controllerName),
 	})
 	if err != nil {
 		return err
@@ -96,32 +99,24 @@ func add(importSecretInformer cache.SharedIndexInformer, mgr manager.Manager, r
 	if err := c.Watch(
 		&runtimesource.Kind{Type: &clusterv1.ManagedCluster{}},
 		&handler.EnqueueRequestForObject{},
-		predicate.Predicate(predicate.Funcs{
-			GenericFunc: func(e event.GenericEvent) bool { return isDefaultModeObject(e.Object) },
-			DeleteFunc:  func(e event.DeleteEvent) bool { return isDefaultModeObject(e.Object) },
-			CreateFunc:  func(e event.CreateEvent) bool { return isDefaultModeObject(e.Object) },
-			UpdateFunc:  func(e event.UpdateEvent) bool { return isDefaultModeObject(e.ObjectNew) },
-		})); err != nil {
+		managedClusterPredicate,
+	); err != nil {
+		return err
+	}
+
+	// a safety net for the missed events, all of the managed clusters are reconciled periodically
+	if err := c.Watch(
+		source.NewManagedClusterResyncSource(mgr.GetClient(), helpers.GetManifestWorkResyncPeriod()),
+		&handler.EnqueueRequestForObject{},
+		managedClusterPredicate,
+	); err != nil {
 		return err
 	}
 
 	if err := c.Watch(
 		source.NewImportSecretSource(importSecretInformer),
 		&source.ManagedClusterSecretEventHandler{},
-		predicate.Predicate(predicate.Funcs{
-			GenericFunc: func(e event.GenericEvent) bool { return false },
-			DeleteFunc:  func(e event.DeleteEvent) bool { return false },
-			CreateFunc:  func(e event.CreateEvent) bool { return true },
-			UpdateFunc: func(e event.UpdateEvent) bool {
-				new, okNew := e.ObjectNew.(*corev1.Secret)
-				old, okOld := e.ObjectOld.(*corev1.Secret)
-				if okNew && okOld {
-					return !equality.Semantic.DeepEqual(old.Data, new.Data)
-				}
-
-				return false
-			},
-		}),
+		importSecretPredicate,
 	); err != nil {
 		return err
 	}
@@ -129,6 +124,54 @@ func add(importSecretInformer cache.SharedIndexInformer, mgr manager.Manager, r
 	return nil
 }
 
+// importSecretPredicate filters the import secret events, the deletion of an import secret enqueues the managed
+// cluster to wait for the import secret to be regenerated by the importconfig controller, the managed cluster is
+// reconciled again by the creation of the regenerated import secret
+var importSecretPredicate = predicate.Predicate(predicate.Funcs{
+	GenericFunc: func(e event.GenericEvent) bool { return false },
+	DeleteFunc:  func(e event.DeleteEvent) bool { return isImportSecret(e.Object) },
+	CreateFunc:  func(e event.CreateEvent) bool { return true },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		new, okNew := e.ObjectNew.(*corev1.Secret)
+		old, okOld := e.ObjectOld.(*corev1.Secret)
+		if okNew && okOld {
+			return !equality.Semantic.DeepEqual(old.Data, new.Data)
+		}
+
+		return false
+	},
+})
+
+// isImportSecret returns true if the object is the import secret of the managed cluster of its namespace
+func isImportSecret(object client.Object) bool {
+	return object.GetName() == fmt.Sprintf("%s-%s", object.GetNamespace(), constants.ImportSecretNameSuffix)
+}
+
+// managedClusterPredicate filters the default mode managed clusters, the deleting managed clusters that do not have
+// the manifest work finalizer are skipped, because there are no manifest works for this controller to clean up
+var managedClusterPredicate = predicate.Predicate(predicate.Funcs{
+	GenericFunc: func(e event.GenericEvent) bool { return isDefaultModeObject(e.Object) },
+	DeleteFunc: func(e event.DeleteEvent) bool {
+		return isDefaultModeObject(e.Object) && hasManifestWorkFinalizer(e.Object)
+	},
+	CreateFunc: func(e event.CreateEvent) bool { return isDefaultModeObject(e.Object) },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		if !isDefaultModeObject(e.ObjectNew) {
+			return false
+		}
+		return e.ObjectNew.GetDeletionTimestamp().IsZero() || hasManifestWorkFinalizer(e.ObjectNew)
+	},
+})
+
+func hasManifestWorkFinalizer(object client.Object) bool {
+	for _, finalizer := range object.GetFinalizers() {
+		if finalizer == constants.ManifestWorkFinalizer {
+			return true
+		}
+	}
+	return false
+}
+
 func isDefaultModeObject(object client.Object) bool {
 	return !strings.EqualFold(object.GetAnnotations()[constants.KlusterletDeployModeAnnotation], constants.KlusterletDeployModeHosted)
 }
@@ -5,6 +5,7 @@ package manifestwork
 
 import (
 	"context"
+	goerrors "errors"
 	"fmt"
 	"strings"
 	"time"
@@ -14,26 +15,51 @@ import (
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
 	workv1 "open-cluster-management.io/api/work/v1"
 
-	"github.com/ghodss/yaml"
+	"github.com/go-logr/logr"
 	"github.com/openshift/library-go/pkg/operator/events"
 	operatorhelpers "github.com/openshift/library-go/pkg/operator/v1helpers"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
-var log = logf.Log.WithName(controllerName)
+// klusterletWorkDeletionPollInterval and klusterletWorkDeletionTimeout bound the wait for the klusterlet manifest work
+// removal in one reconcile
+var (
+	klusterletWorkDeletionPollInterval = 1 * time.Second
+	klusterletWorkDeletionTimeout      = 5 * time.Second
+)
+
+// klusterletCRDsWorkDeletionBlockedThreshold is the period that the klusterlet crds manifest work can be terminating
+// after it is force deleted, if the manifest work still exists after this period, its deletion is reported as blocked
+const klusterletCRDsWorkDeletionBlockedThreshold = 5 * time.Minute
+
+// clusterNamespaceRequeuePeriod is the period to recheck the managed cluster namespace if it is missing or terminating
+const clusterNamespaceRequeuePeriod = 10 * time.Second
+
+// bootstrapHubServerRequeuePeriod is the period to recheck the import secret if its bootstrap hub server is not the
+// current hub
+const bootstrapHubServerRequeuePeriod = 1 * time.Minute
+
+// klusterletDeploymentName is the name of the klusterlet operator deployment on the managed cluster
+const klusterletDeploymentName = "klusterlet"
 
 // ReconcileManifestWork reconciles the ManagedClusters of the ManifestWorks object
 type ReconcileManifestWork struct {
 	clientHolder *helpers.ClientHolder
 	scheme       *runtime.Scheme
 	recorder     events.Recorder
+
+	// clusterUnavailableGracePeriod is the period that a cluster must be unavailable for before
+	// its manifest works are force deleted
+	clusterUnavailableGracePeriod time.Duration
 }
 
 // blank assignment to verify that ReconcileManifestWork implements reconcile.Reconciler
@@ -48,7 +74,8 @@ var _ reconcile.Reconciler = &ReconcileManifestWork{}
 // Note: The Controller will requeue the Request to be processed again if the returned error is non-nil or
 // Result.Requeue is true, otherwise upon completion it will remove the work from the queue.
 func (r *ReconcileManifestWork) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
-	reqLogger := log.WithValues("Request.Name", request.Name)
+	// the logger of the reconcile context has the controller name and the request
+	reqLogger := logf.FromContext(ctx, "Request.Name", request.Name)
 	reqLogger.Info("Reconciling the manifest works of the managed cluster")
 
 	managedClusterName := request.Name
@@ -63,7 +90,9 @@ func (r *ReconcileManifestWork) Reconcile(ctx context.Context, request reconcile
 		return reconcile.Result{}, err
 	}
 
-	if helpers.DetermineKlusterletMode(managedCluster) != constants.KlusterletDeployModeDefault {
+	mode := helpers.DetermineKlusterletMode(managedCluster)
+	reqLogger = reqLogger.WithValues("cluster", managedClusterName, "mode", mode)
+	if mode != constants.KlusterletDeployModeDefault {
 		return reconcile.Result{}, nil
 	}
 
@@ -72,15 +101,48 @@ func (r *ReconcileManifestWork) Reconcile(ctx context.Context, request reconcile
 	if err := r.clientHolder.RuntimeClient.List(ctx, manifestWorks, listOpts); err != nil {
 		return reconcile.Result{}, err
 	}
+	reqLogger = reqLogger.WithValues("pendingWorks", len(manifestWorks.Items))
 
-	if err := helpers.AssertManifestWorkFinalizer(ctx, r.clientHolder.RuntimeClient, r.recorder,
-		managedCluster, len(manifestWorks.Items)); err != nil {
+	if err := helpers.AssertManifestWorkFinalizer(ctx, r.clientHolder.RuntimeClient, r.recorder, managedCluster,
+		helpers.NamespacedManifestWorksCounter(r.clientHolder.RuntimeClient, managedClusterName)); err != nil {
 		return reconcile.Result{}, err
 	}
 
 	if !managedCluster.DeletionTimestamp.IsZero() {
 		// the managed cluster is deleting, delete its addons and manifestworks
-		return r.deleteAddonsAndWorks(ctx, managedCluster, manifestWorks.Items)
+		reqLogger = reqLogger.WithValues("phase", "deleting")
+		reqLogger.Info("Deleting the addons and manifest works of the managed cluster")
+		return r.deleteAddonsAndWorks(ctx, reqLogger, managedCluster, manifestWorks.Items)
+	}
+
+	if isDetaching(managedCluster) {
+		reqLogger = reqLogger.WithValues("phase", "detaching")
+		if len(manifestWorks.Items) == 0 {
+			// the addons and manifest works are cleaned up, the managed cluster is detached
+			return reconcile.Result{}, r.removeDetachingAnnotation(ctx, managedCluster)
+		}
+
+		reqLogger.Info("Detaching the managed cluster, delete its addons and manifest works")
+		return r.deleteAddonsAndWorks(ctx, reqLogger, managedCluster, manifestWorks.Items)
+	}
+	reqLogger = reqLogger.WithValues("phase", "importing")
+
+	// the manifest works cannot be applied if the managed cluster namespace is missing or terminating, wait for the
+	// namespace to be (re)created
+	err = helpers.ValidateClusterNamespace(ctx, r.clientHolder.KubeClient, managedClusterName)
+	if goerrors.Is(err, helpers.ErrNamespaceNotFound) || goerrors.Is(err, helpers.ErrNamespaceTerminating) {
+		reqLogger.Info("Waiting for the managed cluster namespace to be active", "reason", err.Error())
+		return reconcile.Result{RequeueAfter: clusterNamespaceRequeuePeriod}, nil
+	}
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// the klusterlet manifest works could be orphaned if they are created before the managed cluster is accepted,
+	// wait for the managed cluster to be accepted, the managed cluster is reconciled again once it is updated
+	if !helpers.IsManagedClusterAccepted(managedCluster) {
+		reqLogger.Info("Waiting for the managed cluster to be accepted")
+		return reconcile.Result{}, nil
 	}
 
 	// apply klusterlet manifest works from import secret
@@ -90,6 +152,9 @@ func (r *ReconcileManifestWork) Reconcile(ctx context.Context, request reconcile
 	importSecretName := fmt.Sprintf("%s-%s", managedClusterName, constants.ImportSecretNameSuffix)
 	importSecret, err := r.clientHolder.KubeClient.CoreV1().Secrets(managedClusterName).Get(ctx, importSecretName, metav1.GetOptions{})
 	if errors.IsNotFound(err) {
+		// the import secret may be deleted unexpectedly, it is regenerated by the importconfig controller, the managed
+		// cluster is reconciled again once the import secret is created
+		reqLogger.Info("Waiting for the import secret to be regenerated", "importSecret", importSecretName)
 		return reconcile.Result{}, nil
 	}
 	if err != nil {
@@ -100,33 +165,141 @@ func (r *ReconcileManifestWork) Reconcile(ctx context.Context, request reconcile
 		return reconcile.Result{}, err
 	}
 
-	if err := helpers.ApplyResources(
+	// the klusterlet would connect to a wrong hub if the import secret is restored from the backup of another hub,
+	// wait for the import secret to be regenerated with the current hub
+	err = r.validateBootstrapHubServer(ctx, importSecret)
+	if goerrors.Is(err, helpers.ErrHubServerMismatch) {
+		reqLogger.Info("Waiting for the import secret to be regenerated", "reason", err.Error())
+		return reconcile.Result{RequeueAfter: bootstrapHubServerRequeuePeriod}, nil
+	}
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	crdsWork, err := createKlusterletCRDsManifestWork(managedCluster, importSecret)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	klusterletWork, err := createKlusterletManifestWork(managedCluster, importSecret)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// add the additional labels and annotations to the klusterlet manifest works
+	helpers.MergeManifestWorkMetadata(crdsWork)
+	helpers.MergeManifestWorkMetadata(klusterletWork)
+
+	// the manifest works that are not changed are skipped by the apply, the klusterlet images drift is reported
+	// before it is reverted
+	for _, work := range []*workv1.ManifestWork{crdsWork, klusterletWork} {
+		existing := findManifestWork(manifestWorks.Items, work.Name)
+		if existing == nil {
+			continue
+		}
+		drifted, err := helpers.KlusterletImagesDrifted(existing, work)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if drifted {
+			reqLogger.Info("The klusterlet images are drifted from the import secret, re-apply the manifest work",
+				"manifestWork", work.Name)
+		}
+	}
+
+	results, err := helpers.ApplyResourcesWithOptions(
 		r.clientHolder,
 		r.recorder,
 		r.scheme,
 		managedCluster,
-		createKlusterletCRDsManifestWork(managedCluster, importSecret),
-		createKlusterletManifestWork(managedCluster, importSecret),
-	); err != nil {
+		helpers.ApplyOptions{},
+		crdsWork,
+		klusterletWork,
+	)
+	if err != nil {
 		return reconcile.Result{}, err
 	}
 
-	return reconcile.Result{}, nil
+	if applied(results) {
+		reqLogger.Info("Applied klusterlet manifest works", applySummary(results)...)
+	}
+
+	if helpers.IsImportManifestsDebugEnabled() {
+		// present the import manifests in a ConfigMap for debugging, the ConfigMap is only updated when the hash of
+		// the import secret is changed
+		if err := r.applyImportManifestsConfigMap(managedCluster, importSecret); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	// the klusterlet manifest works may be left in the hosting cluster namespace after the klusterlet mode is changed
+	conflict, err := helpers.DetectKlusterletWorksConflict(ctx, r.clientHolder.RuntimeClient, managedCluster)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if err := helpers.CleanUpStaleKlusterletWorks(ctx, r.clientHolder.RuntimeClient, r.recorder, conflict); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// mirror the status of the klusterlet manifest work to the managed cluster
+	return reconcile.Result{}, helpers.UpdateManagedClusterStatusConditions(r.clientHolder.RuntimeClient, r.recorder,
+		managedClusterName,
+		helpers.KlusterletAppliedCondition(findManifestWork(manifestWorks.Items, klusterletWork.Name)),
+		helpers.KlusterletWorksConflictCondition(conflict))
+}
+
+func findManifestWork(works []workv1.ManifestWork, name string) *workv1.ManifestWork {
+	for i := range works {
+		if works[i].Name == name {
+			return &works[i]
+		}
+	}
+	return nil
+}
+
+// applied returns true if any of the objects is created or updated
+func applied(results []helpers.ApplyResult) bool {
+	for _, result := range results {
+		if result.Action != helpers.ApplyActionUnchanged {
+			return true
+		}
+	}
+	return false
+}
+
+// applySummary summarizes the names of the applied objects by their apply actions
+func applySummary(results []helpers.ApplyResult) []interface{} {
+	summary := map[helpers.ApplyAction][]string{}
+	for _, result := range results {
+		if accessor, err := meta.Accessor(result.Object); err == nil {
+			summary[result.Action] = append(summary[result.Action], accessor.GetName())
+		}
+	}
+
+	return []interface{}{
+		string(helpers.ApplyActionCreated), summary[helpers.ApplyActionCreated],
+		string(helpers.ApplyActionUpdated), summary[helpers.ApplyActionUpdated],
+		string(helpers.ApplyActionUnchanged), summary[helpers.ApplyActionUnchanged],
+	}
 }
 
 func (r *ReconcileManifestWork) deleteAddonsAndWorks(
-	ctx context.Context, cluster *clusterv1.ManagedCluster, works []workv1.ManifestWork) (
+	ctx context.Context, reqLogger logr.Logger, cluster *clusterv1.ManagedCluster, works []workv1.ManifestWork) (
 	reconcile.Result, error) {
 	errs := make([]error, 0)
 
-	err := helpers.DeleteManagedClusterAddons(ctx, r.clientHolder.RuntimeClient, r.recorder, cluster)
+	// the deletion helpers report the resources that they delete to the detach report
+	report := helpers.NewDetachReport(r.recorder)
+	defer report.Summarize(reqLogger, cluster.Name)
+
+	err := helpers.DeleteManagedClusterAddons(ctx, r.clientHolder.RuntimeClient, report, cluster)
 	if err != nil {
 		// continue to delete manifestworks
 		errs = append(errs, err)
 	}
 
 	// the managed cluster is deleting, delete its manifestworks
-	result, err := r.deleteManifestWorks(ctx, cluster, works)
+	result, err := r.deleteManifestWorks(ctx, reqLogger, report, cluster, works)
 	if err != nil {
 		errs = append(errs, err)
 	}
@@ -134,9 +307,10 @@ func (r *ReconcileManifestWork) deleteAddonsAndWorks(
 }
 
 // deleteManifestWorks deletes manifest works when a managed cluster is deleting
-// If the managed cluster is unavailable, we will force delete all manifest works
-// If the managed cluster is available, we will
-//   1. delete the manifest work with the postpone-delete annotation until 10 min after the cluster is deleted.
+// If the managed cluster is unavailable for the grace period, we will force delete all manifest works
+// If the managed cluster is available or is unavailable within the grace period, we will
+//   1. delete the manifest work with the postpone-delete annotation until its postpone time (10 min by default)
+//      after the cluster is deleted, a detaching cluster is not deleted, so its manifest works are not postponed.
 //   2. delete the manifest works that do not include klusterlet works and klusterlet addon works
 //   3. delete the klusterlet manifest work, the delete option of the klusterlet manifest work
 //      is orphan, so we can delete it safely
@@ -147,6 +321,8 @@ func (r *ReconcileManifestWork) deleteAddonsAndWorks(
 //      clean up the klusterlet on the managed cluster
 func (r *ReconcileManifestWork) deleteManifestWorks(
 	ctx context.Context,
+	reqLogger logr.Logger,
+	recorder events.Recorder,
 	cluster *clusterv1.ManagedCluster,
 	works []workv1.ManifestWork) (
 	reconcile.Result, error) {
@@ -154,11 +330,34 @@ func (r *ReconcileManifestWork) deleteManifestWorks(
 		return reconcile.Result{}, nil
 	}
 
-	if helpers.IsClusterUnavailable(cluster) {
+	if helpers.IsClusterUnavailableFor(cluster, r.clusterUnavailableGracePeriod) {
 		// the managed cluster is offline, force delete all manifest works
-		return reconcile.Result{}, helpers.ForceDeleteAllManifestWorks(ctx, r.clientHolder.RuntimeClient, r.recorder, works)
+		reqLogger.Info("The managed cluster is unavailable, force delete its manifest works")
+		return reconcile.Result{}, helpers.ForceDeleteAllManifestWorks(ctx, r.clientHolder.RuntimeClient, recorder, works)
+	}
+
+	result, err := r.gracefullyDeleteManifestWorks(ctx, reqLogger, recorder, cluster, works)
+	if err != nil {
+		return result, err
 	}
 
+	if helpers.IsClusterUnavailable(cluster) && result.RequeueAfter == 0 {
+		// the managed cluster is unavailable recently, recheck it after the grace period, the manifest works
+		// will be force deleted if the cluster is still unavailable at that time
+		availableCondition := meta.FindStatusCondition(cluster.Status.Conditions, clusterv1.ManagedClusterConditionAvailable)
+		result.RequeueAfter = r.clusterUnavailableGracePeriod - time.Since(availableCondition.LastTransitionTime.Time)
+	}
+
+	return result, nil
+}
+
+func (r *ReconcileManifestWork) gracefullyDeleteManifestWorks(
+	ctx context.Context,
+	reqLogger logr.Logger,
+	recorder events.Recorder,
+	cluster *clusterv1.ManagedCluster,
+	works []workv1.ManifestWork) (
+	reconcile.Result, error) {
 	// delete works that do not include klusterlet works and klusterlet addon works, the addon works were removed
 	// above, we need to wait them to be deleted.
 	//
@@ -166,39 +365,38 @@ func (r *ReconcileManifestWork) deleteManifestWorks(
 	ignoreKlusterletAndAddons := func(clusterName string, manifestWork workv1.ManifestWork) bool {
 		manifestWorkName := manifestWork.GetName()
 		switch {
-		case manifestWorkName == fmt.Sprintf("%s-%s", clusterName, constants.KlusterletSuffix):
-		case manifestWorkName == fmt.Sprintf("%s-%s", clusterName, constants.KlusterletCRDsSuffix):
-		case manifestWorkName == fmt.Sprintf("%s-%s", clusterName, constants.HostedKlusterletManifestworkSuffix):
-		case manifestWorkName == fmt.Sprintf("%s-%s", clusterName, constants.HostedManagedKubeconfigManifestworkSuffix):
-		case strings.HasPrefix(manifestWorkName, fmt.Sprintf("%s-klusterlet-addon", manifestWork.GetNamespace())):
-		case strings.HasPrefix(manifestWorkName, "addon-") && strings.HasSuffix(manifestWork.GetName(), "-deploy"):
-		case strings.HasPrefix(manifestWorkName, "addon-") && strings.HasSuffix(manifestWork.GetName(), "-pre-delete"):
+		case manifestWorkName == helpers.KlusterletWorkName(clusterName):
+		case manifestWorkName == helpers.KlusterletCRDsWorkName(clusterName):
+		case manifestWorkName == helpers.HostedKlusterletWorkName(clusterName):
+		case manifestWorkName == helpers.HostedManagedKubeconfigWorkName(clusterName):
+		case helpers.IsAddonManifestWork(manifestWork):
 		default:
 			return false
 		}
 		return true
 	}
-	err := helpers.DeleteManifestWorkWithSelector(ctx, r.clientHolder.RuntimeClient, r.recorder, cluster, works, ignoreKlusterletAndAddons)
+	err := helpers.DeleteManifestWorkWithSelector(ctx, r.clientHolder.RuntimeClient, recorder, cluster, works, ignoreKlusterletAndAddons)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
 
-	noAddons, err := helpers.NoManagedClusterAddons(ctx, r.clientHolder.RuntimeClient, cluster.GetName())
+	addonsCleaned, err := helpers.AddonsCleaned(ctx, r.clientHolder.RuntimeClient, cluster.GetName())
 	if err != nil {
 		return reconcile.Result{}, err
 	}
-	if !noAddons {
-		// wait for addons deletion
+	if !addonsCleaned {
+		// wait for addons and their manifest works deletion
+		reqLogger.Info("Waiting for the addons of the managed cluster to be cleaned")
 		return reconcile.Result{RequeueAfter: 10 * time.Second}, nil
 	}
 
 	// check whether there are only klusterlet manifestworks
 	ignoreKlusterlet := func(clusterName string, manifestWork workv1.ManifestWork) bool {
-		return manifestWork.GetName() == fmt.Sprintf("%s-%s", clusterName, constants.KlusterletSuffix) ||
-			manifestWork.GetName() == fmt.Sprintf("%s-%s", clusterName, constants.KlusterletCRDsSuffix)
+		return manifestWork.GetName() == helpers.KlusterletWorkName(clusterName) ||
+			manifestWork.GetName() == helpers.KlusterletCRDsWorkName(clusterName)
 	}
 	noPendingManifestWorks, err := helpers.NoPendingManifestWorks(
-		ctx, r.clientHolder.RuntimeClient, log, cluster.GetName(), ignoreKlusterlet)
+		ctx, r.clientHolder.RuntimeClient, reqLogger, cluster.GetName(), ignoreKlusterlet)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
@@ -208,13 +406,12 @@ func (r *ReconcileManifestWork) deleteManifestWorks(
 	}
 
 	// only have klusterlet manifest works, delete klusterlet manifest works
-	klusterletName := fmt.Sprintf("%s-%s", cluster.Name, constants.KlusterletSuffix)
+	klusterletName := helpers.KlusterletWorkName(cluster.Name)
 	klusterletWork := &workv1.ManifestWork{}
 	err = r.clientHolder.RuntimeClient.Get(ctx, types.NamespacedName{Namespace: cluster.Name, Name: klusterletName}, klusterletWork)
 	if errors.IsNotFound(err) {
 		// the klusterlet work could be deleted, ensure the klusterlet crds work is deleted
-		return reconcile.Result{}, helpers.ForceDeleteManifestWork(ctx, r.clientHolder.RuntimeClient, r.recorder,
-			cluster.Name, fmt.Sprintf("%s-%s", cluster.Name, constants.KlusterletCRDsSuffix))
+		return r.deleteKlusterletCRDsWork(ctx, reqLogger, recorder, cluster)
 	}
 	if err != nil {
 		return reconcile.Result{}, err
@@ -224,28 +421,112 @@ func (r *ReconcileManifestWork) deleteManifestWorks(
 	// but the klusterlet works is not applied, in this time, user delete the cluster, this will cause that the
 	// klusterlet cannot be deleted from the mangaed cluser, we need user to handle this manually
 
-	return reconcile.Result{}, helpers.DeleteManifestWork(
-		ctx, r.clientHolder.RuntimeClient, r.recorder, klusterletWork.Namespace, klusterletWork.Name)
+	err = helpers.DeleteManifestWorkAndWait(ctx, r.clientHolder.RuntimeClient, recorder,
+		klusterletWork.Namespace, klusterletWork.Name, klusterletWorkDeletionPollInterval, klusterletWorkDeletionTimeout)
+	if goerrors.Is(err, wait.ErrWaitTimeout) {
+		// the klusterlet work is still deleting, check it later
+		return reconcile.Result{RequeueAfter: klusterletWorkDeletionTimeout}, nil
+	}
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// the klusterlet work is removed, delete the klusterlet crds work
+	return r.deleteKlusterletCRDsWork(ctx, reqLogger, recorder, cluster)
 }
 
-func createKlusterletCRDsManifestWork(managedCluster *clusterv1.ManagedCluster, importSecret *corev1.Secret) *workv1.ManifestWork {
-	crdsKey := constants.ImportSecretCRDSV1YamlKey
-	if managedCluster.Status.Version.Kubernetes != "" &&
-		!helpers.IsAPIExtensionV1Supported(managedCluster.Status.Version.Kubernetes) {
-		log.Info("crd v1 is not supported, put v1beta1 to manifest work")
-		crdsKey = constants.ImportSecretCRDSV1beta1YamlKey
+// deleteKlusterletCRDsWork force deletes the klusterlet crds manifest work. If the manifest work is still terminating
+// after the klusterletCRDsWorkDeletionBlockedThreshold, e.g. its finalizers are added back by another controller, a
+// warning event is reported and the deletion is rechecked after the threshold rather than retried silently.
+func (r *ReconcileManifestWork) deleteKlusterletCRDsWork(
+	ctx context.Context,
+	reqLogger logr.Logger,
+	recorder events.Recorder,
+	cluster *clusterv1.ManagedCluster) (reconcile.Result, error) {
+	crdsWorkName := helpers.KlusterletCRDsWorkName(cluster.Name)
+	if err := helpers.ForceDeleteManifestWork(ctx, r.clientHolder.RuntimeClient, recorder,
+		cluster.Name, crdsWorkName); err != nil {
+		return reconcile.Result{}, err
 	}
 
-	crdYaml := importSecret.Data[crdsKey]
-	jsonData, err := yaml.YAMLToJSON(crdYaml)
+	crdsWork := &workv1.ManifestWork{}
+	err := r.clientHolder.RuntimeClient.Get(ctx, types.NamespacedName{Namespace: cluster.Name, Name: crdsWorkName}, crdsWork)
+	if errors.IsNotFound(err) {
+		return reconcile.Result{}, nil
+	}
 	if err != nil {
-		panic(err)
+		return reconcile.Result{}, err
+	}
+
+	if helpers.IsManifestWorkDeletionBlocked(crdsWork, klusterletCRDsWorkDeletionBlockedThreshold) {
+		reqLogger.Info("The deletion of the klusterlet crds manifest work is blocked", "finalizers", crdsWork.Finalizers)
+		recorder.Warningf("ManifestWorkDeletionBlocked",
+			"The manifest work %s/%s is terminating for more than %v, it is blocked by the finalizers %v",
+			crdsWork.Namespace, crdsWork.Name, klusterletCRDsWorkDeletionBlockedThreshold, crdsWork.Finalizers)
+		return reconcile.Result{RequeueAfter: klusterletCRDsWorkDeletionBlockedThreshold}, nil
+	}
+
+	return reconcile.Result{}, nil
+}
+
+// validateBootstrapHubServer validates the bootstrap hub server of the import secret is the external server of the
+// hub, the validation is skipped if the external server of the hub is unknown, e.g. the hub is not an ocp cluster
+func (r *ReconcileManifestWork) validateBootstrapHubServer(ctx context.Context, importSecret *corev1.Secret) error {
+	hubServer, err := helpers.GetKubeAPIServerAddress(ctx, r.clientHolder.RuntimeClient)
+	if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(hubServer) == 0 {
+		return nil
+	}
+
+	return helpers.ValidateBootstrapHubServer(importSecret, hubServer)
+}
+
+func (r *ReconcileManifestWork) applyImportManifestsConfigMap(
+	managedCluster *clusterv1.ManagedCluster, importSecret *corev1.Secret) error {
+	configMap, err := helpers.BuildImportManifestsConfigMap(importSecret)
+	if err != nil {
+		return err
+	}
+
+	// the ConfigMap is owned by the managed cluster, so it will be deleted with the managed cluster
+	return helpers.ApplyResources(r.clientHolder, r.recorder, r.scheme, managedCluster, configMap)
+}
+
+// isDetaching returns true if the managed cluster requires to uninstall its klusterlet without being deleted
+func isDetaching(cluster *clusterv1.ManagedCluster) bool {
+	detaching, ok := cluster.Annotations[constants.DetachingAnnotation]
+	return ok && strings.EqualFold(detaching, "true")
+}
+
+func (r *ReconcileManifestWork) removeDetachingAnnotation(ctx context.Context, cluster *clusterv1.ManagedCluster) error {
+	patch := client.MergeFrom(cluster.DeepCopy())
+	delete(cluster.Annotations, constants.DetachingAnnotation)
+	if err := r.clientHolder.RuntimeClient.Patch(ctx, cluster, patch); err != nil {
+		return err
+	}
+
+	r.recorder.Eventf("ManagedClusterDetached",
+		"The klusterlet of the managed cluster %s is uninstalled, the annotation %s is removed",
+		cluster.Name, constants.DetachingAnnotation)
+	return nil
+}
+
+func createKlusterletCRDsManifestWork(managedCluster *clusterv1.ManagedCluster,
+	importSecret *corev1.Secret) (*workv1.ManifestWork, error) {
+	manifests, err := helpers.BuildKlusterletCRDsManifests(managedCluster, importSecret)
+	if err != nil {
+		return nil, err
 	}
 
 	return &workv1.ManifestWork{
 		TypeMeta: metav1.TypeMeta{},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-%s", managedCluster.Name, constants.KlusterletCRDsSuffix),
+			Name:      helpers.KlusterletCRDsWorkName(managedCluster.Name),
 			Namespace: managedCluster.Name,
 			Labels: map[string]string{
 				constants.KlusterletWorksLabel: "true",
@@ -253,31 +534,28 @@ func createKlusterletCRDsManifestWork(managedCluster *clusterv1.ManagedCluster,
 		},
 		Spec: workv1.ManifestWorkSpec{
 			Workload: workv1.ManifestsTemplate{
-				Manifests: []workv1.Manifest{
-					{RawExtension: runtime.RawExtension{Raw: jsonData}},
-				},
+				Manifests: manifests,
 			},
 		},
-	}
+	}, nil
 }
 
-func createKlusterletManifestWork(managedCluster *clusterv1.ManagedCluster, importSecret *corev1.Secret) *workv1.ManifestWork {
-	manifests := []workv1.Manifest{}
-	importYaml := importSecret.Data[constants.ImportSecretImportYamlKey]
-	for _, yamlData := range helpers.SplitYamls(importYaml) {
-		jsonData, err := yaml.YAMLToJSON(yamlData)
-		if err != nil {
-			panic(err)
-		}
-		manifests = append(manifests, workv1.Manifest{
-			RawExtension: runtime.RawExtension{Raw: jsonData},
-		})
+func createKlusterletManifestWork(managedCluster *clusterv1.ManagedCluster,
+	importSecret *corev1.Secret) (*workv1.ManifestWork, error) {
+	manifests, err := helpers.BuildKlusterletManifests(managedCluster, importSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	deleteOption, err := helpers.GetKlusterletWorkDeleteOption(managedCluster, manifests)
+	if err != nil {
+		return nil, err
 	}
 
 	return &workv1.ManifestWork{
 		TypeMeta: metav1.TypeMeta{},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-%s", managedCluster.Name, constants.KlusterletSuffix),
+			Name:      helpers.KlusterletWorkName(managedCluster.Name),
 			Namespace: managedCluster.Name,
 			Labels: map[string]string{
 				constants.KlusterletWorksLabel: "true",
@@ -287,8 +565,27 @@ func createKlusterletManifestWork(managedCluster *clusterv1.ManagedCluster, impo
 			Workload: workv1.ManifestsTemplate{
 				Manifests: manifests,
 			},
-			DeleteOption: &workv1.DeleteOption{
-				PropagationPolicy: workv1.DeletePropagationPolicyTypeOrphan,
+			DeleteOption:    deleteOption,
+			ManifestConfigs: klusterletManifestConfigs(managedCluster),
+		},
+	}, nil
+}
+
+// klusterletManifestConfigs requests the well known status feedback of the klusterlet operator deployment, so the
+// replica readiness of the klusterlet operator can be observed from the klusterlet manifest work status on the hub
+func klusterletManifestConfigs(managedCluster *clusterv1.ManagedCluster) []workv1.ManifestConfigOption {
+	return []workv1.ManifestConfigOption{
+		{
+			ResourceIdentifier: workv1.ResourceIdentifier{
+				Group:     "apps",
+				Resource:  "deployments",
+				Name:      klusterletDeploymentName,
+				Namespace: helpers.GetKlusterletNamespace(managedCluster),
+			},
+			FeedbackRules: []workv1.FeedbackRule{
+				{
+					Type: workv1.WellKnownStatusType,
+				},
 			},
 		},
 	}
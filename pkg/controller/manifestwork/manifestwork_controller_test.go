@@ -5,26 +5,41 @@ package manifestwork
 
 import (
 	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stolostron/managedcluster-import-controller/pkg/constants"
 	"github.com/stolostron/managedcluster-import-controller/pkg/helpers"
 	testinghelpers "github.com/stolostron/managedcluster-import-controller/pkg/helpers/testing"
+	"github.com/stolostron/managedcluster-import-controller/pkg/source"
 	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	operatorfake "open-cluster-management.io/api/client/operator/clientset/versioned/fake"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
 	workv1 "open-cluster-management.io/api/work/v1"
 
+	"github.com/go-logr/logr/funcr"
+	ocinfrav1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/library-go/pkg/operator/events"
 	"github.com/openshift/library-go/pkg/operator/events/eventstesting"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	kubefake "k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/workqueue"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -39,6 +54,7 @@ func init() {
 	testscheme.AddKnownTypes(workv1.SchemeGroupVersion, &workv1.ManifestWorkList{})
 	testscheme.AddKnownTypes(addonv1alpha1.SchemeGroupVersion, &addonv1alpha1.ManagedClusterAddOn{})
 	testscheme.AddKnownTypes(addonv1alpha1.SchemeGroupVersion, &addonv1alpha1.ManagedClusterAddOnList{})
+	testscheme.AddKnownTypes(ocinfrav1.SchemeGroupVersion, &ocinfrav1.Infrastructure{})
 }
 
 func TestReconcile(t *testing.T) {
@@ -69,6 +85,7 @@ func TestReconcile(t *testing.T) {
 					ObjectMeta: v1.ObjectMeta{
 						Name: "test",
 					},
+					Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
 				},
 				&workv1.ManifestWork{
 					ObjectMeta: v1.ObjectMeta{
@@ -104,6 +121,7 @@ func TestReconcile(t *testing.T) {
 						Finalizers:        []string{constants.ManifestWorkFinalizer},
 						DeletionTimestamp: &now,
 					},
+					Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
 				},
 				&workv1.ManifestWork{
 					ObjectMeta: v1.ObjectMeta{
@@ -143,8 +161,9 @@ func TestReconcile(t *testing.T) {
 				if err := runtimeClient.List(context.TODO(), manifestWorks, &client.ListOptions{Namespace: "test"}); err != nil {
 					t.Errorf("unexpected error: %v", err)
 				}
-				if len(manifestWorks.Items) != 1 {
-					t.Errorf("expected one work, but failed %d", len(manifestWorks.Items))
+				// the klusterlet crds work is deleted once the klusterlet work is removed
+				if len(manifestWorks.Items) != 0 {
+					t.Errorf("expected no works, but failed %d", len(manifestWorks.Items))
 				}
 			},
 		},
@@ -160,6 +179,7 @@ func TestReconcile(t *testing.T) {
 						},
 						DeletionTimestamp: &now,
 					},
+					Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
 				},
 				&workv1.ManifestWork{
 					ObjectMeta: v1.ObjectMeta{
@@ -199,6 +219,7 @@ func TestReconcile(t *testing.T) {
 						Finalizers:        []string{constants.ManifestWorkFinalizer},
 						DeletionTimestamp: &now,
 					},
+					Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
 				},
 				&workv1.ManifestWork{
 					ObjectMeta: v1.ObjectMeta{
@@ -232,6 +253,7 @@ func TestReconcile(t *testing.T) {
 						Finalizers:        []string{constants.ManifestWorkFinalizer},
 						DeletionTimestamp: &now,
 					},
+					Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
 					Status: clusterv1.ManagedClusterStatus{
 						Conditions: []v1.Condition{
 							{
@@ -288,6 +310,7 @@ func TestReconcile(t *testing.T) {
 						Finalizers:        []string{constants.ManifestWorkFinalizer},
 						DeletionTimestamp: &now,
 					},
+					Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
 				},
 				&workv1.ManifestWork{
 					ObjectMeta: v1.ObjectMeta{
@@ -335,6 +358,43 @@ func TestReconcile(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "managed clusters is deleting and the custom postpone time of manifestwork is expired",
+			startObjs: []client.Object{
+				&clusterv1.ManagedCluster{
+					ObjectMeta: v1.ObjectMeta{
+						Name:              "test",
+						Finalizers:        []string{constants.ManifestWorkFinalizer},
+						DeletionTimestamp: &v1.Time{Time: now.Add(-1 * time.Minute)},
+					},
+					Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+				},
+				&workv1.ManifestWork{
+					ObjectMeta: v1.ObjectMeta{
+						Name:      "test-postpone-time",
+						Namespace: "test",
+						Annotations: map[string]string{
+							"open-cluster-management/postpone-delete": "30s",
+						},
+					},
+				},
+			},
+			secrets: []runtime.Object{},
+			request: reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name: "test",
+				},
+			},
+			validateFunc: func(t *testing.T, runtimeClient client.Client) {
+				manifestWorks := &workv1.ManifestWorkList{}
+				if err := runtimeClient.List(context.TODO(), manifestWorks, &client.ListOptions{Namespace: "test"}); err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				if len(manifestWorks.Items) != 0 {
+					t.Errorf("expected no works, but failed %v", len(manifestWorks.Items))
+				}
+			},
+		},
 		{
 			name: "managed clusters is deleting and there are managed cluster addons",
 			startObjs: []client.Object{
@@ -344,6 +404,7 @@ func TestReconcile(t *testing.T) {
 						Finalizers:        []string{constants.ManifestWorkFinalizer},
 						DeletionTimestamp: &now,
 					},
+					Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
 					Status: clusterv1.ManagedClusterStatus{
 						Conditions: []v1.Condition{
 							{
@@ -407,6 +468,7 @@ func TestReconcile(t *testing.T) {
 						Finalizers:        []string{constants.ManifestWorkFinalizer},
 						DeletionTimestamp: &now,
 					},
+					Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
 					Status: clusterv1.ManagedClusterStatus{
 						Conditions: []v1.Condition{
 							{
@@ -486,6 +548,7 @@ func TestReconcile(t *testing.T) {
 						Finalizers:        []string{constants.ManifestWorkFinalizer},
 						DeletionTimestamp: &now,
 					},
+					Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
 					Status: clusterv1.ManagedClusterStatus{
 						Conditions: []v1.Condition{
 							{
@@ -549,6 +612,7 @@ func TestReconcile(t *testing.T) {
 						Name:       "test",
 						Finalizers: []string{constants.ManifestWorkFinalizer},
 					},
+					Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
 					Status: clusterv1.ManagedClusterStatus{
 						Conditions: []v1.Condition{
 							{
@@ -570,6 +634,162 @@ func TestReconcile(t *testing.T) {
 			},
 			validateFunc: func(t *testing.T, runtimeClient client.Client) {},
 		},
+		{
+			name: "refresh the metadata of the klusterlet manifest work whose spec is unchanged",
+			startObjs: []client.Object{
+				&clusterv1.ManagedCluster{
+					ObjectMeta: v1.ObjectMeta{
+						Name:       "test",
+						Finalizers: []string{constants.ManifestWorkFinalizer},
+					},
+					Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+					Status: clusterv1.ManagedClusterStatus{
+						Version: clusterv1.ManagedClusterVersion{Kubernetes: "v1.18.0"},
+					},
+				},
+				&workv1.ManifestWork{
+					ObjectMeta: v1.ObjectMeta{
+						Name:      "test-klusterlet",
+						Namespace: "test",
+					},
+					Spec: workv1.ManifestWorkSpec{
+						Workload: workv1.ManifestsTemplate{
+							Manifests: mustBuildKlusterletManifests(testinghelpers.GetImportSecret("test")),
+						},
+						DeleteOption: &workv1.DeleteOption{
+							PropagationPolicy: workv1.DeletePropagationPolicyTypeOrphan,
+						},
+						ManifestConfigs: klusterletManifestConfigs(&clusterv1.ManagedCluster{}),
+					},
+				},
+			},
+			secrets: []runtime.Object{
+				testinghelpers.GetImportSecret("test"),
+			},
+			request: reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name: "test",
+				},
+			},
+			validateFunc: func(t *testing.T, runtimeClient client.Client) {
+				klusterletWork := &workv1.ManifestWork{}
+				if err := runtimeClient.Get(context.TODO(),
+					types.NamespacedName{Namespace: "test", Name: "test-klusterlet"}, klusterletWork); err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				// the klusterlet works label and the controller version are added once the manifest work is applied
+				if _, ok := klusterletWork.Labels[constants.KlusterletWorksLabel]; !ok {
+					t.Errorf("expected the klusterlet manifest work is applied, but it is not")
+				}
+				if _, ok := klusterletWork.Annotations[constants.ControllerVersionAnnotation]; !ok {
+					t.Errorf("expected the controller version annotation, but failed")
+				}
+
+				crdsWork := &workv1.ManifestWork{}
+				if err := runtimeClient.Get(context.TODO(),
+					types.NamespacedName{Namespace: "test", Name: "test-klusterlet-crds"}, crdsWork); err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				if len(crdsWork.Annotations[constants.ManifestWorkSpecHashAnnotation]) == 0 {
+					t.Errorf("expected the manifest work spec hash annotation, but failed")
+				}
+			},
+		},
+		{
+			name: "apply the klusterlet manifest work whose klusterlet images are drifted",
+			startObjs: []client.Object{
+				&clusterv1.ManagedCluster{
+					ObjectMeta: v1.ObjectMeta{
+						Name:       "test",
+						Finalizers: []string{constants.ManifestWorkFinalizer},
+					},
+					Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+					Status: clusterv1.ManagedClusterStatus{
+						Version: clusterv1.ManagedClusterVersion{Kubernetes: "v1.18.0"},
+					},
+				},
+				&workv1.ManifestWork{
+					ObjectMeta: v1.ObjectMeta{
+						Name:      "test-klusterlet",
+						Namespace: "test",
+					},
+					Spec: workv1.ManifestWorkSpec{
+						Workload: workv1.ManifestsTemplate{
+							Manifests: mustBuildKlusterletManifests(
+								importSecretWithImage("test", "registration:latest", "registration:old")),
+						},
+						ManifestConfigs: klusterletManifestConfigs(&clusterv1.ManagedCluster{}),
+					},
+				},
+			},
+			secrets: []runtime.Object{
+				testinghelpers.GetImportSecret("test"),
+			},
+			request: reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name: "test",
+				},
+			},
+			validateFunc: func(t *testing.T, runtimeClient client.Client) {
+				klusterletWork := &workv1.ManifestWork{}
+				if err := runtimeClient.Get(context.TODO(),
+					types.NamespacedName{Namespace: "test", Name: "test-klusterlet"}, klusterletWork); err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				drifted, err := helpers.KlusterletImagesDrifted(klusterletWork, &workv1.ManifestWork{
+					Spec: workv1.ManifestWorkSpec{
+						Workload: workv1.ManifestsTemplate{
+							Manifests: mustBuildKlusterletManifests(testinghelpers.GetImportSecret("test")),
+						},
+					},
+				})
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				if drifted {
+					t.Errorf("expected the klusterlet manifest work is re-applied, but it is not")
+				}
+			},
+		},
+		{
+			name: "apply the klusterlet manifest work whose manifest configs are changed",
+			startObjs: []client.Object{
+				&clusterv1.ManagedCluster{
+					ObjectMeta: v1.ObjectMeta{
+						Name:       "test",
+						Finalizers: []string{constants.ManifestWorkFinalizer},
+					},
+					Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+					Status: clusterv1.ManagedClusterStatus{
+						Version: clusterv1.ManagedClusterVersion{Kubernetes: "v1.18.0"},
+					},
+				},
+				&workv1.ManifestWork{
+					ObjectMeta: v1.ObjectMeta{
+						Name:      "test-klusterlet",
+						Namespace: "test",
+					},
+				},
+			},
+			secrets: []runtime.Object{
+				testinghelpers.GetImportSecret("test"),
+			},
+			request: reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name: "test",
+				},
+			},
+			validateFunc: func(t *testing.T, runtimeClient client.Client) {
+				klusterletWork := &workv1.ManifestWork{}
+				if err := runtimeClient.Get(context.TODO(),
+					types.NamespacedName{Namespace: "test", Name: "test-klusterlet"}, klusterletWork); err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				if len(klusterletWork.Spec.ManifestConfigs) == 0 {
+					t.Errorf("expected the klusterlet manifest work is applied with manifest configs, but failed")
+				}
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -578,7 +798,7 @@ func TestReconcile(t *testing.T) {
 				clientHolder: &helpers.ClientHolder{
 					RuntimeClient:  fake.NewClientBuilder().WithScheme(testscheme).WithObjects(c.startObjs...).Build(),
 					OperatorClient: operatorfake.NewSimpleClientset(),
-					KubeClient:     kubefake.NewSimpleClientset(c.secrets...),
+					KubeClient:     kubefake.NewSimpleClientset(append(c.secrets, newClusterNamespace("test"))...),
 				},
 				scheme:   testscheme,
 				recorder: eventstesting.NewTestingEventRecorder(t),
@@ -593,3 +813,995 @@ func TestReconcile(t *testing.T) {
 		})
 	}
 }
+
+func TestDeleteManifestWorksWithGracePeriod(t *testing.T) {
+	cases := []struct {
+		name               string
+		lastTransitionTime v1.Time
+		expectedWorks      int
+		expectedRequeue    bool
+	}{
+		{
+			name:               "managed cluster is unavailable recently",
+			lastTransitionTime: v1.NewTime(time.Now().Add(-1 * time.Minute)),
+			expectedWorks:      3,
+			expectedRequeue:    true,
+		},
+		{
+			name:               "managed cluster is unavailable for a long time",
+			lastTransitionTime: v1.NewTime(time.Now().Add(-10 * time.Minute)),
+			expectedWorks:      0,
+			expectedRequeue:    false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			works := []client.Object{}
+			for _, name := range []string{"test1", "test-klusterlet", "test-klusterlet-crds"} {
+				works = append(works, &workv1.ManifestWork{
+					ObjectMeta: v1.ObjectMeta{
+						Name:       name,
+						Namespace:  "test",
+						Finalizers: []string{"test"},
+					},
+				})
+			}
+
+			startObjs := append(works, &clusterv1.ManagedCluster{
+				ObjectMeta: v1.ObjectMeta{
+					Name:              "test",
+					Finalizers:        []string{constants.ManifestWorkFinalizer},
+					DeletionTimestamp: &now,
+				},
+				Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+				Status: clusterv1.ManagedClusterStatus{
+					Conditions: []v1.Condition{
+						{
+							Type:               clusterv1.ManagedClusterConditionAvailable,
+							Status:             v1.ConditionUnknown,
+							LastTransitionTime: c.lastTransitionTime,
+						},
+					},
+				},
+			})
+
+			r := &ReconcileManifestWork{
+				clientHolder: &helpers.ClientHolder{
+					RuntimeClient:  fake.NewClientBuilder().WithScheme(testscheme).WithObjects(startObjs...).Build(),
+					OperatorClient: operatorfake.NewSimpleClientset(),
+					KubeClient:     kubefake.NewSimpleClientset(),
+				},
+				scheme:                        testscheme,
+				recorder:                      eventstesting.NewTestingEventRecorder(t),
+				clusterUnavailableGracePeriod: 5 * time.Minute,
+			}
+
+			result, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			if (result.RequeueAfter > 0) != c.expectedRequeue {
+				t.Errorf("expected requeue %v, but got %v", c.expectedRequeue, result.RequeueAfter)
+			}
+
+			manifestWorks := &workv1.ManifestWorkList{}
+			if err := r.clientHolder.RuntimeClient.List(
+				context.TODO(), manifestWorks, &client.ListOptions{Namespace: "test"}); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if len(manifestWorks.Items) != c.expectedWorks {
+				t.Errorf("expected %d works, but failed %d", c.expectedWorks, len(manifestWorks.Items))
+			}
+		})
+	}
+}
+
+func TestManagedClusterPredicate(t *testing.T) {
+	cases := []struct {
+		name           string
+		cluster        *clusterv1.ManagedCluster
+		expectedDelete bool
+		expectedUpdate bool
+	}{
+		{
+			name: "deleting cluster without manifest work finalizer",
+			cluster: &clusterv1.ManagedCluster{
+				ObjectMeta: v1.ObjectMeta{
+					Name:              "test",
+					DeletionTimestamp: &now,
+					Finalizers:        []string{constants.ImportFinalizer},
+				},
+				Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+			},
+			expectedDelete: false,
+			expectedUpdate: false,
+		},
+		{
+			name: "deleting cluster with manifest work finalizer",
+			cluster: &clusterv1.ManagedCluster{
+				ObjectMeta: v1.ObjectMeta{
+					Name:              "test",
+					DeletionTimestamp: &now,
+					Finalizers:        []string{constants.ImportFinalizer, constants.ManifestWorkFinalizer},
+				},
+				Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+			},
+			expectedDelete: true,
+			expectedUpdate: true,
+		},
+		{
+			name: "cluster is not deleting",
+			cluster: &clusterv1.ManagedCluster{
+				ObjectMeta: v1.ObjectMeta{
+					Name: "test",
+				},
+				Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+			},
+			expectedDelete: false,
+			expectedUpdate: true,
+		},
+		{
+			name: "hosted mode cluster",
+			cluster: &clusterv1.ManagedCluster{
+				ObjectMeta: v1.ObjectMeta{
+					Name:              "test",
+					DeletionTimestamp: &now,
+					Finalizers:        []string{constants.ManifestWorkFinalizer},
+					Annotations: map[string]string{
+						constants.KlusterletDeployModeAnnotation: constants.KlusterletDeployModeHosted,
+					},
+				},
+				Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+			},
+			expectedDelete: false,
+			expectedUpdate: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if actual := managedClusterPredicate.Delete(event.DeleteEvent{Object: c.cluster}); actual != c.expectedDelete {
+				t.Errorf("expected delete event %v, but got %v", c.expectedDelete, actual)
+			}
+
+			old := c.cluster.DeepCopy()
+			old.DeletionTimestamp = nil
+			actual := managedClusterPredicate.Update(event.UpdateEvent{ObjectOld: old, ObjectNew: c.cluster})
+			if actual != c.expectedUpdate {
+				t.Errorf("expected update event %v, but got %v", c.expectedUpdate, actual)
+			}
+		})
+	}
+}
+
+func TestImportSecretPredicate(t *testing.T) {
+	cases := []struct {
+		name             string
+		secret           *corev1.Secret
+		expectedRequests []reconcile.Request
+	}{
+		{
+			name: "import secret is deleted",
+			secret: &corev1.Secret{
+				ObjectMeta: v1.ObjectMeta{Name: "test-import", Namespace: "test"},
+			},
+			expectedRequests: []reconcile.Request{
+				{NamespacedName: types.NamespacedName{Namespace: "test", Name: "test"}},
+			},
+		},
+		{
+			name: "other secret is deleted",
+			secret: &corev1.Secret{
+				ObjectMeta: v1.ObjectMeta{Name: "other-import", Namespace: "test"},
+			},
+			expectedRequests: []reconcile.Request{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+			defer queue.ShutDown()
+
+			deleteEvent := event.DeleteEvent{Object: c.secret}
+			if importSecretPredicate.Delete(deleteEvent) {
+				(&source.ManagedClusterSecretEventHandler{}).Delete(deleteEvent, queue)
+			}
+
+			requests := []reconcile.Request{}
+			for queue.Len() > 0 {
+				item, _ := queue.Get()
+				requests = append(requests, item.(reconcile.Request))
+				queue.Done(item)
+			}
+			if !reflect.DeepEqual(requests, c.expectedRequests) {
+				t.Errorf("expected requests %v, but got %v", c.expectedRequests, requests)
+			}
+		})
+	}
+}
+
+func TestReconcileLogFields(t *testing.T) {
+	lines := []string{}
+	ctx := logf.IntoContext(context.TODO(), funcr.New(func(prefix, args string) {
+		lines = append(lines, args)
+	}, funcr.Options{}))
+
+	startObjs := []client.Object{
+		&workv1.ManifestWork{
+			ObjectMeta: v1.ObjectMeta{
+				Name:       "test1",
+				Namespace:  "test",
+				Finalizers: []string{"test"},
+			},
+		},
+		&clusterv1.ManagedCluster{
+			ObjectMeta: v1.ObjectMeta{
+				Name:              "test",
+				Finalizers:        []string{constants.ManifestWorkFinalizer},
+				DeletionTimestamp: &now,
+			},
+			Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+			Status: clusterv1.ManagedClusterStatus{
+				Conditions: []v1.Condition{
+					{
+						Type:               clusterv1.ManagedClusterConditionAvailable,
+						Status:             v1.ConditionTrue,
+						LastTransitionTime: now,
+					},
+				},
+			},
+		},
+	}
+
+	r := &ReconcileManifestWork{
+		clientHolder: &helpers.ClientHolder{
+			RuntimeClient:  fake.NewClientBuilder().WithScheme(testscheme).WithObjects(startObjs...).Build(),
+			OperatorClient: operatorfake.NewSimpleClientset(),
+			KubeClient:     kubefake.NewSimpleClientset(),
+		},
+		scheme:   testscheme,
+		recorder: eventstesting.NewTestingEventRecorder(t),
+	}
+
+	if _, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	expectedFields := []string{`"cluster"="test"`, `"mode"="Default"`, `"pendingWorks"=1`, `"phase"="deleting"`}
+	assertLogFields(t, lines, "Deleting the addons and manifest works", expectedFields)
+
+	// the helpers log with the logger of the reconcile
+	assertLogFields(t, lines, "There are pending manifest works",
+		append(expectedFields, `"pendingManifestWorks"="test1"`))
+}
+
+func assertLogFields(t *testing.T, lines []string, msg string, expectedFields []string) {
+	found := false
+	for _, line := range lines {
+		if !strings.Contains(line, msg) {
+			continue
+		}
+		found = true
+		for _, field := range expectedFields {
+			if !strings.Contains(line, field) {
+				t.Errorf("expected field %s in log %q", field, line)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("expected log %q, but got %v", msg, lines)
+	}
+}
+
+func TestCreateKlusterletManifestWork(t *testing.T) {
+	cases := []struct {
+		name              string
+		managedCluster    *clusterv1.ManagedCluster
+		expectedNamespace string
+	}{
+		{
+			name: "default klusterlet namespace",
+			managedCluster: &clusterv1.ManagedCluster{
+				ObjectMeta: v1.ObjectMeta{
+					Name: "test",
+				},
+				Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+			},
+			expectedNamespace: "open-cluster-management-agent",
+		},
+		{
+			name: "customized klusterlet namespace",
+			managedCluster: &clusterv1.ManagedCluster{
+				ObjectMeta: v1.ObjectMeta{
+					Name: "test",
+					Annotations: map[string]string{
+						constants.KlusterletNamespaceAnnotation: "open-cluster-management-test",
+					},
+				},
+				Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+			},
+			expectedNamespace: "open-cluster-management-test",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work, err := createKlusterletManifestWork(c.managedCluster, testinghelpers.GetImportSecret("test"))
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			expected := []workv1.ManifestConfigOption{
+				{
+					ResourceIdentifier: workv1.ResourceIdentifier{
+						Group:     "apps",
+						Resource:  "deployments",
+						Name:      "klusterlet",
+						Namespace: c.expectedNamespace,
+					},
+					FeedbackRules: []workv1.FeedbackRule{
+						{
+							Type: workv1.WellKnownStatusType,
+						},
+					},
+				},
+			}
+			if !reflect.DeepEqual(work.Spec.ManifestConfigs, expected) {
+				t.Errorf("expected manifest configs %v, but got %v", expected, work.Spec.ManifestConfigs)
+			}
+		})
+	}
+}
+
+func TestCreateKlusterletManifestWorkDeleteOption(t *testing.T) {
+	cases := []struct {
+		name           string
+		policy         string
+		expectedPolicy workv1.DeletePropagationPolicyType
+		expectedErr    bool
+	}{
+		{
+			name:           "default policy",
+			expectedPolicy: workv1.DeletePropagationPolicyTypeOrphan,
+		},
+		{
+			name:           "foreground policy",
+			policy:         "Foreground",
+			expectedPolicy: workv1.DeletePropagationPolicyTypeForeground,
+		},
+		{
+			name:           "selectively orphan policy",
+			policy:         "selectivelyorphan",
+			expectedPolicy: workv1.DeletePropagationPolicyTypeSelectivelyOrphan,
+		},
+		{
+			name:        "invalid policy",
+			policy:      "Background",
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			managedCluster := &clusterv1.ManagedCluster{ObjectMeta: v1.ObjectMeta{Name: "test"}}
+			if len(c.policy) != 0 {
+				managedCluster.Annotations = map[string]string{
+					constants.KlusterletWorkDeletePropagationPolicyAnnotation: c.policy,
+				}
+			}
+
+			work, err := createKlusterletManifestWork(managedCluster, testinghelpers.GetImportSecret("test"))
+			if c.expectedErr {
+				if err == nil {
+					t.Errorf("expected error, but failed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if work.Spec.DeleteOption.PropagationPolicy != c.expectedPolicy {
+				t.Errorf("expected policy %s, but got %s", c.expectedPolicy, work.Spec.DeleteOption.PropagationPolicy)
+			}
+			if c.expectedPolicy != workv1.DeletePropagationPolicyTypeSelectivelyOrphan {
+				return
+			}
+
+			// all of the klusterlet resources except the klusterlet CR are orphaned
+			rules := work.Spec.DeleteOption.SelectivelyOrphan.OrphaningRules
+			if len(rules) != len(work.Spec.Workload.Manifests)-1 {
+				t.Errorf("expected %d orphaning rules, but got %d", len(work.Spec.Workload.Manifests)-1, len(rules))
+			}
+			for _, rule := range rules {
+				if rule.Resource == "klusterlets" {
+					t.Errorf("expected the klusterlet is not orphaned, but got %v", rule)
+				}
+				if rule.Resource == "deployments" && (rule.Group != "apps" || rule.Name != "klusterlet" ||
+					rule.Namespace != "open-cluster-management-agent") {
+					t.Errorf("unexpected orphaning rule %v", rule)
+				}
+			}
+		})
+	}
+}
+
+func mustBuildKlusterletManifests(importSecret *corev1.Secret) []workv1.Manifest {
+	manifests, err := helpers.BuildKlusterletManifests(
+		&clusterv1.ManagedCluster{ObjectMeta: v1.ObjectMeta{Name: "test"}}, importSecret)
+	if err != nil {
+		panic(err)
+	}
+	return manifests
+}
+
+func importSecretWithImage(clusterName, image, newImage string) *corev1.Secret {
+	importSecret := testinghelpers.GetImportSecret(clusterName)
+	importSecret.Data[constants.ImportSecretImportYamlKey] = []byte(strings.ReplaceAll(
+		string(importSecret.Data[constants.ImportSecretImportYamlKey]), image, newImage))
+	return importSecret
+}
+
+func TestReconcileDetaching(t *testing.T) {
+	cases := []struct {
+		name            string
+		workAnnotations map[string]string
+	}{
+		{
+			name: "detach the managed cluster",
+		},
+		{
+			name: "the postponed manifest work is deleted without postponement",
+			workAnnotations: map[string]string{
+				constants.PostponeDeletionAnnotation: "",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			startObjs := []client.Object{
+				&clusterv1.ManagedCluster{
+					ObjectMeta: v1.ObjectMeta{
+						Name:        "test",
+						Finalizers:  []string{constants.ManifestWorkFinalizer},
+						Annotations: map[string]string{constants.DetachingAnnotation: "true"},
+					},
+					Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+					Status: clusterv1.ManagedClusterStatus{
+						Conditions: []v1.Condition{
+							{
+								Type:               clusterv1.ManagedClusterConditionAvailable,
+								Status:             v1.ConditionTrue,
+								LastTransitionTime: now,
+							},
+						},
+					},
+				},
+				&workv1.ManifestWork{
+					ObjectMeta: v1.ObjectMeta{
+						Name:        "test1",
+						Namespace:   "test",
+						Annotations: c.workAnnotations,
+					},
+				},
+			}
+			for _, name := range []string{"test-klusterlet", "test-klusterlet-crds"} {
+				startObjs = append(startObjs, &workv1.ManifestWork{
+					ObjectMeta: v1.ObjectMeta{
+						Name:      name,
+						Namespace: "test",
+					},
+				})
+			}
+
+			r := &ReconcileManifestWork{
+				clientHolder: &helpers.ClientHolder{
+					RuntimeClient:  fake.NewClientBuilder().WithScheme(testscheme).WithObjects(startObjs...).Build(),
+					OperatorClient: operatorfake.NewSimpleClientset(),
+					KubeClient:     kubefake.NewSimpleClientset(testinghelpers.GetImportSecret("test")),
+				},
+				scheme:   testscheme,
+				recorder: eventstesting.NewTestingEventRecorder(t),
+			}
+
+			request := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}}
+			// the first reconcile deletes the manifest works
+			if _, err := r.Reconcile(context.TODO(), request); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			manifestWorks := &workv1.ManifestWorkList{}
+			if err := r.clientHolder.RuntimeClient.List(
+				context.TODO(), manifestWorks, &client.ListOptions{Namespace: "test"}); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if len(manifestWorks.Items) != 0 {
+				t.Errorf("expected the manifest works are deleted, but got %d", len(manifestWorks.Items))
+			}
+
+			// the second reconcile removes the detaching annotation
+			if _, err := r.Reconcile(context.TODO(), request); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			managedCluster := &clusterv1.ManagedCluster{}
+			if err := r.clientHolder.RuntimeClient.Get(
+				context.TODO(), types.NamespacedName{Name: "test"}, managedCluster); err != nil {
+				t.Fatalf("expected the managed cluster is not deleted, but got %v", err)
+			}
+			if !managedCluster.DeletionTimestamp.IsZero() {
+				t.Errorf("expected the managed cluster is not deleting, but it is deleting")
+			}
+			if _, ok := managedCluster.Annotations[constants.DetachingAnnotation]; ok {
+				t.Errorf("expected the detaching annotation is removed, but it is not")
+			}
+		})
+	}
+}
+
+func TestReconcileKlusterletAppliedCondition(t *testing.T) {
+	startObjs := []client.Object{
+		&clusterv1.ManagedCluster{
+			ObjectMeta: v1.ObjectMeta{
+				Name: "test",
+			},
+			Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+		},
+		&workv1.ManifestWork{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "test-klusterlet",
+				Namespace: "test",
+			},
+			Status: workv1.ManifestWorkStatus{
+				Conditions: []v1.Condition{
+					{
+						Type:               workv1.WorkApplied,
+						Status:             v1.ConditionTrue,
+						Reason:             "AppliedManifestWorkComplete",
+						LastTransitionTime: now,
+					},
+				},
+			},
+		},
+	}
+
+	r := &ReconcileManifestWork{
+		clientHolder: &helpers.ClientHolder{
+			RuntimeClient:  fake.NewClientBuilder().WithScheme(testscheme).WithObjects(startObjs...).Build(),
+			OperatorClient: operatorfake.NewSimpleClientset(),
+			KubeClient:     kubefake.NewSimpleClientset(testinghelpers.GetImportSecret("test"), newClusterNamespace("test")),
+		},
+		scheme:   testscheme,
+		recorder: eventstesting.NewTestingEventRecorder(t),
+	}
+
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}}
+	if _, err := r.Reconcile(context.TODO(), request); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	managedCluster := &clusterv1.ManagedCluster{}
+	if err := r.clientHolder.RuntimeClient.Get(
+		context.TODO(), types.NamespacedName{Name: "test"}, managedCluster); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cond := meta.FindStatusCondition(managedCluster.Status.Conditions, constants.KlusterletAppliedConditionType)
+	if cond == nil {
+		t.Fatalf("expected the condition %s is set, but failed", constants.KlusterletAppliedConditionType)
+	}
+	if cond.Status != v1.ConditionTrue || cond.Reason != "KlusterletManifestWorkApplied" {
+		t.Errorf("expected the klusterlet is applied, but got %s %s", cond.Status, cond.Reason)
+	}
+}
+
+func TestReconcileImportManifestsConfigMap(t *testing.T) {
+	os.Setenv("DEBUG_IMPORT_MANIFESTS", "true")
+	defer os.Unsetenv("DEBUG_IMPORT_MANIFESTS")
+
+	kubeClient := kubefake.NewSimpleClientset(testinghelpers.GetImportSecret("test"), newClusterNamespace("test"))
+	r := &ReconcileManifestWork{
+		clientHolder: &helpers.ClientHolder{
+			RuntimeClient: fake.NewClientBuilder().WithScheme(testscheme).WithObjects(&clusterv1.ManagedCluster{
+				ObjectMeta: v1.ObjectMeta{
+					Name: "test",
+				},
+				Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+			}).Build(),
+			OperatorClient: operatorfake.NewSimpleClientset(),
+			KubeClient:     kubeClient,
+		},
+		scheme:   testscheme,
+		recorder: eventstesting.NewTestingEventRecorder(t),
+	}
+
+	if _, err := r.Reconcile(context.TODO(), reconcile.Request{
+		NamespacedName: types.NamespacedName{Name: "test"},
+	}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	configMap, err := kubeClient.CoreV1().ConfigMaps("test").Get(context.TODO(), "test-import-manifests", v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the import manifests ConfigMap is created, but got %v", err)
+	}
+	if len(configMap.Data) == 0 {
+		t.Errorf("expected the import manifests in the ConfigMap, but it is empty")
+	}
+	for key, manifest := range configMap.Data {
+		if strings.Contains(manifest, ".dockerconfigjson: test") {
+			t.Errorf("expected the secret data in the manifest %s is redacted, but got %s", key, manifest)
+		}
+	}
+
+	// the ConfigMap is updated once the import secret is changed
+	importSecret, err := kubeClient.CoreV1().Secrets("test").Get(context.TODO(), "test-import", v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	importSecret.Data["import.yaml"] = append(importSecret.Data["import.yaml"],
+		[]byte("\n---\napiVersion: v1\nkind: Namespace\nmetadata:\n  name: test-debug\n")...)
+	if _, err := kubeClient.CoreV1().Secrets("test").Update(context.TODO(), importSecret, v1.UpdateOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := r.Reconcile(context.TODO(), reconcile.Request{
+		NamespacedName: types.NamespacedName{Name: "test"},
+	}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	configMap, err = kubeClient.CoreV1().ConfigMaps("test").Get(context.TODO(), "test-import-manifests", v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectedHash := helpers.ImportSecretDataHash(importSecret, "import.yaml")
+	if configMap.Annotations[constants.ImportSecretHashAnnotation] != expectedHash {
+		t.Errorf("expected the import secret hash %s, but got %v", expectedHash, configMap.Annotations)
+	}
+}
+
+func TestReconcileClusterNamespace(t *testing.T) {
+	terminatingNamespace := newClusterNamespace("test")
+	terminatingNamespace.Status.Phase = corev1.NamespaceTerminating
+
+	cases := []struct {
+		name            string
+		namespaces      []runtime.Object
+		expectedRequeue bool
+		expectedWorks   int
+	}{
+		{
+			name:            "the cluster namespace is missing",
+			namespaces:      []runtime.Object{},
+			expectedRequeue: true,
+		},
+		{
+			name:            "the cluster namespace is terminating",
+			namespaces:      []runtime.Object{terminatingNamespace},
+			expectedRequeue: true,
+		},
+		{
+			name:          "the cluster namespace is active",
+			namespaces:    []runtime.Object{newClusterNamespace("test")},
+			expectedWorks: 2,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := &ReconcileManifestWork{
+				clientHolder: &helpers.ClientHolder{
+					RuntimeClient: fake.NewClientBuilder().WithScheme(testscheme).WithObjects(&clusterv1.ManagedCluster{
+						ObjectMeta: v1.ObjectMeta{Name: "test"},
+						Spec:       clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+					}).Build(),
+					OperatorClient: operatorfake.NewSimpleClientset(),
+					KubeClient: kubefake.NewSimpleClientset(
+						append(c.namespaces, testinghelpers.GetImportSecret("test"))...),
+				},
+				scheme:   testscheme,
+				recorder: eventstesting.NewTestingEventRecorder(t),
+			}
+
+			result, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if (result.RequeueAfter == clusterNamespaceRequeuePeriod) != c.expectedRequeue {
+				t.Errorf("expected requeue %v, but got %v", c.expectedRequeue, result)
+			}
+
+			works := &workv1.ManifestWorkList{}
+			if err := r.clientHolder.RuntimeClient.List(context.TODO(), works); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if len(works.Items) != c.expectedWorks {
+				t.Errorf("expected %d works, but got %d", c.expectedWorks, len(works.Items))
+			}
+		})
+	}
+}
+
+func TestReconcileClusterAcceptance(t *testing.T) {
+	cases := []struct {
+		name          string
+		accepted      bool
+		expectedWorks int
+	}{
+		{
+			name: "the managed cluster is not accepted",
+		},
+		{
+			name:          "the managed cluster is accepted",
+			accepted:      true,
+			expectedWorks: 2,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := &ReconcileManifestWork{
+				clientHolder: &helpers.ClientHolder{
+					RuntimeClient: fake.NewClientBuilder().WithScheme(testscheme).WithObjects(&clusterv1.ManagedCluster{
+						ObjectMeta: v1.ObjectMeta{Name: "test"},
+						Spec:       clusterv1.ManagedClusterSpec{HubAcceptsClient: c.accepted},
+					}).Build(),
+					OperatorClient: operatorfake.NewSimpleClientset(),
+					KubeClient: kubefake.NewSimpleClientset(
+						newClusterNamespace("test"), testinghelpers.GetImportSecret("test")),
+				},
+				scheme:   testscheme,
+				recorder: eventstesting.NewTestingEventRecorder(t),
+			}
+
+			result, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if result.Requeue || result.RequeueAfter != 0 {
+				t.Errorf("expected the managed cluster is not requeued, but got %v", result)
+			}
+
+			works := &workv1.ManifestWorkList{}
+			if err := r.clientHolder.RuntimeClient.List(context.TODO(), works); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if len(works.Items) != c.expectedWorks {
+				t.Errorf("expected %d works, but got %d", c.expectedWorks, len(works.Items))
+			}
+		})
+	}
+}
+
+func TestDeleteKlusterletCRDsWorkBlocked(t *testing.T) {
+	cases := []struct {
+		name            string
+		deletionAge     time.Duration
+		expectedBlocked bool
+	}{
+		{
+			name:        "the klusterlet crds work is terminating recently",
+			deletionAge: time.Minute,
+		},
+		{
+			name:            "the klusterlet crds work never finalizes",
+			deletionAge:     klusterletCRDsWorkDeletionBlockedThreshold + time.Minute,
+			expectedBlocked: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			deletionTimestamp := v1.NewTime(time.Now().Add(-c.deletionAge))
+			crdsWork := &workv1.ManifestWork{
+				ObjectMeta: v1.ObjectMeta{
+					Name:              "test-klusterlet-crds",
+					Namespace:         "test",
+					DeletionTimestamp: &deletionTimestamp,
+					Finalizers:        []string{"test.open-cluster-management.io/finalizer"},
+				},
+			}
+			recorder := events.NewInMemoryRecorder("test")
+			r := &ReconcileManifestWork{
+				clientHolder: &helpers.ClientHolder{
+					RuntimeClient: &stuckManifestWorkClient{
+						Client: fake.NewClientBuilder().WithScheme(testscheme).WithObjects(crdsWork).Build(),
+					},
+				},
+				scheme:   testscheme,
+				recorder: recorder,
+			}
+
+			result, err := r.deleteKlusterletCRDsWork(context.TODO(), logf.Log, recorder, &clusterv1.ManagedCluster{
+				ObjectMeta: v1.ObjectMeta{Name: "test"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			blocked := false
+			for _, event := range recorder.Events() {
+				if event.Reason == "ManifestWorkDeletionBlocked" && event.Type == corev1.EventTypeWarning {
+					blocked = true
+				}
+			}
+			if blocked != c.expectedBlocked {
+				t.Errorf("expected the deletion blocked warning %v, but got %v", c.expectedBlocked, blocked)
+			}
+			if c.expectedBlocked && result.RequeueAfter != klusterletCRDsWorkDeletionBlockedThreshold {
+				t.Errorf("expected requeue after %v, but got %v", klusterletCRDsWorkDeletionBlockedThreshold, result)
+			}
+		})
+	}
+}
+
+// stuckManifestWorkClient simulates the manifest works whose finalizers are added back by another controller, the
+// deletion and the patch of the manifest works take no effect
+type stuckManifestWorkClient struct {
+	client.Client
+}
+
+func (c *stuckManifestWorkClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if _, ok := obj.(*workv1.ManifestWork); ok {
+		return nil
+	}
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c *stuckManifestWorkClient) Patch(ctx context.Context, obj client.Object, patch client.Patch,
+	opts ...client.PatchOption) error {
+	if _, ok := obj.(*workv1.ManifestWork); ok {
+		return nil
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func newClusterNamespace(name string) *corev1.Namespace {
+	return &corev1.Namespace{
+		ObjectMeta: v1.ObjectMeta{Name: name},
+		Status:     corev1.NamespaceStatus{Phase: corev1.NamespaceActive},
+	}
+}
+
+func TestReconcileBootstrapHubServer(t *testing.T) {
+	newImportSecret := func(bootstrapServer string) *corev1.Secret {
+		kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: %s
+  name: default-cluster
+contexts:
+- context:
+    cluster: default-cluster
+    user: default-auth
+  name: default-context
+current-context: default-context
+users:
+- name: default-auth
+  user:
+    token: test
+`, bootstrapServer)
+
+		importSecret := testinghelpers.GetImportSecret("test")
+		importSecret.Data["import.yaml"] = []byte(strings.Replace(string(importSecret.Data["import.yaml"]),
+			`kubeconfig: "test"`, fmt.Sprintf("kubeconfig: %q", base64.StdEncoding.EncodeToString([]byte(kubeconfig))), 1))
+		return importSecret
+	}
+
+	cases := []struct {
+		name                 string
+		bootstrapServer      string
+		expectedWorks        bool
+		expectedRequeueAfter time.Duration
+	}{
+		{
+			name:            "the bootstrap hub server is the current hub",
+			bootstrapServer: "https://api.hub.example.com:6443",
+			expectedWorks:   true,
+		},
+		{
+			name:                 "the bootstrap hub server is another hub",
+			bootstrapServer:      "https://api.old-hub.example.com:6443",
+			expectedRequeueAfter: bootstrapHubServerRequeuePeriod,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := &ReconcileManifestWork{
+				clientHolder: &helpers.ClientHolder{
+					RuntimeClient: fake.NewClientBuilder().WithScheme(testscheme).WithObjects(
+						&clusterv1.ManagedCluster{
+							ObjectMeta: v1.ObjectMeta{
+								Name: "test",
+							},
+							Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+						},
+						&ocinfrav1.Infrastructure{
+							ObjectMeta: v1.ObjectMeta{
+								Name: "cluster",
+							},
+							Status: ocinfrav1.InfrastructureStatus{
+								APIServerURL: "https://api.hub.example.com:6443",
+							},
+						},
+					).Build(),
+					OperatorClient: operatorfake.NewSimpleClientset(),
+					KubeClient: kubefake.NewSimpleClientset(
+						newImportSecret(c.bootstrapServer), newClusterNamespace("test")),
+				},
+				scheme:   testscheme,
+				recorder: eventstesting.NewTestingEventRecorder(t),
+			}
+
+			result, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if result.RequeueAfter != c.expectedRequeueAfter {
+				t.Errorf("expected requeue after %v, but got %v", c.expectedRequeueAfter, result.RequeueAfter)
+			}
+
+			works := &workv1.ManifestWorkList{}
+			if err := r.clientHolder.RuntimeClient.List(context.TODO(), works); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if (len(works.Items) != 0) != c.expectedWorks {
+				t.Errorf("expected the klusterlet manifest works are created %v, but got %d works",
+					c.expectedWorks, len(works.Items))
+			}
+		})
+	}
+}
+
+func TestReconcileImportSecretDeleted(t *testing.T) {
+	kubeClient := kubefake.NewSimpleClientset(newClusterNamespace("test"))
+	r := &ReconcileManifestWork{
+		clientHolder: &helpers.ClientHolder{
+			RuntimeClient: fake.NewClientBuilder().WithScheme(testscheme).WithObjects(&clusterv1.ManagedCluster{
+				ObjectMeta: v1.ObjectMeta{
+					Name: "test",
+				},
+				Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+			}).Build(),
+			OperatorClient: operatorfake.NewSimpleClientset(),
+			KubeClient:     kubeClient,
+		},
+		scheme:   testscheme,
+		recorder: eventstesting.NewTestingEventRecorder(t),
+	}
+
+	// the deletion of the import secret enqueues the managed cluster
+	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer queue.ShutDown()
+	deleteEvent := event.DeleteEvent{Object: testinghelpers.GetImportSecret("test")}
+	if !importSecretPredicate.Delete(deleteEvent) {
+		t.Fatalf("expected the deletion of the import secret is handled, but it is filtered")
+	}
+	(&source.ManagedClusterSecretEventHandler{}).Delete(deleteEvent, queue)
+	if queue.Len() != 1 {
+		t.Fatalf("expected one request, but got %d", queue.Len())
+	}
+	item, _ := queue.Get()
+	defer queue.Done(item)
+
+	// the reconcile of the deletion waits for the import secret to be created, it is not requeued
+	result, err := r.Reconcile(context.TODO(), item.(reconcile.Request))
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if result.Requeue || result.RequeueAfter != 0 {
+		t.Errorf("expected the managed cluster is not requeued, but got %v", result)
+	}
+
+	// the import secret is only regenerated by the importconfig controller
+	if _, err := kubeClient.CoreV1().Secrets("test").Get(context.TODO(), "test-import", v1.GetOptions{}); err == nil {
+		t.Errorf("expected the import secret is not created by the manifestwork controller, but it is created")
+	}
+}
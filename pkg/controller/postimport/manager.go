@@ -0,0 +1,80 @@
+// Copyright (c) Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package postimport
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/stolostron/managedcluster-import-controller/pkg/constants"
+	"github.com/stolostron/managedcluster-import-controller/pkg/helpers"
+
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	"k8s.io/client-go/tools/cache"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const controllerName = "postimport-controller"
+
+// webhookTimeout is the timeout of calling a post-import hook webhook
+const webhookTimeout = 30 * time.Second
+
+// Add creates a new post-import hook controller and adds it to the Manager.
+// The Manager will set fields on the Controller and Start it when the Manager is Started.
+func Add(mgr manager.Manager, clientHolder *helpers.ClientHolder,
+	importSecretInformer, autoImportSecretInformer cache.SharedIndexInformer) (string, error) {
+	return controllerName, add(mgr, newReconciler(mgr, clientHolder))
+}
+
+// newReconciler returns a new reconcile.Reconciler
+func newReconciler(mgr manager.Manager, clientHolder *helpers.ClientHolder) reconcile.Reconciler {
+	return &ReconcilePostImportHook{
+		clientHolder: clientHolder,
+		scheme:       mgr.GetScheme(),
+		recorder:     helpers.NewEventRecorder(clientHolder.KubeClient, controllerName),
+		httpClient:   &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// adds a new Controller to mgr with r as the reconcile.Reconciler
+func add(mgr manager.Manager, r reconcile.Reconciler) error {
+	c, err := controller.New(controllerName, mgr, controller.Options{
+		Reconciler:              r,
+		MaxConcurrentReconciles: helpers.GetMaxConcurrentReconcilesFor(controllerName),
+	})
+	if err != nil {
+		return err
+	}
+
+	// watch the managed clusters that have a post-import hook
+	if err := c.Watch(
+		&source.Kind{Type: &clusterv1.ManagedCluster{}},
+		&handler.EnqueueRequestForObject{},
+		predicate.Predicate(predicate.Funcs{
+			GenericFunc: func(e event.GenericEvent) bool { return false },
+			DeleteFunc:  func(e event.DeleteEvent) bool { return false },
+			CreateFunc:  func(e event.CreateEvent) bool { return hasPostImportHook(e.Object) },
+			UpdateFunc:  func(e event.UpdateEvent) bool { return hasPostImportHook(e.ObjectNew) },
+		}),
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func hasPostImportHook(obj client.Object) bool {
+	annotations := obj.GetAnnotations()
+	return len(annotations[constants.PostImportHookConfigMapAnnotation]) > 0 ||
+		len(annotations[constants.PostImportHookWebhookAnnotation]) > 0
+}
@@ -0,0 +1,263 @@
+// Copyright (c) Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package postimport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	goerrors "errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/stolostron/managedcluster-import-controller/pkg/constants"
+	"github.com/stolostron/managedcluster-import-controller/pkg/helpers"
+
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	workv1 "open-cluster-management.io/api/work/v1"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// postImportHookWebhookConditionType records the webhook of the post-import hook is called, so the webhook is
+// not called again when the other part of the hook is retried
+const postImportHookWebhookConditionType = "ManagedClusterPostImportHookWebhookSucceeded"
+
+var (
+	// errWebhookNotAllowed means the webhook is not configured by the operator, retrying does not help
+	errWebhookNotAllowed = goerrors.New("the post-import hook webhook is not allowed")
+
+	// errConfigMapNotAllowed means the ConfigMap is not configured by the operator, retrying does not help
+	errConfigMapNotAllowed = goerrors.New("the post-import hook ConfigMap is not allowed")
+)
+
+var log = logf.Log.WithName(controllerName)
+
+// webhookPayload is the request body of a post-import hook webhook
+type webhookPayload struct {
+	ClusterName string `json:"clusterName"`
+}
+
+// ReconcilePostImportHook reconciles the managed clusters that have a post-import hook to run the hook
+type ReconcilePostImportHook struct {
+	clientHolder *helpers.ClientHolder
+	scheme       *runtime.Scheme
+	recorder     events.Recorder
+	httpClient   *http.Client
+}
+
+// blank assignment to verify that ReconcilePostImportHook implements reconcile.Reconciler
+var _ reconcile.Reconciler = &ReconcilePostImportHook{}
+
+// Reconcile runs the post-import hook of a managed cluster once the managed cluster is imported.
+// The hook is specified by the post-import-hook-configmap annotation or the post-import-hook-webhook annotation,
+// once the hook succeeds, the ManagedClusterPostImportHookSucceeded condition is set to true and the hook will
+// not be run again.
+//
+// Note: The Controller will requeue the Request to be processed again if the returned error is non-nil or
+// Result.Requeue is true, otherwise upon completion it will remove the work from the queue.
+func (r *ReconcilePostImportHook) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	reqLogger := log.WithValues("Request.Name", request.Name)
+
+	managedCluster := &clusterv1.ManagedCluster{}
+	err := r.clientHolder.RuntimeClient.Get(ctx, types.NamespacedName{Name: request.Name}, managedCluster)
+	if errors.IsNotFound(err) {
+		// the managed cluster could have been deleted, do nothing
+		return reconcile.Result{}, nil
+	}
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	if !managedCluster.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, nil
+	}
+
+	if !hasPostImportHook(managedCluster) {
+		return reconcile.Result{}, nil
+	}
+
+	if !meta.IsStatusConditionTrue(managedCluster.Status.Conditions, constants.ImportSucceededConditionType) {
+		// the managed cluster is not imported yet
+		return reconcile.Result{}, nil
+	}
+
+	if meta.IsStatusConditionTrue(managedCluster.Status.Conditions, constants.PostImportHookSucceededConditionType) {
+		// the post-import hook has been run
+		return reconcile.Result{}, nil
+	}
+
+	reqLogger.Info("Running the post-import hook of the managed cluster")
+
+	hookCondition := metav1.Condition{
+		Type:    constants.PostImportHookSucceededConditionType,
+		Status:  metav1.ConditionTrue,
+		Message: "Post-import hook succeeded",
+		Reason:  "PostImportHookSucceeded",
+	}
+
+	conds := []metav1.Condition{}
+	errs := []error{}
+	webhookCalled, err := r.runPostImportHook(ctx, managedCluster)
+	if webhookCalled {
+		conds = append(conds, metav1.Condition{
+			Type:    postImportHookWebhookConditionType,
+			Status:  metav1.ConditionTrue,
+			Message: webhookCalledMessage(managedCluster.Annotations[constants.PostImportHookWebhookAnnotation]),
+			Reason:  "PostImportHookWebhookCalled",
+		})
+	}
+	if err != nil {
+		// the webhook or the ConfigMap that is not allowed is reported by the condition only, it is retried once the
+		// annotation of the managed cluster is changed
+		if err := utilerrors.FilterOut(err, func(e error) bool {
+			return goerrors.Is(e, errWebhookNotAllowed) || goerrors.Is(e, errConfigMapNotAllowed)
+		}); err != nil {
+			errs = append(errs, err)
+		}
+
+		hookCondition.Status = metav1.ConditionFalse
+		hookCondition.Message = fmt.Sprintf("Unable to run the post-import hook of %s: %s", request.Name, err.Error())
+		hookCondition.Reason = "PostImportHookFailed"
+	}
+	conds = append(conds, hookCondition)
+
+	if err := helpers.UpdateManagedClusterStatusConditions(
+		r.clientHolder.RuntimeClient, r.recorder, request.Name, conds...); err != nil {
+		errs = append(errs, err)
+	}
+
+	return reconcile.Result{}, utilerrors.NewAggregate(errs)
+}
+
+// runPostImportHook runs the post-import hook of the managed cluster, the webhook is skipped if it has been called
+// with the current webhook URL, returns true if the webhook is called in this run
+func (r *ReconcilePostImportHook) runPostImportHook(ctx context.Context,
+	managedCluster *clusterv1.ManagedCluster) (bool, error) {
+	errs := []error{}
+	if configMapName := managedCluster.Annotations[constants.PostImportHookConfigMapAnnotation]; len(configMapName) > 0 {
+		if err := r.applyPostImportHookManifests(ctx, managedCluster, configMapName); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	webhookCalled := false
+	webhookURL := managedCluster.Annotations[constants.PostImportHookWebhookAnnotation]
+	if len(webhookURL) > 0 && !isWebhookCalled(managedCluster, webhookURL) {
+		if err := r.callPostImportHookWebhook(ctx, managedCluster, webhookURL); err != nil {
+			errs = append(errs, err)
+		} else {
+			webhookCalled = true
+		}
+	}
+
+	return webhookCalled, utilerrors.NewAggregate(errs)
+}
+
+// applyPostImportHookManifests applies the manifests in the data of the ConfigMap to the managed cluster with
+// a manifest work, the data keys are sorted to keep the manifests in a stable order. Only the ConfigMaps that are
+// configured by the operator can be applied.
+func (r *ReconcilePostImportHook) applyPostImportHookManifests(ctx context.Context,
+	managedCluster *clusterv1.ManagedCluster, configMapName string) error {
+	if !helpers.IsPostImportHookConfigMapAllowed(configMapName) {
+		return fmt.Errorf("%w: %s is not configured by the operator", errConfigMapNotAllowed, configMapName)
+	}
+
+	configMap, err := r.clientHolder.KubeClient.CoreV1().ConfigMaps(managedCluster.Name).Get(
+		ctx, configMapName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	keys := []string{}
+	for key := range configMap.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	manifests := []workv1.Manifest{}
+	for _, key := range keys {
+		keyManifests, err := helpers.BuildUnstructuredManifests([]byte(configMap.Data[key]))
+		if err != nil {
+			return fmt.Errorf("invalid %s in the ConfigMap %s/%s: %v", key, configMap.Namespace, configMap.Name, err)
+		}
+		manifests = append(manifests, keyManifests...)
+	}
+
+	if len(manifests) == 0 {
+		return fmt.Errorf("there are no manifests in the ConfigMap %s/%s", configMap.Namespace, configMap.Name)
+	}
+
+	hookWork := &workv1.ManifestWork{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%s", managedCluster.Name, constants.PostImportHookSuffix),
+			Namespace: managedCluster.Name,
+		},
+		Spec: workv1.ManifestWorkSpec{
+			Workload: workv1.ManifestsTemplate{
+				Manifests: manifests,
+			},
+		},
+	}
+	helpers.MergeManifestWorkMetadata(hookWork)
+
+	return helpers.ApplyResources(r.clientHolder, r.recorder, r.scheme, managedCluster, hookWork)
+}
+
+// callPostImportHookWebhook posts the managed cluster name to the webhook, a non 2xx response is treated as
+// a failure. Only the webhooks that are configured by the operator can be called.
+func (r *ReconcilePostImportHook) callPostImportHookWebhook(ctx context.Context,
+	managedCluster *clusterv1.ManagedCluster, webhookURL string) error {
+	if !helpers.IsPostImportHookWebhookAllowed(webhookURL) {
+		return fmt.Errorf("%w: %s is not configured by the operator", errWebhookNotAllowed, webhookURL)
+	}
+
+	payload, err := json.Marshal(webhookPayload{ClusterName: managedCluster.Name})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call the post-import hook webhook: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("the post-import hook webhook returns an unexpected status %d", resp.StatusCode)
+	}
+
+	r.recorder.Eventf("PostImportHookWebhookCalled",
+		"The post-import hook webhook of managed cluster %s is called", managedCluster.Name)
+	return nil
+}
+
+// isWebhookCalled returns true if the webhook condition records the webhook is called with the webhook URL, the
+// webhook is called again once the webhook URL is changed
+func isWebhookCalled(managedCluster *clusterv1.ManagedCluster, webhookURL string) bool {
+	cond := meta.FindStatusCondition(managedCluster.Status.Conditions, postImportHookWebhookConditionType)
+	return cond != nil && cond.Status == metav1.ConditionTrue && cond.Message == webhookCalledMessage(webhookURL)
+}
+
+// webhookCalledMessage returns the message of the webhook condition, it records the called webhook URL
+func webhookCalledMessage(webhookURL string) string {
+	return fmt.Sprintf("Post-import hook webhook %s is called", webhookURL)
+}
@@ -0,0 +1,310 @@
+// Copyright (c) Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package postimport
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stolostron/managedcluster-import-controller/pkg/constants"
+	"github.com/stolostron/managedcluster-import-controller/pkg/helpers"
+
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	workv1 "open-cluster-management.io/api/work/v1"
+
+	"github.com/openshift/library-go/pkg/operator/events/eventstesting"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var testscheme = scheme.Scheme
+
+func init() {
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+	testscheme.AddKnownTypes(workv1.SchemeGroupVersion, &workv1.ManifestWork{})
+	testscheme.AddKnownTypes(workv1.SchemeGroupVersion, &workv1.ManifestWorkList{})
+}
+
+func newManagedCluster(annotations map[string]string, imported bool) *clusterv1.ManagedCluster {
+	cluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test",
+			Annotations: annotations,
+		},
+	}
+	if imported {
+		cluster.Status.Conditions = []metav1.Condition{
+			{
+				Type:   constants.ImportSucceededConditionType,
+				Status: metav1.ConditionTrue,
+				Reason: "ManagedClusterImported",
+			},
+		}
+	}
+	return cluster
+}
+
+func TestReconcile(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		if req.URL.Path == "/failed" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	os.Setenv("POST_IMPORT_HOOK_WEBHOOK_URLS", server.URL+","+server.URL+"/failed")
+	defer os.Unsetenv("POST_IMPORT_HOOK_WEBHOOK_URLS")
+	os.Setenv("POST_IMPORT_HOOK_CONFIGMAPS", "bootstrap")
+	defer os.Unsetenv("POST_IMPORT_HOOK_CONFIGMAPS")
+
+	webhookCalledCluster := newManagedCluster(map[string]string{
+		constants.PostImportHookWebhookAnnotation: server.URL,
+	}, true)
+	webhookCalledCluster.Status.Conditions = append(webhookCalledCluster.Status.Conditions,
+		metav1.Condition{
+			Type:    postImportHookWebhookConditionType,
+			Status:  metav1.ConditionTrue,
+			Reason:  "PostImportHookWebhookCalled",
+			Message: webhookCalledMessage(server.URL + "/previous"),
+		},
+		metav1.Condition{
+			Type:   constants.PostImportHookSucceededConditionType,
+			Status: metav1.ConditionFalse,
+			Reason: "PostImportHookFailed",
+		},
+	)
+
+	cases := []struct {
+		name          string
+		objs          []client.Object
+		kubeObjs      []runtime.Object
+		expectedErr   bool
+		expectedCalls int
+		validateFunc  func(t *testing.T, runtimeClient client.Client)
+	}{
+		{
+			name: "no post-import hook",
+			objs: []client.Object{
+				newManagedCluster(nil, true),
+			},
+			validateFunc: func(t *testing.T, runtimeClient client.Client) {
+				assertHookCondition(t, runtimeClient, "")
+			},
+		},
+		{
+			name: "the cluster is not imported",
+			objs: []client.Object{
+				newManagedCluster(map[string]string{
+					constants.PostImportHookWebhookAnnotation: server.URL,
+				}, false),
+			},
+			validateFunc: func(t *testing.T, runtimeClient client.Client) {
+				assertHookCondition(t, runtimeClient, "")
+			},
+		},
+		{
+			name: "the webhook is called once",
+			objs: []client.Object{
+				newManagedCluster(map[string]string{
+					constants.PostImportHookWebhookAnnotation: server.URL,
+				}, true),
+			},
+			expectedCalls: 1,
+			validateFunc: func(t *testing.T, runtimeClient client.Client) {
+				assertHookCondition(t, runtimeClient, metav1.ConditionTrue)
+			},
+		},
+		{
+			name: "the webhook is failed",
+			objs: []client.Object{
+				newManagedCluster(map[string]string{
+					constants.PostImportHookWebhookAnnotation: server.URL + "/failed",
+				}, true),
+			},
+			expectedErr:   true,
+			expectedCalls: 2,
+			validateFunc: func(t *testing.T, runtimeClient client.Client) {
+				assertHookCondition(t, runtimeClient, metav1.ConditionFalse)
+			},
+		},
+		{
+			name: "the webhook is not allowed",
+			objs: []client.Object{
+				newManagedCluster(map[string]string{
+					constants.PostImportHookWebhookAnnotation: server.URL + "/unknown",
+				}, true),
+			},
+			validateFunc: func(t *testing.T, runtimeClient client.Client) {
+				assertHookCondition(t, runtimeClient, metav1.ConditionFalse)
+			},
+		},
+		{
+			name: "the webhook is not called again when the configmap is failed",
+			objs: []client.Object{
+				newManagedCluster(map[string]string{
+					constants.PostImportHookWebhookAnnotation:   server.URL,
+					constants.PostImportHookConfigMapAnnotation: "bootstrap",
+				}, true),
+			},
+			expectedErr:   true,
+			expectedCalls: 1,
+			validateFunc: func(t *testing.T, runtimeClient client.Client) {
+				assertHookCondition(t, runtimeClient, metav1.ConditionFalse)
+			},
+		},
+		{
+			name: "apply the manifests of the configmap",
+			objs: []client.Object{
+				newManagedCluster(map[string]string{
+					constants.PostImportHookConfigMapAnnotation: "bootstrap",
+				}, true),
+			},
+			kubeObjs: []runtime.Object{
+				&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "bootstrap",
+						Namespace: "test",
+					},
+					Data: map[string]string{
+						"namespace.yaml": "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: bootstrap\n",
+						"policy.yaml": "apiVersion: example.com/v1\nkind: Policy\nmetadata:\n  name: bootstrap\n" +
+							"  namespace: bootstrap\n",
+					},
+				},
+			},
+			validateFunc: func(t *testing.T, runtimeClient client.Client) {
+				assertHookCondition(t, runtimeClient, metav1.ConditionTrue)
+
+				work := &workv1.ManifestWork{}
+				err := runtimeClient.Get(context.TODO(),
+					types.NamespacedName{Namespace: "test", Name: "test-post-import-hook"}, work)
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				if len(work.Spec.Workload.Manifests) != 2 {
+					t.Errorf("expected two manifests, but got %d", len(work.Spec.Workload.Manifests))
+				}
+			},
+		},
+		{
+			name:          "the webhook is called again once the webhook url is changed",
+			objs:          []client.Object{webhookCalledCluster},
+			expectedCalls: 1,
+			validateFunc: func(t *testing.T, runtimeClient client.Client) {
+				assertHookCondition(t, runtimeClient, metav1.ConditionTrue)
+			},
+		},
+		{
+			name: "the configmap is not allowed",
+			objs: []client.Object{
+				newManagedCluster(map[string]string{
+					constants.PostImportHookConfigMapAnnotation: "cluster-admin",
+				}, true),
+			},
+			kubeObjs: []runtime.Object{
+				&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster-admin",
+						Namespace: "test",
+					},
+					Data: map[string]string{
+						"namespace.yaml": "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: bootstrap\n",
+					},
+				},
+			},
+			validateFunc: func(t *testing.T, runtimeClient client.Client) {
+				assertHookCondition(t, runtimeClient, metav1.ConditionFalse)
+
+				works := &workv1.ManifestWorkList{}
+				if err := runtimeClient.List(context.TODO(), works); err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				if len(works.Items) != 0 {
+					t.Errorf("expected no manifest works, but got %d", len(works.Items))
+				}
+			},
+		},
+		{
+			name: "the configmap does not exist",
+			objs: []client.Object{
+				newManagedCluster(map[string]string{
+					constants.PostImportHookConfigMapAnnotation: "bootstrap",
+				}, true),
+			},
+			expectedErr: true,
+			validateFunc: func(t *testing.T, runtimeClient client.Client) {
+				assertHookCondition(t, runtimeClient, metav1.ConditionFalse)
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			calls = 0
+			runtimeClient := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(c.objs...).Build()
+			r := &ReconcilePostImportHook{
+				clientHolder: &helpers.ClientHolder{
+					KubeClient:    kubefake.NewSimpleClientset(c.kubeObjs...),
+					RuntimeClient: runtimeClient,
+				},
+				scheme:     testscheme,
+				recorder:   eventstesting.NewTestingEventRecorder(t),
+				httpClient: server.Client(),
+			}
+
+			// reconcile twice to make sure the hook only runs once after it succeeds
+			for i := 0; i < 2; i++ {
+				_, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}})
+				if c.expectedErr && err == nil {
+					t.Errorf("expected error, but failed")
+				}
+				if !c.expectedErr && err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			}
+
+			if calls != c.expectedCalls {
+				t.Errorf("expected the webhook is called %d times, but got %d", c.expectedCalls, calls)
+			}
+
+			c.validateFunc(t, runtimeClient)
+		})
+	}
+}
+
+func assertHookCondition(t *testing.T, runtimeClient client.Client, expectedStatus metav1.ConditionStatus) {
+	cluster := &clusterv1.ManagedCluster{}
+	if err := runtimeClient.Get(context.TODO(), types.NamespacedName{Name: "test"}, cluster); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	cond := meta.FindStatusCondition(cluster.Status.Conditions, constants.PostImportHookSucceededConditionType)
+	if len(expectedStatus) == 0 {
+		if cond != nil {
+			t.Errorf("unexpected condition %v", cond)
+		}
+		return
+	}
+
+	if cond == nil || cond.Status != expectedStatus {
+		t.Errorf("expected the condition status %s, but got %v", expectedStatus, cond)
+	}
+}
@@ -51,30 +51,18 @@ func newReconciler(mgr manager.Manager, clientHolder *helpers.ClientHolder) reco
 func add(importSecretInformer cache.SharedIndexInformer, mgr manager.Manager, r reconcile.Reconciler) error {
 	c, err := controller.New(controllerName, mgr, controller.Options{
 		Reconciler:              r,
-		MaxConcurrentReconciles: helpers.GetMaxConcurrentReconciles(),
+		MaxConcurrentReconciles: helpers.GetMaxConcurrentReconcilesFor(controllerName),
 	})
 	if err != nil {
 		return err
 	}
 
-	// watch the import-secret
+	// watch the import-secret, the cluster will be re-imported once the import secret data is changed, e.g. the
+	// bootstrap token is rotated
 	if err := c.Watch(
 		source.NewImportSecretSource(importSecretInformer),
 		&source.ManagedClusterSecretEventHandler{},
-		predicate.Predicate(predicate.Funcs{
-			GenericFunc: func(e event.GenericEvent) bool { return false },
-			DeleteFunc:  func(e event.DeleteEvent) bool { return false },
-			CreateFunc:  func(e event.CreateEvent) bool { return true },
-			UpdateFunc: func(e event.UpdateEvent) bool {
-				new, okNew := e.ObjectNew.(*corev1.Secret)
-				old, okOld := e.ObjectOld.(*corev1.Secret)
-				if okNew && okOld {
-					return !equality.Semantic.DeepEqual(old.Data, new.Data)
-				}
-
-				return false
-			},
-		}),
+		importSecretPredicate,
 	); err != nil {
 		return err
 	}
@@ -147,3 +135,20 @@ func add(importSecretInformer cache.SharedIndexInformer, mgr manager.Manager, r
 
 	return nil
 }
+
+// importSecretPredicate filters the import secret events, only the created import secrets and the import secrets
+// whose data is changed are handled
+var importSecretPredicate = predicate.Predicate(predicate.Funcs{
+	GenericFunc: func(e event.GenericEvent) bool { return false },
+	DeleteFunc:  func(e event.DeleteEvent) bool { return false },
+	CreateFunc:  func(e event.CreateEvent) bool { return true },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		new, okNew := e.ObjectNew.(*corev1.Secret)
+		old, okOld := e.ObjectOld.(*corev1.Secret)
+		if okNew && okOld {
+			return !equality.Semantic.DeepEqual(old.Data, new.Data)
+		}
+
+		return false
+	},
+})
@@ -12,25 +12,20 @@ import (
 	"github.com/stolostron/managedcluster-import-controller/pkg/helpers"
 
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
-	workv1 "open-cluster-management.io/api/work/v1"
 
 	"github.com/openshift/library-go/pkg/operator/events"
 
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 
-	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
-var log = logf.Log.WithName(controllerName)
-
 // ReconcileLocalCluster reconciles the import secret of a self managed cluster to import the managed cluster
 type ReconcileLocalCluster struct {
 	clientHolder *helpers.ClientHolder
@@ -48,7 +43,8 @@ var _ reconcile.Reconciler = &ReconcileLocalCluster{}
 // Note: The Controller will requeue the Request to be processed again if the returned error is non-nil or
 // Result.Requeue is true, otherwise upon completion it will remove the work from the queue.
 func (r *ReconcileLocalCluster) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
-	reqLogger := log.WithValues("Request.Name", request.Name)
+	// the logger of the reconcile context has the controller name and the request
+	reqLogger := logf.FromContext(ctx, "Request.Name", request.Name)
 	reqLogger.Info("Reconciling self managed cluster")
 
 	managedCluster := &clusterv1.ManagedCluster{}
@@ -62,15 +58,37 @@ func (r *ReconcileLocalCluster) Reconcile(ctx context.Context, request reconcile
 	}
 
 	if selfManaged, ok := managedCluster.Labels[constants.SelfManagedLabel]; !ok || !strings.EqualFold(selfManaged, "true") {
-		log.Info(fmt.Sprintf("The managed cluster %s is not self managed cluster", request.Name))
+		reqLogger.Info(fmt.Sprintf("The managed cluster %s is not self managed cluster", request.Name))
+		r.recorder.Eventf("SelfManagedClusterImportSkippedNotSelfManaged",
+			"The managed cluster %s is not self managed cluster, skipped", request.Name)
 		return reconcile.Result{}, nil
 	}
 
+	// a self managed cluster is imported by deploying the klusterlet on the hub cluster itself, so only the default
+	// mode is supported
+	if mode := helpers.DetermineKlusterletMode(managedCluster); mode != constants.KlusterletDeployModeDefault {
+		reqLogger.Info(fmt.Sprintf("The self managed cluster %s is in %s mode, skipped", request.Name, mode))
+		return reconcile.Result{}, helpers.UpdateManagedClusterStatus(
+			r.clientHolder.RuntimeClient,
+			r.recorder,
+			request.Name,
+			metav1.Condition{
+				Type:   constants.ImportSucceededConditionType,
+				Status: metav1.ConditionFalse,
+				Message: fmt.Sprintf("Unable to import %s: the self managed cluster cannot be imported in %s mode",
+					request.Name, mode),
+				Reason: "ManagedClusterDeployModeNotSupported",
+			},
+		)
+	}
+
 	// if there is an auto import secret in the managed cluster namespace, we will use the auto import secret to import
 	// the cluster
 	_, err = r.clientHolder.KubeClient.CoreV1().Secrets(request.Name).Get(ctx, constants.AutoImportSecretName, metav1.GetOptions{})
 	if err == nil {
-		log.Info(fmt.Sprintf("The self managed cluster %s has auto import secret, skipped", request.Name))
+		reqLogger.Info(fmt.Sprintf("The self managed cluster %s has auto import secret, skipped", request.Name))
+		r.recorder.Eventf("SelfManagedClusterImportSkippedAutoImportSecretExists",
+			"The self managed cluster %s has auto import secret, skipped", request.Name)
 		return reconcile.Result{}, nil
 	}
 	if !errors.IsNotFound(err) {
@@ -87,38 +105,46 @@ func (r *ReconcileLocalCluster) Reconcile(ctx context.Context, request reconcile
 		return reconcile.Result{}, err
 	}
 
-	// ensure the klusterlet manifest works exist
-	listOpts := &client.ListOptions{
-		Namespace:     request.Name,
-		LabelSelector: labels.SelectorFromSet(map[string]string{constants.KlusterletWorksLabel: "true"}),
-	}
-	manifestWorks := &workv1.ManifestWorkList{}
-	if err := r.clientHolder.RuntimeClient.List(ctx, manifestWorks, listOpts); err != nil {
+	// ensure both the klusterlet crds manifest work and the klusterlet manifest work exist, other manifest works
+	// with the klusterlet works label are ignored
+	crdsWork, klusterletWork, err := helpers.ListKlusterletManifestWorks(ctx, r.clientHolder.RuntimeClient, request.Name)
+	if err != nil {
 		return reconcile.Result{}, err
 	}
-	if len(manifestWorks.Items) != 2 {
+	if crdsWork == nil || klusterletWork == nil {
 		reqLogger.Info(fmt.Sprintf("Waiting for klusterlet manifest works for managed cluster %s", request.Name))
 		return reconcile.Result{}, nil
 	}
 
 	importCondition := metav1.Condition{
-		Type:    "ManagedClusterImportSucceeded",
+		Type:    constants.ImportSucceededConditionType,
 		Status:  metav1.ConditionTrue,
 		Message: "Import succeeded",
 		Reason:  "ManagedClusterImported",
 	}
 
 	errs := []error{}
-	err = helpers.ImportManagedClusterFromSecret(r.clientHolder, r.restMapper, r.recorder, importSecret)
+	conditions := []metav1.Condition{}
+	importResult, err := helpers.ImportManagedClusterFromSecretWithResult(r.clientHolder, r.restMapper, r.recorder,
+		importSecret, helpers.GetImportScope(managedCluster))
 	if err != nil {
 		errs = append(errs, err)
 
 		importCondition.Status = metav1.ConditionFalse
 		importCondition.Message = fmt.Sprintf("Unable to import %s: %s", request.Name, err.Error())
 		importCondition.Reason = "ManagedClusterNotImported"
+		conditions = append(conditions, importCondition)
+	} else {
+		conditions = append(conditions, importCondition, helpers.KlusterletCRDsSkippedCondition(importResult))
+
+		if err := helpers.EnsureClusterIDAnnotation(ctx, r.clientHolder.RuntimeClient, r.recorder, r.clientHolder.KubeClient,
+			managedCluster); err != nil {
+			errs = append(errs, err)
+		}
 	}
 
-	err = helpers.UpdateManagedClusterStatus(r.clientHolder.RuntimeClient, r.recorder, request.Name, importCondition)
+	err = helpers.UpdateManagedClusterStatusConditions(r.clientHolder.RuntimeClient, r.recorder, request.Name,
+		conditions...)
 	if err != nil {
 		errs = append(errs, err)
 	}
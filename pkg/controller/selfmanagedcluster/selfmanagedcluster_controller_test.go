@@ -5,6 +5,7 @@ package selfmanagedcluster
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/stolostron/managedcluster-import-controller/pkg/constants"
@@ -16,11 +17,13 @@ import (
 	operatorv1 "open-cluster-management.io/api/operator/v1"
 	workv1 "open-cluster-management.io/api/work/v1"
 
+	"github.com/openshift/library-go/pkg/operator/events"
 	"github.com/openshift/library-go/pkg/operator/events/eventstesting"
 
 	corev1 "k8s.io/api/core/v1"
 	crdv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	apiextensionsfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -30,6 +33,7 @@ import (
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -198,6 +202,199 @@ func TestReconcile(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "self managed cluster in hosted mode",
+			objs: []client.Object{
+				&clusterv1.ManagedCluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "local-cluster",
+						Labels: map[string]string{
+							"local-cluster": "true",
+						},
+						Annotations: map[string]string{
+							constants.KlusterletDeployModeAnnotation: constants.KlusterletDeployModeHosted,
+						},
+					},
+				},
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "local-cluster-klusterlet-crds",
+						Namespace: "local-cluster",
+						Labels: map[string]string{
+							constants.KlusterletWorksLabel: "true",
+						},
+					},
+				},
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "local-cluster-klusterlet",
+						Namespace: "local-cluster",
+						Labels: map[string]string{
+							constants.KlusterletWorksLabel: "true",
+						},
+					},
+				},
+			},
+			secrets: []runtime.Object{
+				testinghelpers.GetImportSecret("local-cluster"),
+			},
+			validateFunc: func(t *testing.T, runtimeClient client.Client) {
+				cluster := &clusterv1.ManagedCluster{}
+				err := runtimeClient.Get(context.TODO(), types.NamespacedName{Name: "local-cluster"}, cluster)
+				if err != nil {
+					t.Errorf("unexpected error %v", err)
+				}
+				condition := meta.FindStatusCondition(cluster.Status.Conditions, constants.ImportSucceededConditionType)
+				if condition == nil || condition.Status != metav1.ConditionFalse ||
+					condition.Reason != "ManagedClusterDeployModeNotSupported" {
+					t.Errorf("unexpected condition %v", condition)
+				}
+			},
+		},
+		{
+			name: "self managed cluster in default mode",
+			objs: []client.Object{
+				&clusterv1.ManagedCluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "local-cluster",
+						Labels: map[string]string{
+							"local-cluster": "true",
+						},
+						Annotations: map[string]string{
+							constants.KlusterletDeployModeAnnotation: constants.KlusterletDeployModeDefault,
+						},
+					},
+				},
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "local-cluster-klusterlet-crds",
+						Namespace: "local-cluster",
+						Labels: map[string]string{
+							constants.KlusterletWorksLabel: "true",
+						},
+					},
+				},
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "local-cluster-klusterlet",
+						Namespace: "local-cluster",
+						Labels: map[string]string{
+							constants.KlusterletWorksLabel: "true",
+						},
+					},
+				},
+			},
+			secrets: []runtime.Object{
+				testinghelpers.GetImportSecret("local-cluster"),
+			},
+			validateFunc: func(t *testing.T, runtimeClient client.Client) {
+				cluster := &clusterv1.ManagedCluster{}
+				err := runtimeClient.Get(context.TODO(), types.NamespacedName{Name: "local-cluster"}, cluster)
+				if err != nil {
+					t.Errorf("unexpected error %v", err)
+				}
+				if !meta.IsStatusConditionTrue(cluster.Status.Conditions, constants.ImportSucceededConditionType) {
+					t.Errorf("unexpected conditions %v", cluster.Status.Conditions)
+				}
+			},
+		},
+		{
+			name: "only klusterlet crds work",
+			objs: []client.Object{
+				&clusterv1.ManagedCluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "local-cluster",
+						Labels: map[string]string{
+							"local-cluster": "true",
+						},
+					},
+				},
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "local-cluster-klusterlet-crds",
+						Namespace: "local-cluster",
+						Labels: map[string]string{
+							constants.KlusterletWorksLabel: "true",
+						},
+					},
+				},
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "local-cluster-post-import-hook",
+						Namespace: "local-cluster",
+						Labels: map[string]string{
+							constants.KlusterletWorksLabel: "true",
+						},
+					},
+				},
+			},
+			secrets: []runtime.Object{
+				testinghelpers.GetImportSecret("local-cluster"),
+			},
+			validateFunc: func(t *testing.T, runtimeClient client.Client) {
+				cluster := &clusterv1.ManagedCluster{}
+				err := runtimeClient.Get(context.TODO(), types.NamespacedName{Name: "local-cluster"}, cluster)
+				if err != nil {
+					t.Errorf("unexpected error %v", err)
+				}
+				if len(cluster.Status.Conditions) != 0 {
+					t.Errorf("unexpected condistions")
+				}
+			},
+		},
+		{
+			name: "import cluster with an extra labeled work",
+			objs: []client.Object{
+				&clusterv1.ManagedCluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "local-cluster",
+						Labels: map[string]string{
+							"local-cluster": "true",
+						},
+					},
+				},
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "local-cluster-klusterlet-crds",
+						Namespace: "local-cluster",
+						Labels: map[string]string{
+							constants.KlusterletWorksLabel: "true",
+						},
+					},
+				},
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "local-cluster-klusterlet",
+						Namespace: "local-cluster",
+						Labels: map[string]string{
+							constants.KlusterletWorksLabel: "true",
+						},
+					},
+				},
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "local-cluster-extra",
+						Namespace: "local-cluster",
+						Labels: map[string]string{
+							constants.KlusterletWorksLabel: "true",
+						},
+					},
+				},
+			},
+			secrets: []runtime.Object{
+				testinghelpers.GetImportSecret("local-cluster"),
+			},
+			validateFunc: func(t *testing.T, runtimeClient client.Client) {
+				cluster := &clusterv1.ManagedCluster{}
+				err := runtimeClient.Get(context.TODO(), types.NamespacedName{Name: "local-cluster"}, cluster)
+				if err != nil {
+					t.Errorf("unexpected error %v", err)
+				}
+				if len(cluster.Status.Conditions) == 0 {
+					t.Errorf("unexpected condistions")
+				}
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -227,3 +424,139 @@ func TestReconcile(t *testing.T) {
 		})
 	}
 }
+
+func TestReconcileImportSecretChanged(t *testing.T) {
+	objs := []client.Object{
+		&clusterv1.ManagedCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "local-cluster",
+				Labels: map[string]string{
+					"local-cluster": "true",
+				},
+			},
+		},
+		&workv1.ManifestWork{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "local-cluster-klusterlet-crds",
+				Namespace: "local-cluster",
+				Labels: map[string]string{
+					constants.KlusterletWorksLabel: "true",
+				},
+			},
+		},
+		&workv1.ManifestWork{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "local-cluster-klusterlet",
+				Namespace: "local-cluster",
+				Labels: map[string]string{
+					constants.KlusterletWorksLabel: "true",
+				},
+			},
+		},
+	}
+	importSecret := testinghelpers.GetImportSecret("local-cluster")
+
+	r := &ReconcileLocalCluster{
+		clientHolder: &helpers.ClientHolder{
+			KubeClient:          kubefake.NewSimpleClientset(importSecret),
+			APIExtensionsClient: apiextensionsfake.NewSimpleClientset(),
+			OperatorClient:      operatorfake.NewSimpleClientset(),
+			RuntimeClient:       fake.NewClientBuilder().WithScheme(testscheme).WithObjects(objs...).Build(),
+		},
+		scheme:     testscheme,
+		recorder:   eventstesting.NewTestingEventRecorder(t),
+		restMapper: restmapper.NewDiscoveryRESTMapper(apiGroupResources),
+	}
+
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: "local-cluster"}}
+	if _, err := r.Reconcile(context.TODO(), request); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// rotate the bootstrap hub kubeconfig in the import secret
+	updatedImportSecret := importSecret.DeepCopy()
+	updatedImportSecret.Data["import.yaml"] = []byte(strings.Replace(string(importSecret.Data["import.yaml"]),
+		`kubeconfig: "test"`, `kubeconfig: "dGVzdDE="`, 1))
+	if _, err := r.clientHolder.KubeClient.CoreV1().Secrets("local-cluster").Update(
+		context.TODO(), updatedImportSecret, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !importSecretPredicate.Update(event.UpdateEvent{ObjectOld: importSecret, ObjectNew: updatedImportSecret}) {
+		t.Errorf("expected the import secret data change is handled, but failed")
+	}
+
+	if _, err := r.Reconcile(context.TODO(), request); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	bootstrapSecret, err := r.clientHolder.KubeClient.CoreV1().Secrets("open-cluster-management-agent").Get(
+		context.TODO(), "bootstrap-hub-kubeconfig", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(bootstrapSecret.Data["kubeconfig"]) != "test1" {
+		t.Errorf("expected the bootstrap hub kubeconfig is re-applied, but got %q", string(bootstrapSecret.Data["kubeconfig"]))
+	}
+}
+
+func TestReconcileSkippedEvents(t *testing.T) {
+	cases := []struct {
+		name           string
+		labels         map[string]string
+		secrets        []runtime.Object
+		expectedReason string
+	}{
+		{
+			name:           "not self managed cluster",
+			expectedReason: "SelfManagedClusterImportSkippedNotSelfManaged",
+		},
+		{
+			name:   "self managed cluster has auto import secret",
+			labels: map[string]string{constants.SelfManagedLabel: "true"},
+			secrets: []runtime.Object{
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      constants.AutoImportSecretName,
+						Namespace: "local-cluster",
+					},
+				},
+			},
+			expectedReason: "SelfManagedClusterImportSkippedAutoImportSecretExists",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			recorder := events.NewInMemoryRecorder("test")
+			r := &ReconcileLocalCluster{
+				clientHolder: &helpers.ClientHolder{
+					KubeClient: kubefake.NewSimpleClientset(c.secrets...),
+					RuntimeClient: fake.NewClientBuilder().WithScheme(testscheme).WithObjects(
+						&clusterv1.ManagedCluster{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:   "local-cluster",
+								Labels: c.labels,
+							},
+						},
+					).Build(),
+				},
+				scheme:   testscheme,
+				recorder: recorder,
+			}
+
+			request := reconcile.Request{NamespacedName: types.NamespacedName{Name: "local-cluster"}}
+			if _, err := r.Reconcile(context.TODO(), request); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			recordedEvents := recorder.Events()
+			if len(recordedEvents) != 1 {
+				t.Fatalf("expected one event, but got %d", len(recordedEvents))
+			}
+			if recordedEvents[0].Reason != c.expectedReason {
+				t.Errorf("expected event reason %s, but got %s", c.expectedReason, recordedEvents[0].Reason)
+			}
+		})
+	}
+}
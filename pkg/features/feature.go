@@ -18,6 +18,11 @@ const (
 	// KlusterletHostedMode will provide a hosted importing worker for import-secret controller,
 	// and will start a new hosted controller to process cluster in hosted mode importing,
 	KlusterletHostedMode featuregate.Feature = "KlusterletHostedMode"
+
+	// ImportConfigurationConfigMap will write the resolved import configuration of a managed cluster to a
+	// ConfigMap in the managed cluster namespace, so the configuration can be inspected without decoding
+	// the manifest works.
+	ImportConfigurationConfigMap featuregate.Feature = "ImportConfigurationConfigMap"
 )
 
 var (
@@ -35,5 +40,6 @@ func init() {
 // feature keys.  To add a new feature, define a key for it above and
 // add it here.
 var defaultRegistrationFeatureGates = map[featuregate.Feature]featuregate.FeatureSpec{
-	KlusterletHostedMode: {Default: true, PreRelease: featuregate.Alpha},
+	KlusterletHostedMode:         {Default: true, PreRelease: featuregate.Alpha},
+	ImportConfigurationConfigMap: {Default: false, PreRelease: featuregate.Alpha},
 }
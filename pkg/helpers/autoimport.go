@@ -5,20 +5,77 @@ package helpers
 
 import (
 	"context"
+	goerrors "errors"
 	"fmt"
+	"math"
+	"sort"
 	"strconv"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/openshift/library-go/pkg/operator/events"
 
 	"github.com/stolostron/managedcluster-import-controller/pkg/constants"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
 )
 
 const keepingAutoImportSecretAnnotation = "managedcluster-import-controller.open-cluster-management.io/keeping-auto-import-secret"
 
+// ClusterIdentityMismatchError is returned when the identity of the target cluster does not match the
+// expected cluster id in the auto import secret
+type ClusterIdentityMismatchError struct {
+	Expected string
+	Actual   string
+}
+
+func (e *ClusterIdentityMismatchError) Error() string {
+	return fmt.Sprintf("the cluster id %q of the target cluster does not match the expected cluster id %q",
+		e.Actual, e.Expected)
+}
+
+// Unwrap returns the ErrClusterIdentityMismatch, so the error can be checked with errors.Is
+func (e *ClusterIdentityMismatchError) Unwrap() error {
+	return ErrClusterIdentityMismatch
+}
+
+// IsClusterIdentityMismatch returns true if the error is or wraps a ClusterIdentityMismatchError, it also matches
+// the error that is wrapped by an aggregate
+func IsClusterIdentityMismatch(err error) bool {
+	return goerrors.Is(err, ErrClusterIdentityMismatch)
+}
+
+// ServerURLMismatchError is returned when the server of the auto import secret does not match the expected server
+// url of the managed cluster
+type ServerURLMismatchError struct {
+	Expected string
+	Actual   string
+}
+
+func (e *ServerURLMismatchError) Error() string {
+	return fmt.Sprintf("the server %q of the auto import secret does not match the expected server %q",
+		e.Actual, e.Expected)
+}
+
+// Unwrap returns the ErrServerURLMismatch, so the error can be checked with errors.Is
+func (e *ServerURLMismatchError) Unwrap() error {
+	return ErrServerURLMismatch
+}
+
+// IsServerURLMismatch returns true if the error is or wraps a ServerURLMismatchError, it also matches the error
+// that is wrapped by an aggregate
+func IsServerURLMismatch(err error) bool {
+	return goerrors.Is(err, ErrServerURLMismatch)
+}
+
 // UpdateAutoImportRetryTimes minus 1 for the value of AutoImportRetryName in the auto import secret
 func UpdateAutoImportRetryTimes(ctx context.Context, kubeClient kubernetes.Interface, recorder events.Recorder, secret *corev1.Secret) error {
 	autoImportRetry, err := strconv.Atoi(string(secret.Data[constants.AutoImportRetryName]))
@@ -54,3 +111,263 @@ func DeleteAutoImportSecret(ctx context.Context, kubeClient kubernetes.Interface
 
 	return kubeClient.CoreV1().Secrets(secret.Namespace).Delete(ctx, secret.Name, metav1.DeleteOptions{})
 }
+
+// ListAutoImportSecrets returns the auto import secrets of the managed cluster in the order that they are tried, the
+// auto-import-secret is the first one, then the secrets with the AutoImportSecretLabel are sorted by their priority
+// annotation and their names
+func ListAutoImportSecrets(ctx context.Context, kubeClient kubernetes.Interface,
+	clusterName string) ([]*corev1.Secret, error) {
+	secrets := []*corev1.Secret{}
+	autoImportSecret, err := kubeClient.CoreV1().Secrets(clusterName).Get(ctx, constants.AutoImportSecretName,
+		metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+	case err != nil:
+		return nil, err
+	default:
+		secrets = append(secrets, autoImportSecret)
+	}
+
+	labeledSecrets, err := kubeClient.CoreV1().Secrets(clusterName).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=true", constants.AutoImportSecretLabel),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fallbacks := []*corev1.Secret{}
+	for i := range labeledSecrets.Items {
+		if labeledSecrets.Items[i].Name == constants.AutoImportSecretName {
+			continue
+		}
+		fallbacks = append(fallbacks, &labeledSecrets.Items[i])
+	}
+	sort.SliceStable(fallbacks, func(i, j int) bool {
+		pi, pj := autoImportSecretPriority(fallbacks[i]), autoImportSecretPriority(fallbacks[j])
+		if pi != pj {
+			return pi < pj
+		}
+		return fallbacks[i].Name < fallbacks[j].Name
+	})
+
+	return append(secrets, fallbacks...), nil
+}
+
+// autoImportSecretPriority returns the priority of the auto import secret, if the priority is not set or invalid,
+// the max int is returned
+func autoImportSecretPriority(secret *corev1.Secret) int {
+	value, ok := secret.Annotations[constants.AutoImportSecretPriorityAnnotation]
+	if !ok {
+		return math.MaxInt
+	}
+
+	priority, err := strconv.Atoi(value)
+	if err != nil {
+		klog.Warningf("The priority %q of the auto import secret %s/%s is wrong, ignore it",
+			value, secret.Namespace, secret.Name)
+		return math.MaxInt
+	}
+	return priority
+}
+
+// GenerateClientFromAutoImportSecrets tries to generate the clients from the auto import secrets in order until one
+// succeeds, it returns the clients and the secret that the clients are generated from. If the clients cannot be
+// generated from any of the secrets, an aggregated error of all secrets is returned.
+func GenerateClientFromAutoImportSecrets(secrets []*corev1.Secret,
+	generateClientFunc func(secret *corev1.Secret) (*ClientHolder, meta.RESTMapper, error)) (
+	*ClientHolder, meta.RESTMapper, *corev1.Secret, error) {
+	if len(secrets) == 0 {
+		return nil, nil, nil, fmt.Errorf("%w: there is no auto import secret", ErrSecretMissingCredentials)
+	}
+
+	errs := []error{}
+	for _, secret := range secrets {
+		clientHolder, restMapper, err := generateClientFunc(secret)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("the auto import secret %s: %w", secret.Name, err))
+			continue
+		}
+		return clientHolder, restMapper, secret, nil
+	}
+	return nil, nil, nil, utilerrors.NewAggregate(errs)
+}
+
+// DeleteImportSecrets deletes the import secret and the auto-import-secret of a managed cluster, it is used to clean
+// up the secrets that are left over after the managed cluster is deleted, e.g. the namespace of the managed cluster
+// is kept. It is only called in the deletion of the managed cluster, because the secrets may be created before the
+// managed cluster. The secrets that are already deleted are ignored, and the auto-import-secret that has the keeping
+// annotation is kept.
+func DeleteImportSecrets(ctx context.Context, kubeClient kubernetes.Interface, recorder events.Recorder,
+	clusterName string) error {
+	deleted := []string{}
+
+	importSecretName := fmt.Sprintf("%s-%s", clusterName, constants.ImportSecretNameSuffix)
+	err := kubeClient.CoreV1().Secrets(clusterName).Delete(ctx, importSecretName, metav1.DeleteOptions{})
+	switch {
+	case errors.IsNotFound(err):
+	case err != nil:
+		return err
+	default:
+		deleted = append(deleted, importSecretName)
+	}
+
+	autoImportSecret, err := kubeClient.CoreV1().Secrets(clusterName).Get(ctx, constants.AutoImportSecretName,
+		metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+	case err != nil:
+		return err
+	default:
+		if _, ok := autoImportSecret.Annotations[keepingAutoImportSecretAnnotation]; ok {
+			break
+		}
+		err := kubeClient.CoreV1().Secrets(clusterName).Delete(ctx, constants.AutoImportSecretName, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		if err == nil {
+			deleted = append(deleted, constants.AutoImportSecretName)
+		}
+	}
+
+	for _, name := range deleted {
+		reportDeletion(recorder, "Secret", clusterName, name)
+	}
+	if len(deleted) != 0 {
+		recorder.Eventf("ImportSecretsDeleted", "The import secrets %s of the managed cluster %s are deleted",
+			strings.Join(deleted, ","), clusterName)
+	}
+	return nil
+}
+
+// ValidateClusterIdentity validates the identity of the target cluster with the expected cluster id of the auto
+// import secret, the identity of a cluster is the UID of its kube-system namespace. If the secret does not have
+// the expected cluster id, the validation is skipped.
+func ValidateClusterIdentity(ctx context.Context, kubeClient kubernetes.Interface, secret *corev1.Secret) error {
+	expectedClusterID, ok := secret.Data[constants.AutoImportExpectedClusterIDName]
+	if !ok || len(expectedClusterID) == 0 {
+		return nil
+	}
+
+	ns, err := kubeClient.CoreV1().Namespaces().Get(ctx, metav1.NamespaceSystem, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if string(ns.UID) != string(expectedClusterID) {
+		return &ClusterIdentityMismatchError{Expected: string(expectedClusterID), Actual: string(ns.UID)}
+	}
+
+	return nil
+}
+
+// EnsureClusterIDAnnotation sets the cluster id annotation of the managed cluster to the UID of the kube-system
+// namespace of the imported cluster, the managed cluster is not updated if the annotation is already up to date or
+// the cluster does not have the kube-system namespace.
+func EnsureClusterIDAnnotation(ctx context.Context, runtimeClient client.Client, recorder events.Recorder,
+	kubeClient kubernetes.Interface, cluster *clusterv1.ManagedCluster) error {
+	ns, err := kubeClient.CoreV1().Namespaces().Get(ctx, metav1.NamespaceSystem, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	clusterID := string(ns.UID)
+	if len(clusterID) == 0 || cluster.Annotations[constants.ClusterIDAnnotation] == clusterID {
+		return nil
+	}
+
+	patch := client.MergeFrom(cluster.DeepCopy())
+	if cluster.Annotations == nil {
+		cluster.Annotations = map[string]string{}
+	}
+	cluster.Annotations[constants.ClusterIDAnnotation] = clusterID
+	if err := runtimeClient.Patch(ctx, cluster, patch); err != nil {
+		return err
+	}
+
+	recorder.Eventf("ManagedClusterMetaObjModified",
+		"The managed cluster %s meta data is modified: annotation %s is set to %s",
+		cluster.Name, constants.ClusterIDAnnotation, clusterID)
+	return nil
+}
+
+// SecretType is the format of the credentials in an auto import secret
+type SecretType string
+
+const (
+	// SecretTypeKubeconfig means the secret has a kubeconfig whose user does not authenticate with a client
+	// certificate, e.g. a token, an exec plugin or an auth provider
+	SecretTypeKubeconfig SecretType = "Kubeconfig"
+	// SecretTypeTokenServer means the secret has a token (or a tokenFile) and a server
+	SecretTypeTokenServer SecretType = "TokenServer"
+	// SecretTypeClientCert means the secret has a kubeconfig whose user authenticates with a client certificate
+	SecretTypeClientCert SecretType = "ClientCert"
+	// SecretTypeUnknown means the format of the secret is not recognized
+	SecretTypeUnknown SecretType = "Unknown"
+)
+
+// DetectSecretType returns the format of the credentials in the secret, the formats are detected in the same order as
+// the clients are built from the secret, e.g. the token and server take precedence over the kubeconfig. If the format
+// is not recognized, SecretTypeUnknown and an error that wraps the ErrSecretMissingCredentials are returned.
+func DetectSecretType(secret *corev1.Secret) (SecretType, error) {
+	_, hasToken := secret.Data["token"]
+	_, hasTokenFile := secret.Data["tokenFile"]
+	_, hasServer := secret.Data["server"]
+	if (hasToken || hasTokenFile) && hasServer {
+		return SecretTypeTokenServer, nil
+	}
+
+	kubeconfig, ok := secret.Data["kubeconfig"]
+	if !ok {
+		return SecretTypeUnknown, fmt.Errorf("%w: kubeconfig or token and server are missing",
+			ErrSecretMissingCredentials)
+	}
+
+	config, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return SecretTypeUnknown, fmt.Errorf("%w: invalid kubeconfig: %v", ErrSecretMissingCredentials, err)
+	}
+
+	currentContext, ok := config.Contexts[config.CurrentContext]
+	if !ok {
+		return SecretTypeKubeconfig, nil
+	}
+	authInfo, ok := config.AuthInfos[currentContext.AuthInfo]
+	if ok && (len(authInfo.ClientCertificateData) != 0 || len(authInfo.ClientCertificate) != 0) {
+		return SecretTypeClientCert, nil
+	}
+	return SecretTypeKubeconfig, nil
+}
+
+// ValidateExpectedServerURL validates the server of the auto import secret with the expected server url annotation
+// of the managed cluster, the server is parsed from the secret in the same way as the clients of the secret are built.
+// If the managed cluster does not have the annotation, the validation is skipped.
+func ValidateExpectedServerURL(cluster *clusterv1.ManagedCluster, secret *corev1.Secret) error {
+	expectedServer, ok := cluster.Annotations[constants.ExpectedServerURLAnnotation]
+	if !ok || len(strings.TrimSpace(expectedServer)) == 0 {
+		return nil
+	}
+
+	clientConfig, err := buildClientConfigFromSecret(secret)
+	if err != nil {
+		return err
+	}
+
+	normalizedExpected, err := normalizeServerURL(expectedServer)
+	if err != nil {
+		return fmt.Errorf("invalid expected server url annotation of cluster %s, %v", cluster.Name, err)
+	}
+	normalizedActual, err := normalizeServerURL(clientConfig.Host)
+	if err != nil {
+		return err
+	}
+
+	if normalizedExpected != normalizedActual {
+		return &ServerURLMismatchError{Expected: expectedServer, Actual: clientConfig.Host}
+	}
+
+	return nil
+}
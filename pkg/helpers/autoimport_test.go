@@ -0,0 +1,529 @@
+// Copyright (c) Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package helpers
+
+import (
+	"context"
+	goerrors "errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/openshift/library-go/pkg/operator/events/eventstesting"
+	"github.com/stolostron/managedcluster-import-controller/pkg/constants"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestValidateClusterIdentity(t *testing.T) {
+	cases := []struct {
+		name             string
+		secret           *corev1.Secret
+		expectedMismatch bool
+	}{
+		{
+			name: "no expected cluster id",
+			secret: &corev1.Secret{
+				Data: map[string][]byte{},
+			},
+		},
+		{
+			name: "cluster id matches",
+			secret: &corev1.Secret{
+				Data: map[string][]byte{
+					constants.AutoImportExpectedClusterIDName: []byte("7f9ce2e1-3a41-4b3e-a4b6-3a2c8a0b2d10"),
+				},
+			},
+		},
+		{
+			name: "cluster id mismatches",
+			secret: &corev1.Secret{
+				Data: map[string][]byte{
+					constants.AutoImportExpectedClusterIDName: []byte("0a6c1f4e-8f3d-4f86-9e0e-6b1e2a9c7d55"),
+				},
+			},
+			expectedMismatch: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			kubeClient := kubefake.NewSimpleClientset(&corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: metav1.NamespaceSystem,
+					UID:  "7f9ce2e1-3a41-4b3e-a4b6-3a2c8a0b2d10",
+				},
+			})
+
+			err := ValidateClusterIdentity(context.TODO(), kubeClient, c.secret)
+			if c.expectedMismatch {
+				if !IsClusterIdentityMismatch(err) {
+					t.Errorf("expected cluster identity mismatch error, but got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestEnsureClusterIDAnnotation(t *testing.T) {
+	cases := []struct {
+		name        string
+		annotations map[string]string
+	}{
+		{
+			name: "no annotations",
+		},
+		{
+			name: "cluster id is out of date",
+			annotations: map[string]string{
+				constants.ClusterIDAnnotation: "0a6c1f4e-8f3d-4f86-9e0e-6b1e2a9c7d55",
+				"test":                        "test",
+			},
+		},
+		{
+			name: "cluster id is up to date",
+			annotations: map[string]string{
+				constants.ClusterIDAnnotation: "7f9ce2e1-3a41-4b3e-a4b6-3a2c8a0b2d10",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			kubeClient := kubefake.NewSimpleClientset(&corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: metav1.NamespaceSystem,
+					UID:  "7f9ce2e1-3a41-4b3e-a4b6-3a2c8a0b2d10",
+				},
+			})
+			cluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Annotations: c.annotations,
+				},
+			}
+			runtimeClient := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(cluster).Build()
+
+			if err := EnsureClusterIDAnnotation(context.TODO(), runtimeClient, eventstesting.NewTestingEventRecorder(t),
+				kubeClient, cluster); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			updated := &clusterv1.ManagedCluster{}
+			if err := runtimeClient.Get(context.TODO(), types.NamespacedName{Name: "test"}, updated); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if updated.Annotations[constants.ClusterIDAnnotation] != "7f9ce2e1-3a41-4b3e-a4b6-3a2c8a0b2d10" {
+				t.Errorf("expected the cluster id annotation is set from the kube-system namespace, but got %v",
+					updated.Annotations)
+			}
+			for k, v := range c.annotations {
+				if k != constants.ClusterIDAnnotation && updated.Annotations[k] != v {
+					t.Errorf("expected the annotation %s is kept, but got %v", k, updated.Annotations)
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteImportSecrets(t *testing.T) {
+	newSecret := func(name string, annotations map[string]string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   "test",
+				Annotations: annotations,
+			},
+		}
+	}
+
+	cases := []struct {
+		name            string
+		secrets         []runtime.Object
+		expectedSecrets []string
+	}{
+		{
+			name: "secrets are present",
+			secrets: []runtime.Object{
+				newSecret("test-import", nil),
+				newSecret(constants.AutoImportSecretName, nil),
+				newSecret("test-other", nil),
+			},
+			expectedSecrets: []string{"test-other"},
+		},
+		{
+			name: "secrets are already absent",
+		},
+		{
+			name: "auto-import-secret is kept",
+			secrets: []runtime.Object{
+				newSecret("test-import", nil),
+				newSecret(constants.AutoImportSecretName, map[string]string{keepingAutoImportSecretAnnotation: ""}),
+			},
+			expectedSecrets: []string{constants.AutoImportSecretName},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			kubeClient := kubefake.NewSimpleClientset(c.secrets...)
+
+			if err := DeleteImportSecrets(context.TODO(), kubeClient, eventstesting.NewTestingEventRecorder(t),
+				"test"); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			for _, name := range []string{"test-import", constants.AutoImportSecretName, "test-other"} {
+				_, err := kubeClient.CoreV1().Secrets("test").Get(context.TODO(), name, metav1.GetOptions{})
+				expected := false
+				for _, expectedName := range c.expectedSecrets {
+					expected = expected || expectedName == name
+				}
+				if expected && err != nil {
+					t.Errorf("expected the secret %s is kept, but got %v", name, err)
+				}
+				if !expected && !errors.IsNotFound(err) {
+					t.Errorf("expected the secret %s is deleted, but got %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateExpectedServerURL(t *testing.T) {
+	secret := &corev1.Secret{
+		Data: map[string][]byte{
+			"token":  []byte("test"),
+			"server": []byte("https://api.cluster1.example.com:6443"),
+		},
+	}
+
+	cases := []struct {
+		name             string
+		annotations      map[string]string
+		expectedMismatch bool
+	}{
+		{
+			name: "missing annotation",
+		},
+		{
+			name: "server matches",
+			annotations: map[string]string{
+				constants.ExpectedServerURLAnnotation: "https://api.cluster1.example.com:6443",
+			},
+		},
+		{
+			name: "server matches with different case and trailing slash",
+			annotations: map[string]string{
+				constants.ExpectedServerURLAnnotation: "https://API.cluster1.example.com:6443/",
+			},
+		},
+		{
+			name: "server mismatches",
+			annotations: map[string]string{
+				constants.ExpectedServerURLAnnotation: "https://api.cluster2.example.com:6443",
+			},
+			expectedMismatch: true,
+		},
+		{
+			name: "server port mismatches",
+			annotations: map[string]string{
+				constants.ExpectedServerURLAnnotation: "https://api.cluster1.example.com",
+			},
+			expectedMismatch: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Annotations: c.annotations,
+				},
+			}
+
+			err := ValidateExpectedServerURL(cluster, secret)
+			if c.expectedMismatch {
+				if !IsServerURLMismatch(err) {
+					t.Errorf("expected server url mismatch error, but got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestDetectSecretType(t *testing.T) {
+	tokenKubeconfig := []byte(`apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://api.test.com:6443
+  name: test
+contexts:
+- context:
+    cluster: test
+    user: test
+  name: test
+current-context: test
+users:
+- name: test
+  user:
+    token: test-token
+`)
+	clientCertKubeconfig := []byte(`apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://api.test.com:6443
+  name: test
+contexts:
+- context:
+    cluster: test
+    user: test
+  name: test
+current-context: test
+users:
+- name: test
+  user:
+    client-certificate-data: dGVzdA==
+    client-key-data: dGVzdA==
+`)
+
+	cases := []struct {
+		name         string
+		data         map[string][]byte
+		expectedType SecretType
+		expectedErr  bool
+	}{
+		{
+			name:         "token and server",
+			data:         map[string][]byte{"token": []byte("test-token"), "server": []byte("https://api.test.com:6443")},
+			expectedType: SecretTypeTokenServer,
+		},
+		{
+			name:         "token file and server",
+			data:         map[string][]byte{"tokenFile": []byte("/tmp/token"), "server": []byte("https://api.test.com:6443")},
+			expectedType: SecretTypeTokenServer,
+		},
+		{
+			name: "token and server take precedence over kubeconfig",
+			data: map[string][]byte{
+				"token":      []byte("test-token"),
+				"server":     []byte("https://api.test.com:6443"),
+				"kubeconfig": clientCertKubeconfig,
+			},
+			expectedType: SecretTypeTokenServer,
+		},
+		{
+			name:         "kubeconfig with token",
+			data:         map[string][]byte{"kubeconfig": tokenKubeconfig},
+			expectedType: SecretTypeKubeconfig,
+		},
+		{
+			name:         "kubeconfig with client certificate",
+			data:         map[string][]byte{"kubeconfig": clientCertKubeconfig},
+			expectedType: SecretTypeClientCert,
+		},
+		{
+			name:         "token without server",
+			data:         map[string][]byte{"token": []byte("test-token")},
+			expectedType: SecretTypeUnknown,
+			expectedErr:  true,
+		},
+		{
+			name:         "invalid kubeconfig",
+			data:         map[string][]byte{"kubeconfig": []byte("invalid")},
+			expectedType: SecretTypeUnknown,
+			expectedErr:  true,
+		},
+		{
+			name:         "unknown",
+			data:         map[string][]byte{"username": []byte("admin")},
+			expectedType: SecretTypeUnknown,
+			expectedErr:  true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			secretType, err := DetectSecretType(&corev1.Secret{Data: c.data})
+			if secretType != c.expectedType {
+				t.Errorf("expected secret type %s, but got %s", c.expectedType, secretType)
+			}
+			if c.expectedErr && !goerrors.Is(err, ErrSecretMissingCredentials) {
+				t.Errorf("expected ErrSecretMissingCredentials, but got %v", err)
+			}
+			if !c.expectedErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestListAutoImportSecrets(t *testing.T) {
+	newSecret := func(name string, labeled bool, priority string) *corev1.Secret {
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test"}}
+		if labeled {
+			secret.Labels = map[string]string{constants.AutoImportSecretLabel: "true"}
+		}
+		if len(priority) != 0 {
+			secret.Annotations = map[string]string{constants.AutoImportSecretPriorityAnnotation: priority}
+		}
+		return secret
+	}
+
+	cases := []struct {
+		name          string
+		secrets       []runtime.Object
+		expectedNames []string
+	}{
+		{
+			name:          "no auto import secrets",
+			secrets:       []runtime.Object{newSecret("test", false, "")},
+			expectedNames: []string{},
+		},
+		{
+			name:          "only the auto import secret",
+			secrets:       []runtime.Object{newSecret(constants.AutoImportSecretName, false, "")},
+			expectedNames: []string{constants.AutoImportSecretName},
+		},
+		{
+			name: "the labeled secrets are sorted by the priority",
+			secrets: []runtime.Object{
+				newSecret("fallback-b", true, ""),
+				newSecret("fallback-a", true, "invalid"),
+				newSecret("fallback-2", true, "2"),
+				newSecret("fallback-1", true, "1"),
+				newSecret(constants.AutoImportSecretName, true, "3"),
+				newSecret("unlabeled", false, "0"),
+			},
+			expectedNames: []string{
+				constants.AutoImportSecretName, "fallback-1", "fallback-2", "fallback-a", "fallback-b",
+			},
+		},
+		{
+			name:          "only the labeled secrets",
+			secrets:       []runtime.Object{newSecret("fallback-2", true, "2"), newSecret("fallback-1", true, "1")},
+			expectedNames: []string{"fallback-1", "fallback-2"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			secrets, err := ListAutoImportSecrets(context.TODO(), kubefake.NewSimpleClientset(c.secrets...), "test")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			names := []string{}
+			for _, secret := range secrets {
+				names = append(names, secret.Name)
+			}
+			if !reflect.DeepEqual(names, c.expectedNames) {
+				t.Errorf("expected secrets %v, but got %v", c.expectedNames, names)
+			}
+		})
+	}
+}
+
+func TestGenerateClientFromAutoImportSecrets(t *testing.T) {
+	primary := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: constants.AutoImportSecretName, Namespace: "test"}}
+	fallback := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "fallback", Namespace: "test"}}
+
+	cases := []struct {
+		name               string
+		secrets            []*corev1.Secret
+		failedSecrets      []string
+		expectedSecret     string
+		expectedGenerated  []string
+		expectedErrSecrets []string
+	}{
+		{
+			name:              "the primary secret succeeds",
+			secrets:           []*corev1.Secret{primary, fallback},
+			expectedSecret:    constants.AutoImportSecretName,
+			expectedGenerated: []string{constants.AutoImportSecretName},
+		},
+		{
+			name:              "the primary secret fails and the fallback secret succeeds",
+			secrets:           []*corev1.Secret{primary, fallback},
+			failedSecrets:     []string{constants.AutoImportSecretName},
+			expectedSecret:    "fallback",
+			expectedGenerated: []string{constants.AutoImportSecretName, "fallback"},
+		},
+		{
+			name:               "all secrets fail",
+			secrets:            []*corev1.Secret{primary, fallback},
+			failedSecrets:      []string{constants.AutoImportSecretName, "fallback"},
+			expectedGenerated:  []string{constants.AutoImportSecretName, "fallback"},
+			expectedErrSecrets: []string{constants.AutoImportSecretName, "fallback"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			generated := []string{}
+			clientHolder, _, secret, err := GenerateClientFromAutoImportSecrets(c.secrets,
+				func(secret *corev1.Secret) (*ClientHolder, meta.RESTMapper, error) {
+					generated = append(generated, secret.Name)
+					for _, failed := range c.failedSecrets {
+						if secret.Name == failed {
+							return nil, nil, ErrClusterUnreachable
+						}
+					}
+					return &ClientHolder{}, nil, nil
+				})
+
+			if !reflect.DeepEqual(generated, c.expectedGenerated) {
+				t.Errorf("expected the secrets %v are tried, but got %v", c.expectedGenerated, generated)
+			}
+
+			if len(c.expectedErrSecrets) != 0 {
+				if !goerrors.Is(err, ErrClusterUnreachable) {
+					t.Fatalf("expected ErrClusterUnreachable, but got %v", err)
+				}
+				for _, name := range c.expectedErrSecrets {
+					if !strings.Contains(err.Error(), name) {
+						t.Errorf("expected the error of the secret %s, but got %v", name, err)
+					}
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if clientHolder == nil || secret.Name != c.expectedSecret {
+				t.Errorf("expected the clients are generated from the secret %s, but got %v", c.expectedSecret, secret)
+			}
+		})
+	}
+}
+
+func TestGenerateClientFromNoAutoImportSecrets(t *testing.T) {
+	_, _, _, err := GenerateClientFromAutoImportSecrets(nil,
+		func(secret *corev1.Secret) (*ClientHolder, meta.RESTMapper, error) {
+			return &ClientHolder{}, nil, nil
+		})
+	if !goerrors.Is(err, ErrSecretMissingCredentials) {
+		t.Errorf("expected ErrSecretMissingCredentials, but got %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright (c) Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package helpers
+
+import (
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+// clientHolderCacheKey is the key of the cached clients, a managed cluster may have more than one auto import
+// secret, the clients generated from each of them are cached separately
+type clientHolderCacheKey struct {
+	clusterName string
+	secretName  string
+}
+
+type cachedClientHolder struct {
+	resourceVersion string
+	clientHolder    *ClientHolder
+	restMapper      meta.RESTMapper
+}
+
+// ClientHolderCache caches the managed cluster clients that are generated from the secrets, the cached clients
+// are keyed by the managed cluster name and the secret name, and are rebuilt once the resource version of the secret is changed.
+type ClientHolderCache struct {
+	lock    sync.Mutex
+	clients map[clientHolderCacheKey]*cachedClientHolder
+
+	generateClientFunc func(secret *corev1.Secret) (*ClientHolder, meta.RESTMapper, error)
+}
+
+// NewClientHolderCache returns a ClientHolderCache that generates the clients with GenerateClientFromSecret
+func NewClientHolderCache() *ClientHolderCache {
+	return &ClientHolderCache{
+		clients:            map[clientHolderCacheKey]*cachedClientHolder{},
+		generateClientFunc: GenerateClientFromSecret,
+	}
+}
+
+// Get returns the cached clients of the managed cluster secret if the secret is not changed, otherwise, generates the
+// clients from the secret and caches them. The clients are generated without holding the lock, so an unreachable
+// managed cluster does not block getting the clients of the other managed clusters.
+func (c *ClientHolderCache) Get(clusterName string, secret *corev1.Secret) (*ClientHolder, meta.RESTMapper, error) {
+	key := clientHolderCacheKey{clusterName: clusterName, secretName: secret.Name}
+	if cached, ok := c.get(key); ok && cached.resourceVersion == secret.ResourceVersion {
+		return cached.clientHolder, cached.restMapper, nil
+	}
+
+	clientHolder, restMapper, err := c.generateClientFunc(secret)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err != nil {
+		delete(c.clients, key)
+		return nil, nil, err
+	}
+
+	c.clients[key] = &cachedClientHolder{
+		resourceVersion: secret.ResourceVersion,
+		clientHolder:    clientHolder,
+		restMapper:      restMapper,
+	}
+	return clientHolder, restMapper, nil
+}
+
+func (c *ClientHolderCache) get(key clientHolderCacheKey) (*cachedClientHolder, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	cached, ok := c.clients[key]
+	return cached, ok
+}
+
+// Invalidate removes the cached clients of all of the secrets of the managed cluster
+func (c *ClientHolderCache) Invalidate(clusterName string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for key := range c.clients {
+		if key.clusterName == clusterName {
+			delete(c.clients, key)
+		}
+	}
+}
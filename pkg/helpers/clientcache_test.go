@@ -0,0 +1,140 @@
+// Copyright (c) Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package helpers
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestClientHolderCache(t *testing.T) {
+	builds := 0
+	cache := NewClientHolderCache()
+	cache.generateClientFunc = func(secret *corev1.Secret) (*ClientHolder, meta.RESTMapper, error) {
+		builds++
+		return &ClientHolder{}, nil, nil
+	}
+
+	newSecret := func(resourceVersion string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "auto-import-secret",
+				Namespace:       "test",
+				ResourceVersion: resourceVersion,
+			},
+		}
+	}
+
+	first, _, err := cache.Get("test", newSecret("1"))
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	second, _, err := cache.Get("test", newSecret("1"))
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if builds != 1 {
+		t.Errorf("expected the clients are built once, but got %d", builds)
+	}
+	if first != second {
+		t.Errorf("expected the cached clients are returned, but failed")
+	}
+
+	if _, _, err := cache.Get("test", newSecret("2")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if builds != 2 {
+		t.Errorf("expected the clients are rebuilt after the secret is changed, but got %d", builds)
+	}
+
+	cache.Invalidate("test")
+	if _, _, err := cache.Get("test", newSecret("2")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if builds != 3 {
+		t.Errorf("expected the clients are rebuilt after the cache is invalidated, but got %d", builds)
+	}
+}
+
+func TestClientHolderCacheSecrets(t *testing.T) {
+	builds := map[string]int{}
+	cache := NewClientHolderCache()
+	cache.generateClientFunc = func(secret *corev1.Secret) (*ClientHolder, meta.RESTMapper, error) {
+		builds[secret.Name]++
+		return &ClientHolder{}, nil, nil
+	}
+
+	autoImportSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "auto-import-secret", Namespace: "test", ResourceVersion: "1"},
+	}
+	fallbackSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "fallback", Namespace: "test", ResourceVersion: "1"},
+	}
+
+	first, _, err := cache.Get("test", autoImportSecret)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	fallback, _, err := cache.Get("test", fallbackSecret)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if first == fallback {
+		t.Errorf("expected the clients of the secrets are cached separately, but failed")
+	}
+
+	second, _, err := cache.Get("test", autoImportSecret)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if first != second || builds["auto-import-secret"] != 1 || builds["fallback"] != 1 {
+		t.Errorf("expected the cached clients of each secret are returned, but got builds %v", builds)
+	}
+
+	cache.Invalidate("test")
+	if _, _, err := cache.Get("test", fallbackSecret); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if builds["fallback"] != 2 {
+		t.Errorf("expected the clients of all secrets are invalidated, but got builds %v", builds)
+	}
+}
+
+func TestClientHolderCacheGenerateWithoutLock(t *testing.T) {
+	blocked := make(chan struct{})
+	defer close(blocked)
+
+	cache := NewClientHolderCache()
+	cache.generateClientFunc = func(secret *corev1.Secret) (*ClientHolder, meta.RESTMapper, error) {
+		if secret.Namespace == "unreachable" {
+			<-blocked
+		}
+		return &ClientHolder{}, nil, nil
+	}
+
+	go func() {
+		_, _, _ = cache.Get("unreachable", &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "auto-import-secret", Namespace: "unreachable"},
+		})
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _, _ = cache.Get("test", &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "auto-import-secret", Namespace: "test"},
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Errorf("expected the clients of the other cluster are generated, but they are blocked")
+	}
+}
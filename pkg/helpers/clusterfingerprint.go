@@ -0,0 +1,192 @@
+// Copyright (c) Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package helpers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/stolostron/managedcluster-import-controller/pkg/constants"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ClusterFingerprint returns the fingerprint of the cluster that the credentials of the secret point at, the
+// fingerprint is built from the server url and the CA of the cluster, so the secrets in different namespaces that
+// point at the same cluster have the same fingerprint.
+func ClusterFingerprint(secret *corev1.Secret) (string, error) {
+	clientConfig, err := buildClientConfigFromSecret(secret)
+	if err != nil {
+		return "", err
+	}
+
+	normalizedServer, err := normalizeServerURL(clientConfig.Host)
+	if err != nil {
+		return "", err
+	}
+
+	caHash := sha256.Sum256(clientConfig.CAData)
+	hash := sha256.New()
+	hash.Write([]byte(normalizedServer))
+	hash.Write([]byte(hex.EncodeToString(caHash[:])))
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// normalizeServerURL normalizes the server url, the scheme is https if it is not set, the scheme and the host are
+// lower-cased, the default port of the scheme is added and the trailing slash is removed, so the urls of the same
+// server are identical
+func normalizeServerURL(serverURL string) (string, error) {
+	server, _, err := rest.DefaultServerURL(strings.TrimSpace(serverURL), "", schema.GroupVersion{}, true)
+	if err != nil {
+		return "", fmt.Errorf("invalid server %q: %v", serverURL, err)
+	}
+	if len(server.Hostname()) == 0 {
+		return "", fmt.Errorf("invalid server %q: the host is empty", serverURL)
+	}
+
+	scheme := strings.ToLower(server.Scheme)
+	host := strings.ToLower(server.Host)
+	if len(server.Port()) == 0 {
+		switch scheme {
+		case "https":
+			host = host + ":443"
+		case "http":
+			host = host + ":80"
+		}
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, host, strings.TrimSuffix(server.Path, "/")), nil
+}
+
+// ClusterFingerprintRegistry records which managed cluster is importing the cluster of a fingerprint, it is used to
+// detect that the secrets of multiple managed clusters point at the same cluster while they are being imported, the
+// fingerprints of the imported clusters are persisted in the ClusterFingerprintAnnotation of the managed clusters.
+type ClusterFingerprintRegistry struct {
+	lock         sync.Mutex
+	owners       map[string]string
+	fingerprints map[string]string
+}
+
+// NewClusterFingerprintRegistry returns an empty ClusterFingerprintRegistry
+func NewClusterFingerprintRegistry() *ClusterFingerprintRegistry {
+	return &ClusterFingerprintRegistry{
+		owners:       map[string]string{},
+		fingerprints: map[string]string{},
+	}
+}
+
+// Register registers the fingerprint for the managed cluster, if the fingerprint is already registered by another
+// managed cluster, the name of that managed cluster is returned with true, and the fingerprint is not registered.
+func (r *ClusterFingerprintRegistry) Register(clusterName, fingerprint string) (string, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if owner, ok := r.owners[fingerprint]; ok && owner != clusterName {
+		return owner, true
+	}
+
+	// the cluster of the managed cluster may be changed, release its previous fingerprint
+	if previous, ok := r.fingerprints[clusterName]; ok && previous != fingerprint {
+		delete(r.owners, previous)
+	}
+
+	r.owners[fingerprint] = clusterName
+	r.fingerprints[clusterName] = fingerprint
+	return "", false
+}
+
+// Unregister removes the fingerprint of the managed cluster
+func (r *ClusterFingerprintRegistry) Unregister(clusterName string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if fingerprint, ok := r.fingerprints[clusterName]; ok {
+		delete(r.owners, fingerprint)
+		delete(r.fingerprints, clusterName)
+	}
+}
+
+// ValidateNotDuplicated validates the cluster that the credentials of the secret point at is not imported by another
+// managed cluster, which is found by the ClusterFingerprintAnnotation of the managed clusters, and is not being
+// imported by another managed cluster, which is found by the registry. If the cluster is not duplicated, its
+// fingerprint is registered for the managed cluster. If the cluster is duplicated, an error that wraps the
+// ErrClusterImportDuplicated is returned.
+func (r *ClusterFingerprintRegistry) ValidateNotDuplicated(ctx context.Context, runtimeClient client.Client,
+	clusterName string, secret *corev1.Secret) error {
+	fingerprint, err := ClusterFingerprint(secret)
+	if err != nil {
+		// the invalid secret is reported when the clients are generated from it
+		return nil
+	}
+
+	owner, err := findManagedClusterByFingerprint(ctx, runtimeClient, clusterName, fingerprint)
+	if err != nil {
+		return err
+	}
+	if len(owner) == 0 {
+		owner, _ = r.Register(clusterName, fingerprint)
+	}
+	if len(owner) != 0 {
+		return fmt.Errorf("%w: the cluster of the secret %s/%s is imported by the managed cluster %s",
+			ErrClusterImportDuplicated, secret.Namespace, secret.Name, owner)
+	}
+	return nil
+}
+
+// findManagedClusterByFingerprint returns the name of the managed cluster other than the given one that has the
+// fingerprint in its ClusterFingerprintAnnotation, if there is no such managed cluster, an empty name is returned
+func findManagedClusterByFingerprint(ctx context.Context, runtimeClient client.Client,
+	clusterName, fingerprint string) (string, error) {
+	managedClusters := &clusterv1.ManagedClusterList{}
+	if err := runtimeClient.List(ctx, managedClusters); err != nil {
+		return "", err
+	}
+
+	for _, managedCluster := range managedClusters.Items {
+		if managedCluster.Name == clusterName {
+			continue
+		}
+		if managedCluster.Annotations[constants.ClusterFingerprintAnnotation] == fingerprint {
+			return managedCluster.Name, nil
+		}
+	}
+	return "", nil
+}
+
+// EnsureClusterFingerprintAnnotation sets the cluster fingerprint annotation of the managed cluster to the fingerprint
+// of the secret that the managed cluster is imported with, the managed cluster is not updated if the annotation is
+// already up to date or the fingerprint cannot be built.
+func EnsureClusterFingerprintAnnotation(ctx context.Context, runtimeClient client.Client, recorder events.Recorder,
+	cluster *clusterv1.ManagedCluster, secret *corev1.Secret) error {
+	fingerprint, err := ClusterFingerprint(secret)
+	if err != nil {
+		return nil
+	}
+
+	if cluster.Annotations[constants.ClusterFingerprintAnnotation] == fingerprint {
+		return nil
+	}
+
+	patch := client.MergeFrom(cluster.DeepCopy())
+	if cluster.Annotations == nil {
+		cluster.Annotations = map[string]string{}
+	}
+	cluster.Annotations[constants.ClusterFingerprintAnnotation] = fingerprint
+	if err := runtimeClient.Patch(ctx, cluster, patch); err != nil {
+		return err
+	}
+
+	recorder.Eventf("ManagedClusterMetaObjModified",
+		"The managed cluster %s meta data is modified: annotation %s is set to %s",
+		cluster.Name, constants.ClusterFingerprintAnnotation, fingerprint)
+	return nil
+}
@@ -0,0 +1,213 @@
+// Copyright (c) Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package helpers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/openshift/library-go/pkg/operator/events/eventstesting"
+	"github.com/stolostron/managedcluster-import-controller/pkg/constants"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestClusterFingerprintRegistry(t *testing.T) {
+	newKubeconfigSecret := func(server string, caData []byte) *corev1.Secret {
+		kubeconfig, err := clientcmd.Write(*createBasic(server, "test", "test", caData))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return &corev1.Secret{
+			Data: map[string][]byte{
+				"kubeconfig": kubeconfig,
+			},
+		}
+	}
+
+	newTokenSecret := func(server string) *corev1.Secret {
+		return &corev1.Secret{
+			Data: map[string][]byte{
+				"token":  []byte("test"),
+				"server": []byte(server),
+			},
+		}
+	}
+
+	cases := []struct {
+		name              string
+		first             *corev1.Secret
+		second            *corev1.Secret
+		expectedCollision bool
+	}{
+		{
+			name:   "distinct servers",
+			first:  newKubeconfigSecret("https://cluster1:6443", []byte("ca")),
+			second: newKubeconfigSecret("https://cluster2:6443", []byte("ca")),
+		},
+		{
+			name:   "distinct CAs",
+			first:  newKubeconfigSecret("https://cluster1:6443", []byte("ca1")),
+			second: newKubeconfigSecret("https://cluster1:6443", []byte("ca2")),
+		},
+		{
+			name:              "identical server and CA",
+			first:             newKubeconfigSecret("https://cluster1:6443", []byte("ca")),
+			second:            newKubeconfigSecret("https://cluster1:6443", []byte("ca")),
+			expectedCollision: true,
+		},
+		{
+			name:              "identical server with different case and trailing slash",
+			first:             newTokenSecret("https://Cluster1:6443/"),
+			second:            newTokenSecret("https://cluster1:6443"),
+			expectedCollision: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			registry := NewClusterFingerprintRegistry()
+
+			firstFingerprint, err := ClusterFingerprint(c.first)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			secondFingerprint, err := ClusterFingerprint(c.second)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if _, collided := registry.Register("cluster1", firstFingerprint); collided {
+				t.Errorf("expected no collision for the first cluster, but failed")
+			}
+			// register again with the same cluster should not collide
+			if _, collided := registry.Register("cluster1", firstFingerprint); collided {
+				t.Errorf("expected no collision for the same cluster, but failed")
+			}
+
+			owner, collided := registry.Register("cluster2", secondFingerprint)
+			if collided != c.expectedCollision {
+				t.Errorf("expected collision %v, but got %v", c.expectedCollision, collided)
+			}
+			if collided && owner != "cluster1" {
+				t.Errorf("expected the owner cluster1, but got %s", owner)
+			}
+
+			// the fingerprint is released once the first cluster is unregistered
+			registry.Unregister("cluster1")
+			if _, collided := registry.Register("cluster2", secondFingerprint); collided {
+				t.Errorf("expected no collision after the first cluster is unregistered, but failed")
+			}
+		})
+	}
+}
+
+func TestValidateNotDuplicated(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      constants.AutoImportSecretName,
+			Namespace: "cluster2",
+		},
+		Data: map[string][]byte{
+			"token":  []byte("test"),
+			"server": []byte("https://cluster1:6443"),
+		},
+	}
+	fingerprint, err := ClusterFingerprint(secret)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	newManagedCluster := func(name, fingerprint string) *clusterv1.ManagedCluster {
+		return &clusterv1.ManagedCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+				Annotations: map[string]string{
+					constants.ClusterFingerprintAnnotation: fingerprint,
+				},
+			},
+		}
+	}
+
+	cases := []struct {
+		name        string
+		objs        []client.Object
+		registered  string
+		expectedErr error
+	}{
+		{
+			name: "no other managed cluster",
+			objs: []client.Object{newManagedCluster("cluster2", fingerprint)},
+		},
+		{
+			name: "another managed cluster with a different fingerprint",
+			objs: []client.Object{newManagedCluster("cluster1", "other")},
+		},
+		{
+			name:        "another managed cluster with the same fingerprint",
+			objs:        []client.Object{newManagedCluster("cluster1", fingerprint)},
+			expectedErr: ErrClusterImportDuplicated,
+		},
+		{
+			name:        "another managed cluster is being imported with the same fingerprint",
+			registered:  "cluster1",
+			expectedErr: ErrClusterImportDuplicated,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			registry := NewClusterFingerprintRegistry()
+			if len(c.registered) != 0 {
+				registry.Register(c.registered, fingerprint)
+			}
+
+			runtimeClient := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(c.objs...).Build()
+			err := registry.ValidateNotDuplicated(context.TODO(), runtimeClient, "cluster2", secret)
+			if !errors.Is(err, c.expectedErr) {
+				t.Errorf("expected error %v, but got %v", c.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestEnsureClusterFingerprintAnnotation(t *testing.T) {
+	secret := &corev1.Secret{
+		Data: map[string][]byte{
+			"token":  []byte("test"),
+			"server": []byte("https://cluster1:6443"),
+		},
+	}
+	fingerprint, err := ClusterFingerprint(secret)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster1",
+		},
+	}
+	runtimeClient := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(cluster).Build()
+
+	if err := EnsureClusterFingerprintAnnotation(context.TODO(), runtimeClient,
+		eventstesting.NewTestingEventRecorder(t), cluster, secret); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	updated := &clusterv1.ManagedCluster{}
+	if err := runtimeClient.Get(context.TODO(), types.NamespacedName{Name: "cluster1"}, updated); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated.Annotations[constants.ClusterFingerprintAnnotation] != fingerprint {
+		t.Errorf("expected the fingerprint annotation %s, but got %v", fingerprint, updated.Annotations)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (c) Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package helpers
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+// DeletedResource is a resource that is deleted when a managed cluster is detached
+type DeletedResource struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func (r DeletedResource) String() string {
+	if len(r.Namespace) == 0 {
+		return fmt.Sprintf("%s/%s", r.Kind, r.Name)
+	}
+	return fmt.Sprintf("%s/%s/%s", r.Kind, r.Namespace, r.Name)
+}
+
+// DetachReport accumulates the resources that are deleted when a managed cluster is detached. It wraps the event
+// recorder of a controller, so it can be passed to the deletion helpers as the recorder, and the helpers report the
+// resources that they delete to it.
+type DetachReport struct {
+	events.Recorder
+
+	lock    sync.Mutex
+	deleted []DeletedResource
+}
+
+// NewDetachReport returns an empty DetachReport that records the events with the given recorder
+func NewDetachReport(recorder events.Recorder) *DetachReport {
+	return &DetachReport{Recorder: recorder}
+}
+
+// ResourceDeleted adds a deleted resource to the report
+func (r *DetachReport) ResourceDeleted(kind, namespace, name string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.deleted = append(r.deleted, DeletedResource{Kind: kind, Namespace: namespace, Name: name})
+}
+
+// Resources returns the deleted resources in the order that they are deleted
+func (r *DetachReport) Resources() []DeletedResource {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]DeletedResource{}, r.deleted...)
+}
+
+// String returns the deleted resources in one line, e.g. ManifestWork/cluster1/work1, ManagedClusterAddOn/cluster1/addon1
+func (r *DetachReport) String() string {
+	resources := []string{}
+	for _, resource := range r.Resources() {
+		resources = append(resources, resource.String())
+	}
+	return strings.Join(resources, ", ")
+}
+
+// Summarize logs the deleted resources of the managed cluster and records them in one event, it does nothing if no
+// resource is deleted
+func (r *DetachReport) Summarize(log logr.Logger, clusterName string) {
+	summary := r.String()
+	if len(summary) == 0 {
+		return
+	}
+
+	log.Info("Deleted the resources of the managed cluster", "resources", summary)
+	r.Recorder.Eventf("ManagedClusterResourcesDeleted", "The resources of the managed cluster %s are deleted: %s",
+		clusterName, summary)
+}
+
+// deletionReporter is implemented by the recorders that accumulate the deleted resources, e.g. DetachReport
+type deletionReporter interface {
+	ResourceDeleted(kind, namespace, name string)
+}
+
+// reportDeletion reports the deleted resource to the recorder if the recorder accumulates the deleted resources
+func reportDeletion(recorder events.Recorder, kind, namespace, name string) {
+	if reporter, ok := recorder.(deletionReporter); ok {
+		reporter.ResourceDeleted(kind, namespace, name)
+	}
+}
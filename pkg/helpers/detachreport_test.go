@@ -0,0 +1,91 @@
+// Copyright (c) Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package helpers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/openshift/library-go/pkg/operator/events"
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	workv1 "open-cluster-management.io/api/work/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDetachReport(t *testing.T) {
+	cluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "test",
+			DeletionTimestamp: &metav1.Time{Time: time.Now()},
+		},
+	}
+	works := []workv1.ManifestWork{
+		{ObjectMeta: metav1.ObjectMeta{Name: "work1", Namespace: "test"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "work2", Namespace: "test"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "test-klusterlet", Namespace: "test"}},
+	}
+	objs := []client.Object{
+		&addonv1alpha1.ManagedClusterAddOn{ObjectMeta: metav1.ObjectMeta{Name: "addon1", Namespace: "test"}},
+		&addonv1alpha1.ManagedClusterAddOn{ObjectMeta: metav1.ObjectMeta{Name: "addon2", Namespace: "test"}},
+	}
+	for i := range works {
+		objs = append(objs, &works[i])
+	}
+	runtimeClient := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(objs...).Build()
+
+	recorder := events.NewInMemoryRecorder("test")
+	report := NewDetachReport(recorder)
+
+	if err := DeleteManagedClusterAddons(context.TODO(), runtimeClient, report, cluster); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	ignoreKlusterlet := func(clusterName string, manifestWork workv1.ManifestWork) bool {
+		return manifestWork.Name == KlusterletWorkName(clusterName)
+	}
+	if err := DeleteManifestWorkWithSelector(context.TODO(), runtimeClient, report, cluster, works,
+		ignoreKlusterlet); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	expected := []DeletedResource{
+		{Kind: "ManagedClusterAddOn", Namespace: "test", Name: "addon1"},
+		{Kind: "ManagedClusterAddOn", Namespace: "test", Name: "addon2"},
+		{Kind: "ManifestWork", Namespace: "test", Name: "work1"},
+		{Kind: "ManifestWork", Namespace: "test", Name: "work2"},
+	}
+	if !reflect.DeepEqual(report.Resources(), expected) {
+		t.Errorf("expected deleted resources %v, but got %v", expected, report.Resources())
+	}
+
+	report.Summarize(logr.Discard(), cluster.Name)
+	summarized := false
+	for _, event := range recorder.Events() {
+		if event.Reason != "ManagedClusterResourcesDeleted" {
+			continue
+		}
+		summarized = true
+		expectedMessage := "The resources of the managed cluster test are deleted: " +
+			"ManagedClusterAddOn/test/addon1, ManagedClusterAddOn/test/addon2, ManifestWork/test/work1, ManifestWork/test/work2"
+		if event.Message != expectedMessage {
+			t.Errorf("expected message %q, but got %q", expectedMessage, event.Message)
+		}
+	}
+	if !summarized {
+		t.Errorf("expected the summary event, but failed")
+	}
+
+	// nothing is summarized if no resource is deleted
+	emptyRecorder := events.NewInMemoryRecorder("test")
+	NewDetachReport(emptyRecorder).Summarize(logr.Discard(), cluster.Name)
+	if len(emptyRecorder.Events()) != 0 {
+		t.Errorf("expected no event, but got %v", emptyRecorder.Events())
+	}
+}
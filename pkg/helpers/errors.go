@@ -0,0 +1,64 @@
+// Copyright (c) Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package helpers
+
+import "errors"
+
+// The errors of the helpers are wrapped with the below errors, the callers can use errors.Is to check them
+var (
+	// ErrSecretMissingCredentials means the secret does not have a kubeconfig or a token and server
+	ErrSecretMissingCredentials = errors.New("the secret is missing credentials")
+
+	// ErrClusterUnreachable means the cluster cannot be reached with the credentials of the secret, it is matched by
+	// the ClusterUnreachableError
+	ErrClusterUnreachable = errors.New("the cluster is unreachable")
+
+	// ErrInvalidImportSecret means the import secret does not have the required data
+	ErrInvalidImportSecret = errors.New("the import secret is invalid")
+
+	// ErrInsecureServer means the server of the credentials in the secret does not use https
+	ErrInsecureServer = errors.New("the server does not use https")
+
+	// ErrNamespaceNotFound means the managed cluster namespace does not exist
+	ErrNamespaceNotFound = errors.New("the namespace is not found")
+
+	// ErrNamespaceTerminating means the managed cluster namespace is terminating, the resources cannot be created in it
+	ErrNamespaceTerminating = errors.New("the namespace is terminating")
+
+	// ErrHubServerMismatch means the bootstrap hub kubeconfig of the import secret does not point at the current hub,
+	// e.g. the import secret is restored from the backup of another hub
+	ErrHubServerMismatch = errors.New("the bootstrap hub server does not match the hub")
+
+	// ErrInsufficientPermissions means the credentials of the secret do not have the permissions to apply the
+	// resources of the import secret on the managed cluster
+	ErrInsufficientPermissions = errors.New("insufficient permissions")
+
+	// ErrManagedByAnotherHub means the klusterlet on the managed cluster is bootstrapped with another hub, importing
+	// the cluster again would make it managed by two hubs
+	ErrManagedByAnotherHub = errors.New("the managed cluster is managed by another hub")
+
+	// ErrUnsupportedAuthProvider means the auth provider of the kubeconfig is not registered or its config is invalid,
+	// it is matched by the UnsupportedAuthProviderError
+	ErrUnsupportedAuthProvider = errors.New("the auth provider is not supported")
+
+	// ErrNodeSelectorMayNotMatch means the nodeSelector is valid but its well-known node labels are unexpected, so the
+	// klusterlet may not be scheduled to any node, it is a warning rather than a failure
+	ErrNodeSelectorMayNotMatch = errors.New("the nodeSelector may not match any node")
+
+	// ErrClusterIdentityMismatch means the identity of the target cluster does not match the expected cluster id,
+	// it is wrapped by the ClusterIdentityMismatchError
+	ErrClusterIdentityMismatch = errors.New("the cluster identity does not match")
+
+	// ErrServerURLMismatch means the server of the auto import secret does not match the expected server url, it is
+	// wrapped by the ServerURLMismatchError
+	ErrServerURLMismatch = errors.New("the server url does not match")
+
+	// ErrInvalidHostingCluster means the hosting cluster of a hosted mode managed cluster cannot be resolved, it is
+	// wrapped by the InvalidHostingClusterError
+	ErrInvalidHostingCluster = errors.New("the hosting cluster is invalid")
+
+	// ErrClusterImportDuplicated means the cluster that the credentials of a secret point at is imported or being
+	// imported by another managed cluster
+	ErrClusterImportDuplicated = errors.New("the cluster is imported by another managed cluster")
+)
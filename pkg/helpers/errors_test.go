@@ -0,0 +1,268 @@
+// Copyright (c) Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stolostron/managedcluster-import-controller/pkg/constants"
+	testinghelpers "github.com/stolostron/managedcluster-import-controller/pkg/helpers/testing"
+
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestErrors(t *testing.T) {
+	cases := []struct {
+		name        string
+		do          func() error
+		expectedErr error
+	}{
+		{
+			name: "the secret does not have credentials",
+			do: func() error {
+				_, _, err := GenerateClientFromSecret(&corev1.Secret{})
+				return err
+			},
+			expectedErr: ErrSecretMissingCredentials,
+		},
+		{
+			name: "the cluster is unreachable",
+			do: func() error {
+				_, _, err := GenerateClientFromSecret(&corev1.Secret{
+					Data: map[string][]byte{
+						"token":  []byte("test"),
+						"server": []byte("https://127.0.0.1:1"),
+					},
+				})
+				return err
+			},
+			expectedErr: ErrClusterUnreachable,
+		},
+		{
+			name: "the server uses http",
+			do: func() error {
+				_, _, err := GenerateClientFromSecret(&corev1.Secret{
+					Data: map[string][]byte{
+						"token":  []byte("test"),
+						"server": []byte("http://127.0.0.1:1"),
+					},
+				})
+				return err
+			},
+			expectedErr: ErrInsecureServer,
+		},
+		{
+			name: "the server uses http and http is allowed",
+			do: func() error {
+				os.Setenv(allowHTTPServerEnvVarName, "true")
+				defer os.Unsetenv(allowHTTPServerEnvVarName)
+
+				_, _, err := GenerateClientFromSecret(&corev1.Secret{
+					Data: map[string][]byte{
+						"token":  []byte("test"),
+						"server": []byte("http://127.0.0.1:1"),
+					},
+				})
+				return err
+			},
+			expectedErr: ErrClusterUnreachable,
+		},
+		{
+			name: "the import secret is invalid",
+			do: func() error {
+				return ValidateImportSecret(&corev1.Secret{})
+			},
+			expectedErr: ErrInvalidImportSecret,
+		},
+		{
+			name: "the cluster namespace is not found",
+			do: func() error {
+				return ValidateClusterNamespace(context.TODO(), kubefake.NewSimpleClientset(), "test")
+			},
+			expectedErr: ErrNamespaceNotFound,
+		},
+		{
+			name: "the cluster namespace is terminating",
+			do: func() error {
+				kubeClient := kubefake.NewSimpleClientset(&corev1.Namespace{
+					ObjectMeta: metav1.ObjectMeta{Name: "test"},
+					Status:     corev1.NamespaceStatus{Phase: corev1.NamespaceTerminating},
+				})
+				return ValidateClusterNamespace(context.TODO(), kubeClient, "test")
+			},
+			expectedErr: ErrNamespaceTerminating,
+		},
+		{
+			name: "the bootstrap hub server does not match the hub",
+			do: func() error {
+				return ValidateBootstrapHubServer(newImportSecretWithBootstrapServer("https://api.old-hub.example.com:6443"),
+					"https://api.hub.example.com:6443")
+			},
+			expectedErr: ErrHubServerMismatch,
+		},
+		{
+			name: "the credentials do not have the permissions to import",
+			do: func() error {
+				kubeClient := kubefake.NewSimpleClientset()
+				kubeClient.PrependReactor("create", "selfsubjectaccessreviews",
+					func(action clienttesting.Action) (bool, runtime.Object, error) {
+						return true, action.(clienttesting.CreateAction).GetObject(), nil
+					})
+				return ValidateImportPermissions(context.TODO(), kubeClient, testinghelpers.GetImportSecret("test"))
+			},
+			expectedErr: ErrInsufficientPermissions,
+		},
+		{
+			name: "the managed cluster is managed by another hub",
+			do: func() error {
+				bootstrapSecret, err := getBootstrapHubKubeconfigSecret(
+					newImportSecretWithBootstrapServer("https://api.another-hub.example.com:6443"))
+				if err != nil {
+					return err
+				}
+				return ValidateNotManagedByAnotherHub(context.TODO(), kubefake.NewSimpleClientset(bootstrapSecret),
+					newImportSecretWithBootstrapServer("https://api.hub.example.com:6443"))
+			},
+			expectedErr: ErrManagedByAnotherHub,
+		},
+		{
+			name: "the auth provider is not supported",
+			do: func() error {
+				config := clientcmdapi.NewConfig()
+				config.Clusters["test"] = &clientcmdapi.Cluster{Server: "https://127.0.0.1:6443"}
+				config.AuthInfos["test"] = &clientcmdapi.AuthInfo{
+					AuthProvider: &clientcmdapi.AuthProviderConfig{Name: "unknown"},
+				}
+				config.Contexts["test"] = &clientcmdapi.Context{Cluster: "test", AuthInfo: "test"}
+				config.CurrentContext = "test"
+				kubeconfig, err := clientcmd.Write(*config)
+				if err != nil {
+					return err
+				}
+				_, _, err = GenerateClientFromSecret(&corev1.Secret{
+					Data: map[string][]byte{"kubeconfig": kubeconfig},
+				})
+				return err
+			},
+			expectedErr: ErrUnsupportedAuthProvider,
+		},
+		{
+			name: "the nodeSelector may not match any node",
+			do: func() error {
+				return ValidateNodeSelectorWellKnownLabels(map[string]string{"kubernetes.io/os": "darwin"})
+			},
+			expectedErr: ErrNodeSelectorMayNotMatch,
+		},
+		{
+			name: "the cluster identity does not match",
+			do: func() error {
+				kubeClient := kubefake.NewSimpleClientset(&corev1.Namespace{
+					ObjectMeta: metav1.ObjectMeta{Name: metav1.NamespaceSystem, UID: "actual"},
+				})
+				err := ValidateClusterIdentity(context.TODO(), kubeClient, &corev1.Secret{
+					Data: map[string][]byte{constants.AutoImportExpectedClusterIDName: []byte("expected")},
+				})
+				return utilerrors.NewAggregate([]error{fmt.Errorf("auto-import-secret: %w", err)})
+			},
+			expectedErr: ErrClusterIdentityMismatch,
+		},
+		{
+			name: "the server url does not match",
+			do: func() error {
+				return ValidateExpectedServerURL(&clusterv1.ManagedCluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "test",
+						Annotations: map[string]string{constants.ExpectedServerURLAnnotation: "https://api.a.example.com"},
+					},
+				}, &corev1.Secret{
+					Data: map[string][]byte{
+						"token":  []byte("test"),
+						"server": []byte("https://api.b.example.com"),
+					},
+				})
+			},
+			expectedErr: ErrServerURLMismatch,
+		},
+		{
+			name: "the hosting cluster is invalid",
+			do: func() error {
+				_, err := GetHostingClusterName(&clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "test"}})
+				return fmt.Errorf("wrapped: %w", err)
+			},
+			expectedErr: ErrInvalidHostingCluster,
+		},
+		{
+			name: "the cluster namespace is active",
+			do: func() error {
+				kubeClient := kubefake.NewSimpleClientset(&corev1.Namespace{
+					ObjectMeta: metav1.ObjectMeta{Name: "test"},
+					Status:     corev1.NamespaceStatus{Phase: corev1.NamespaceActive},
+				})
+				return ValidateClusterNamespace(context.TODO(), kubeClient, "test")
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "the hosted import secret is invalid",
+			do: func() error {
+				return ValidateHostedImportSecret(&corev1.Secret{})
+			},
+			expectedErr: ErrInvalidImportSecret,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.do()
+			if !errors.Is(err, c.expectedErr) {
+				t.Errorf("expected error %v, but got %v", c.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestTypedErrorsWrapped(t *testing.T) {
+	wrap := func(err error) error {
+		return utilerrors.NewAggregate([]error{fmt.Errorf("wrapped: %w", err)})
+	}
+
+	if !IsClusterIdentityMismatch(wrap(&ClusterIdentityMismatchError{})) {
+		t.Errorf("expected the wrapped cluster identity mismatch error is matched, but failed")
+	}
+	if !IsServerURLMismatch(wrap(&ServerURLMismatchError{})) {
+		t.Errorf("expected the wrapped server url mismatch error is matched, but failed")
+	}
+	if !IsInvalidHostingCluster(wrap(&InvalidHostingClusterError{})) {
+		t.Errorf("expected the wrapped invalid hosting cluster error is matched, but failed")
+	}
+
+	cause := errors.New("connection refused")
+	for _, err := range []error{
+		&ClusterUnreachableError{Err: cause},
+		&UnsupportedAuthProviderError{Name: "test", Err: cause},
+	} {
+		if !errors.Is(wrap(err), cause) {
+			t.Errorf("expected the cause of the wrapped error %v is matched, but failed", err)
+		}
+	}
+	if !errors.Is(wrap(&ClusterUnreachableError{Err: cause}), ErrClusterUnreachable) {
+		t.Errorf("expected the wrapped cluster unreachable error is matched, but failed")
+	}
+	if !errors.Is(wrap(&UnsupportedAuthProviderError{Name: "test", Err: cause}), ErrUnsupportedAuthProvider) {
+		t.Errorf("expected the wrapped unsupported auth provider error is matched, but failed")
+	}
+}
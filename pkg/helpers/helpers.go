@@ -6,13 +6,20 @@ package helpers
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	goerrors "errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
@@ -24,11 +31,13 @@ import (
 	operatorv1 "open-cluster-management.io/api/operator/v1"
 	workv1 "open-cluster-management.io/api/work/v1"
 
+	ocinfrav1 "github.com/openshift/api/config/v1"
 	"github.com/openshift/library-go/pkg/operator/events"
 	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
 	"github.com/openshift/library-go/pkg/operator/resource/resourcemerge"
 
 	appsv1 "k8s.io/api/apps/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	crdv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
@@ -44,11 +53,16 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/version"
+	apimachineryversion "k8s.io/apimachinery/pkg/version"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
 	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 
@@ -59,14 +73,96 @@ import (
 
 const maxConcurrentReconcilesEnvVarName = "MAX_CONCURRENT_RECONCILES"
 
+const maxConcurrentReconcilesCeilingEnvVarName = "MAX_CONCURRENT_RECONCILES_CEILING"
+
+// defaultMaxConcurrentReconcilesCeiling is the default upper limit of the max concurrent reconciles, a larger value
+// may exhaust the api client
+const defaultMaxConcurrentReconcilesCeiling = 50
+
+const clusterUnavailableGracePeriodEnvVarName = "CLUSTER_UNAVAILABLE_GRACE_PERIOD"
+
+const maxTolerationsEnvVarName = "MAX_TOLERATIONS"
+
+const defaultMaxTolerations = 10
+
+const forceDeleteTimeoutEnvVarName = "FORCE_DELETE_TIMEOUT"
+
+// defaultForceDeleteTimeout is the default timeout of force deleting one resource
+const defaultForceDeleteTimeout = 30 * time.Second
+
+const importTimeoutEnvVarName = "IMPORT_TIMEOUT"
+
+// defaultImportTimeout is the default timeout of the requests of the managed cluster clients to import a managed cluster
+const defaultImportTimeout = 2 * time.Minute
+
+const manifestWorkResyncPeriodEnvVarName = "MANIFESTWORK_RESYNC_PERIOD"
+
+// defaultManifestWorkResyncPeriod is the default period of enqueuing all of the managed clusters to the manifestwork
+// controller, it is a safety net for the missed events
+const defaultManifestWorkResyncPeriod = 30 * time.Minute
+
+// componentNameEnvVarName is the env to customize the name of this controller instance, it is used as the field
+// manager of the server-side apply and the prefix of the event source, so that the changes and the events from
+// multiple instances of this controller can be distinguished
+const componentNameEnvVarName = "EVENT_SOURCE_COMPONENT_NAME"
+
+// defaultComponentName is the default name of this controller instance
+const defaultComponentName = "managedcluster-import-controller"
+
+const defaultNodeSelectorEnvVarName = "DEFAULT_NODE_SELECTOR"
+
+const defaultTolerationsEnvVarName = "DEFAULT_TOLERATIONS"
+
+const noExecuteTolerationSecondsEnvVarName = "NO_EXECUTE_TOLERATION_SECONDS"
+
+// defaultNoExecuteTolerationSeconds is the default period that the klusterlet agents tolerate the unreachable and
+// not-ready NoExecute taints, it is same as the default toleration seconds of the kubernetes pods
+const defaultNoExecuteTolerationSeconds int64 = 300
+
+const maxTolerationSecondsEnvVarName = "MAX_TOLERATION_SECONDS"
+
+// defaultMaxTolerationSeconds is the default upper limit of the tolerationSeconds of a toleration, a klusterlet
+// agent that tolerates an unhealthy node longer than one day is not meaningful
+const defaultMaxTolerationSeconds int64 = 86400
+
+// allowHTTPServerEnvVarName is the env to allow the managed cluster servers that use plain http, e.g. the test
+// clusters, by default, only the https servers are allowed
+const allowHTTPServerEnvVarName = "ALLOW_HTTP_SERVER"
+
+// allowedTokenFileDirsEnvVarName is the env to configure the comma-separated directories that the tokenFile of the
+// secrets can reference, e.g. the directory of a projected service account token, by default, the tokenFile is not
+// allowed
+const allowedTokenFileDirsEnvVarName = "ALLOWED_TOKEN_FILE_DIRS"
+
+// inClusterServiceAccountDir is the directory of the service account credentials of this controller, the tokenFile
+// can never reference the files in it, otherwise the token of this controller could be sent to any server
+const inClusterServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// postImportHookWebhookURLsEnvVarName is the env to configure the comma-separated URLs of the post-import hook
+// webhooks that can be called, by default, no webhook can be called
+const postImportHookWebhookURLsEnvVarName = "POST_IMPORT_HOOK_WEBHOOK_URLS"
+
+// postImportHookConfigMapsEnvVarName is the env to configure the comma-separated names of the post-import hook
+// ConfigMaps whose manifests can be applied to the managed clusters, by default, no ConfigMap can be applied
+const postImportHookConfigMapsEnvVarName = "POST_IMPORT_HOOK_CONFIGMAPS"
+
+// bootstrapServiceAccountTokenExpirationSeconds is the expiration of the token that is requested for the bootstrap
+// service account, the token is only used to import the managed cluster
+const bootstrapServiceAccountTokenExpirationSeconds int64 = 3600
+
 const (
 	nodeSelectorAnnotation = "open-cluster-management/nodeSelector"
 	tolerationsAnnotation  = "open-cluster-management/tolerations"
+
+	// priorityClassAnnotation is the name of the PriorityClass of the klusterlet deployment, it is used to prevent
+	// the klusterlet from being evicted on the contended managed clusters
+	priorityClassAnnotation = "open-cluster-management/klusterlet-priority-class"
 )
 
 var v1APIExtensionMinVersion = version.MustParseGeneric("v1.16.0")
 
-var crdGroupKind = schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}
+// CRDGroupKind is the group kind of the CustomResourceDefinition, it covers both the v1 and v1beta1 versions
+var CRDGroupKind = schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}
 
 var (
 	genericScheme = runtime.NewScheme()
@@ -93,7 +189,7 @@ type ClientHolder struct {
 }
 
 // GetMaxConcurrentReconciles get the max concurrent reconciles from MAX_CONCURRENT_RECONCILES env,
-// if the reconciles cannot be found, return 1
+// if the reconciles cannot be found, return 1. The reconciles is clamped to [1, GetMaxConcurrentReconcilesCeiling()]
 func GetMaxConcurrentReconciles() int {
 	maxConcurrentReconciles := 1
 	if os.Getenv(maxConcurrentReconcilesEnvVarName) != "" {
@@ -104,48 +200,261 @@ func GetMaxConcurrentReconciles() int {
 			maxConcurrentReconciles = 1
 		}
 	}
-	return maxConcurrentReconciles
+	return clampMaxConcurrentReconciles(maxConcurrentReconcilesEnvVarName, maxConcurrentReconciles)
 }
 
-// GenerateClientFromSecret generate a client from a given secret
-func GenerateClientFromSecret(secret *corev1.Secret) (*ClientHolder, meta.RESTMapper, error) {
-	var err error
-	var config *clientcmdapi.Config
+// GetMaxConcurrentReconcilesCeiling get the upper limit of the max concurrent reconciles from
+// MAX_CONCURRENT_RECONCILES_CEILING env, if the limit cannot be found, return 50
+func GetMaxConcurrentReconcilesCeiling() int {
+	ceiling := defaultMaxConcurrentReconcilesCeiling
+	if os.Getenv(maxConcurrentReconcilesCeilingEnvVarName) != "" {
+		var err error
+		ceiling, err = strconv.Atoi(os.Getenv(maxConcurrentReconcilesCeilingEnvVarName))
+		if err != nil || ceiling < 1 {
+			klog.Warningf("The value of %s env is wrong, using default ceiling (%d)",
+				maxConcurrentReconcilesCeilingEnvVarName, defaultMaxConcurrentReconcilesCeiling)
+			ceiling = defaultMaxConcurrentReconcilesCeiling
+		}
+	}
+	return ceiling
+}
 
-	if kubeconfig, ok := secret.Data["kubeconfig"]; ok {
-		config, err = clientcmd.Load(kubeconfig)
-		if err != nil {
-			return nil, nil, err
+func clampMaxConcurrentReconciles(envVarName string, reconciles int) int {
+	if reconciles < 1 {
+		klog.Warningf("The value of %s env %d is less than 1, using 1", envVarName, reconciles)
+		return 1
+	}
+
+	ceiling := GetMaxConcurrentReconcilesCeiling()
+	if reconciles > ceiling {
+		klog.Warningf("The value of %s env %d exceeds the ceiling, using %d", envVarName, reconciles, ceiling)
+		return ceiling
+	}
+
+	return reconciles
+}
+
+// GetMaxConcurrentReconcilesFor get the max concurrent reconciles of the given controller from its controller specific
+// env MAX_CONCURRENT_RECONCILES_<CONTROLLER>, e.g. MAX_CONCURRENT_RECONCILES_MANIFESTWORK for the
+// manifestwork-controller, if the reconciles cannot be found, fall back to GetMaxConcurrentReconciles
+func GetMaxConcurrentReconcilesFor(controllerName string) int {
+	envVarName := maxConcurrentReconcilesEnvVarNameFor(controllerName)
+	if os.Getenv(envVarName) != "" {
+		maxConcurrentReconciles, err := strconv.Atoi(os.Getenv(envVarName))
+		if err == nil {
+			return clampMaxConcurrentReconciles(envVarName, maxConcurrentReconciles)
 		}
+		klog.Warningf("The value of %s env is wrong, using the value of %s env",
+			envVarName, maxConcurrentReconcilesEnvVarName)
 	}
+	return GetMaxConcurrentReconciles()
+}
 
-	token, tok := secret.Data["token"]
-	server, sok := secret.Data["server"]
-	if tok && sok {
-		config = clientcmdapi.NewConfig()
-		config.Clusters["default"] = &clientcmdapi.Cluster{
-			Server:                string(server),
-			InsecureSkipTLSVerify: true,
+func maxConcurrentReconcilesEnvVarNameFor(controllerName string) string {
+	name := strings.TrimSuffix(controllerName, "-controller")
+	name = strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+	return fmt.Sprintf("%s_%s", maxConcurrentReconcilesEnvVarName, name)
+}
+
+// GetClusterUnavailableGracePeriod get the grace period of an unavailable cluster from CLUSTER_UNAVAILABLE_GRACE_PERIOD
+// env, the value is a duration string, e.g. 5m. If the grace period cannot be found, return 0, which means the cluster
+// is considered as unavailable immediately once its available condition is not true.
+func GetClusterUnavailableGracePeriod() time.Duration {
+	gracePeriod := time.Duration(0)
+	if os.Getenv(clusterUnavailableGracePeriodEnvVarName) != "" {
+		var err error
+		gracePeriod, err = time.ParseDuration(os.Getenv(clusterUnavailableGracePeriodEnvVarName))
+		if err != nil || gracePeriod < 0 {
+			klog.Warningf("The value of %s env is wrong, using default grace period (0)", clusterUnavailableGracePeriodEnvVarName)
+			gracePeriod = 0
 		}
-		config.AuthInfos["default"] = &clientcmdapi.AuthInfo{
-			Token: string(token),
+	}
+	return gracePeriod
+}
+
+// GetMaxTolerations get the max number of the tolerations in the tolerations annotation of a managed cluster from
+// MAX_TOLERATIONS env, the max number must be positive, if the max number cannot be found or is invalid, return 10
+func GetMaxTolerations() int {
+	maxTolerations := defaultMaxTolerations
+	if os.Getenv(maxTolerationsEnvVarName) != "" {
+		var err error
+		maxTolerations, err = strconv.Atoi(os.Getenv(maxTolerationsEnvVarName))
+		if err != nil || maxTolerations <= 0 {
+			klog.Warningf("The value of %s env is wrong, using default max tolerations (%d)",
+				maxTolerationsEnvVarName, defaultMaxTolerations)
+			maxTolerations = defaultMaxTolerations
 		}
-		config.Contexts["default"] = &clientcmdapi.Context{
-			Cluster:  "default",
-			AuthInfo: "default",
+	}
+	return maxTolerations
+}
+
+// GetNoExecuteTolerationSeconds get the period that the klusterlet agents tolerate the unreachable and not-ready
+// NoExecute taints from NO_EXECUTE_TOLERATION_SECONDS env, if the period cannot be found, return 300
+func GetNoExecuteTolerationSeconds() int64 {
+	tolerationSeconds := defaultNoExecuteTolerationSeconds
+	if os.Getenv(noExecuteTolerationSecondsEnvVarName) != "" {
+		var err error
+		tolerationSeconds, err = strconv.ParseInt(os.Getenv(noExecuteTolerationSecondsEnvVarName), 10, 64)
+		if err != nil || tolerationSeconds < 0 {
+			klog.Warningf("The value of %s env is wrong, using default toleration seconds (%d)",
+				noExecuteTolerationSecondsEnvVarName, defaultNoExecuteTolerationSeconds)
+			tolerationSeconds = defaultNoExecuteTolerationSeconds
 		}
-		config.CurrentContext = "default"
 	}
+	return tolerationSeconds
+}
 
-	if config == nil {
-		return nil, nil, fmt.Errorf("kubeconfig or token and server are missing")
+// GetMaxTolerationSeconds get the upper limit of the tolerationSeconds of a toleration from MAX_TOLERATION_SECONDS
+// env, if the upper limit cannot be found, return 86400
+func GetMaxTolerationSeconds() int64 {
+	maxTolerationSeconds := defaultMaxTolerationSeconds
+	if os.Getenv(maxTolerationSecondsEnvVarName) != "" {
+		var err error
+		maxTolerationSeconds, err = strconv.ParseInt(os.Getenv(maxTolerationSecondsEnvVarName), 10, 64)
+		if err != nil || maxTolerationSeconds < 0 {
+			klog.Warningf("The value of %s env is wrong, using default max toleration seconds (%d)",
+				maxTolerationSecondsEnvVarName, defaultMaxTolerationSeconds)
+			maxTolerationSeconds = defaultMaxTolerationSeconds
+		}
 	}
+	return maxTolerationSeconds
+}
 
-	clientConfig, err := clientcmd.NewDefaultClientConfig(*config, &clientcmd.ConfigOverrides{}).ClientConfig()
+// GetForceDeleteTimeout get the timeout of force deleting one resource from FORCE_DELETE_TIMEOUT env, the value is a
+// duration string, e.g. 30s. If the timeout cannot be found, return 30s.
+func GetForceDeleteTimeout() time.Duration {
+	timeout := defaultForceDeleteTimeout
+	if os.Getenv(forceDeleteTimeoutEnvVarName) != "" {
+		var err error
+		timeout, err = time.ParseDuration(os.Getenv(forceDeleteTimeoutEnvVarName))
+		if err != nil || timeout <= 0 {
+			klog.Warningf("The value of %s env is wrong, using default timeout (%v)",
+				forceDeleteTimeoutEnvVarName, defaultForceDeleteTimeout)
+			timeout = defaultForceDeleteTimeout
+		}
+	}
+	return timeout
+}
+
+// GetImportTimeout get the timeout of the requests to import a managed cluster from IMPORT_TIMEOUT env, the value is a
+// duration string, e.g. 5m. If the timeout cannot be found, return 2m.
+func GetImportTimeout() time.Duration {
+	timeout := defaultImportTimeout
+	if os.Getenv(importTimeoutEnvVarName) != "" {
+		var err error
+		timeout, err = time.ParseDuration(os.Getenv(importTimeoutEnvVarName))
+		if err != nil || timeout <= 0 {
+			klog.Warningf("The value of %s env is wrong, using default timeout (%v)",
+				importTimeoutEnvVarName, defaultImportTimeout)
+			timeout = defaultImportTimeout
+		}
+	}
+	return timeout
+}
+
+// GetManifestWorkResyncPeriod get the period of resyncing all of the managed clusters in the manifestwork controller from
+// MANIFESTWORK_RESYNC_PERIOD env, the value is a duration string, e.g. 1h, 0 disables the resync. If the period cannot
+// be found, return 30m
+func GetManifestWorkResyncPeriod() time.Duration {
+	period := defaultManifestWorkResyncPeriod
+	if os.Getenv(manifestWorkResyncPeriodEnvVarName) != "" {
+		var err error
+		period, err = time.ParseDuration(os.Getenv(manifestWorkResyncPeriodEnvVarName))
+		if err != nil || period < 0 {
+			klog.Warningf("The value of %s env is wrong, using default resync period (%v)",
+				manifestWorkResyncPeriodEnvVarName, defaultManifestWorkResyncPeriod)
+			period = defaultManifestWorkResyncPeriod
+		}
+	}
+	return period
+}
+
+// IsPostImportHookWebhookAllowed returns true if the webhook URL is one of the URLs in the
+// POST_IMPORT_HOOK_WEBHOOK_URLS env, the webhook URL comes from an annotation of the managed cluster, so only the
+// webhooks that are configured by the operator can be called by this controller
+func IsPostImportHookWebhookAllowed(webhookURL string) bool {
+	return isConfiguredInEnv(postImportHookWebhookURLsEnvVarName, webhookURL)
+}
+
+// IsPostImportHookConfigMapAllowed returns true if the ConfigMap name is one of the names in the
+// POST_IMPORT_HOOK_CONFIGMAPS env, the ConfigMap name comes from an annotation of the managed cluster and its
+// manifests are applied to the managed cluster, so only the ConfigMaps that are configured by the operator can be
+// applied by this controller
+func IsPostImportHookConfigMapAllowed(configMapName string) bool {
+	return isConfiguredInEnv(postImportHookConfigMapsEnvVarName, configMapName)
+}
+
+// isConfiguredInEnv returns true if the value is one of the comma-separated values of the env
+func isConfiguredInEnv(envVarName, value string) bool {
+	value = strings.TrimSpace(value)
+	if len(value) == 0 {
+		return false
+	}
+
+	for _, configured := range strings.Split(os.Getenv(envVarName), ",") {
+		if strings.TrimSpace(configured) == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ClusterUnreachableError is returned when the cluster cannot be reached with the credentials of a secret, it keeps
+// the error of the discovery of the cluster apis
+type ClusterUnreachableError struct {
+	Err error
+}
+
+func (e *ClusterUnreachableError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrClusterUnreachable, e.Err)
+}
+
+// Is returns true for the ErrClusterUnreachable, so the error can be checked with errors.Is
+func (e *ClusterUnreachableError) Is(target error) bool {
+	return target == ErrClusterUnreachable
+}
+
+// Unwrap returns the error of the discovery, so the cause can be checked with errors.Is and errors.As
+func (e *ClusterUnreachableError) Unwrap() error {
+	return e.Err
+}
+
+// UnsupportedAuthProviderError is returned when the auth provider of a kubeconfig is not registered or its config is
+// invalid, it keeps the error of the auth provider
+type UnsupportedAuthProviderError struct {
+	Name string
+	Err  error
+}
+
+func (e *UnsupportedAuthProviderError) Error() string {
+	return fmt.Sprintf("%v: %s: %v", ErrUnsupportedAuthProvider, e.Name, e.Err)
+}
+
+// Is returns true for the ErrUnsupportedAuthProvider, so the error can be checked with errors.Is
+func (e *UnsupportedAuthProviderError) Is(target error) bool {
+	return target == ErrUnsupportedAuthProvider
+}
+
+// Unwrap returns the error of the auth provider, so the cause can be checked with errors.Is and errors.As
+func (e *UnsupportedAuthProviderError) Unwrap() error {
+	return e.Err
+}
+
+// GenerateClientFromSecret generate a client from a given secret, if the secret has a bootstrap service account, the
+// client uses a token of the service account that is requested with the credentials of the secret
+func GenerateClientFromSecret(secret *corev1.Secret) (*ClientHolder, meta.RESTMapper, error) {
+	clientConfig, err := buildClientConfigFromSecret(secret)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	if bootstrapSA, ok := secret.Data[constants.AutoImportBootstrapServiceAccountName]; ok {
+		clientConfig, err = buildBootstrapServiceAccountClientConfig(clientConfig, string(bootstrapSA))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
 	kubeClient, err := kubernetes.NewForConfig(clientConfig)
 	if err != nil {
 		return nil, nil, err
@@ -161,14 +470,15 @@ func GenerateClientFromSecret(secret *corev1.Secret) (*ClientHolder, meta.RESTMa
 		return nil, nil, err
 	}
 
+	// both of the runtime client and the rest mapper discover the apis of the cluster
 	runtimeClient, err := client.New(clientConfig, client.Options{})
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, &ClusterUnreachableError{Err: err}
 	}
 
 	mapper, err := apiutil.NewDiscoveryRESTMapper(clientConfig)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, &ClusterUnreachableError{Err: err}
 	}
 
 	return &ClientHolder{
@@ -179,295 +489,1298 @@ func GenerateClientFromSecret(secret *corev1.Secret) (*ClientHolder, meta.RESTMa
 	}, mapper, nil
 }
 
-// AddManagedClusterFinalizer add a finalizer to a managed cluster
-func AddManagedClusterFinalizer(modified *bool, managedCluster *clusterv1.ManagedCluster, finalizer string) {
-	for i := range managedCluster.Finalizers {
-		if managedCluster.Finalizers[i] == finalizer {
-			return
+// buildClientConfigFromSecret builds the client config from the kubeconfig, or the token (or the tokenFile) and server
+// of the secret
+func buildClientConfigFromSecret(secret *corev1.Secret) (*rest.Config, error) {
+	var err error
+	var config *clientcmdapi.Config
+
+	if kubeconfig, ok := secret.Data["kubeconfig"]; ok {
+		config, err = clientcmd.Load(kubeconfig)
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	managedCluster.Finalizers = append(managedCluster.Finalizers, finalizer)
-	*modified = true
-}
+	token, tok := secret.Data["token"]
+	tokenFile, tfok := secret.Data["tokenFile"]
+	server, sok := secret.Data["server"]
+	if (tok || tfok) && sok {
+		authInfo := &clientcmdapi.AuthInfo{
+			Token: string(token),
+		}
+		if !tok {
+			// the token file is read by the client each time the token is required, so the rotated token is used
+			if err := validateTokenFile(string(tokenFile)); err != nil {
+				return nil, err
+			}
+			authInfo = &clientcmdapi.AuthInfo{
+				TokenFile: filepath.Clean(string(tokenFile)),
+			}
+		}
 
-// RemoveManagedClusterFinalizer remove a finalizer from a managed cluster
-func RemoveManagedClusterFinalizer(ctx context.Context, runtimeClient client.Client, recorder events.Recorder,
-	managedCluster *clusterv1.ManagedCluster, finalizer string) error {
-	copiedFinalizers := []string{}
-	for i := range managedCluster.Finalizers {
-		if managedCluster.Finalizers[i] == finalizer {
-			continue
+		config = clientcmdapi.NewConfig()
+		config.Clusters["default"] = &clientcmdapi.Cluster{
+			Server:                string(server),
+			InsecureSkipTLSVerify: true,
 		}
-		copiedFinalizers = append(copiedFinalizers, managedCluster.Finalizers[i])
+		config.AuthInfos["default"] = authInfo
+		config.Contexts["default"] = &clientcmdapi.Context{
+			Cluster:  "default",
+			AuthInfo: "default",
+		}
+		config.CurrentContext = "default"
 	}
 
-	if len(managedCluster.Finalizers) == len(copiedFinalizers) {
-		return nil
+	if config == nil {
+		return nil, fmt.Errorf("%w: kubeconfig or token and server are missing", ErrSecretMissingCredentials)
 	}
 
-	patch := client.MergeFrom(managedCluster.DeepCopy())
-	managedCluster.Finalizers = copiedFinalizers
-	if err := runtimeClient.Patch(ctx, managedCluster, patch); err != nil {
-		return err
+	clientConfig, err := clientcmd.NewDefaultClientConfig(*config, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, err
 	}
 
-	recorder.Eventf("ManagedClusterFinalizerRemoved",
-		"The managed cluster %s finalizer %s is removed", managedCluster.Name, finalizer)
-	return nil
-}
+	// a request to the managed cluster is abandoned if the managed cluster does not respond in time
+	clientConfig.Timeout = GetImportTimeout()
 
-// UpdateManagedClusterStatus update managed cluster status
-func UpdateManagedClusterStatus(client client.Client, recorder events.Recorder,
-	managedClusterName string, cond metav1.Condition) error {
-	managedCluster := &clusterv1.ManagedCluster{}
-	err := client.Get(context.TODO(), types.NamespacedName{Name: managedClusterName}, managedCluster)
-	if err != nil {
-		return err
+	if err := ValidateServerURL(clientConfig); err != nil {
+		return nil, err
+	}
+
+	if err := validateAuthProvider(clientConfig); err != nil {
+		return nil, err
 	}
 
-	oldStatus := &managedCluster.Status
-	newStatus := oldStatus.DeepCopy()
+	return clientConfig, nil
+}
 
-	meta.SetStatusCondition(&newStatus.Conditions, cond)
-	if equality.Semantic.DeepEqual(managedCluster.Status.Conditions, newStatus.Conditions) {
+// validateAuthProvider checks the auth provider (e.g. oidc) of the client config is registered and its config is
+// valid, so an unsupported auth provider is reported before the import instead of failing every request. The auth
+// provider config is kept in the client config and the client authenticates with the registered plugin.
+func validateAuthProvider(config *rest.Config) error {
+	if config.AuthProvider == nil {
 		return nil
 	}
 
-	managedCluster.Status = *newStatus
-	if err := client.Status().Update(context.TODO(), managedCluster); err != nil {
-		return err
+	if _, err := rest.GetAuthProvider(config.Host, config.AuthProvider, nil); err != nil {
+		return &UnsupportedAuthProviderError{Name: config.AuthProvider.Name, Err: err}
 	}
-
-	recorder.Eventf("ManagedClusterStatusUpdated",
-		"Update the ManagedClusterImportSucceeded status of managed cluster %s to %s", managedClusterName, cond.Status)
-
 	return nil
 }
 
-// ValidateImportSecret validate managed cluster import secret
-func ValidateImportSecret(importSecret *corev1.Secret) error {
-	if data, ok := importSecret.Data[constants.ImportSecretCRDSYamlKey]; !ok || len(data) == 0 {
-		return fmt.Errorf("the %s is required", constants.ImportSecretCRDSYamlKey)
+// buildBootstrapServiceAccountClientConfig requests a token of the bootstrap service account with the admin client
+// config, and returns a client config that uses the token to access the same server
+func buildBootstrapServiceAccountClientConfig(adminConfig *rest.Config, bootstrapSA string) (*rest.Config, error) {
+	namespace, name, err := parseBootstrapServiceAccount(bootstrapSA)
+	if err != nil {
+		return nil, err
 	}
 
-	if data, ok := importSecret.Data[constants.ImportSecretCRDSV1beta1YamlKey]; !ok || len(data) == 0 {
-		return fmt.Errorf("the %s is required", constants.ImportSecretCRDSV1beta1YamlKey)
+	kubeClient, err := kubernetes.NewForConfig(adminConfig)
+	if err != nil {
+		return nil, err
 	}
 
-	if data, ok := importSecret.Data[constants.ImportSecretCRDSV1YamlKey]; !ok || len(data) == 0 {
-		return fmt.Errorf("the %s is required", constants.ImportSecretCRDSV1YamlKey)
+	token, err := RequestServiceAccountToken(context.TODO(), kubeClient, namespace, name)
+	if err != nil {
+		return nil, err
 	}
 
-	if data, ok := importSecret.Data[constants.ImportSecretImportYamlKey]; !ok || len(data) == 0 {
-		return fmt.Errorf("the %s is required", constants.ImportSecretImportYamlKey)
+	// only the server and the TLS settings of the admin client config are kept
+	clientConfig := rest.AnonymousClientConfig(adminConfig)
+	clientConfig.BearerToken = token
+	return clientConfig, nil
+}
+
+// parseBootstrapServiceAccount parses the namespace and name from the bootstrap service account of a secret, the
+// namespace is kube-system if it is not specified
+func parseBootstrapServiceAccount(bootstrapSA string) (string, string, error) {
+	namespace, name := metav1.NamespaceSystem, strings.TrimSpace(bootstrapSA)
+	if parts := strings.Split(name, "/"); len(parts) == 2 {
+		namespace, name = parts[0], parts[1]
 	}
-	return nil
+
+	if len(validation.IsDNS1123Subdomain(name)) != 0 || len(validation.IsDNS1123Label(namespace)) != 0 {
+		return "", "", fmt.Errorf("%w: invalid bootstrap service account %q", ErrSecretMissingCredentials, bootstrapSA)
+	}
+	return namespace, name, nil
 }
 
-// ValidateHostedImportSecret validate hosted mode managed cluster import secret
-func ValidateHostedImportSecret(importSecret *corev1.Secret) error {
-	if data, ok := importSecret.Data[constants.ImportSecretImportYamlKey]; !ok || len(data) == 0 {
-		return fmt.Errorf("the %s is required", constants.ImportSecretImportYamlKey)
+// RequestServiceAccountToken requests a token of the service account with the TokenRequest API
+func RequestServiceAccountToken(ctx context.Context, kubeClient kubernetes.Interface,
+	namespace, name string) (string, error) {
+	expirationSeconds := bootstrapServiceAccountTokenExpirationSeconds
+	tokenRequest, err := kubeClient.CoreV1().ServiceAccounts(namespace).CreateToken(ctx, name,
+		&authenticationv1.TokenRequest{
+			Spec: authenticationv1.TokenRequestSpec{
+				ExpirationSeconds: &expirationSeconds,
+			},
+		}, metav1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to request a token of the service account %s/%s: %w", namespace, name, err)
 	}
-	return nil
+
+	if len(tokenRequest.Status.Token) == 0 {
+		return "", fmt.Errorf("the token of the service account %s/%s is empty", namespace, name)
+	}
+	return tokenRequest.Status.Token, nil
 }
 
-// ImportManagedClusterFromSecret use managed cluster client to import managed cluster from import-secret
-func ImportManagedClusterFromSecret(client *ClientHolder, restMapper meta.RESTMapper, recorder events.Recorder,
-	importSecret *corev1.Secret) error {
-	if err := ValidateImportSecret(importSecret); err != nil {
-		return err
+// validateTokenFile validates the token file can be used, reading a file of the controller is sensitive, so the
+// token file is allowed only if it is in one of the directories of the ALLOWED_TOKEN_FILE_DIRS env, and it is never
+// allowed to be the service account token of this controller
+func validateTokenFile(tokenFile string) error {
+	cleanedTokenFile := filepath.Clean(tokenFile)
+	if !filepath.IsAbs(cleanedTokenFile) {
+		return fmt.Errorf("%w: the tokenFile %s is not an absolute path", ErrSecretMissingCredentials, tokenFile)
 	}
 
-	crdsKey := constants.ImportSecretCRDSV1YamlKey
-	if _, err := restMapper.RESTMapping(crdGroupKind, "v1"); err != nil {
-		klog.Infof("crd v1 is not supported, deploy v1beta1")
-		crdsKey = constants.ImportSecretCRDSV1beta1YamlKey
+	if isInDir(cleanedTokenFile, inClusterServiceAccountDir) {
+		return fmt.Errorf("%w: the tokenFile %s is not allowed", ErrSecretMissingCredentials, tokenFile)
 	}
 
-	objs := []runtime.Object{}
-	objs = append(objs, MustCreateObject(importSecret.Data[crdsKey]))
-	for _, yaml := range SplitYamls(importSecret.Data[constants.ImportSecretImportYamlKey]) {
-		objs = append(objs, MustCreateObject(yaml))
+	allowed := false
+	for _, dir := range strings.Split(os.Getenv(allowedTokenFileDirsEnvVarName), ",") {
+		dir = strings.TrimSpace(dir)
+		if len(dir) == 0 || !filepath.IsAbs(dir) {
+			continue
+		}
+		if isInDir(cleanedTokenFile, filepath.Clean(dir)) {
+			allowed = true
+			break
+		}
 	}
-	// using managed cluster client to apply resources in managed cluster, so the owner is not need
-	return ApplyResources(client, recorder, nil, nil, objs...)
+	if !allowed {
+		return fmt.Errorf("%w: the tokenFile %s is not allowed, add its directory to %s env to allow it",
+			ErrSecretMissingCredentials, tokenFile, allowedTokenFileDirsEnvVarName)
+	}
+
+	token, err := ioutil.ReadFile(cleanedTokenFile)
+	if err != nil {
+		return fmt.Errorf("%w: failed to read the tokenFile %s: %v", ErrSecretMissingCredentials, tokenFile, err)
+	}
+	if len(strings.TrimSpace(string(token))) == 0 {
+		return fmt.Errorf("%w: the tokenFile %s is empty", ErrSecretMissingCredentials, tokenFile)
+	}
+	return nil
 }
 
-// SplitYamls split yamls with sperator `---`
-func SplitYamls(yamls []byte) [][]byte {
-	bYamls := [][]byte{}
-	// remove the head sperator
-	sYamls := strings.Replace(string(yamls), constants.YamlSperator, "", 1)
-	for _, yaml := range strings.Split(sYamls, constants.YamlSperator) {
-		bYamls = append(bYamls, []byte(yaml))
+// isInDir returns true if the cleaned absolute path is in the cleaned absolute directory or its sub-directories
+func isInDir(path, dir string) bool {
+	if dir == string(filepath.Separator) {
+		return true
 	}
-	return bYamls
+	return strings.HasPrefix(path, dir+string(filepath.Separator))
 }
 
-// IsAPIExtensionV1Supported if the cluster can support the crdv1, return true
-func IsAPIExtensionV1Supported(kubeVersion string) bool {
-	isV1, err := v1APIExtensionMinVersion.Compare(kubeVersion)
+// ValidateServerURL validates the server url resolved from the client config uses https, the plain http is allowed
+// only if the ALLOW_HTTP_SERVER env is true
+func ValidateServerURL(clientConfig *rest.Config) error {
+	// a host without scheme is resolved to https if the tls is configured, this is consistent with the rest client
+	defaultTLS := len(clientConfig.CAFile) != 0 || len(clientConfig.CAData) != 0 ||
+		len(clientConfig.CertFile) != 0 || len(clientConfig.CertData) != 0 || clientConfig.Insecure
+	serverURL, _, err := rest.DefaultServerURL(clientConfig.Host, "", schema.GroupVersion{}, defaultTLS)
 	if err != nil {
-		klog.Errorf("a bad kube version: %v", kubeVersion)
-		return false
+		return fmt.Errorf("invalid server %q: %v", clientConfig.Host, err)
 	}
-	return isV1 == -1
-}
 
-// MustCreateObjectFromTemplate render a template to a runtime object with its configuration
-// If it's failed, this function will panic
-func MustCreateObjectFromTemplate(file string, template []byte, config interface{}) runtime.Object {
-	raw := MustCreateAssetFromTemplate(file, template, config)
-	return MustCreateObject(raw)
+	switch serverURL.Scheme {
+	case "https":
+		return nil
+	case "http":
+		if allowHTTP, _ := strconv.ParseBool(os.Getenv(allowHTTPServerEnvVarName)); allowHTTP {
+			return nil
+		}
+		return fmt.Errorf("%w: the server %s uses http, set %s env to true to allow it",
+			ErrInsecureServer, serverURL.String(), allowHTTPServerEnvVarName)
+	default:
+		return fmt.Errorf("%w: the server %s uses unsupported scheme %q",
+			ErrInsecureServer, serverURL.String(), serverURL.Scheme)
+	}
 }
 
-// MustCreateAssetFromTemplate render a template with its configuration
-// If it's failed, this function will panic
-func MustCreateAssetFromTemplate(name string, tb []byte, config interface{}) []byte {
-	tmpl, err := template.New(name).Parse(string(tb))
-	if err != nil {
-		panic(err)
+// AddManagedClusterFinalizer add a finalizer to a managed cluster
+func AddManagedClusterFinalizer(modified *bool, managedCluster *clusterv1.ManagedCluster, finalizer string) {
+	for i := range managedCluster.Finalizers {
+		if managedCluster.Finalizers[i] == finalizer {
+			return
+		}
 	}
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, config); err != nil {
-		panic(err)
+
+	managedCluster.Finalizers = append(managedCluster.Finalizers, finalizer)
+	*modified = true
+}
+
+// EnsureManagedClusterFinalizer adds a finalizer to a managed cluster and persists it, it returns true if the managed
+// cluster is updated
+func EnsureManagedClusterFinalizer(ctx context.Context, runtimeClient client.Client, recorder events.Recorder,
+	managedCluster *clusterv1.ManagedCluster, finalizer string) (bool, error) {
+	patch := client.MergeFrom(managedCluster.DeepCopy())
+	modified := resourcemerge.BoolPtr(false)
+	AddManagedClusterFinalizer(modified, managedCluster, finalizer)
+	if !*modified {
+		return false, nil
+	}
+
+	if err := runtimeClient.Patch(ctx, managedCluster, patch); err != nil {
+		return false, err
+	}
+
+	recorder.Eventf("ManagedClusterMetaObjModified",
+		"The managed cluster %s meta data is modified: finalizer %s is added", managedCluster.Name, finalizer)
+	return true, nil
+}
+
+// RemoveManagedClusterFinalizer remove a finalizer from a managed cluster
+func RemoveManagedClusterFinalizer(ctx context.Context, runtimeClient client.Client, recorder events.Recorder,
+	managedCluster *clusterv1.ManagedCluster, finalizer string) error {
+	return RemoveManagedClusterFinalizers(ctx, runtimeClient, recorder, managedCluster, finalizer)
+}
+
+// RemoveManagedClusterFinalizers remove the finalizers from a managed cluster in one update
+func RemoveManagedClusterFinalizers(ctx context.Context, runtimeClient client.Client, recorder events.Recorder,
+	managedCluster *clusterv1.ManagedCluster, finalizers ...string) error {
+	toRemove := sets.NewString(finalizers...)
+	copiedFinalizers := []string{}
+	removedFinalizers := []string{}
+	for i := range managedCluster.Finalizers {
+		if toRemove.Has(managedCluster.Finalizers[i]) {
+			removedFinalizers = append(removedFinalizers, managedCluster.Finalizers[i])
+			continue
+		}
+		copiedFinalizers = append(copiedFinalizers, managedCluster.Finalizers[i])
+	}
+
+	if len(removedFinalizers) == 0 {
+		return nil
+	}
+
+	patch := client.MergeFrom(managedCluster.DeepCopy())
+	managedCluster.Finalizers = copiedFinalizers
+	if err := runtimeClient.Patch(ctx, managedCluster, patch); err != nil {
+		return err
+	}
+
+	recorder.Eventf("ManagedClusterFinalizerRemoved",
+		"The managed cluster %s finalizer %s is removed", managedCluster.Name, strings.Join(removedFinalizers, ","))
+	return nil
+}
+
+// ListImportedManagedClusters lists the managed clusters that are imported by this controller, a managed cluster is
+// imported if it has the manifest work finalizer or its ManagedClusterImportSucceeded condition is true, the managed
+// clusters that are created and managed by other tools are not returned
+func ListImportedManagedClusters(ctx context.Context, runtimeClient client.Client) ([]clusterv1.ManagedCluster, error) {
+	managedClusters := &clusterv1.ManagedClusterList{}
+	if err := runtimeClient.List(ctx, managedClusters); err != nil {
+		return nil, err
+	}
+
+	imported := []clusterv1.ManagedCluster{}
+	for _, cluster := range managedClusters.Items {
+		if sets.NewString(cluster.Finalizers...).Has(constants.ManifestWorkFinalizer) ||
+			meta.IsStatusConditionTrue(cluster.Status.Conditions, constants.ImportSucceededConditionType) {
+			imported = append(imported, cluster)
+		}
+	}
+	return imported, nil
+}
+
+// UpdateManagedClusterStatus update managed cluster status, the observed generation of the condition is set to the
+// current generation of the managed cluster
+func UpdateManagedClusterStatus(client client.Client, recorder events.Recorder,
+	managedClusterName string, cond metav1.Condition) error {
+	return UpdateManagedClusterStatusConditions(client, recorder, managedClusterName, cond)
+}
+
+// UpdateManagedClusterStatusConditions update the managed cluster status with the conditions in one status update,
+// the observed generation of the conditions is set to the current generation of the managed cluster. If the update
+// is conflicted, the managed cluster will be re-fetched and the conditions will be re-applied. If the managed cluster
+// is imported by the update, the failure conditions of the prior import are pruned, see PruneImportConditions.
+func UpdateManagedClusterStatusConditions(client client.Client, recorder events.Recorder,
+	managedClusterName string, conds ...metav1.Condition) error {
+	var updatedConds []metav1.Condition
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		updatedConds = []metav1.Condition{}
+
+		managedCluster := &clusterv1.ManagedCluster{}
+		err := client.Get(context.TODO(), types.NamespacedName{Name: managedClusterName}, managedCluster)
+		if err != nil {
+			return err
+		}
+
+		oldStatus := &managedCluster.Status
+		newStatus := oldStatus.DeepCopy()
+
+		for _, cond := range conds {
+			cond.ObservedGeneration = managedCluster.Generation
+			meta.SetStatusCondition(&newStatus.Conditions, cond)
+		}
+		if !meta.IsStatusConditionTrue(oldStatus.Conditions, constants.ImportSucceededConditionType) {
+			// the managed cluster is imported in this update, the failure conditions of the prior import are
+			// removed in the same update
+			imported := &clusterv1.ManagedCluster{Status: *newStatus}
+			PruneImportConditions(imported)
+			newStatus.Conditions = imported.Status.Conditions
+		}
+		if equality.Semantic.DeepEqual(managedCluster.Status.Conditions, newStatus.Conditions) {
+			return nil
+		}
+
+		for _, cond := range conds {
+			if !equality.Semantic.DeepEqual(meta.FindStatusCondition(oldStatus.Conditions, cond.Type),
+				meta.FindStatusCondition(newStatus.Conditions, cond.Type)) {
+				updatedConds = append(updatedConds, cond)
+			}
+		}
+
+		managedCluster.Status = *newStatus
+		return client.Status().Update(context.TODO(), managedCluster)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, cond := range updatedConds {
+		recorder.Eventf("ManagedClusterStatusUpdated",
+			"Update the %s status of managed cluster %s to %s", cond.Type, managedClusterName, cond.Status)
+	}
+
+	return nil
+}
+
+// importFailureConditionTypes are the import related conditions that record a failure of a prior import, they are
+// no longer relevant once the managed cluster is imported again
+var importFailureConditionTypes = []string{
+	constants.PostImportHookSucceededConditionType,
+}
+
+// PruneImportConditions removes the failure conditions of the prior import from the managed cluster if the managed
+// cluster is imported, e.g. the failed post-import hook condition is removed, so the hook is reported by the current
+// import only. It returns true if any condition is removed.
+func PruneImportConditions(cluster *clusterv1.ManagedCluster) bool {
+	if !meta.IsStatusConditionTrue(cluster.Status.Conditions, constants.ImportSucceededConditionType) {
+		return false
+	}
+
+	pruned := false
+	for _, conditionType := range importFailureConditionTypes {
+		if !meta.IsStatusConditionFalse(cluster.Status.Conditions, conditionType) {
+			continue
+		}
+		meta.RemoveStatusCondition(&cluster.Status.Conditions, conditionType)
+		pruned = true
+	}
+	return pruned
+}
+
+// ValidateImportSecret validate managed cluster import secret
+func ValidateImportSecret(importSecret *corev1.Secret) error {
+	if data, ok := importSecret.Data[constants.ImportSecretCRDSYamlKey]; !ok || len(data) == 0 {
+		return fmt.Errorf("%w: the %s is required", ErrInvalidImportSecret, constants.ImportSecretCRDSYamlKey)
+	}
+
+	if data, ok := importSecret.Data[constants.ImportSecretCRDSV1beta1YamlKey]; !ok || len(data) == 0 {
+		return fmt.Errorf("%w: the %s is required", ErrInvalidImportSecret, constants.ImportSecretCRDSV1beta1YamlKey)
+	}
+
+	if data, ok := importSecret.Data[constants.ImportSecretCRDSV1YamlKey]; !ok || len(data) == 0 {
+		return fmt.Errorf("%w: the %s is required", ErrInvalidImportSecret, constants.ImportSecretCRDSV1YamlKey)
+	}
+
+	if data, ok := importSecret.Data[constants.ImportSecretImportYamlKey]; !ok || len(data) == 0 {
+		return fmt.Errorf("%w: the %s is required", ErrInvalidImportSecret, constants.ImportSecretImportYamlKey)
+	}
+	return nil
+}
+
+// ValidateClusterNamespace checks that the managed cluster namespace exists and is not terminating, the error wraps
+// the ErrNamespaceNotFound if the namespace does not exist, and wraps the ErrNamespaceTerminating if the namespace is
+// terminating.
+func ValidateClusterNamespace(ctx context.Context, kubeClient kubernetes.Interface, namespace string) error {
+	ns, err := kubeClient.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return fmt.Errorf("%w: %s", ErrNamespaceNotFound, namespace)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !ns.DeletionTimestamp.IsZero() || ns.Status.Phase == corev1.NamespaceTerminating {
+		return fmt.Errorf("%w: %s", ErrNamespaceTerminating, namespace)
+	}
+	return nil
+}
+
+// ValidateHostedImportSecret validate hosted mode managed cluster import secret
+func ValidateHostedImportSecret(importSecret *corev1.Secret) error {
+	if data, ok := importSecret.Data[constants.ImportSecretImportYamlKey]; !ok || len(data) == 0 {
+		return fmt.Errorf("%w: the %s is required", ErrInvalidImportSecret, constants.ImportSecretImportYamlKey)
+	}
+	return nil
+}
+
+// ValidateBootstrapHubServer validates the server of the bootstrap hub kubeconfig in the import.yaml of the import
+// secret is the hub server, the servers are compared after the scheme, the host and the default port are normalized.
+// If they do not match, an error that wraps the ErrHubServerMismatch is returned.
+func ValidateBootstrapHubServer(importSecret *corev1.Secret, hubServer string) error {
+	bootstrapServer, err := GetBootstrapHubServer(importSecret)
+	if err != nil {
+		return err
+	}
+
+	normalizedBootstrapServer, err := normalizeServerURL(bootstrapServer)
+	if err != nil {
+		return fmt.Errorf("%w: invalid bootstrap hub server %q: %v", ErrInvalidImportSecret, bootstrapServer, err)
+	}
+	normalizedHubServer, err := normalizeServerURL(hubServer)
+	if err != nil {
+		return fmt.Errorf("invalid hub server %q: %v", hubServer, err)
+	}
+
+	if normalizedBootstrapServer != normalizedHubServer {
+		return fmt.Errorf("%w: the bootstrap hub server of the import secret %s/%s is %s, but the hub server is %s",
+			ErrHubServerMismatch, importSecret.Namespace, importSecret.Name, bootstrapServer, hubServer)
+	}
+	return nil
+}
+
+// GetBootstrapHubServer returns the server of the current context of the bootstrap hub kubeconfig in the import.yaml
+// of the import secret
+func GetBootstrapHubServer(importSecret *corev1.Secret) (string, error) {
+	bootstrapSecret, err := getBootstrapHubKubeconfigSecret(importSecret)
+	if err != nil {
+		return "", err
+	}
+
+	server, err := kubeconfigServer(bootstrapSecret.Data["kubeconfig"])
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid bootstrap hub kubeconfig: %v", ErrInvalidImportSecret, err)
+	}
+	return server, nil
+}
+
+// getBootstrapHubKubeconfigSecret returns the bootstrap hub kubeconfig secret in the import.yaml of the import secret
+func getBootstrapHubKubeconfigSecret(importSecret *corev1.Secret) (*corev1.Secret, error) {
+	for _, yaml := range SplitYamls(importSecret.Data[constants.ImportSecretImportYamlKey]) {
+		obj, _, err := genericCodec.Decode(yaml, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImportSecret, err)
+		}
+
+		if secret, ok := obj.(*corev1.Secret); ok && secret.Name == bootstrapHubKubeconfigSecretName {
+			return secret, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: the %s secret is not found in the %s",
+		ErrInvalidImportSecret, bootstrapHubKubeconfigSecretName, constants.ImportSecretImportYamlKey)
+}
+
+// kubeconfigServer returns the server of the current context of the kubeconfig
+func kubeconfigServer(kubeconfig []byte) (string, error) {
+	config, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return "", err
+	}
+	currentContext, ok := config.Contexts[config.CurrentContext]
+	if !ok {
+		return "", fmt.Errorf("the current context of the kubeconfig is not found")
+	}
+	cluster, ok := config.Clusters[currentContext.Cluster]
+	if !ok || len(cluster.Server) == 0 {
+		return "", fmt.Errorf("the server of the kubeconfig is not found")
+	}
+	return cluster.Server, nil
+}
+
+// ValidateNotManagedByAnotherHub checks the managed cluster is not managed by another hub before importing it, the
+// server of the existing bootstrap hub kubeconfig secret on the managed cluster is compared with the server of the
+// bootstrap hub kubeconfig in the import secret. If the managed cluster does not have the secret or the secret cannot
+// be parsed, the cluster is treated as not managed. If the servers do not match, an error that wraps the
+// ErrManagedByAnotherHub is returned.
+func ValidateNotManagedByAnotherHub(ctx context.Context, kubeClient kubernetes.Interface,
+	importSecret *corev1.Secret) error {
+	bootstrapSecret, err := getBootstrapHubKubeconfigSecret(importSecret)
+	if err != nil {
+		return err
+	}
+	hubServer, err := GetBootstrapHubServer(importSecret)
+	if err != nil {
+		return err
+	}
+
+	existing, err := kubeClient.CoreV1().Secrets(bootstrapSecret.Namespace).Get(ctx, bootstrapSecret.Name,
+		metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	existingServer, err := kubeconfigServer(existing.Data["kubeconfig"])
+	if err != nil {
+		klog.Warningf("The existing bootstrap hub kubeconfig secret %s/%s is invalid, %v",
+			existing.Namespace, existing.Name, err)
+		return nil
+	}
+
+	normalizedExistingServer, err := normalizeServerURL(existingServer)
+	if err != nil {
+		klog.Warningf("The server %s of the existing bootstrap hub kubeconfig secret %s/%s is invalid, %v",
+			existingServer, existing.Namespace, existing.Name, err)
+		return nil
+	}
+	normalizedHubServer, err := normalizeServerURL(hubServer)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidImportSecret, err)
+	}
+
+	if normalizedExistingServer != normalizedHubServer {
+		return fmt.Errorf("%w: the bootstrap hub kubeconfig secret %s/%s on the managed cluster points at %s",
+			ErrManagedByAnotherHub, existing.Namespace, existing.Name, existingServer)
+	}
+	return nil
+}
+
+// GetKubeAPIServerAddress returns the external URL of the hub kube-apiserver from the ocp infrastructure, it is the
+// server of the bootstrap hub kubeconfig in the import secrets
+func GetKubeAPIServerAddress(ctx context.Context, runtimeClient client.Client) (string, error) {
+	infraConfig := &ocinfrav1.Infrastructure{}
+	if err := runtimeClient.Get(ctx, types.NamespacedName{Name: "cluster"}, infraConfig); err != nil {
+		return "", err
+	}
+
+	return infraConfig.Status.APIServerURL, nil
+}
+
+// ImportScope represents which subset of the import secret is applied on the managed cluster
+type ImportScope string
+
+const (
+	// ImportScopeAll applies both the klusterlet crds and the klusterlet agent manifests
+	ImportScopeAll ImportScope = "All"
+	// ImportScopeCRDsOnly only applies the klusterlet crds, e.g. to upgrade the crds before the agent
+	ImportScopeCRDsOnly ImportScope = "CRDsOnly"
+	// ImportScopeAgentOnly only applies the klusterlet agent manifests in the import.yaml
+	ImportScopeAgentOnly ImportScope = "AgentOnly"
+	// ImportScopeAdopt only updates the bootstrap hub kubeconfig secret of the existing klusterlet on the managed
+	// cluster, if there is no existing klusterlet of the same cluster name, it is same as the ImportScopeAll
+	ImportScopeAdopt ImportScope = "Adopt"
+)
+
+// bootstrapHubKubeconfigSecretName is the name of the bootstrap hub kubeconfig secret in the import.yaml
+const bootstrapHubKubeconfigSecretName = "bootstrap-hub-kubeconfig"
+
+// GetImportScope returns the import scope of the managed cluster, the ImportScopeAdopt is returned if the managed
+// cluster has the AdoptKlusterletAnnotation with true, otherwise the ImportScopeAll is returned
+func GetImportScope(cluster *clusterv1.ManagedCluster) ImportScope {
+	if adopt, _ := strconv.ParseBool(cluster.Annotations[constants.AdoptKlusterletAnnotation]); adopt {
+		return ImportScopeAdopt
+	}
+	return ImportScopeAll
+}
+
+// ImportManagedClusterFromSecret use managed cluster client to import managed cluster from import-secret
+func ImportManagedClusterFromSecret(client *ClientHolder, restMapper meta.RESTMapper, recorder events.Recorder,
+	importSecret *corev1.Secret) error {
+	return ImportManagedClusterFromSecretWithScope(client, restMapper, recorder, importSecret, ImportScopeAll)
+}
+
+// ImportManagedClusterFromSecretWithScope use managed cluster client to apply the subset of the import-secret that
+// is specified by the scope on the managed cluster
+func ImportManagedClusterFromSecretWithScope(client *ClientHolder, restMapper meta.RESTMapper,
+	recorder events.Recorder, importSecret *corev1.Secret, scope ImportScope) error {
+	_, err := ImportManagedClusterFromSecretWithResult(client, restMapper, recorder, importSecret, scope)
+	return err
+}
+
+// ImportResult is the result of importing a managed cluster with the import secret
+type ImportResult struct {
+	// SkippedCRDs are the names of the klusterlet crds that are not applied, because the managed cluster already
+	// has the newer versions of them
+	SkippedCRDs []string
+}
+
+// ImportManagedClusterFromSecretWithResult use managed cluster client to apply the subset of the import-secret that
+// is specified by the scope on the managed cluster, and returns the result of the import. If the managed cluster
+// already has a newer version of a klusterlet crd, the crd is skipped to avoid downgrading it.
+//
+// The requests of the managed cluster clients that are generated by GenerateClientFromSecret are bounded by the timeout
+// from GetImportTimeout, so a slow managed cluster does not block the reconcile.
+func ImportManagedClusterFromSecretWithResult(client *ClientHolder, restMapper meta.RESTMapper,
+	recorder events.Recorder, importSecret *corev1.Secret, scope ImportScope) (*ImportResult, error) {
+	if err := ValidateImportSecret(importSecret); err != nil {
+		return nil, err
+	}
+
+	var applyCRDs, applyAgent bool
+	switch scope {
+	case ImportScopeAll:
+		applyCRDs, applyAgent = true, true
+	case ImportScopeCRDsOnly:
+		applyCRDs = true
+	case ImportScopeAgentOnly:
+		applyAgent = true
+	case ImportScopeAdopt:
+		adopted, err := adoptExistingKlusterlet(client, recorder, importSecret)
+		if err != nil {
+			return nil, err
+		}
+		if adopted {
+			return &ImportResult{}, nil
+		}
+		applyCRDs, applyAgent = true, true
+	default:
+		return nil, fmt.Errorf("unsupported import scope %q", scope)
+	}
+
+	result := &ImportResult{}
+	objs := []runtime.Object{}
+	if applyCRDs {
+		crdsKey := constants.ImportSecretCRDSV1YamlKey
+		if _, err := restMapper.RESTMapping(CRDGroupKind, "v1"); err != nil {
+			klog.Infof("crd v1 is not supported, deploy v1beta1")
+			crdsKey = constants.ImportSecretCRDSV1beta1YamlKey
+		}
+
+		crd := MustCreateObject(importSecret.Data[crdsKey])
+		name, newer, err := newerCRDExists(client.APIExtensionsClient, crd)
+		if err != nil {
+			return nil, err
+		}
+		if newer {
+			result.SkippedCRDs = append(result.SkippedCRDs, name)
+			recorder.Eventf("KlusterletCRDSkipped",
+				"The crd %s is not applied, the managed cluster already has a newer version of it", name)
+		} else {
+			objs = append(objs, crd)
+		}
+	}
+	if applyAgent {
+		for _, yaml := range SplitYamls(importSecret.Data[constants.ImportSecretImportYamlKey]) {
+			objs = append(objs, MustCreateObject(yaml))
+		}
+	}
+	// using managed cluster client to apply resources in managed cluster, so the owner is not need
+	if err := ApplyResources(client, recorder, nil, nil, objs...); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// newerCRDExists returns the name of the required crd and whether the managed cluster already has a newer version
+// of it, a crd is newer if its latest version is newer than the latest version of the required crd, e.g. v1 is
+// newer than v1beta1. If the required object is not a crd or the crd does not exist, false is returned.
+// Note: only the version names are compared, the schema changes in the same version name are not detected, so a crd
+// with the same latest version name is always applied.
+func newerCRDExists(apiExtensionsClient apiextensionsclient.Interface, required runtime.Object) (string, bool, error) {
+	var name string
+	var requiredVersions, existingVersions []string
+	switch crd := required.(type) {
+	case *crdv1.CustomResourceDefinition:
+		name = crd.Name
+		requiredVersions = crdV1Versions(crd)
+		existing, err := apiExtensionsClient.ApiextensionsV1().CustomResourceDefinitions().Get(
+			context.TODO(), name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return name, false, nil
+		}
+		if err != nil {
+			return name, false, err
+		}
+		existingVersions = crdV1Versions(existing)
+	case *crdv1beta1.CustomResourceDefinition:
+		name = crd.Name
+		requiredVersions = crdV1beta1Versions(crd)
+		existing, err := apiExtensionsClient.ApiextensionsV1beta1().CustomResourceDefinitions().Get(
+			context.TODO(), name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return name, false, nil
+		}
+		if err != nil {
+			return name, false, err
+		}
+		existingVersions = crdV1beta1Versions(existing)
+	default:
+		return "", false, nil
+	}
+
+	requiredLatest := latestCRDVersion(requiredVersions)
+	if len(requiredLatest) == 0 {
+		return name, false, nil
+	}
+	return name, apimachineryversion.CompareKubeAwareVersionStrings(
+		latestCRDVersion(existingVersions), requiredLatest) > 0, nil
+}
+
+func crdV1Versions(crd *crdv1.CustomResourceDefinition) []string {
+	versions := []string{}
+	for _, v := range crd.Spec.Versions {
+		versions = append(versions, v.Name)
+	}
+	return versions
+}
+
+func crdV1beta1Versions(crd *crdv1beta1.CustomResourceDefinition) []string {
+	versions := []string{}
+	if len(crd.Spec.Version) != 0 {
+		versions = append(versions, crd.Spec.Version)
+	}
+	for _, v := range crd.Spec.Versions {
+		versions = append(versions, v.Name)
+	}
+	return versions
+}
+
+// latestCRDVersion returns the latest version of the kube-like versions, e.g. v1 is later than v1beta2
+func latestCRDVersion(versions []string) string {
+	latest := ""
+	for _, v := range versions {
+		if len(latest) == 0 || apimachineryversion.CompareKubeAwareVersionStrings(v, latest) > 0 {
+			latest = v
+		}
+	}
+	return latest
+}
+
+// KlusterletCRDsSkippedCondition returns the condition that shows whether the klusterlet crds are skipped in the
+// import of the managed cluster, because the managed cluster already has the newer versions of them
+func KlusterletCRDsSkippedCondition(result *ImportResult) metav1.Condition {
+	if result == nil || len(result.SkippedCRDs) == 0 {
+		return metav1.Condition{
+			Type:    constants.KlusterletCRDsSkippedConditionType,
+			Status:  metav1.ConditionFalse,
+			Reason:  "KlusterletCRDsApplied",
+			Message: "The klusterlet crds are applied",
+		}
+	}
+
+	return metav1.Condition{
+		Type:   constants.KlusterletCRDsSkippedConditionType,
+		Status: metav1.ConditionTrue,
+		Reason: "NewerKlusterletCRDsExist",
+		Message: fmt.Sprintf("The klusterlet crds %s are not applied, the managed cluster already has the newer "+
+			"versions of them. Note: only the names of the crd versions are compared, the crds with a newer version "+
+			"name are kept even if their schemas are older", strings.Join(result.SkippedCRDs, ", ")),
+	}
+}
+
+// adoptExistingKlusterlet updates the bootstrap hub kubeconfig secret of the existing klusterlet on the managed cluster
+// with the one in the import.yaml, so the existing klusterlet is bootstrapped to this hub without re-applying the
+// whole import.yaml. It returns false if there is no existing klusterlet or the existing klusterlet is registered
+// with another cluster name.
+func adoptExistingKlusterlet(client *ClientHolder, recorder events.Recorder, importSecret *corev1.Secret) (bool, error) {
+	var required *operatorv1.Klusterlet
+	var bootstrapSecret *corev1.Secret
+	for _, yaml := range SplitYamls(importSecret.Data[constants.ImportSecretImportYamlKey]) {
+		switch obj := MustCreateObject(yaml).(type) {
+		case *operatorv1.Klusterlet:
+			required = obj
+		case *corev1.Secret:
+			if obj.Name == bootstrapHubKubeconfigSecretName {
+				bootstrapSecret = obj
+			}
+		}
+	}
+	if required == nil || bootstrapSecret == nil {
+		return false, fmt.Errorf("%w: the klusterlet or the %s secret is not found in the %s",
+			ErrInvalidImportSecret, bootstrapHubKubeconfigSecretName, constants.ImportSecretImportYamlKey)
+	}
+
+	existing, err := client.OperatorClient.OperatorV1().Klusterlets().Get(context.TODO(), required.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if existing.Spec.ClusterName != required.Spec.ClusterName {
+		// the existing klusterlet is registered with another cluster name, it has to be re-applied
+		return false, nil
+	}
+
+	// the bootstrap hub kubeconfig secret is in the agent namespace of the existing klusterlet
+	if len(existing.Spec.Namespace) != 0 {
+		bootstrapSecret.Namespace = existing.Spec.Namespace
+	}
+	if err := ApplyResources(client, recorder, nil, nil, bootstrapSecret); err != nil {
+		return false, err
+	}
+
+	recorder.Eventf("KlusterletAdopted",
+		"The existing klusterlet %s is adopted, its bootstrap hub kubeconfig secret %s/%s is updated",
+		existing.Name, bootstrapSecret.Namespace, bootstrapSecret.Name)
+	return true, nil
+}
+
+// SplitYamls split yamls with sperator `---`
+func SplitYamls(yamls []byte) [][]byte {
+	bYamls := [][]byte{}
+	// remove the head sperator
+	sYamls := strings.Replace(string(yamls), constants.YamlSperator, "", 1)
+	for _, yaml := range strings.Split(sYamls, constants.YamlSperator) {
+		bYamls = append(bYamls, []byte(yaml))
+	}
+	return bYamls
+}
+
+// IsAPIExtensionV1Supported if the cluster can support the crdv1, return true.
+//
+// The kube version is parsed leniently, the leading "v", the surrounding spaces and the common suffixes, e.g.
+// the build metadata "+abc" or the pre-release and vendor suffix "-eks", are ignored, so "v1.22.0-eks" is treated as
+// 1.22.0. If the kube version cannot be parsed, false is returned.
+func IsAPIExtensionV1Supported(kubeVersion string) bool {
+	kubeVersion = strings.TrimSpace(kubeVersion)
+	if kubeVersion == "" {
+		klog.Errorf("the kube version is empty")
+		return false
+	}
+
+	cmp, err := v1APIExtensionMinVersion.Compare(kubeVersion)
+	if err != nil {
+		klog.Errorf("a bad kube version: %v", kubeVersion)
+		return false
+	}
+	// the min version is less than or equal to the kube version
+	return cmp <= 0
+}
+
+// MustCreateObjectFromTemplate render a template to a runtime object with its configuration
+// If it's failed, this function will panic
+func MustCreateObjectFromTemplate(file string, template []byte, config interface{}) runtime.Object {
+	raw := MustCreateAssetFromTemplate(file, template, config)
+	return MustCreateObject(raw)
+}
+
+// MustCreateAssetFromTemplate render a template with its configuration
+// If it's failed, this function will panic
+func MustCreateAssetFromTemplate(name string, tb []byte, config interface{}) []byte {
+	asset, err := CreateAssetFromTemplate(name, tb, config)
+	if err != nil {
+		panic(err)
+	}
+	return asset
+}
+
+// CreateAssetFromTemplate render a template with its configuration, if the template cannot be parsed or
+// executed, return an error
+func CreateAssetFromTemplate(name string, tb []byte, config interface{}) ([]byte, error) {
+	tmpl, err := template.New(name).Parse(string(tb))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the template %s: %v", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, config); err != nil {
+		return nil, fmt.Errorf("failed to execute the template %s: %v", name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ManifestsEqual if two manifests are equal, return true
+func ManifestsEqual(newManifests, oldManifests []workv1.Manifest) bool {
+	if len(newManifests) != len(oldManifests) {
+		return false
+	}
+
+	for i := range newManifests {
+		if !equality.Semantic.DeepEqual(newManifests[i].Raw, oldManifests[i].Raw) {
+			return false
+		}
+	}
+	return true
+}
+
+// ApplyOptions are the options to apply resources
+type ApplyOptions struct {
+	// ServerSideApply uses the server-side apply with the field manager of this controller to apply the objects
+	// that are managed by the runtime client, e.g. manifestwork. By default, the objects are created or updated
+	// with client-side merge.
+	ServerSideApply bool
+
+	// SkipOwnerReferenceGroupKinds are the group kinds of the objects that the owner reference is not set on,
+	// e.g. the CRDs are shared by all of the managed clusters, they should not be garbage collected when one
+	// managed cluster is removed.
+	SkipOwnerReferenceGroupKinds []schema.GroupKind
+}
+
+func (o ApplyOptions) skipOwnerReference(obj runtime.Object, scheme *runtime.Scheme) bool {
+	if len(o.SkipOwnerReferenceGroupKinds) == 0 {
+		return false
+	}
+
+	gvk, err := apiutil.GVKForObject(obj, scheme)
+	if err != nil {
+		return false
+	}
+
+	for _, groupKind := range o.SkipOwnerReferenceGroupKinds {
+		if gvk.GroupKind() == groupKind {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyAction is the action that is taken when an object is applied
+type ApplyAction string
+
+const (
+	ApplyActionCreated   ApplyAction = "created"
+	ApplyActionUpdated   ApplyAction = "updated"
+	ApplyActionUnchanged ApplyAction = "unchanged"
+)
+
+// ApplyResult is the result of applying an object
+type ApplyResult struct {
+	Object runtime.Object
+	Action ApplyAction
+}
+
+// ApplyResources apply resources, includes: namespace, serviceaccount, secret, configmap, deployment, clusterrole,
+// clusterrolebinding, role, rolebinding, crdv1beta1, crdv1, manifestwork and klusterlet
+func ApplyResources(clientHolder *ClientHolder, recorder events.Recorder,
+	scheme *runtime.Scheme, owner metav1.Object, objs ...runtime.Object) error {
+	_, err := ApplyResourcesWithOptions(clientHolder, recorder, scheme, owner, ApplyOptions{}, objs...)
+	return err
+}
+
+// ApplyResourcesWithOptions apply resources with the apply options, see ApplyResources for the supported resources.
+// It returns the results of the objects that are applied successfully, each result tells whether the object is
+// created, updated or unchanged.
+func ApplyResourcesWithOptions(clientHolder *ClientHolder, recorder events.Recorder,
+	scheme *runtime.Scheme, owner metav1.Object, options ApplyOptions, objs ...runtime.Object) ([]ApplyResult, error) {
+	results := []ApplyResult{}
+	errs := []error{}
+	for _, obj := range objs {
+		if owner != nil && !options.skipOwnerReference(obj, scheme) {
+			required, ok := obj.(metav1.Object)
+			if !ok {
+				errs = append(errs, fmt.Errorf("%T is not a metav1.Object, cannot call SetControllerReference", obj))
+				continue
+			}
+
+			if err := controllerutil.SetControllerReference(owner, required, scheme); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+
+		action, err := applyResource(clientHolder, recorder, options, obj)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if len(action) == 0 {
+			// the object is not supported
+			continue
+		}
+
+		results = append(results, ApplyResult{Object: obj, Action: action})
+	}
+
+	return results, utilerrors.NewAggregate(errs)
+}
+
+func applyResource(clientHolder *ClientHolder, recorder events.Recorder,
+	options ApplyOptions, obj runtime.Object) (ApplyAction, error) {
+	ctx := context.TODO()
+	kubeClient := clientHolder.KubeClient
+
+	switch required := obj.(type) {
+	case *corev1.ServiceAccount:
+		return applyKubeResource(
+			func() error {
+				_, err := kubeClient.CoreV1().ServiceAccounts(required.Namespace).Get(ctx, required.Name, metav1.GetOptions{})
+				return err
+			},
+			func() (bool, error) {
+				_, modified, err := resourceapply.ApplyServiceAccount(kubeClient.CoreV1(), recorder, required)
+				return modified, err
+			},
+		)
+	case *corev1.Secret:
+		return applyKubeResource(
+			func() error {
+				_, err := kubeClient.CoreV1().Secrets(required.Namespace).Get(ctx, required.Name, metav1.GetOptions{})
+				return err
+			},
+			func() (bool, error) {
+				_, modified, err := resourceapply.ApplySecret(kubeClient.CoreV1(), recorder, required)
+				return modified, err
+			},
+		)
+	case *corev1.ConfigMap:
+		return applyKubeResource(
+			func() error {
+				_, err := kubeClient.CoreV1().ConfigMaps(required.Namespace).Get(ctx, required.Name, metav1.GetOptions{})
+				return err
+			},
+			func() (bool, error) {
+				_, modified, err := resourceapply.ApplyConfigMap(kubeClient.CoreV1(), recorder, required)
+				return modified, err
+			},
+		)
+	case *corev1.Namespace:
+		return applyKubeResource(
+			func() error {
+				_, err := kubeClient.CoreV1().Namespaces().Get(ctx, required.Name, metav1.GetOptions{})
+				return err
+			},
+			func() (bool, error) {
+				_, modified, err := resourceapply.ApplyNamespace(kubeClient.CoreV1(), recorder, required)
+				return modified, err
+			},
+		)
+	case *appsv1.Deployment:
+		return applyDeployment(clientHolder, recorder, required)
+	case *rbacv1.ClusterRole:
+		return applyKubeResource(
+			func() error {
+				_, err := kubeClient.RbacV1().ClusterRoles().Get(ctx, required.Name, metav1.GetOptions{})
+				return err
+			},
+			func() (bool, error) {
+				_, modified, err := resourceapply.ApplyClusterRole(kubeClient.RbacV1(), recorder, required)
+				return modified, err
+			},
+		)
+	case *rbacv1.ClusterRoleBinding:
+		return applyKubeResource(
+			func() error {
+				_, err := kubeClient.RbacV1().ClusterRoleBindings().Get(ctx, required.Name, metav1.GetOptions{})
+				return err
+			},
+			func() (bool, error) {
+				_, modified, err := resourceapply.ApplyClusterRoleBinding(kubeClient.RbacV1(), recorder, required)
+				return modified, err
+			},
+		)
+	case *rbacv1.Role:
+		return applyKubeResource(
+			func() error {
+				_, err := kubeClient.RbacV1().Roles(required.Namespace).Get(ctx, required.Name, metav1.GetOptions{})
+				return err
+			},
+			func() (bool, error) {
+				_, modified, err := resourceapply.ApplyRole(kubeClient.RbacV1(), recorder, required)
+				return modified, err
+			},
+		)
+	case *rbacv1.RoleBinding:
+		return applyKubeResource(
+			func() error {
+				_, err := kubeClient.RbacV1().RoleBindings(required.Namespace).Get(ctx, required.Name, metav1.GetOptions{})
+				return err
+			},
+			func() (bool, error) {
+				_, modified, err := resourceapply.ApplyRoleBinding(kubeClient.RbacV1(), recorder, required)
+				return modified, err
+			},
+		)
+	case *crdv1beta1.CustomResourceDefinition:
+		crdClient := clientHolder.APIExtensionsClient.ApiextensionsV1beta1()
+		return applyKubeResource(
+			func() error {
+				_, err := crdClient.CustomResourceDefinitions().Get(ctx, required.Name, metav1.GetOptions{})
+				return err
+			},
+			func() (bool, error) {
+				_, modified, err := resourceapply.ApplyCustomResourceDefinitionV1Beta1(crdClient, recorder, required)
+				return modified, err
+			},
+		)
+	case *crdv1.CustomResourceDefinition:
+		crdClient := clientHolder.APIExtensionsClient.ApiextensionsV1()
+		return applyKubeResource(
+			func() error {
+				_, err := crdClient.CustomResourceDefinitions().Get(ctx, required.Name, metav1.GetOptions{})
+				return err
+			},
+			func() (bool, error) {
+				_, modified, err := resourceapply.ApplyCustomResourceDefinitionV1(crdClient, recorder, required)
+				return modified, err
+			},
+		)
+	case *workv1.ManifestWork:
+		unchanged, err := manifestWorkUnchanged(clientHolder.RuntimeClient, required)
+		if err != nil {
+			return "", err
+		}
+		if unchanged {
+			return ApplyActionUnchanged, nil
+		}
+		if options.ServerSideApply {
+			return serverSideApplyManifestWork(clientHolder.RuntimeClient, recorder, required)
+		}
+		return applyManifestWork(clientHolder.RuntimeClient, recorder, required)
+	case *operatorv1.Klusterlet:
+		return applyKlusterlet(clientHolder.OperatorClient, recorder, required)
 	}
-	return buf.Bytes()
+
+	return "", nil
 }
 
-// ManifestsEqual if two manifests are equal, return true
-func ManifestsEqual(newManifests, oldManifests []workv1.Manifest) bool {
-	if len(newManifests) != len(oldManifests) {
-		return false
+// applyKubeResource applies an object with a resourceapply function, the resourceapply functions only report
+// whether the object is modified, so the object is got before it is applied to know whether it is created
+func applyKubeResource(get func() error, apply func() (bool, error)) (ApplyAction, error) {
+	err := get()
+	if err != nil && !errors.IsNotFound(err) {
+		return "", err
 	}
+	created := errors.IsNotFound(err)
 
-	for i := range newManifests {
-		if !equality.Semantic.DeepEqual(newManifests[i].Raw, oldManifests[i].Raw) {
-			return false
-		}
+	modified, err := apply()
+	if err != nil {
+		return "", err
 	}
-	return true
+	return applyActionOf(created, modified), nil
 }
 
-// ApplyResources apply resources, includes: serviceaccount, secret, deployment, clusterrole, clusterrolebinding,
-// crdv1beta1, crdv1, manifestwork and klusterlet
-func ApplyResources(clientHolder *ClientHolder, recorder events.Recorder,
-	scheme *runtime.Scheme, owner metav1.Object, objs ...runtime.Object) error {
-	errs := []error{}
-	for _, obj := range objs {
-		if owner != nil {
-			required, ok := obj.(metav1.Object)
-			if !ok {
-				errs = append(errs, fmt.Errorf("%T is not a metav1.Object, cannot call SetControllerReference", obj))
-				continue
-			}
-
-			if err := controllerutil.SetControllerReference(owner, required, scheme); err != nil {
-				errs = append(errs, err)
-				continue
-			}
-		}
-
-		switch required := obj.(type) {
-		case *corev1.ServiceAccount:
-			_, _, err := resourceapply.ApplyServiceAccount(clientHolder.KubeClient.CoreV1(), recorder, required)
-			errs = append(errs, err)
-		case *corev1.Secret:
-			_, _, err := resourceapply.ApplySecret(clientHolder.KubeClient.CoreV1(), recorder, required)
-			errs = append(errs, err)
-		case *corev1.Namespace:
-			_, _, err := resourceapply.ApplyNamespace(clientHolder.KubeClient.CoreV1(), recorder, required)
-			errs = append(errs, err)
-		case *appsv1.Deployment:
-			errs = append(errs, applyDeployment(clientHolder, recorder, required))
-		case *rbacv1.ClusterRole:
-			_, _, err := resourceapply.ApplyClusterRole(clientHolder.KubeClient.RbacV1(), recorder, required)
-			errs = append(errs, err)
-		case *rbacv1.ClusterRoleBinding:
-			_, _, err := resourceapply.ApplyClusterRoleBinding(clientHolder.KubeClient.RbacV1(), recorder, required)
-			errs = append(errs, err)
-		case *crdv1beta1.CustomResourceDefinition:
-			_, _, err := resourceapply.ApplyCustomResourceDefinitionV1Beta1(
-				clientHolder.APIExtensionsClient.ApiextensionsV1beta1(),
-				recorder,
-				required,
-			)
-			errs = append(errs, err)
-		case *crdv1.CustomResourceDefinition:
-			_, _, err := resourceapply.ApplyCustomResourceDefinitionV1(
-				clientHolder.APIExtensionsClient.ApiextensionsV1(),
-				recorder,
-				required,
-			)
-			errs = append(errs, err)
-		case *workv1.ManifestWork:
-			errs = append(errs, applyManifestWork(clientHolder.RuntimeClient, recorder, required))
-		case *operatorv1.Klusterlet:
-			errs = append(errs, applyKlusterlet(clientHolder.OperatorClient, recorder, required))
-		}
+func applyActionOf(created, modified bool) ApplyAction {
+	switch {
+	case !modified:
+		return ApplyActionUnchanged
+	case created:
+		return ApplyActionCreated
+	default:
+		return ApplyActionUpdated
 	}
-
-	return utilerrors.NewAggregate(errs)
 }
 
-func applyDeployment(clientHolder *ClientHolder, recorder events.Recorder, required *appsv1.Deployment) error {
-	key := types.NamespacedName{Namespace: required.Namespace, Name: required.Name}
-	existing := &appsv1.Deployment{}
-	err := clientHolder.RuntimeClient.Get(context.TODO(), key, existing)
+func applyDeployment(clientHolder *ClientHolder, recorder events.Recorder,
+	required *appsv1.Deployment) (ApplyAction, error) {
+	existing, err := clientHolder.KubeClient.AppsV1().Deployments(required.Namespace).Get(context.TODO(),
+		required.Name, metav1.GetOptions{})
 	if errors.IsNotFound(err) {
-		_, _, err := resourceapply.ApplyDeployment(clientHolder.KubeClient.AppsV1(), recorder, required, -1)
-		return err
+		_, modified, err := resourceapply.ApplyDeployment(clientHolder.KubeClient.AppsV1(), recorder, required, -1)
+		if err != nil {
+			return "", err
+		}
+		return applyActionOf(true, modified), nil
 	}
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	_, _, err = resourceapply.ApplyDeployment(clientHolder.KubeClient.AppsV1(), recorder, required, existing.Generation)
-	return err
+	_, modified, err := resourceapply.ApplyDeployment(clientHolder.KubeClient.AppsV1(), recorder, required, existing.Generation)
+	if err != nil {
+		return "", err
+	}
+	return applyActionOf(false, modified), nil
 }
 
-func applyKlusterlet(client operatorclient.Interface, recorder events.Recorder, required *operatorv1.Klusterlet) error {
+func applyKlusterlet(client operatorclient.Interface, recorder events.Recorder,
+	required *operatorv1.Klusterlet) (ApplyAction, error) {
 	existing, err := client.OperatorV1().Klusterlets().Get(context.TODO(), required.Name, metav1.GetOptions{})
 	if errors.IsNotFound(err) {
 		if _, err := client.OperatorV1().Klusterlets().Create(context.TODO(), required, metav1.CreateOptions{}); err != nil {
-			return err
+			return "", err
 		}
 
 		reportEvent(recorder, required, "Klusterlet", "created")
-		return nil
+		return ApplyActionCreated, nil
 	}
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	if equality.Semantic.DeepEqual(existing.Spec, required.Spec) {
-		return nil
+		return ApplyActionUnchanged, nil
 	}
 
 	existing = existing.DeepCopy()
 	existing.Spec = required.Spec
 	if _, err := client.OperatorV1().Klusterlets().Update(context.TODO(), existing, metav1.UpdateOptions{}); err != nil {
-		return err
+		return "", err
 	}
 	reportEvent(recorder, required, "Klusterlet", "updated")
-	return nil
+	return ApplyActionUpdated, nil
+}
+
+// manifestWorkUnchanged records the hash of the required spec on the required manifest work, and returns true if
+// the existing manifest work has the same spec hash, spec and metadata, so the manifest work is not written again
+// and its generation is not bumped. If the spec cannot be hashed, the manifest work is compared by the apply functions.
+func manifestWorkUnchanged(runtimeClient client.Client, required *workv1.ManifestWork) (bool, error) {
+	specHash, err := manifestWorkSpecHash(required.Spec)
+	if err != nil {
+		klog.Warningf("Failed to hash the spec of the manifest work %s/%s: %v", required.Namespace, required.Name, err)
+		return false, nil
+	}
+	if required.Annotations == nil {
+		required.Annotations = map[string]string{}
+	}
+	required.Annotations[constants.ManifestWorkSpecHashAnnotation] = specHash
+
+	existing := &workv1.ManifestWork{}
+	err = runtimeClient.Get(context.TODO(),
+		types.NamespacedName{Namespace: required.Namespace, Name: required.Name}, existing)
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if existing.Annotations[constants.ManifestWorkSpecHashAnnotation] != specHash {
+		return false, nil
+	}
+
+	// the spec of the existing manifest work may be modified after it is applied, e.g. the manifests are edited
+	// manually, the modified manifest work is applied again to revert the modification
+	if !ManifestsEqual(existing.Spec.Workload.Manifests, required.Spec.Workload.Manifests) ||
+		!equality.Semantic.DeepEqual(existing.Spec.DeleteOption, required.Spec.DeleteOption) ||
+		!equality.Semantic.DeepEqual(existing.Spec.ManifestConfigs, required.Spec.ManifestConfigs) {
+		return false, nil
+	}
+
+	modified := resourcemerge.BoolPtr(false)
+	resourcemerge.EnsureObjectMeta(modified, existing.ObjectMeta.DeepCopy(), required.ObjectMeta)
+	if *modified {
+		return false, nil
+	}
+
+	// the owner references are not merged by the EnsureObjectMeta
+	for _, ownerRef := range required.OwnerReferences {
+		found := false
+		for _, existingOwnerRef := range existing.OwnerReferences {
+			if equality.Semantic.DeepEqual(ownerRef, existingOwnerRef) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// manifestWorkSpecHash returns the hash of the manifest work spec
+func manifestWorkSpecHash(spec workv1.ManifestWorkSpec) (string, error) {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), nil
 }
 
-func applyManifestWork(client client.Client, recorder events.Recorder, required *workv1.ManifestWork) error {
+func applyManifestWork(client client.Client, recorder events.Recorder, required *workv1.ManifestWork) (ApplyAction, error) {
 	existing := &workv1.ManifestWork{}
 	err := client.Get(context.TODO(), types.NamespacedName{Namespace: required.Namespace, Name: required.Name}, existing)
 	if errors.IsNotFound(err) {
 		if err := client.Create(context.TODO(), required); err != nil {
-			return err
+			return "", err
 		}
 
 		reportEvent(recorder, required, "ManifestWork", "created")
-		return nil
+		return ApplyActionCreated, nil
 	}
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	modified := resourcemerge.BoolPtr(false)
@@ -475,17 +1788,53 @@ func applyManifestWork(client client.Client, recorder events.Recorder, required
 	if !ManifestsEqual(existing.Spec.Workload.Manifests, required.Spec.Workload.Manifests) {
 		*modified = true
 	}
+	if !equality.Semantic.DeepEqual(existing.Spec.DeleteOption, required.Spec.DeleteOption) {
+		*modified = true
+	}
 
 	if !*modified {
-		return nil
+		return ApplyActionUnchanged, nil
 	}
 
 	existing.Spec = required.Spec
 	if err := client.Update(context.TODO(), existing); err != nil {
-		return err
+		return "", err
 	}
 	reportEvent(recorder, required, "ManifestWork", "updated")
-	return nil
+	return ApplyActionUpdated, nil
+}
+
+func serverSideApplyManifestWork(runtimeClient client.Client, recorder events.Recorder,
+	required *workv1.ManifestWork) (ApplyAction, error) {
+	existing := &workv1.ManifestWork{}
+	err := runtimeClient.Get(context.TODO(),
+		types.NamespacedName{Namespace: required.Namespace, Name: required.Name}, existing)
+	if err != nil && !errors.IsNotFound(err) {
+		return "", err
+	}
+	created := errors.IsNotFound(err)
+
+	// the server-side apply requires the type meta and does not allow the managed fields
+	applied := required.DeepCopy()
+	applied.SetGroupVersionKind(workv1.GroupVersion.WithKind("ManifestWork"))
+	applied.ManagedFields = nil
+	applied.ResourceVersion = ""
+
+	if err := runtimeClient.Patch(context.TODO(), applied, client.Apply,
+		client.FieldOwner(GetComponentName()), client.ForceOwnership); err != nil {
+		return "", err
+	}
+
+	switch {
+	case created:
+		reportEvent(recorder, required, "ManifestWork", "created")
+		return ApplyActionCreated, nil
+	case applied.ResourceVersion == existing.ResourceVersion:
+		return ApplyActionUnchanged, nil
+	default:
+		reportEvent(recorder, required, "ManifestWork", "applied")
+		return ApplyActionUpdated, nil
+	}
 }
 
 // MustCreateObject translate object from raw bytes to runtime object
@@ -519,7 +1868,26 @@ func NewEventRecorder(kubeClient kubernetes.Interface, controllerName string) ev
 	}
 
 	options := events.RecommendedClusterSingletonCorrelatorOptions()
-	return events.NewKubeRecorderWithOptions(kubeClient.CoreV1().Events(namespace), options, controllerName, controllerRef)
+	return events.NewKubeRecorderWithOptions(
+		kubeClient.CoreV1().Events(namespace), options, eventSourceName(controllerName), controllerRef)
+}
+
+// GetComponentName returns the name of this controller instance from the EVENT_SOURCE_COMPONENT_NAME env, if the env
+// is not set, the managedcluster-import-controller is returned
+func GetComponentName() string {
+	if componentName := os.Getenv(componentNameEnvVarName); len(componentName) > 0 {
+		return componentName
+	}
+	return defaultComponentName
+}
+
+// eventSourceName returns the event source of the controller, the controller name is prefixed with the component
+// name only when the component name is customized
+func eventSourceName(controllerName string) string {
+	if componentName := os.Getenv(componentNameEnvVarName); len(componentName) > 0 {
+		return fmt.Sprintf("%s-%s", componentName, controllerName)
+	}
+	return controllerName
 }
 
 func GetComponentNamespace() (string, error) {
@@ -546,13 +1914,148 @@ func GetNodeSelector(cluster *clusterv1.ManagedCluster) (map[string]string, erro
 		return nil, fmt.Errorf("invalid nodeSelector annotation of cluster %s, %v", cluster.Name, err)
 	}
 
-	if err := validateNodeSelector(nodeSelector); err != nil {
+	if err := validateLabels(nodeSelector); err != nil {
 		return nil, fmt.Errorf("invalid nodeSelector annotation of cluster %s, %v", cluster.Name, err)
 	}
 
 	return nodeSelector, nil
 }
 
+// wellKnownNodeLabelValues are the well-known node labels that have a known set of values
+var wellKnownNodeLabelValues = map[string]sets.String{
+	"kubernetes.io/os":        sets.NewString("linux", "windows"),
+	"beta.kubernetes.io/os":   sets.NewString("linux", "windows"),
+	"kubernetes.io/arch":      sets.NewString("amd64", "arm64", "arm", "ppc64le", "s390x"),
+	"beta.kubernetes.io/arch": sets.NewString("amd64", "arm64", "arm", "ppc64le", "s390x"),
+}
+
+// wellKnownNodeLabels are the well-known node labels in the reserved domains that have arbitrary values
+var wellKnownNodeLabels = sets.NewString(
+	"kubernetes.io/hostname",
+	"topology.kubernetes.io/region",
+	"topology.kubernetes.io/zone",
+	"node.kubernetes.io/instance-type",
+	"beta.kubernetes.io/instance-type",
+	"failure-domain.beta.kubernetes.io/region",
+	"failure-domain.beta.kubernetes.io/zone",
+)
+
+// wellKnownNodeLabelPrefixes are the prefixes of the well-known node labels in the reserved domains, e.g. the node
+// role labels
+var wellKnownNodeLabelPrefixes = []string{"node-role.kubernetes.io/"}
+
+// ValidateNodeSelectorWellKnownLabels validates the well-known node labels of the nodeSelector, e.g. the value of
+// kubernetes.io/os must be linux or windows, and the keys in the reserved kubernetes.io and k8s.io domains must be
+// well-known, because an unknown one is likely a typo. The nodeSelector is still valid, so an error that wraps the
+// ErrNodeSelectorMayNotMatch is returned for the caller to surface as a warning without blocking the import.
+func ValidateNodeSelectorWellKnownLabels(nodeSelector map[string]string) error {
+	warnings := []string{}
+	for _, key := range sets.StringKeySet(nodeSelector).List() {
+		value := nodeSelector[key]
+		if values, ok := wellKnownNodeLabelValues[key]; ok {
+			if !values.Has(value) {
+				warnings = append(warnings, fmt.Sprintf("the value %q of %s is not one of %v", value, key, values.List()))
+			}
+			continue
+		}
+
+		if !isReservedLabelKey(key) || wellKnownNodeLabels.Has(key) || hasWellKnownNodeLabelPrefix(key) {
+			continue
+		}
+		warnings = append(warnings, fmt.Sprintf("%s is not a well-known node label", key))
+	}
+
+	if len(warnings) != 0 {
+		return fmt.Errorf("%w: %s", ErrNodeSelectorMayNotMatch, strings.Join(warnings, "; "))
+	}
+	return nil
+}
+
+// isReservedLabelKey returns true if the prefix of the label key is in the kubernetes.io or k8s.io domain
+func isReservedLabelKey(key string) bool {
+	parts := strings.SplitN(key, "/", 2)
+	if len(parts) != 2 {
+		return false
+	}
+
+	for _, domain := range []string{"kubernetes.io", "k8s.io"} {
+		if parts[0] == domain || strings.HasSuffix(parts[0], "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasWellKnownNodeLabelPrefix(key string) bool {
+	for _, prefix := range wellKnownNodeLabelPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPriorityClassName returns the name of the PriorityClass of the klusterlet deployment from the priority class
+// annotation of the managed cluster, an empty name is returned if the annotation is not set. The name must be a
+// DNS-1123 subdomain.
+func GetPriorityClassName(cluster *clusterv1.ManagedCluster) (string, error) {
+	priorityClassName, ok := cluster.Annotations[priorityClassAnnotation]
+	if !ok {
+		return "", nil
+	}
+
+	if errMsgs := validation.IsDNS1123Subdomain(priorityClassName); len(errMsgs) != 0 {
+		return "", fmt.Errorf("invalid priority class annotation of cluster %s, %s",
+			cluster.Name, strings.Join(errMsgs, ";"))
+	}
+
+	return priorityClassName, nil
+}
+
+// GetNodeSelectorWithDefaults returns the nodeSelector of the managed cluster merged with the default nodeSelector,
+// the values from the nodeSelector annotation of the managed cluster win on conflict.
+//
+// The default nodeSelector can be customized with the DEFAULT_NODE_SELECTOR env in JSON format, if the env is not
+// set, kubernetes.io/os: linux is used.
+func GetNodeSelectorWithDefaults(cluster *clusterv1.ManagedCluster) (map[string]string, error) {
+	nodeSelector, err := GetNodeSelector(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range getDefaultNodeSelector() {
+		if _, ok := nodeSelector[key]; !ok {
+			nodeSelector[key] = value
+		}
+	}
+
+	return nodeSelector, nil
+}
+
+func getDefaultNodeSelector() map[string]string {
+	defaultNodeSelectorString, ok := os.LookupEnv(defaultNodeSelectorEnvVarName)
+	if !ok {
+		return map[string]string{"kubernetes.io/os": "linux"}
+	}
+
+	defaultNodeSelector := map[string]string{}
+	if len(defaultNodeSelectorString) == 0 {
+		return defaultNodeSelector
+	}
+
+	if err := json.Unmarshal([]byte(defaultNodeSelectorString), &defaultNodeSelector); err != nil {
+		klog.Warningf("The value of %s env is wrong, ignore it: %v", defaultNodeSelectorEnvVarName, err)
+		return map[string]string{}
+	}
+
+	if err := validateLabels(defaultNodeSelector); err != nil {
+		klog.Warningf("The value of %s env is wrong, ignore it: %v", defaultNodeSelectorEnvVarName, err)
+		return map[string]string{}
+	}
+
+	return defaultNodeSelector
+}
+
 func GetTolerations(cluster *clusterv1.ManagedCluster) ([]corev1.Toleration, error) {
 	tolerations := []corev1.Toleration{}
 
@@ -572,6 +2075,10 @@ func GetTolerations(cluster *clusterv1.ManagedCluster) ([]corev1.Toleration, err
 		return nil, fmt.Errorf("invalid tolerations annotation of cluster %s, %v", cluster.Name, err)
 	}
 
+	if len(tolerations) > GetMaxTolerations() {
+		return nil, fmt.Errorf("too many tolerations in annotation of cluster %s", cluster.Name)
+	}
+
 	if err := validateTolerations(tolerations); err != nil {
 		return nil, fmt.Errorf("invalid tolerations annotation of cluster %s, %v", cluster.Name, err)
 	}
@@ -579,6 +2086,168 @@ func GetTolerations(cluster *clusterv1.ManagedCluster) ([]corev1.Toleration, err
 	return tolerations, nil
 }
 
+// GetTolerationsWithDefaults returns the tolerations of the managed cluster merged with the default tolerations and
+// the default NoExecute tolerations, the identical tolerations are de-duplicated.
+//
+// The default tolerations can be customized with the DEFAULT_TOLERATIONS env in JSON format, if the env is not set,
+// no default tolerations are merged.
+func GetTolerationsWithDefaults(cluster *clusterv1.ManagedCluster) ([]corev1.Toleration, error) {
+	tolerations, err := GetTolerations(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	tolerations = mergeTolerations(tolerations, getDefaultTolerations())
+
+	// the NoExecute tolerations of the managed cluster take precedence over the default NoExecute tolerations
+	for _, toleration := range GetDefaultNoExecuteTolerations(GetNoExecuteTolerationSeconds()) {
+		if hasTolerationFor(tolerations, toleration.Key, toleration.Effect) {
+			continue
+		}
+		tolerations = append(tolerations, toleration)
+	}
+
+	return tolerations, nil
+}
+
+// GetDefaultNoExecuteTolerations returns the tolerations of the unreachable and not-ready NoExecute taints with the
+// toleration seconds, so the klusterlet agents will not be evicted immediately when their nodes are unhealthy.
+func GetDefaultNoExecuteTolerations(tolerationSeconds int64) []corev1.Toleration {
+	tolerations := []corev1.Toleration{}
+	for _, key := range []string{corev1.TaintNodeUnreachable, corev1.TaintNodeNotReady} {
+		seconds := tolerationSeconds
+		tolerations = append(tolerations, corev1.Toleration{
+			Key:               key,
+			Operator:          corev1.TolerationOpExists,
+			Effect:            corev1.TaintEffectNoExecute,
+			TolerationSeconds: &seconds,
+		})
+	}
+	return tolerations
+}
+
+func getDefaultTolerations() []corev1.Toleration {
+	defaultTolerations := []corev1.Toleration{}
+	if os.Getenv(defaultTolerationsEnvVarName) == "" {
+		return defaultTolerations
+	}
+
+	if err := json.Unmarshal([]byte(os.Getenv(defaultTolerationsEnvVarName)), &defaultTolerations); err != nil {
+		klog.Warningf("The value of %s env is wrong, ignore it: %v", defaultTolerationsEnvVarName, err)
+		return []corev1.Toleration{}
+	}
+
+	if err := validateTolerations(defaultTolerations); err != nil {
+		klog.Warningf("The value of %s env is wrong, ignore it: %v", defaultTolerationsEnvVarName, err)
+		return []corev1.Toleration{}
+	}
+
+	return defaultTolerations
+}
+
+func mergeTolerations(tolerations, additional []corev1.Toleration) []corev1.Toleration {
+	merged := []corev1.Toleration{}
+	for _, toleration := range append(tolerations, additional...) {
+		if containsToleration(merged, toleration) {
+			continue
+		}
+		merged = append(merged, toleration)
+	}
+	return merged
+}
+
+func hasTolerationFor(tolerations []corev1.Toleration, key string, effect corev1.TaintEffect) bool {
+	for _, t := range tolerations {
+		if t.Key == key && t.Effect == effect {
+			return true
+		}
+	}
+	return false
+}
+
+func containsToleration(tolerations []corev1.Toleration, toleration corev1.Toleration) bool {
+	for _, t := range tolerations {
+		if equality.Semantic.DeepEqual(t, toleration) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetBootstrapCABundle returns the custom CA bundle of the bootstrap hub kubeconfig from the managed cluster
+// annotation, the CA bundle must be valid PEM certificates. If the annotation is not set, return nil.
+func GetBootstrapCABundle(cluster *clusterv1.ManagedCluster) ([]byte, error) {
+	caBundleString, ok := cluster.Annotations[constants.BootstrapCABundleAnnotation]
+	if !ok {
+		return nil, nil
+	}
+
+	caBundle, err := base64.StdEncoding.DecodeString(caBundleString)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bootstrap CA bundle annotation of cluster %s, %v", cluster.Name, err)
+	}
+
+	if _, err := certutil.ParseCertsPEM(caBundle); err != nil {
+		return nil, fmt.Errorf("invalid bootstrap CA bundle annotation of cluster %s, %v", cluster.Name, err)
+	}
+
+	return caBundle, nil
+}
+
+// imageReferenceRegexp matches the image references, e.g. registry.example.com:5000/ocm/registration-operator:v1,
+// it follows the grammar of the docker distribution references
+var imageReferenceRegexp = regexp.MustCompile(
+	`^(?:(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])(?:\.(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9]))*` +
+		`(?::[0-9]+)?/)?` +
+		`[a-z0-9]+(?:(?:[._]|__|-*)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-*)[a-z0-9]+)*)*` +
+		`(?::[\w][\w.-]{0,127})?` +
+		`(?:@[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9A-Fa-f]{32,})?$`)
+
+// GetKlusterletImageOverride returns the image that is pinned for the klusterlet of the managed cluster by the
+// klusterlet image annotation, if the annotation is not set or is empty, an empty string is returned. An error is
+// returned if the value of the annotation is not a valid image reference.
+func GetKlusterletImageOverride(cluster *clusterv1.ManagedCluster) (string, error) {
+	image := strings.TrimSpace(cluster.Annotations[constants.KlusterletImageOverrideAnnotation])
+	if len(image) == 0 {
+		return "", nil
+	}
+
+	if !imageReferenceRegexp.MatchString(image) {
+		return "", fmt.Errorf("invalid klusterlet image annotation of cluster %s, %q is not a valid image reference",
+			cluster.Name, image)
+	}
+
+	return image, nil
+}
+
+// GetKlusterletLabels returns the labels of the Klusterlet CR from the managed cluster annotations that have the
+// KlusterletLabelAnnotationPrefix, if a label key or value is invalid, return an error
+func GetKlusterletLabels(cluster *clusterv1.ManagedCluster) (map[string]string, error) {
+	labels := map[string]string{}
+	for key, val := range cluster.Annotations {
+		if !strings.HasPrefix(key, constants.KlusterletLabelAnnotationPrefix) {
+			continue
+		}
+		labels[strings.TrimPrefix(key, constants.KlusterletLabelAnnotationPrefix)] = val
+	}
+
+	if err := validateLabels(labels); err != nil {
+		return nil, fmt.Errorf("invalid klusterlet label annotations of cluster %s, %v", cluster.Name, err)
+	}
+
+	return labels, nil
+}
+
+// GetKlusterletNamespace gets the namespace to deploy the agent on the managed cluster, if the klusterlet namespace
+// annotation is not set, the default klusterlet namespace is returned.
+func GetKlusterletNamespace(cluster *clusterv1.ManagedCluster) string {
+	if klusterletNamespace, ok := cluster.Annotations[constants.KlusterletNamespaceAnnotation]; ok {
+		return klusterletNamespace
+	}
+
+	return constants.DefaultKlusterletNamespace
+}
+
 // DetermineKlusterletMode gets the klusterlet deploy mode for the managed cluster.
 func DetermineKlusterletMode(cluster *clusterv1.ManagedCluster) string {
 	mode, ok := cluster.Annotations[constants.KlusterletDeployModeAnnotation]
@@ -597,13 +2266,56 @@ func DetermineKlusterletMode(cluster *clusterv1.ManagedCluster) string {
 	return "Unknown"
 }
 
-// GetHostingCluster gets the hosting cluster name from the managed cluster annotation
-func GetHostingCluster(cluster *clusterv1.ManagedCluster) (string, error) {
-	if managementCluster, ok := cluster.Annotations[constants.HostingClusterNameAnnotation]; ok {
-		return managementCluster, nil
+// InvalidHostingClusterError is returned when the hosting cluster of a hosted mode managed cluster cannot be resolved
+// from the hosting cluster name annotation
+type InvalidHostingClusterError struct {
+	ClusterName string
+	Reason      string
+}
+
+func (e *InvalidHostingClusterError) Error() string {
+	return fmt.Sprintf("invalid hosting cluster of the managed cluster %s: %s", e.ClusterName, e.Reason)
+}
+
+// Unwrap returns the ErrInvalidHostingCluster, so the error can be checked with errors.Is
+func (e *InvalidHostingClusterError) Unwrap() error {
+	return ErrInvalidHostingCluster
+}
+
+// IsInvalidHostingCluster returns true if the error is or wraps an InvalidHostingClusterError, it also matches
+// the error that is wrapped by an aggregate
+func IsInvalidHostingCluster(err error) bool {
+	return goerrors.Is(err, ErrInvalidHostingCluster)
+}
+
+// GetHostingClusterName gets the hosting cluster name from the managed cluster annotation, the hosting cluster name
+// must be a valid cluster name, because the hosted mode manifest works are created in the namespace of the hosting
+// cluster. An InvalidHostingClusterError is returned if the annotation is missing or invalid.
+func GetHostingClusterName(cluster *clusterv1.ManagedCluster) (string, error) {
+	hostingClusterName, ok := cluster.Annotations[constants.HostingClusterNameAnnotation]
+	if !ok {
+		return "", &InvalidHostingClusterError{
+			ClusterName: cluster.Name,
+			Reason:      fmt.Sprintf("annotation %s not found", constants.HostingClusterNameAnnotation),
+		}
+	}
+
+	if len(hostingClusterName) == 0 {
+		return "", &InvalidHostingClusterError{
+			ClusterName: cluster.Name,
+			Reason:      fmt.Sprintf("annotation %s is empty", constants.HostingClusterNameAnnotation),
+		}
+	}
+
+	if errMsgs := validation.IsDNS1123Label(hostingClusterName); len(errMsgs) != 0 {
+		return "", &InvalidHostingClusterError{
+			ClusterName: cluster.Name,
+			Reason: fmt.Sprintf("annotation %s has an invalid cluster name %q: %s",
+				constants.HostingClusterNameAnnotation, hostingClusterName, strings.Join(errMsgs, ";")),
+		}
 	}
 
-	return "", fmt.Errorf("annotation %s not found", constants.HostingClusterNameAnnotation)
+	return hostingClusterName, nil
 }
 
 // ForceDeleteManagedClusterAddon will delete the managedClusterAddon regardless of finalizers.
@@ -637,6 +2349,7 @@ func ForceDeleteManagedClusterAddon(
 			}
 			return err
 		}
+		reportDeletion(recorder, "ManagedClusterAddOn", addon.Namespace, addon.Name)
 	}
 
 	recorder.Eventf("ManagedClusterAddonForceDeleted",
@@ -663,9 +2376,9 @@ func ForceDeleteAllManagedClusterAddons(
 }
 
 // refer to https://github.com/kubernetes/kubernetes/blob/master/pkg/apis/core/validation/validation.go#L3498
-func validateNodeSelector(nodeSelector map[string]string) error {
+func validateLabels(labels map[string]string) error {
 	errs := []error{}
-	for key, val := range nodeSelector {
+	for key, val := range labels {
 		if errMsgs := validation.IsQualifiedName(key); len(errMsgs) != 0 {
 			errs = append(errs, fmt.Errorf(strings.Join(errMsgs, ";")))
 		}
@@ -699,6 +2412,14 @@ func validateTolerations(tolerations []corev1.Toleration) error {
 			errs = append(errs, fmt.Errorf("effect must be 'NoExecute' when `tolerationSeconds` is set"))
 		}
 
+		if toleration.TolerationSeconds != nil {
+			if maxTolerationSeconds := GetMaxTolerationSeconds(); *toleration.TolerationSeconds < 0 ||
+				*toleration.TolerationSeconds > maxTolerationSeconds {
+				errs = append(errs, fmt.Errorf("`tolerationSeconds` %d must be between 0 and %d",
+					*toleration.TolerationSeconds, maxTolerationSeconds))
+			}
+		}
+
 		// validate toleration operator and value
 		switch toleration.Operator {
 		// empty operator means Equal
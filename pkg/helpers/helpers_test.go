@@ -5,51 +5,69 @@ package helpers
 
 import (
 	"context"
+	"encoding/base64"
+	goerrors "errors"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/stolostron/managedcluster-import-controller/pkg/constants"
 	testinghelpers "github.com/stolostron/managedcluster-import-controller/pkg/helpers/testing"
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	operatorfake "open-cluster-management.io/api/client/operator/clientset/versioned/fake"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
 	operatorv1 "open-cluster-management.io/api/operator/v1"
 	workv1 "open-cluster-management.io/api/work/v1"
 
+	"github.com/ghodss/yaml"
 	"github.com/openshift/library-go/pkg/operator/events/eventstesting"
 	"github.com/openshift/library-go/pkg/operator/resource/resourcemerge"
 
 	appsv1 "k8s.io/api/apps/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	crdv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	crdv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	apiextensionsfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
 	kubefake "k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/kubernetes/scheme"
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/restmapper"
+	clienttesting "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	"k8s.io/utils/diff"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 )
 
 var testscheme = scheme.Scheme
 
 func init() {
-	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
-	testscheme.AddKnownTypes(workv1.SchemeGroupVersion, &workv1.ManifestWork{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{}, &clusterv1.ManagedClusterList{})
+	testscheme.AddKnownTypes(workv1.SchemeGroupVersion, &workv1.ManifestWork{}, &workv1.ManifestWorkList{})
 	testscheme.AddKnownTypes(operatorv1.SchemeGroupVersion, &operatorv1.Klusterlet{})
 	testscheme.AddKnownTypes(crdv1beta1.SchemeGroupVersion, &crdv1beta1.CustomResourceDefinition{})
 	testscheme.AddKnownTypes(crdv1.SchemeGroupVersion, &crdv1.CustomResourceDefinition{})
+	testscheme.AddKnownTypes(addonv1alpha1.SchemeGroupVersion,
+		&addonv1alpha1.ManagedClusterAddOn{}, &addonv1alpha1.ManagedClusterAddOnList{})
 }
 
 func TestGetMaxConcurrentReconciles(t *testing.T) {
@@ -62,6 +80,213 @@ func TestGetMaxConcurrentReconciles(t *testing.T) {
 	}
 }
 
+func TestIsAPIExtensionV1Supported(t *testing.T) {
+	cases := []struct {
+		name        string
+		kubeVersion string
+		expected    bool
+	}{
+		{
+			name:        "min version",
+			kubeVersion: "v1.16.0",
+			expected:    true,
+		},
+		{
+			name:        "min version without v prefix and patch",
+			kubeVersion: "1.16",
+			expected:    true,
+		},
+		{
+			name:        "version with build metadata",
+			kubeVersion: "v1.16.3+abc",
+			expected:    true,
+		},
+		{
+			name:        "version with vendor suffix",
+			kubeVersion: "v1.22.0-eks",
+			expected:    true,
+		},
+		{
+			name:        "version with spaces",
+			kubeVersion: " v1.18.0 ",
+			expected:    true,
+		},
+		{
+			name:        "old version",
+			kubeVersion: "v1.11.0",
+			expected:    false,
+		},
+		{
+			name:        "old version with vendor suffix",
+			kubeVersion: "v1.15.12-gke.2",
+			expected:    false,
+		},
+		{
+			name:        "empty version",
+			kubeVersion: " ",
+			expected:    false,
+		},
+		{
+			name:        "garbage",
+			kubeVersion: "garbage",
+			expected:    false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if supported := IsAPIExtensionV1Supported(c.kubeVersion); supported != c.expected {
+				t.Errorf("expected %v, but got %v", c.expected, supported)
+			}
+		})
+	}
+}
+
+func TestGetMaxConcurrentReconcilesClamp(t *testing.T) {
+	cases := []struct {
+		name       string
+		reconciles string
+		ceiling    string
+		expected   int
+	}{
+		{
+			name:       "value in range",
+			reconciles: "10",
+			expected:   10,
+		},
+		{
+			name:       "value above the default ceiling",
+			reconciles: "100000",
+			expected:   50,
+		},
+		{
+			name:       "value above the customized ceiling",
+			reconciles: "30",
+			ceiling:    "20",
+			expected:   20,
+		},
+		{
+			name:       "negative value",
+			reconciles: "-5",
+			expected:   1,
+		},
+		{
+			name:       "zero value",
+			reconciles: "0",
+			expected:   1,
+		},
+		{
+			name:       "invalid ceiling",
+			reconciles: "100",
+			ceiling:    "-1",
+			expected:   50,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			os.Setenv(maxConcurrentReconcilesEnvVarName, c.reconciles)
+			defer os.Unsetenv(maxConcurrentReconcilesEnvVarName)
+			os.Setenv(maxConcurrentReconcilesCeilingEnvVarName, c.ceiling)
+			defer os.Unsetenv(maxConcurrentReconcilesCeilingEnvVarName)
+
+			if reconciles := GetMaxConcurrentReconciles(); reconciles != c.expected {
+				t.Errorf("expected %d, but got %d", c.expected, reconciles)
+			}
+		})
+	}
+}
+
+func TestGetMaxConcurrentReconcilesFor(t *testing.T) {
+	cases := []struct {
+		name               string
+		specificReconciles string
+		globalReconciles   string
+		expected           int
+	}{
+		{
+			name:     "env is not set",
+			expected: 1,
+		},
+		{
+			name:             "global env is set",
+			globalReconciles: "5",
+			expected:         5,
+		},
+		{
+			name:               "controller specific env is set",
+			specificReconciles: "10",
+			globalReconciles:   "5",
+			expected:           10,
+		},
+		{
+			name:               "controller specific env is above the ceiling",
+			specificReconciles: "1000",
+			expected:           50,
+		},
+		{
+			name:               "controller specific env is wrong",
+			specificReconciles: "invalid",
+			globalReconciles:   "5",
+			expected:           5,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			os.Setenv("MAX_CONCURRENT_RECONCILES_MANIFESTWORK", c.specificReconciles)
+			defer os.Unsetenv("MAX_CONCURRENT_RECONCILES_MANIFESTWORK")
+			os.Setenv(maxConcurrentReconcilesEnvVarName, c.globalReconciles)
+			defer os.Unsetenv(maxConcurrentReconcilesEnvVarName)
+
+			if reconciles := GetMaxConcurrentReconcilesFor("manifestwork-controller"); reconciles != c.expected {
+				t.Errorf("expected %d, but got %d", c.expected, reconciles)
+			}
+		})
+	}
+}
+
+func TestNewEventRecorder(t *testing.T) {
+	os.Setenv(constants.PodNamespaceEnvVarName, "open-cluster-management")
+	defer os.Unsetenv(constants.PodNamespaceEnvVarName)
+
+	cases := []struct {
+		name                  string
+		componentName         string
+		expectedComponentName string
+		expectedFieldManager  string
+	}{
+		{
+			name:                  "default component name",
+			expectedComponentName: "test-controller",
+			expectedFieldManager:  "managedcluster-import-controller",
+		},
+		{
+			name:                  "custom component name",
+			componentName:         "hub1-import-controller",
+			expectedComponentName: "hub1-import-controller-test-controller",
+			expectedFieldManager:  "hub1-import-controller",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if len(c.componentName) > 0 {
+				os.Setenv(componentNameEnvVarName, c.componentName)
+				defer os.Unsetenv(componentNameEnvVarName)
+			}
+
+			recorder := NewEventRecorder(kubefake.NewSimpleClientset(), "test-controller")
+			if recorder.ComponentName() != c.expectedComponentName {
+				t.Errorf("expected event source %s, but got %s", c.expectedComponentName, recorder.ComponentName())
+			}
+			if GetComponentName() != c.expectedFieldManager {
+				t.Errorf("expected field manager %s, but got %s", c.expectedFieldManager, GetComponentName())
+			}
+		})
+	}
+}
+
 func TestGenerateClientFromSecret(t *testing.T) {
 	apiServer := &envtest.Environment{}
 	config, err := apiServer.Start()
@@ -112,6 +337,39 @@ func TestGenerateClientFromSecret(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "using bootstrap service account",
+			generateSecret: func(server string, config *rest.Config) *corev1.Secret {
+				return &corev1.Secret{
+					Data: map[string][]byte{
+						"kubeconfig": adminKubeconfig(t, server, config),
+						constants.AutoImportBootstrapServiceAccountName: []byte("kube-system/bootstrap-sa"),
+					},
+				}
+			},
+		},
+		{
+			name: "bootstrap service account does not exist",
+			generateSecret: func(server string, config *rest.Config) *corev1.Secret {
+				return &corev1.Secret{
+					Data: map[string][]byte{
+						"kubeconfig": adminKubeconfig(t, server, config),
+						constants.AutoImportBootstrapServiceAccountName: []byte("kube-system/nonexistent"),
+					},
+				}
+			},
+			expectedErr: "failed to request a token of the service account kube-system/nonexistent",
+		},
+	}
+
+	kubeClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = kubeClient.CoreV1().ServiceAccounts(metav1.NamespaceSystem).Create(context.TODO(),
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "bootstrap-sa"}}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
 	}
 
 	for _, c := range cases {
@@ -126,168 +384,387 @@ func TestGenerateClientFromSecret(t *testing.T) {
 			}
 		})
 	}
+
+	token, err := RequestServiceAccountToken(context.TODO(), kubeClient, metav1.NamespaceSystem, "bootstrap-sa")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(token) == 0 {
+		t.Errorf("expected a token of the bootstrap service account, but failed")
+	}
 }
 
-func TestUpdateManagedClusterStatus(t *testing.T) {
+func adminKubeconfig(t *testing.T, server string, config *rest.Config) []byte {
+	apiConfig := createBasic(server, "test", "admin", config.CAData)
+	apiConfig.AuthInfos["admin"] = &clientcmdapi.AuthInfo{
+		Token:                 config.BearerToken,
+		ClientCertificateData: config.CertData,
+		ClientKeyData:         config.KeyData,
+	}
+	kubeconfig, err := clientcmd.Write(*apiConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kubeconfig
+}
+
+func TestRequestServiceAccountToken(t *testing.T) {
 	cases := []struct {
-		name           string
-		managedCluster *clusterv1.ManagedCluster
-		cond           metav1.Condition
+		name          string
+		token         string
+		expectedToken string
+		expectedErr   bool
 	}{
 		{
-			name: "add condition",
-			managedCluster: &clusterv1.ManagedCluster{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: "test_cluster",
-				},
-			},
-			cond: metav1.Condition{
-				Type:    "test",
-				Status:  metav1.ConditionTrue,
-				Message: "test",
-				Reason:  "test",
-			},
-		},
-		{
-			name: "update an existing condition",
-			managedCluster: &clusterv1.ManagedCluster{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: "test_cluster",
-				},
-				Status: clusterv1.ManagedClusterStatus{
-					Conditions: []metav1.Condition{
-						{
-							Type:    "test",
-							Status:  metav1.ConditionTrue,
-							Message: "test",
-							Reason:  "test",
-						},
-					},
-				},
-			},
-			cond: metav1.Condition{
-				Type:    "test",
-				Status:  metav1.ConditionTrue,
-				Message: "test",
-				Reason:  "test",
-			},
+			name:          "token is issued",
+			token:         "bootstrap-token",
+			expectedToken: "bootstrap-token",
 		},
 		{
-			name: "update condition",
-			managedCluster: &clusterv1.ManagedCluster{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: "test_cluster",
-				},
-				Status: clusterv1.ManagedClusterStatus{
-					Conditions: []metav1.Condition{
-						{
-							Type:    "test",
-							Status:  metav1.ConditionTrue,
-							Message: "test",
-							Reason:  "test",
-						},
-					},
-				},
-			},
-			cond: metav1.Condition{
-				Type:    "test",
-				Status:  metav1.ConditionFalse,
-				Message: "test",
-				Reason:  "test",
-			},
+			name:        "token is empty",
+			expectedErr: true,
 		},
 	}
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			fakeClient := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(c.managedCluster).Build()
+			kubeClient := kubefake.NewSimpleClientset()
+			kubeClient.PrependReactor("create", "serviceaccounts",
+				func(action clienttesting.Action) (bool, runtime.Object, error) {
+					createAction := action.(clienttesting.CreateAction)
+					if createAction.GetSubresource() != "token" || createAction.GetNamespace() != "kube-system" {
+						return false, nil, nil
+					}
+					tokenRequest := createAction.GetObject().(*authenticationv1.TokenRequest)
+					if tokenRequest.Spec.ExpirationSeconds == nil {
+						t.Errorf("expected the expiration of the token, but failed")
+					}
+					tokenRequest.Status.Token = c.token
+					return true, tokenRequest, nil
+				})
 
-			err := UpdateManagedClusterStatus(fakeClient, eventstesting.NewTestingEventRecorder(t), c.managedCluster.Name, c.cond)
-			if err != nil {
+			token, err := RequestServiceAccountToken(context.TODO(), kubeClient, "kube-system", "bootstrap-sa")
+			if c.expectedErr && err == nil {
+				t.Errorf("expected error, but failed")
+			}
+			if !c.expectedErr && err != nil {
 				t.Errorf("unexpected error: %v", err)
 			}
+			if token != c.expectedToken {
+				t.Errorf("expected token %q, but got %q", c.expectedToken, token)
+			}
 		})
 	}
-
 }
 
-func TestAddManagedClusterFinalizer(t *testing.T) {
+func TestParseBootstrapServiceAccount(t *testing.T) {
 	cases := []struct {
-		name               string
-		managedCluster     *clusterv1.ManagedCluster
-		finalizer          string
-		expectedFinalizers []string
+		name              string
+		bootstrapSA       string
+		expectedNamespace string
+		expectedName      string
+		expectedErr       bool
 	}{
 		{
-			name: "Add a finalizer",
-			managedCluster: &clusterv1.ManagedCluster{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: "test_cluster",
-				},
-			},
-			finalizer:          "test",
-			expectedFinalizers: []string{"test"},
+			name:              "name only",
+			bootstrapSA:       "bootstrap-sa",
+			expectedNamespace: "kube-system",
+			expectedName:      "bootstrap-sa",
 		},
 		{
-			name: "Add an existent finalizer",
-			managedCluster: &clusterv1.ManagedCluster{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:       "test_cluster",
-					Finalizers: []string{"test"},
-				},
-			},
-			finalizer:          "test",
-			expectedFinalizers: []string{"test"},
+			name:              "namespace and name",
+			bootstrapSA:       "open-cluster-management/bootstrap-sa",
+			expectedNamespace: "open-cluster-management",
+			expectedName:      "bootstrap-sa",
+		},
+		{
+			name:        "empty",
+			bootstrapSA: "",
+			expectedErr: true,
+		},
+		{
+			name:        "invalid",
+			bootstrapSA: "a/b/c",
+			expectedErr: true,
 		},
 	}
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			modified := resourcemerge.BoolPtr(false)
-			AddManagedClusterFinalizer(modified, c.managedCluster, c.finalizer)
-			assertFinalizers(t, c.managedCluster, c.expectedFinalizers)
+			namespace, name, err := parseBootstrapServiceAccount(c.bootstrapSA)
+			if c.expectedErr {
+				if !goerrors.Is(err, ErrSecretMissingCredentials) {
+					t.Errorf("expected ErrSecretMissingCredentials, but got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if namespace != c.expectedNamespace || name != c.expectedName {
+				t.Errorf("expected %s/%s, but got %s/%s", c.expectedNamespace, c.expectedName, namespace, name)
+			}
 		})
 	}
 }
 
-func TestRemoveManagedClusterFinalizer(t *testing.T) {
+func TestValidateServerURL(t *testing.T) {
 	cases := []struct {
-		name               string
-		managedCluster     *clusterv1.ManagedCluster
-		finalizer          string
-		expectedFinalizers []string
+		name        string
+		config      *rest.Config
+		allowHTTP   string
+		expectedErr bool
 	}{
 		{
-			name: "Remove a finalizer",
-			managedCluster: &clusterv1.ManagedCluster{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:       "test_cluster",
-					Finalizers: []string{"test1", "test2"},
-				},
-			},
-			finalizer:          "test2",
-			expectedFinalizers: []string{"test1"},
+			name:   "https server",
+			config: &rest.Config{Host: "https://127.0.0.1:6443"},
 		},
 		{
-			name: "Empty finalizers",
-			managedCluster: &clusterv1.ManagedCluster{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:       "test_cluster",
-					Finalizers: []string{"test"},
-				},
-			},
-			finalizer:          "test",
-			expectedFinalizers: []string{},
+			name:        "http server",
+			config:      &rest.Config{Host: "http://127.0.0.1:6443"},
+			expectedErr: true,
 		},
 		{
-			name: "Remove a nonexistent finalizer",
+			name:      "http server is allowed",
+			config:    &rest.Config{Host: "http://127.0.0.1:6443"},
+			allowHTTP: "true",
+		},
+		{
+			name: "server without scheme using tls",
+			config: &rest.Config{
+				Host:            "127.0.0.1:6443",
+				TLSClientConfig: rest.TLSClientConfig{CAData: []byte("test")},
+			},
+		},
+		{
+			name:        "server without scheme",
+			config:      &rest.Config{Host: "127.0.0.1:6443"},
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			os.Setenv(allowHTTPServerEnvVarName, c.allowHTTP)
+			defer os.Unsetenv(allowHTTPServerEnvVarName)
+
+			err := ValidateServerURL(c.config)
+			if (err != nil) != c.expectedErr {
+				t.Errorf("expected error %v, but got %v", c.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestBuildClientConfigFromSecretWithTokenFile(t *testing.T) {
+	tokenDir := t.TempDir()
+	tokenFile := filepath.Join(tokenDir, "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("test-token"), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	otherTokenFile := filepath.Join(t.TempDir(), "token")
+	if err := ioutil.WriteFile(otherTokenFile, []byte("test-token"), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cases := []struct {
+		name                    string
+		data                    map[string][]byte
+		allowedTokenFileDirs    string
+		expectedBearerTokenFile string
+		expectedErr             bool
+	}{
+		{
+			name: "token file is allowed",
+			data: map[string][]byte{
+				"tokenFile": []byte(tokenFile),
+				"server":    []byte("https://127.0.0.1:6443"),
+			},
+			allowedTokenFileDirs:    "/etc/tokens," + tokenDir,
+			expectedBearerTokenFile: tokenFile,
+		},
+		{
+			name: "token file is not allowed",
+			data: map[string][]byte{
+				"tokenFile": []byte(tokenFile),
+				"server":    []byte("https://127.0.0.1:6443"),
+			},
+			expectedErr: true,
+		},
+		{
+			name: "token file is not in the allowed directories",
+			data: map[string][]byte{
+				"tokenFile": []byte(otherTokenFile),
+				"server":    []byte("https://127.0.0.1:6443"),
+			},
+			allowedTokenFileDirs: tokenDir,
+			expectedErr:          true,
+		},
+		{
+			name: "token file escapes the allowed directories",
+			data: map[string][]byte{
+				"tokenFile": []byte(filepath.Join(tokenDir, "..", filepath.Base(filepath.Dir(otherTokenFile)), "token")),
+				"server":    []byte("https://127.0.0.1:6443"),
+			},
+			allowedTokenFileDirs: tokenDir,
+			expectedErr:          true,
+		},
+		{
+			name: "token file is the service account token of the controller",
+			data: map[string][]byte{
+				"tokenFile": []byte("/var/run/secrets/kubernetes.io/serviceaccount/token"),
+				"server":    []byte("https://127.0.0.1:6443"),
+			},
+			allowedTokenFileDirs: "/",
+			expectedErr:          true,
+		},
+		{
+			name: "token file is a relative path",
+			data: map[string][]byte{
+				"tokenFile": []byte("token"),
+				"server":    []byte("https://127.0.0.1:6443"),
+			},
+			allowedTokenFileDirs: tokenDir,
+			expectedErr:          true,
+		},
+		{
+			name: "token file does not exist",
+			data: map[string][]byte{
+				"tokenFile": []byte(filepath.Join(tokenDir, "nonexistent")),
+				"server":    []byte("https://127.0.0.1:6443"),
+			},
+			allowedTokenFileDirs: tokenDir,
+			expectedErr:          true,
+		},
+		{
+			name: "inline token takes precedence",
+			data: map[string][]byte{
+				"token":     []byte("test"),
+				"tokenFile": []byte(tokenFile),
+				"server":    []byte("https://127.0.0.1:6443"),
+			},
+			allowedTokenFileDirs: tokenDir,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			os.Setenv(allowedTokenFileDirsEnvVarName, c.allowedTokenFileDirs)
+			defer os.Unsetenv(allowedTokenFileDirsEnvVarName)
+
+			config, err := buildClientConfigFromSecret(&corev1.Secret{Data: c.data})
+			if (err != nil) != c.expectedErr {
+				t.Fatalf("expected error %v, but got %v", c.expectedErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if config.BearerTokenFile != c.expectedBearerTokenFile {
+				t.Errorf("expected bearer token file %q, but got %q", c.expectedBearerTokenFile, config.BearerTokenFile)
+			}
+		})
+	}
+}
+
+func TestBuildClientConfigFromSecretWithAuthProvider(t *testing.T) {
+	newAuthProviderKubeconfig := func(authProvider string) []byte {
+		return []byte(fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://127.0.0.1:6443
+  name: test
+contexts:
+- context:
+    cluster: test
+    user: test
+  name: test
+current-context: test
+users:
+- name: test
+  user:
+    auth-provider:
+      %s
+`, authProvider))
+	}
+
+	cases := []struct {
+		name                 string
+		kubeconfig           []byte
+		expectedAuthProvider string
+		expectedErr          error
+	}{
+		{
+			name: "oidc auth provider is preserved",
+			kubeconfig: newAuthProviderKubeconfig(`name: oidc
+      config:
+        client-id: test
+        idp-issuer-url: https://issuer.example.com
+        id-token: test-token`),
+			expectedAuthProvider: "oidc",
+		},
+		{
+			name: "oidc auth provider without issuer",
+			kubeconfig: newAuthProviderKubeconfig(`name: oidc
+      config:
+        client-id: test`),
+			expectedErr: ErrUnsupportedAuthProvider,
+		},
+		{
+			name:        "auth provider is not registered",
+			kubeconfig:  newAuthProviderKubeconfig(`name: unknown`),
+			expectedErr: ErrUnsupportedAuthProvider,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			config, err := buildClientConfigFromSecret(&corev1.Secret{
+				Data: map[string][]byte{"kubeconfig": c.kubeconfig},
+			})
+			if c.expectedErr != nil {
+				if !goerrors.Is(err, c.expectedErr) {
+					t.Errorf("expected error %v, but got %v", c.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if config.AuthProvider == nil || config.AuthProvider.Name != c.expectedAuthProvider {
+				t.Errorf("expected auth provider %q, but got %v", c.expectedAuthProvider, config.AuthProvider)
+			}
+		})
+	}
+}
+
+func TestRemoveManagedClusterFinalizers(t *testing.T) {
+	cases := []struct {
+		name               string
+		managedCluster     *clusterv1.ManagedCluster
+		finalizers         []string
+		expectedFinalizers []string
+	}{
+		{
+			name: "Remove two of three finalizers",
+			managedCluster: &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "test_cluster",
+					Finalizers: []string{"test1", "test2", "test3"},
+				},
+			},
+			finalizers:         []string{"test1", "test3"},
+			expectedFinalizers: []string{"test2"},
+		},
+		{
+			name: "Remove nonexistent finalizers",
 			managedCluster: &clusterv1.ManagedCluster{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:       "test_cluster",
 					Finalizers: []string{"test1"},
 				},
 			},
-			finalizer:          "test",
+			finalizers:         []string{"test2", "test3"},
 			expectedFinalizers: []string{"test1"},
 		},
 	}
@@ -296,396 +773,2070 @@ func TestRemoveManagedClusterFinalizer(t *testing.T) {
 		t.Run(c.name, func(t *testing.T) {
 			fakeClient := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(c.managedCluster).Build()
 
-			managedCluster := &clusterv1.ManagedCluster{}
-			if err := fakeClient.Get(context.TODO(), types.NamespacedName{Name: c.managedCluster.Name}, managedCluster); err != nil {
-				t.Errorf("unexpected error: %v", err)
-			}
-
-			err := RemoveManagedClusterFinalizer(context.TODO(), fakeClient, eventstesting.NewTestingEventRecorder(t), managedCluster, c.finalizer)
+			err := RemoveManagedClusterFinalizers(context.TODO(), fakeClient,
+				eventstesting.NewTestingEventRecorder(t), c.managedCluster, c.finalizers...)
 			if err != nil {
 				t.Errorf("unexpected error: %v", err)
 			}
 
-			updatedManagedCluster := &clusterv1.ManagedCluster{}
-			if err := fakeClient.Get(context.TODO(), types.NamespacedName{Name: c.managedCluster.Name}, updatedManagedCluster); err != nil {
+			managedCluster := &clusterv1.ManagedCluster{}
+			if err := fakeClient.Get(context.TODO(), types.NamespacedName{Name: c.managedCluster.Name}, managedCluster); err != nil {
 				t.Errorf("unexpected error: %v", err)
 			}
-
-			assertFinalizers(t, updatedManagedCluster, c.expectedFinalizers)
+			assertFinalizers(t, managedCluster, c.expectedFinalizers)
 		})
 	}
 }
 
-func TestApplyResources(t *testing.T) {
-	var replicas int32 = 2
-
+func TestUpdateManagedClusterStatus(t *testing.T) {
 	cases := []struct {
 		name           string
-		kubeObjs       []runtime.Object
-		klusterletObjs []runtime.Object
-		clientObjs     []client.Object
-		crds           []runtime.Object
-		requiredObjs   []runtime.Object
-		owner          *clusterv1.ManagedCluster
+		managedCluster *clusterv1.ManagedCluster
+		cond           metav1.Condition
 	}{
 		{
-			name:           "create resources",
-			kubeObjs:       []runtime.Object{},
-			klusterletObjs: []runtime.Object{},
-			clientObjs:     []client.Object{},
-			crds:           []runtime.Object{},
-			requiredObjs: []runtime.Object{
-				&corev1.ServiceAccount{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      "test_cluster",
-						Namespace: "test_cluster",
-					},
-				},
-				&rbacv1.ClusterRole{
-					ObjectMeta: metav1.ObjectMeta{
-						Name: "test_cluster",
-					},
-				},
-				&rbacv1.ClusterRoleBinding{
-					ObjectMeta: metav1.ObjectMeta{
-						Name: "test_cluster",
-					},
-				},
-				&corev1.Secret{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      "test_cluster",
-						Namespace: "test_cluster",
-					},
-				},
-				&appsv1.Deployment{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      "test_cluster",
-						Namespace: "test_cluster",
-					},
-				},
-				&operatorv1.Klusterlet{
-					ObjectMeta: metav1.ObjectMeta{
-						Name: "test_cluster",
-					},
-				},
-				&crdv1beta1.CustomResourceDefinition{
-					ObjectMeta: metav1.ObjectMeta{
-						Name: "test_cluster",
-					},
-				},
-				&crdv1.CustomResourceDefinition{
-					ObjectMeta: metav1.ObjectMeta{
-						Name: "test_cluster",
-					},
-				},
-				&workv1.ManifestWork{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      "test_cluster",
-						Namespace: "test_cluster",
-					},
-				},
-			},
-			owner: &clusterv1.ManagedCluster{
+			name: "add condition",
+			managedCluster: &clusterv1.ManagedCluster{
 				ObjectMeta: metav1.ObjectMeta{
 					Name: "test_cluster",
 				},
 			},
+			cond: metav1.Condition{
+				Type:    "test",
+				Status:  metav1.ConditionTrue,
+				Message: "test",
+				Reason:  "test",
+			},
 		},
 		{
-			name: "update resources",
-			kubeObjs: []runtime.Object{
-				&corev1.ServiceAccount{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      "test_cluster",
-						Namespace: "test_cluster",
-					},
-				},
-				&rbacv1.ClusterRole{
-					ObjectMeta: metav1.ObjectMeta{
-						Name: "test_cluster",
-					},
+			name: "update an existing condition",
+			managedCluster: &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test_cluster",
 				},
-				&rbacv1.ClusterRoleBinding{
-					ObjectMeta: metav1.ObjectMeta{
-						Name: "test_cluster",
-					},
-					Subjects: []rbacv1.Subject{
+				Status: clusterv1.ManagedClusterStatus{
+					Conditions: []metav1.Condition{
 						{
-							Name: "test1",
+							Type:    "test",
+							Status:  metav1.ConditionTrue,
+							Message: "test",
+							Reason:  "test",
 						},
 					},
-					RoleRef: rbacv1.RoleRef{
-						Name: "test1",
-					},
-				},
-				&corev1.Secret{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      "test_cluster",
-						Namespace: "test_cluster",
-					},
-				},
-				&appsv1.Deployment{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      "test_cluster",
-						Namespace: "test_cluster",
-					},
 				},
 			},
-			crds: []runtime.Object{
-				&crdv1beta1.CustomResourceDefinition{
-					ObjectMeta: metav1.ObjectMeta{
-						Name: "test_cluster",
-					},
+			cond: metav1.Condition{
+				Type:    "test",
+				Status:  metav1.ConditionTrue,
+				Message: "test",
+				Reason:  "test",
+			},
+		},
+		{
+			name: "update condition",
+			managedCluster: &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test_cluster",
 				},
-				&crdv1.CustomResourceDefinition{
-					ObjectMeta: metav1.ObjectMeta{
-						Name: "test_cluster",
+				Status: clusterv1.ManagedClusterStatus{
+					Conditions: []metav1.Condition{
+						{
+							Type:    "test",
+							Status:  metav1.ConditionTrue,
+							Message: "test",
+							Reason:  "test",
+						},
 					},
 				},
 			},
-			klusterletObjs: []runtime.Object{
-				&operatorv1.Klusterlet{
-					ObjectMeta: metav1.ObjectMeta{
-						Name: "test_cluster",
-					},
-				},
+			cond: metav1.Condition{
+				Type:    "test",
+				Status:  metav1.ConditionFalse,
+				Message: "test",
+				Reason:  "test",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fakeClient := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(c.managedCluster).Build()
+
+			err := UpdateManagedClusterStatus(fakeClient, eventstesting.NewTestingEventRecorder(t), c.managedCluster.Name, c.cond)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+
+}
+
+func TestPruneImportConditions(t *testing.T) {
+	succeeded := metav1.Condition{
+		Type:    constants.ImportSucceededConditionType,
+		Status:  metav1.ConditionTrue,
+		Reason:  "ManagedClusterImported",
+		Message: "Import succeeded",
+	}
+	failed := metav1.Condition{
+		Type:    constants.ImportSucceededConditionType,
+		Status:  metav1.ConditionFalse,
+		Reason:  "ManagedClusterNotImported",
+		Message: "Import failed",
+	}
+	hookSucceeded := metav1.Condition{
+		Type:    constants.PostImportHookSucceededConditionType,
+		Status:  metav1.ConditionTrue,
+		Reason:  "PostImportHookSucceeded",
+		Message: "Post-import hook succeeded",
+	}
+	hookFailed := metav1.Condition{
+		Type:    constants.PostImportHookSucceededConditionType,
+		Status:  metav1.ConditionFalse,
+		Reason:  "PostImportHookFailed",
+		Message: "Unable to run the post-import hook",
+	}
+	available := metav1.Condition{
+		Type:   clusterv1.ManagedClusterConditionAvailable,
+		Status: metav1.ConditionTrue,
+		Reason: "ManagedClusterAvailable",
+	}
+
+	cases := []struct {
+		name               string
+		conditions         []metav1.Condition
+		expectedPruned     bool
+		expectedConditions []metav1.Condition
+	}{
+		{
+			name:               "no conditions",
+			conditions:         []metav1.Condition{},
+			expectedConditions: []metav1.Condition{},
+		},
+		{
+			name:               "no failure conditions",
+			conditions:         []metav1.Condition{succeeded, hookSucceeded, available},
+			expectedConditions: []metav1.Condition{succeeded, hookSucceeded, available},
+		},
+		{
+			name:               "the managed cluster is not imported",
+			conditions:         []metav1.Condition{failed, hookFailed, available},
+			expectedConditions: []metav1.Condition{failed, hookFailed, available},
+		},
+		{
+			name:               "a prior failure condition is removed",
+			conditions:         []metav1.Condition{succeeded, hookFailed, available},
+			expectedPruned:     true,
+			expectedConditions: []metav1.Condition{succeeded, available},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cluster := &clusterv1.ManagedCluster{
+				Status: clusterv1.ManagedClusterStatus{Conditions: c.conditions},
+			}
+
+			pruned := PruneImportConditions(cluster)
+			if pruned != c.expectedPruned {
+				t.Errorf("expected pruned %v, but got %v", c.expectedPruned, pruned)
+			}
+			if !reflect.DeepEqual(cluster.Status.Conditions, c.expectedConditions) {
+				t.Errorf("expected conditions %v, but got %v", c.expectedConditions, cluster.Status.Conditions)
+			}
+		})
+	}
+}
+
+func TestUpdateManagedClusterStatusConditionsWithStaleImportCondition(t *testing.T) {
+	hookFailed := metav1.Condition{
+		Type:    constants.PostImportHookSucceededConditionType,
+		Status:  metav1.ConditionFalse,
+		Reason:  "PostImportHookFailed",
+		Message: "Unable to run the post-import hook",
+	}
+	succeeded := metav1.Condition{
+		Type:    constants.ImportSucceededConditionType,
+		Status:  metav1.ConditionTrue,
+		Reason:  "ManagedClusterImported",
+		Message: "Import succeeded",
+	}
+
+	cases := []struct {
+		name          string
+		conditions    []metav1.Condition
+		expectedTypes []string
+	}{
+		{
+			name: "a prior failure condition is replaced by success",
+			conditions: []metav1.Condition{
+				{
+					Type:    constants.ImportSucceededConditionType,
+					Status:  metav1.ConditionFalse,
+					Reason:  "ManagedClusterNotImported",
+					Message: "Import failed",
+				},
+				hookFailed,
+			},
+			expectedTypes: []string{constants.ImportSucceededConditionType},
+		},
+		{
+			name:       "the failure condition of the current import is kept",
+			conditions: []metav1.Condition{succeeded, hookFailed},
+			expectedTypes: []string{
+				constants.ImportSucceededConditionType,
+				constants.PostImportHookSucceededConditionType,
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			managedCluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test_cluster",
+				},
+				Status: clusterv1.ManagedClusterStatus{
+					Conditions: c.conditions,
+				},
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(managedCluster).Build()
+
+			err := UpdateManagedClusterStatus(fakeClient, eventstesting.NewTestingEventRecorder(t), managedCluster.Name,
+				succeeded)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			actual := &clusterv1.ManagedCluster{}
+			if err := fakeClient.Get(context.TODO(), types.NamespacedName{Name: managedCluster.Name}, actual); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			actualTypes := []string{}
+			for _, cond := range actual.Status.Conditions {
+				actualTypes = append(actualTypes, cond.Type)
+			}
+			if !reflect.DeepEqual(actualTypes, c.expectedTypes) {
+				t.Errorf("expected conditions %v, but got %v", c.expectedTypes, actual.Status.Conditions)
+			}
+			if !meta.IsStatusConditionTrue(actual.Status.Conditions, constants.ImportSucceededConditionType) {
+				t.Errorf("expected the import succeeded condition, but got %v", actual.Status.Conditions)
+			}
+		})
+	}
+}
+
+func TestUpdateManagedClusterStatusConditions(t *testing.T) {
+	managedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test_cluster",
+		},
+		Status: clusterv1.ManagedClusterStatus{
+			Conditions: []metav1.Condition{
+				{
+					Type:    "test1",
+					Status:  metav1.ConditionFalse,
+					Message: "test",
+					Reason:  "test",
+				},
+			},
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(managedCluster).Build()
+	conditionTypes := []string{"test1", "test2", "test3"}
+	conds := []metav1.Condition{}
+	for _, conditionType := range conditionTypes {
+		conds = append(conds, metav1.Condition{
+			Type:    conditionType,
+			Status:  metav1.ConditionTrue,
+			Message: "test",
+			Reason:  "test",
+		})
+	}
+
+	err := UpdateManagedClusterStatusConditions(fakeClient, eventstesting.NewTestingEventRecorder(t),
+		managedCluster.Name, conds...)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	actual := &clusterv1.ManagedCluster{}
+	if err := fakeClient.Get(context.TODO(), types.NamespacedName{Name: managedCluster.Name}, actual); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	// the fake client increases the resource version once for each write
+	if actual.ResourceVersion != "1000" {
+		t.Errorf("expected the status is updated with one write, but got resource version %s", actual.ResourceVersion)
+	}
+	for _, conditionType := range conditionTypes {
+		if !meta.IsStatusConditionTrue(actual.Status.Conditions, conditionType) {
+			t.Errorf("expected the condition %s is true, but got %v", conditionType, actual.Status.Conditions)
+		}
+	}
+}
+
+func TestUpdateManagedClusterStatusConflict(t *testing.T) {
+	managedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test_cluster",
+		},
+	}
+	runtimeClient := &conflictStatusClient{
+		Client:    fake.NewClientBuilder().WithScheme(testscheme).WithObjects(managedCluster).Build(),
+		conflicts: 1,
+	}
+
+	err := UpdateManagedClusterStatus(runtimeClient, eventstesting.NewTestingEventRecorder(t), managedCluster.Name,
+		metav1.Condition{
+			Type:    "test",
+			Status:  metav1.ConditionTrue,
+			Message: "test",
+			Reason:  "test",
+		})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if runtimeClient.updates != 2 {
+		t.Errorf("expected the status is updated twice, but got %d", runtimeClient.updates)
+	}
+
+	actual := &clusterv1.ManagedCluster{}
+	if err := runtimeClient.Get(context.TODO(), types.NamespacedName{Name: managedCluster.Name}, actual); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !meta.IsStatusConditionTrue(actual.Status.Conditions, "test") {
+		t.Errorf("expected the condition is true, but got %v", actual.Status.Conditions)
+	}
+}
+
+func TestUpdateManagedClusterStatusObservedGeneration(t *testing.T) {
+	cases := []struct {
+		name           string
+		managedCluster *clusterv1.ManagedCluster
+	}{
+		{
+			name: "add condition",
+			managedCluster: &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "test_cluster",
+					Generation: 1,
+				},
+			},
+		},
+		{
+			name: "the generation is changed",
+			managedCluster: &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "test_cluster",
+					Generation: 2,
+				},
+				Status: clusterv1.ManagedClusterStatus{
+					Conditions: []metav1.Condition{
+						{
+							Type:               "test",
+							Status:             metav1.ConditionTrue,
+							Message:            "test",
+							Reason:             "test",
+							ObservedGeneration: 1,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fakeClient := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(c.managedCluster).Build()
+
+			err := UpdateManagedClusterStatus(fakeClient, eventstesting.NewTestingEventRecorder(t), c.managedCluster.Name,
+				metav1.Condition{
+					Type:    "test",
+					Status:  metav1.ConditionTrue,
+					Message: "test",
+					Reason:  "test",
+				})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			managedCluster := &clusterv1.ManagedCluster{}
+			if err := fakeClient.Get(context.TODO(), types.NamespacedName{Name: c.managedCluster.Name}, managedCluster); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			cond := meta.FindStatusCondition(managedCluster.Status.Conditions, "test")
+			if cond == nil || cond.ObservedGeneration != c.managedCluster.Generation {
+				t.Errorf("expected the observed generation is %d, but got %v", c.managedCluster.Generation, cond)
+			}
+		})
+	}
+}
+
+func TestAddManagedClusterFinalizer(t *testing.T) {
+	cases := []struct {
+		name               string
+		managedCluster     *clusterv1.ManagedCluster
+		finalizer          string
+		expectedFinalizers []string
+	}{
+		{
+			name: "Add a finalizer",
+			managedCluster: &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test_cluster",
+				},
+			},
+			finalizer:          "test",
+			expectedFinalizers: []string{"test"},
+		},
+		{
+			name: "Add an existent finalizer",
+			managedCluster: &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "test_cluster",
+					Finalizers: []string{"test"},
+				},
+			},
+			finalizer:          "test",
+			expectedFinalizers: []string{"test"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			modified := resourcemerge.BoolPtr(false)
+			AddManagedClusterFinalizer(modified, c.managedCluster, c.finalizer)
+			assertFinalizers(t, c.managedCluster, c.expectedFinalizers)
+		})
+	}
+}
+
+func TestEnsureManagedClusterFinalizer(t *testing.T) {
+	cases := []struct {
+		name               string
+		managedCluster     *clusterv1.ManagedCluster
+		finalizer          string
+		expectedUpdated    bool
+		expectedFinalizers []string
+	}{
+		{
+			name: "Add a finalizer",
+			managedCluster: &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "test_cluster",
+					Finalizers: []string{"test1"},
+				},
+			},
+			finalizer:          "test2",
+			expectedUpdated:    true,
+			expectedFinalizers: []string{"test1", "test2"},
+		},
+		{
+			name: "Add an existent finalizer",
+			managedCluster: &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "test_cluster",
+					Finalizers: []string{"test"},
+				},
+			},
+			finalizer:          "test",
+			expectedUpdated:    false,
+			expectedFinalizers: []string{"test"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fakeClient := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(c.managedCluster).Build()
+
+			updated, err := EnsureManagedClusterFinalizer(context.TODO(), fakeClient,
+				eventstesting.NewTestingEventRecorder(t), c.managedCluster, c.finalizer)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if updated != c.expectedUpdated {
+				t.Errorf("expected updated %v, but got %v", c.expectedUpdated, updated)
+			}
+
+			// the finalizers are persisted
+			managedCluster := &clusterv1.ManagedCluster{}
+			if err := fakeClient.Get(context.TODO(), types.NamespacedName{Name: c.managedCluster.Name}, managedCluster); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			assertFinalizers(t, managedCluster, c.expectedFinalizers)
+		})
+	}
+}
+
+func TestListImportedManagedClusters(t *testing.T) {
+	newCluster := func(name string, finalizers []string, conditions ...metav1.Condition) *clusterv1.ManagedCluster {
+		return &clusterv1.ManagedCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Finalizers: finalizers},
+			Status:     clusterv1.ManagedClusterStatus{Conditions: conditions},
+		}
+	}
+
+	cases := []struct {
+		name     string
+		objs     []client.Object
+		expected []string
+	}{
+		{
+			name:     "no managed clusters",
+			objs:     []client.Object{},
+			expected: []string{},
+		},
+		{
+			name: "mix of imported and non-imported managed clusters",
+			objs: []client.Object{
+				newCluster("finalizer", []string{constants.ManifestWorkFinalizer}),
+				newCluster("succeeded", nil, metav1.Condition{
+					Type:   constants.ImportSucceededConditionType,
+					Status: metav1.ConditionTrue,
+					Reason: "ManagedClusterImported",
+				}),
+				newCluster("failed", nil, metav1.Condition{
+					Type:   constants.ImportSucceededConditionType,
+					Status: metav1.ConditionFalse,
+					Reason: "ManagedClusterImportFailed",
+				}),
+				newCluster("other-finalizer", []string{"cluster.open-cluster-management.io/api-resource-cleanup"}),
+				newCluster("other-tool", nil),
+			},
+			expected: []string{"finalizer", "succeeded"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			runtimeClient := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(c.objs...).Build()
+
+			clusters, err := ListImportedManagedClusters(context.TODO(), runtimeClient)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			names := []string{}
+			for _, cluster := range clusters {
+				names = append(names, cluster.Name)
+			}
+			if !reflect.DeepEqual(names, c.expected) {
+				t.Errorf("expected %v, but got %v", c.expected, names)
+			}
+		})
+	}
+}
+
+func TestRemoveManagedClusterFinalizer(t *testing.T) {
+	cases := []struct {
+		name               string
+		managedCluster     *clusterv1.ManagedCluster
+		finalizer          string
+		expectedFinalizers []string
+	}{
+		{
+			name: "Remove a finalizer",
+			managedCluster: &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "test_cluster",
+					Finalizers: []string{"test1", "test2"},
+				},
+			},
+			finalizer:          "test2",
+			expectedFinalizers: []string{"test1"},
+		},
+		{
+			name: "Empty finalizers",
+			managedCluster: &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "test_cluster",
+					Finalizers: []string{"test"},
+				},
+			},
+			finalizer:          "test",
+			expectedFinalizers: []string{},
+		},
+		{
+			name: "Remove a nonexistent finalizer",
+			managedCluster: &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "test_cluster",
+					Finalizers: []string{"test1"},
+				},
+			},
+			finalizer:          "test",
+			expectedFinalizers: []string{"test1"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fakeClient := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(c.managedCluster).Build()
+
+			managedCluster := &clusterv1.ManagedCluster{}
+			if err := fakeClient.Get(context.TODO(), types.NamespacedName{Name: c.managedCluster.Name}, managedCluster); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			err := RemoveManagedClusterFinalizer(context.TODO(), fakeClient, eventstesting.NewTestingEventRecorder(t), managedCluster, c.finalizer)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			updatedManagedCluster := &clusterv1.ManagedCluster{}
+			if err := fakeClient.Get(context.TODO(), types.NamespacedName{Name: c.managedCluster.Name}, updatedManagedCluster); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			assertFinalizers(t, updatedManagedCluster, c.expectedFinalizers)
+		})
+	}
+}
+
+func TestApplyResources(t *testing.T) {
+	var replicas int32 = 2
+
+	cases := []struct {
+		name            string
+		kubeObjs        []runtime.Object
+		klusterletObjs  []runtime.Object
+		clientObjs      []client.Object
+		crds            []runtime.Object
+		requiredObjs    []runtime.Object
+		owner           *clusterv1.ManagedCluster
+		options         ApplyOptions
+		expectedActions []ApplyAction
+		validateFunc    func(t *testing.T, clientHolder *ClientHolder, runtimeClient *applyRecordingClient)
+	}{
+		{
+			name:           "create resources",
+			kubeObjs:       []runtime.Object{},
+			klusterletObjs: []runtime.Object{},
+			clientObjs:     []client.Object{},
+			crds:           []runtime.Object{},
+			requiredObjs: []runtime.Object{
+				&corev1.Namespace{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test_cluster",
+					},
+				},
+				&rbacv1.Role{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test_cluster",
+						Namespace: "test_cluster",
+					},
+				},
+				&rbacv1.RoleBinding{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test_cluster",
+						Namespace: "test_cluster",
+					},
+				},
+				&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test_cluster",
+						Namespace: "test_cluster",
+					},
+					Data: map[string]string{
+						"test": "test",
+					},
+				},
+				&corev1.ServiceAccount{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test_cluster",
+						Namespace: "test_cluster",
+					},
+				},
+				&rbacv1.ClusterRole{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test_cluster",
+					},
+				},
+				&rbacv1.ClusterRoleBinding{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test_cluster",
+					},
+				},
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test_cluster",
+						Namespace: "test_cluster",
+					},
+				},
+				&appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test_cluster",
+						Namespace: "test_cluster",
+					},
+				},
+				&operatorv1.Klusterlet{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test_cluster",
+					},
+				},
+				&crdv1beta1.CustomResourceDefinition{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test_cluster",
+					},
+				},
+				&crdv1.CustomResourceDefinition{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test_cluster",
+					},
+				},
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test_cluster",
+						Namespace: "test_cluster",
+					},
+				},
+			},
+			expectedActions: []ApplyAction{
+				ApplyActionCreated, ApplyActionCreated, ApplyActionCreated, ApplyActionCreated, ApplyActionCreated,
+				ApplyActionCreated, ApplyActionCreated, ApplyActionCreated, ApplyActionCreated, ApplyActionCreated,
+				ApplyActionCreated, ApplyActionCreated, ApplyActionCreated,
+			},
+			validateFunc: func(t *testing.T, clientHolder *ClientHolder, runtimeClient *applyRecordingClient) {
+				ns, err := clientHolder.KubeClient.CoreV1().Namespaces().Get(context.TODO(), "test_cluster", metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if len(ns.OwnerReferences) != 1 || ns.OwnerReferences[0].Name != "test_cluster" {
+					t.Errorf("expected the namespace is owned by the managed cluster, but got %v", ns.OwnerReferences)
+				}
+			},
+			owner: &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test_cluster",
+				},
+			},
+		},
+		{
+			name: "update resources",
+			kubeObjs: []runtime.Object{
+				&rbacv1.Role{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test_cluster",
+						Namespace: "test_cluster",
+					},
+				},
+				&rbacv1.RoleBinding{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test_cluster",
+						Namespace: "test_cluster",
+					},
+					Subjects: []rbacv1.Subject{
+						{
+							Name: "test1",
+						},
+					},
+					RoleRef: rbacv1.RoleRef{
+						Name: "test_cluster",
+					},
+				},
+				&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test_cluster",
+						Namespace: "test_cluster",
+					},
+					Data: map[string]string{
+						"test": "test1",
+					},
+				},
+				&corev1.ServiceAccount{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test_cluster",
+						Namespace: "test_cluster",
+					},
+				},
+				&rbacv1.ClusterRole{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test_cluster",
+					},
+				},
+				&rbacv1.ClusterRoleBinding{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test_cluster",
+					},
+					Subjects: []rbacv1.Subject{
+						{
+							Name: "test1",
+						},
+					},
+					RoleRef: rbacv1.RoleRef{
+						Name: "test1",
+					},
+				},
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test_cluster",
+						Namespace: "test_cluster",
+					},
+				},
+				&appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test_cluster",
+						Namespace: "test_cluster",
+					},
+				},
+			},
+			crds: []runtime.Object{
+				&crdv1beta1.CustomResourceDefinition{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test_cluster",
+					},
+				},
+				&crdv1.CustomResourceDefinition{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test_cluster",
+					},
+				},
+			},
+			klusterletObjs: []runtime.Object{
+				&operatorv1.Klusterlet{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test_cluster",
+					},
+				},
+			},
+			clientObjs: []client.Object{
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test_cluster",
+						Namespace: "test_cluster",
+					},
+				},
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "label_test_cluster",
+						Namespace: "label_test_cluster",
+					},
+				},
+			},
+			requiredObjs: []runtime.Object{
+				&rbacv1.Role{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test_cluster",
+						Namespace: "test_cluster",
+					},
+					Rules: []rbacv1.PolicyRule{
+						{
+							Resources: []string{"test"},
+						},
+					},
+				},
+				&rbacv1.RoleBinding{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test_cluster",
+						Namespace: "test_cluster",
+					},
+					Subjects: []rbacv1.Subject{
+						{
+							Name: "test",
+						},
+					},
+					RoleRef: rbacv1.RoleRef{
+						Name: "test_cluster",
+					},
+				},
+				&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test_cluster",
+						Namespace: "test_cluster",
+					},
+					Data: map[string]string{
+						"test": "test",
+					},
+				},
+				&corev1.ServiceAccount{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test_cluster",
+						Namespace: "test_cluster",
+					},
+				},
+				&rbacv1.ClusterRole{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test_cluster",
+					},
+					Rules: []rbacv1.PolicyRule{
+						{
+							Resources: []string{"test"},
+						},
+					},
+				},
+				&rbacv1.ClusterRoleBinding{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test_cluster",
+					},
+					Subjects: []rbacv1.Subject{
+						{
+							Name: "test",
+						},
+					},
+					RoleRef: rbacv1.RoleRef{
+						Name: "test",
+					},
+				},
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test_cluster",
+						Namespace: "test_cluster",
+					},
+					Data: map[string][]byte{
+						"test": []byte("test"),
+					},
+				},
+				&appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test_cluster",
+						Namespace: "test_cluster",
+					},
+					Spec: appsv1.DeploymentSpec{
+						Replicas: &replicas,
+					},
+				},
+				&operatorv1.Klusterlet{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test_cluster",
+					},
+					Spec: operatorv1.KlusterletSpec{
+						Namespace: "test",
+					},
+				},
+				&crdv1beta1.CustomResourceDefinition{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test_cluster",
+					},
+					Spec: crdv1beta1.CustomResourceDefinitionSpec{
+						Version: "test",
+					},
+				},
+				&crdv1.CustomResourceDefinition{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test_cluster",
+					},
+					Spec: crdv1.CustomResourceDefinitionSpec{
+						PreserveUnknownFields: true,
+					},
+				},
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test_cluster",
+						Namespace: "test_cluster",
+					},
+					Spec: workv1.ManifestWorkSpec{
+						Workload: workv1.ManifestsTemplate{
+							Manifests: []workv1.Manifest{
+								{
+									RawExtension: runtime.RawExtension{Raw: []byte("{\"test\":\"test1\"}")},
+								},
+							},
+						},
+					},
+				},
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "label_test_cluster",
+						Namespace: "label_test_cluster",
+						Labels: map[string]string{
+							"test": "test",
+						},
+					},
+				},
+			},
+			// the service account is not changed
+			expectedActions: []ApplyAction{
+				ApplyActionUpdated, ApplyActionUpdated, ApplyActionUpdated,
+				ApplyActionUnchanged, ApplyActionUpdated, ApplyActionUpdated, ApplyActionUpdated, ApplyActionUpdated,
+				ApplyActionUpdated, ApplyActionUpdated, ApplyActionUpdated, ApplyActionUpdated, ApplyActionUpdated,
+			},
+			validateFunc: func(t *testing.T, clientHolder *ClientHolder, runtimeClient *applyRecordingClient) {
+				role, err := clientHolder.KubeClient.RbacV1().Roles("test_cluster").Get(
+					context.TODO(), "test_cluster", metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if len(role.Rules) != 1 || role.Rules[0].Resources[0] != "test" {
+					t.Errorf("expected the role rules are updated, but got %v", role.Rules)
+				}
+
+				roleBinding, err := clientHolder.KubeClient.RbacV1().RoleBindings("test_cluster").Get(
+					context.TODO(), "test_cluster", metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if len(roleBinding.Subjects) != 1 || roleBinding.Subjects[0].Name != "test" {
+					t.Errorf("expected the role binding subjects are updated, but got %v", roleBinding.Subjects)
+				}
+
+				configMap, err := clientHolder.KubeClient.CoreV1().ConfigMaps("test_cluster").Get(
+					context.TODO(), "test_cluster", metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if configMap.Data["test"] != "test" {
+					t.Errorf("expected the configmap data is updated, but got %v", configMap.Data)
+				}
+			},
+			owner: &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test_cluster",
+				},
+			},
+		},
+		{
+			name: "server-side apply manifest works",
+			clientObjs: []client.Object{
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test_cluster",
+						Namespace: "test_cluster",
+					},
+				},
 			},
-			clientObjs: []client.Object{
+			requiredObjs: []runtime.Object{
 				&workv1.ManifestWork{
 					ObjectMeta: metav1.ObjectMeta{
 						Name:      "test_cluster",
 						Namespace: "test_cluster",
 					},
-				},
-				&workv1.ManifestWork{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      "label_test_cluster",
-						Namespace: "label_test_cluster",
+					Spec: workv1.ManifestWorkSpec{
+						Workload: workv1.ManifestsTemplate{
+							Manifests: []workv1.Manifest{
+								{
+									RawExtension: runtime.RawExtension{Raw: []byte("{\"test\":\"test1\"}")},
+								},
+							},
+						},
+					},
+				},
+			},
+			expectedActions: []ApplyAction{ApplyActionUpdated},
+			owner: &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test_cluster",
+				},
+			},
+			options: ApplyOptions{ServerSideApply: true},
+			validateFunc: func(t *testing.T, clientHolder *ClientHolder, runtimeClient *applyRecordingClient) {
+				if len(runtimeClient.appliedObjects) != 1 {
+					t.Fatalf("expected one object is applied, but got %d", len(runtimeClient.appliedObjects))
+				}
+				if runtimeClient.fieldManagers[0] != "managedcluster-import-controller" {
+					t.Errorf("expected the field manager managedcluster-import-controller, but got %q",
+						runtimeClient.fieldManagers[0])
+				}
+				if runtimeClient.appliedObjects[0].GetObjectKind().GroupVersionKind().Kind != "ManifestWork" {
+					t.Errorf("expected the applied object has the type meta, but failed")
+				}
+			},
+		},
+		{
+			name: "apply an unchanged manifest work twice",
+			requiredObjs: []runtime.Object{
+				newTestManifestWork(`{"test":"test"}`),
+				newTestManifestWork(`{"test":"test"}`),
+			},
+			owner: &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test_cluster",
+				},
+			},
+			expectedActions: []ApplyAction{ApplyActionCreated, ApplyActionUnchanged},
+			validateFunc: func(t *testing.T, clientHolder *ClientHolder, runtimeClient *applyRecordingClient) {
+				if runtimeClient.updates != 0 {
+					t.Errorf("expected the unchanged manifest work is not updated, but got %d updates",
+						runtimeClient.updates)
+				}
+
+				work := &workv1.ManifestWork{}
+				if err := runtimeClient.Get(context.TODO(),
+					types.NamespacedName{Namespace: "test_cluster", Name: "test_cluster"}, work); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if len(work.Annotations[constants.ManifestWorkSpecHashAnnotation]) == 0 {
+					t.Errorf("expected the spec hash annotation on the manifest work, but got %v", work.Annotations)
+				}
+			},
+		},
+		{
+			name: "server-side apply an unchanged manifest work",
+			clientObjs: []client.Object{
+				func() client.Object {
+					work := newTestManifestWork(`{"test":"test"}`)
+					specHash, _ := manifestWorkSpecHash(work.Spec)
+					work.Annotations = map[string]string{constants.ManifestWorkSpecHashAnnotation: specHash}
+					_ = controllerutil.SetControllerReference(&clusterv1.ManagedCluster{
+						ObjectMeta: metav1.ObjectMeta{Name: "test_cluster"},
+					}, work, testscheme)
+					return work
+				}(),
+			},
+			requiredObjs: []runtime.Object{newTestManifestWork(`{"test":"test"}`)},
+			options:      ApplyOptions{ServerSideApply: true},
+			owner: &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test_cluster",
+				},
+			},
+			expectedActions: []ApplyAction{ApplyActionUnchanged},
+			validateFunc: func(t *testing.T, clientHolder *ClientHolder, runtimeClient *applyRecordingClient) {
+				if len(runtimeClient.appliedObjects) != 0 {
+					t.Errorf("expected the unchanged manifest work is not applied, but got %d applies",
+						len(runtimeClient.appliedObjects))
+				}
+			},
+		},
+		{
+			name: "server-side apply a manifest work with a changed spec",
+			clientObjs: []client.Object{
+				func() client.Object {
+					work := newTestManifestWork(`{"test":"test"}`)
+					specHash, _ := manifestWorkSpecHash(work.Spec)
+					work.Annotations = map[string]string{constants.ManifestWorkSpecHashAnnotation: specHash}
+					_ = controllerutil.SetControllerReference(&clusterv1.ManagedCluster{
+						ObjectMeta: metav1.ObjectMeta{Name: "test_cluster"},
+					}, work, testscheme)
+					return work
+				}(),
+			},
+			requiredObjs: []runtime.Object{newTestManifestWork(`{"test":"test1"}`)},
+			options:      ApplyOptions{ServerSideApply: true},
+			owner: &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test_cluster",
+				},
+			},
+			expectedActions: []ApplyAction{ApplyActionUpdated},
+			validateFunc: func(t *testing.T, clientHolder *ClientHolder, runtimeClient *applyRecordingClient) {
+				if len(runtimeClient.appliedObjects) != 1 {
+					t.Errorf("expected the changed manifest work is applied, but got %d applies",
+						len(runtimeClient.appliedObjects))
+				}
+			},
+		},
+		{
+			name: "apply a manifest work whose manifests are edited manually",
+			clientObjs: []client.Object{
+				func() client.Object {
+					work := newTestManifestWork(`{"test":"test"}`)
+					specHash, _ := manifestWorkSpecHash(work.Spec)
+					work.Annotations = map[string]string{constants.ManifestWorkSpecHashAnnotation: specHash}
+					_ = controllerutil.SetControllerReference(&clusterv1.ManagedCluster{
+						ObjectMeta: metav1.ObjectMeta{Name: "test_cluster"},
+					}, work, testscheme)
+					// the manifests are edited after the manifest work is applied
+					work.Spec.Workload.Manifests[0].Raw = []byte(`{"test":"edited"}`)
+					return work
+				}(),
+			},
+			requiredObjs: []runtime.Object{newTestManifestWork(`{"test":"test"}`)},
+			owner: &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test_cluster",
+				},
+			},
+			expectedActions: []ApplyAction{ApplyActionUpdated},
+			validateFunc: func(t *testing.T, clientHolder *ClientHolder, runtimeClient *applyRecordingClient) {
+				work := &workv1.ManifestWork{}
+				if err := runtimeClient.Get(context.TODO(),
+					types.NamespacedName{Namespace: "test_cluster", Name: "test_cluster"}, work); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if string(work.Spec.Workload.Manifests[0].Raw) != `{"test":"test"}` {
+					t.Errorf("expected the edited manifests are reverted, but got %s",
+						string(work.Spec.Workload.Manifests[0].Raw))
+				}
+			},
+		},
+		{
+			name: "skip the owner reference of crds",
+			requiredObjs: []runtime.Object{
+				&crdv1.CustomResourceDefinition{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test_cluster",
+					},
+				},
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test_cluster",
+						Namespace: "test_cluster",
+					},
+				},
+			},
+			owner: &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test_cluster",
+				},
+			},
+			options:         ApplyOptions{SkipOwnerReferenceGroupKinds: []schema.GroupKind{CRDGroupKind}},
+			expectedActions: []ApplyAction{ApplyActionCreated, ApplyActionCreated},
+			validateFunc: func(t *testing.T, clientHolder *ClientHolder, runtimeClient *applyRecordingClient) {
+				crd, err := clientHolder.APIExtensionsClient.ApiextensionsV1().CustomResourceDefinitions().Get(
+					context.TODO(), "test_cluster", metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if len(crd.OwnerReferences) != 0 {
+					t.Errorf("expected no owner reference on the crd, but got %v", crd.OwnerReferences)
+				}
+
+				secret, err := clientHolder.KubeClient.CoreV1().Secrets("test_cluster").Get(
+					context.TODO(), "test_cluster", metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if len(secret.OwnerReferences) != 1 {
+					t.Errorf("expected the owner reference on the secret, but got %v", secret.OwnerReferences)
+				}
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			runtimeClient := &applyRecordingClient{
+				Client: fake.NewClientBuilder().WithScheme(testscheme).WithObjects(c.clientObjs...).Build(),
+			}
+			clientHolder := &ClientHolder{
+				KubeClient:          kubefake.NewSimpleClientset(c.kubeObjs...),
+				APIExtensionsClient: apiextensionsfake.NewSimpleClientset(c.crds...),
+				OperatorClient:      operatorfake.NewSimpleClientset(c.klusterletObjs...),
+				RuntimeClient:       runtimeClient,
+			}
+			results, err := ApplyResourcesWithOptions(clientHolder, eventstesting.NewTestingEventRecorder(t), testscheme,
+				c.owner, c.options, c.requiredObjs...)
+			if err != nil {
+				t.Errorf("unexpect err %v", err)
+			}
+
+			actions := []ApplyAction{}
+			for _, result := range results {
+				actions = append(actions, result.Action)
+			}
+			if !reflect.DeepEqual(actions, c.expectedActions) {
+				t.Errorf("expected actions %v, but got %v", c.expectedActions, actions)
+			}
+
+			if c.validateFunc != nil {
+				c.validateFunc(t, clientHolder, runtimeClient)
+			}
+		})
+	}
+}
+
+func newTestManifestWork(manifest string) *workv1.ManifestWork {
+	return &workv1.ManifestWork{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test_cluster",
+			Namespace: "test_cluster",
+		},
+		Spec: workv1.ManifestWorkSpec{
+			Workload: workv1.ManifestsTemplate{
+				Manifests: []workv1.Manifest{
+					{
+						RawExtension: runtime.RawExtension{Raw: []byte(manifest)},
+					},
+				},
+			},
+		},
+	}
+}
+
+// applyRecordingClient records the server-side apply requests and counts the updates, because the fake client does not support
+// the server-side apply
+type applyRecordingClient struct {
+	client.Client
+	appliedObjects []client.Object
+	fieldManagers  []string
+	updates        int
+}
+
+func (c *applyRecordingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	c.updates++
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *applyRecordingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch,
+	opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+
+	patchOptions := &client.PatchOptions{}
+	patchOptions.ApplyOptions(opts)
+	c.appliedObjects = append(c.appliedObjects, obj)
+	c.fieldManagers = append(c.fieldManagers, patchOptions.FieldManager)
+	return nil
+}
+
+// conflictStatusClient returns a conflict error for the first conflicts status updates
+type conflictStatusClient struct {
+	client.Client
+	conflicts int
+	updates   int
+}
+
+func (c *conflictStatusClient) Status() client.StatusWriter {
+	return &conflictStatusWriter{StatusWriter: c.Client.Status(), client: c}
+}
+
+type conflictStatusWriter struct {
+	client.StatusWriter
+	client *conflictStatusClient
+}
+
+func (w *conflictStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	w.client.updates++
+	if w.client.conflicts > 0 {
+		w.client.conflicts--
+		return errors.NewConflict(schema.GroupResource{Group: clusterv1.GroupName, Resource: "managedclusters"},
+			obj.GetName(), fmt.Errorf("the object has been modified"))
+	}
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
+var tb = `
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: klusterlet
+  namespace: "{{ .KlusterletNamespace }}"
+{{- if .UseImagePullSecret }}
+imagePullSecrets:
+- name: "{{ .ImagePullSecretName }}"
+{{- end}}
+`
+
+func TestAssetFromTemplate(t *testing.T) {
+	cases := []struct {
+		name     string
+		config   interface{}
+		validate func(t *testing.T, raw []byte)
+	}{
+		{
+			name: "without ImagePullSecret",
+			config: struct {
+				KlusterletNamespace string
+				UseImagePullSecret  bool
+				ImagePullSecretName string
+			}{
+				KlusterletNamespace: "test",
+			},
+			validate: func(t *testing.T, raw []byte) {
+				_, _, err := genericCodec.Decode(raw, nil, nil)
+				if err != nil {
+					t.Errorf("unexpect err %v, %v", string(raw), err)
+				}
+			},
+		},
+		{
+			name: "with ImagePullSecret",
+			config: struct {
+				KlusterletNamespace string
+				UseImagePullSecret  bool
+				ImagePullSecretName string
+			}{
+				KlusterletNamespace: "test",
+				UseImagePullSecret:  true,
+				ImagePullSecretName: "test",
+			},
+			validate: func(t *testing.T, raw []byte) {
+				_, _, err := genericCodec.Decode(raw, nil, nil)
+				if err != nil {
+					t.Errorf("unexpect err %v, %v", string(raw), err)
+				}
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.validate(t, MustCreateAssetFromTemplate("test", []byte(tb), c.config))
+		})
+	}
+}
+
+func TestCreateAssetFromTemplate(t *testing.T) {
+	cases := []struct {
+		name      string
+		template  string
+		config    interface{}
+		expectErr bool
+	}{
+		{
+			name:     "render the template",
+			template: "namespace: {{ .KlusterletNamespace }}",
+			config: struct {
+				KlusterletNamespace string
+			}{
+				KlusterletNamespace: "test",
+			},
+		},
+		{
+			name:     "the template references a missing field",
+			template: "namespace: {{ .MissingField }}",
+			config: struct {
+				KlusterletNamespace string
+			}{
+				KlusterletNamespace: "test",
+			},
+			expectErr: true,
+		},
+		{
+			name:     "the template is malformed",
+			template: "namespace: {{ .KlusterletNamespace ",
+			config: struct {
+				KlusterletNamespace string
+			}{
+				KlusterletNamespace: "test",
+			},
+			expectErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := CreateAssetFromTemplate("test", []byte(c.template), c.config)
+			if c.expectErr && err == nil {
+				t.Errorf("expected error, but failed")
+			}
+			if !c.expectErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestImportManagedClusterFromSecret(t *testing.T) {
+	cases := []struct {
+		name              string
+		apiGroupResources []*restmapper.APIGroupResources
+	}{
+		{
+			name: "only have crdv1beta1",
+			apiGroupResources: []*restmapper.APIGroupResources{
+				{
+					Group: metav1.APIGroup{
+						Name: "apiextensions.k8s.io",
+						Versions: []metav1.GroupVersionForDiscovery{
+							{Version: "v1beta1"},
+						},
+						PreferredVersion: metav1.GroupVersionForDiscovery{Version: "v1beta1"},
+					},
+					VersionedResources: map[string][]metav1.APIResource{
+						"v1beta1": {
+							{Name: "customresourcedefinitions", Namespaced: false, Kind: "CustomResourceDefinition"},
+						},
 					},
 				},
 			},
-			requiredObjs: []runtime.Object{
-				&corev1.ServiceAccount{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      "test_cluster",
-						Namespace: "test_cluster",
-					},
-				},
-				&rbacv1.ClusterRole{
-					ObjectMeta: metav1.ObjectMeta{
-						Name: "test_cluster",
-					},
-					Rules: []rbacv1.PolicyRule{
-						{
-							Resources: []string{"test"},
+		},
+		{
+			name: "have crdv1beta1 and crdv1",
+			apiGroupResources: []*restmapper.APIGroupResources{
+				{
+					Group: metav1.APIGroup{
+						Name: "apiextensions.k8s.io",
+						Versions: []metav1.GroupVersionForDiscovery{
+							{Version: "v1beta1"},
 						},
+						PreferredVersion: metav1.GroupVersionForDiscovery{Version: "v1beta1"},
 					},
-				},
-				&rbacv1.ClusterRoleBinding{
-					ObjectMeta: metav1.ObjectMeta{
-						Name: "test_cluster",
-					},
-					Subjects: []rbacv1.Subject{
-						{
-							Name: "test",
+					VersionedResources: map[string][]metav1.APIResource{
+						"v1beta1": {
+							{Name: "customresourcedefinitions", Namespaced: false, Kind: "CustomResourceDefinition"},
 						},
 					},
-					RoleRef: rbacv1.RoleRef{
-						Name: "test",
-					},
-				},
-				&corev1.Secret{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      "test_cluster",
-						Namespace: "test_cluster",
-					},
-					Data: map[string][]byte{
-						"test": []byte("test"),
-					},
-				},
-				&appsv1.Deployment{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      "test_cluster",
-						Namespace: "test_cluster",
-					},
-					Spec: appsv1.DeploymentSpec{
-						Replicas: &replicas,
-					},
-				},
-				&operatorv1.Klusterlet{
-					ObjectMeta: metav1.ObjectMeta{
-						Name: "test_cluster",
-					},
-					Spec: operatorv1.KlusterletSpec{
-						Namespace: "test",
-					},
 				},
-				&crdv1beta1.CustomResourceDefinition{
-					ObjectMeta: metav1.ObjectMeta{
-						Name: "test_cluster",
+				{
+					Group: metav1.APIGroup{
+						Name: "apiextensions.k8s.io",
+						Versions: []metav1.GroupVersionForDiscovery{
+							{Version: "v1"},
+						},
+						PreferredVersion: metav1.GroupVersionForDiscovery{Version: "v1"},
 					},
-					Spec: crdv1beta1.CustomResourceDefinitionSpec{
-						Version: "test",
+					VersionedResources: map[string][]metav1.APIResource{
+						"v1": {
+							{Name: "customresourcedefinitions", Namespaced: false, Kind: "CustomResourceDefinition"},
+						},
 					},
 				},
-				&crdv1.CustomResourceDefinition{
-					ObjectMeta: metav1.ObjectMeta{
-						Name: "test_cluster",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mapper := restmapper.NewDiscoveryRESTMapper(c.apiGroupResources)
+			fakeRecorder := eventstesting.NewTestingEventRecorder(t)
+			importSecret := testinghelpers.GetImportSecret("test_cluster")
+			clientHolder := &ClientHolder{
+				KubeClient:          kubefake.NewSimpleClientset(),
+				APIExtensionsClient: apiextensionsfake.NewSimpleClientset(),
+				OperatorClient:      operatorfake.NewSimpleClientset(),
+				RuntimeClient:       fake.NewClientBuilder().WithScheme(testscheme).Build(),
+			}
+			err := ImportManagedClusterFromSecret(clientHolder, mapper, fakeRecorder, importSecret)
+			if err != nil {
+				t.Errorf("unexpect err %v", err)
+			}
+		})
+	}
+}
+
+func TestImportManagedClusterFromSecretWithScope(t *testing.T) {
+	cases := []struct {
+		name          string
+		scope         ImportScope
+		expectedCRDs  bool
+		expectedAgent bool
+		expectedErr   bool
+	}{
+		{
+			name:          "all",
+			scope:         ImportScopeAll,
+			expectedCRDs:  true,
+			expectedAgent: true,
+		},
+		{
+			name:         "crds only",
+			scope:        ImportScopeCRDsOnly,
+			expectedCRDs: true,
+		},
+		{
+			name:          "agent only",
+			scope:         ImportScopeAgentOnly,
+			expectedAgent: true,
+		},
+		{
+			name:        "unsupported scope",
+			scope:       ImportScope("Unknown"),
+			expectedErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mapper := restmapper.NewDiscoveryRESTMapper([]*restmapper.APIGroupResources{
+				{
+					Group: metav1.APIGroup{
+						Name: "apiextensions.k8s.io",
+						Versions: []metav1.GroupVersionForDiscovery{
+							{Version: "v1"},
+						},
+						PreferredVersion: metav1.GroupVersionForDiscovery{Version: "v1"},
 					},
-					Spec: crdv1.CustomResourceDefinitionSpec{
-						PreserveUnknownFields: true,
+					VersionedResources: map[string][]metav1.APIResource{
+						"v1": {
+							{Name: "customresourcedefinitions", Namespaced: false, Kind: "CustomResourceDefinition"},
+						},
 					},
 				},
-				&workv1.ManifestWork{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      "test_cluster",
-						Namespace: "test_cluster",
-					},
-					Spec: workv1.ManifestWorkSpec{
-						Workload: workv1.ManifestsTemplate{
-							Manifests: []workv1.Manifest{
-								{
-									RawExtension: runtime.RawExtension{Raw: []byte("{\"test\":\"test1\"}")},
-								},
-							},
+			})
+			clientHolder := &ClientHolder{
+				KubeClient:          kubefake.NewSimpleClientset(),
+				APIExtensionsClient: apiextensionsfake.NewSimpleClientset(),
+				OperatorClient:      operatorfake.NewSimpleClientset(),
+				RuntimeClient:       fake.NewClientBuilder().WithScheme(testscheme).Build(),
+			}
+			err := ImportManagedClusterFromSecretWithScope(clientHolder, mapper, eventstesting.NewTestingEventRecorder(t),
+				testinghelpers.GetImportSecret("test_cluster"), c.scope)
+			if (err != nil) != c.expectedErr {
+				t.Errorf("expected error %v, but got %v", c.expectedErr, err)
+			}
+
+			crds, err := clientHolder.APIExtensionsClient.ApiextensionsV1().CustomResourceDefinitions().List(
+				context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if (len(crds.Items) != 0) != c.expectedCRDs {
+				t.Errorf("expected crds applied %v, but got %d crds", c.expectedCRDs, len(crds.Items))
+			}
+
+			namespaces, err := clientHolder.KubeClient.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			klusterlets, err := clientHolder.OperatorClient.OperatorV1().Klusterlets().List(
+				context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			agentApplied := len(namespaces.Items) != 0 || len(klusterlets.Items) != 0
+			if agentApplied != c.expectedAgent {
+				t.Errorf("expected agent applied %v, but got %d namespaces and %d klusterlets",
+					c.expectedAgent, len(namespaces.Items), len(klusterlets.Items))
+			}
+		})
+	}
+}
+
+func TestImportManagedClusterFromSecretWithNewerCRDs(t *testing.T) {
+	crdName := "klusterlets.operator.open-cluster-management.io"
+	newCRD := func(versions ...string) *crdv1.CustomResourceDefinition {
+		crd := &crdv1.CustomResourceDefinition{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "apiextensions.k8s.io/v1", Kind: "CustomResourceDefinition"},
+			ObjectMeta: metav1.ObjectMeta{Name: crdName},
+		}
+		for _, v := range versions {
+			crd.Spec.Versions = append(crd.Spec.Versions, crdv1.CustomResourceDefinitionVersion{
+				Name: v, Served: true, Storage: v == versions[0]})
+		}
+		return crd
+	}
+
+	cases := []struct {
+		name             string
+		existingCRDs     []runtime.Object
+		expectedSkipped  []string
+		expectedVersions []string
+	}{
+		{
+			name:             "no crd on the managed cluster",
+			existingCRDs:     []runtime.Object{},
+			expectedVersions: []string{"v1"},
+		},
+		{
+			name:             "older crd on the managed cluster",
+			existingCRDs:     []runtime.Object{newCRD("v1beta1", "v1alpha1")},
+			expectedVersions: []string{"v1"},
+		},
+		{
+			name:             "same crd on the managed cluster",
+			existingCRDs:     []runtime.Object{newCRD("v1")},
+			expectedVersions: []string{"v1"},
+		},
+		{
+			name:             "newer crd on the managed cluster",
+			existingCRDs:     []runtime.Object{newCRD("v2", "v1")},
+			expectedSkipped:  []string{crdName},
+			expectedVersions: []string{"v2", "v1"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mapper := restmapper.NewDiscoveryRESTMapper([]*restmapper.APIGroupResources{
+				{
+					Group: metav1.APIGroup{
+						Name: "apiextensions.k8s.io",
+						Versions: []metav1.GroupVersionForDiscovery{
+							{Version: "v1"},
 						},
+						PreferredVersion: metav1.GroupVersionForDiscovery{Version: "v1"},
 					},
-				},
-				&workv1.ManifestWork{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      "label_test_cluster",
-						Namespace: "label_test_cluster",
-						Labels: map[string]string{
-							"test": "test",
+					VersionedResources: map[string][]metav1.APIResource{
+						"v1": {
+							{Name: "customresourcedefinitions", Namespaced: false, Kind: "CustomResourceDefinition"},
 						},
 					},
 				},
-			},
-			owner: &clusterv1.ManagedCluster{
+			})
+			clientHolder := &ClientHolder{
+				KubeClient:          kubefake.NewSimpleClientset(),
+				APIExtensionsClient: apiextensionsfake.NewSimpleClientset(c.existingCRDs...),
+				OperatorClient:      operatorfake.NewSimpleClientset(),
+				RuntimeClient:       fake.NewClientBuilder().WithScheme(testscheme).Build(),
+			}
+
+			crdsYaml, err := yaml.Marshal(newCRD("v1"))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			importSecret := testinghelpers.GetImportSecret("test")
+			importSecret.Data[constants.ImportSecretCRDSV1YamlKey] = crdsYaml
+
+			result, err := ImportManagedClusterFromSecretWithResult(clientHolder, mapper,
+				eventstesting.NewTestingEventRecorder(t), importSecret, ImportScopeCRDsOnly)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(result.SkippedCRDs, c.expectedSkipped) {
+				t.Errorf("expected skipped crds %v, but got %v", c.expectedSkipped, result.SkippedCRDs)
+			}
+
+			crd, err := clientHolder.APIExtensionsClient.ApiextensionsV1().CustomResourceDefinitions().Get(
+				context.TODO(), crdName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if versions := crdV1Versions(crd); !reflect.DeepEqual(versions, c.expectedVersions) {
+				t.Errorf("expected crd versions %v, but got %v", c.expectedVersions, versions)
+			}
+
+			condition := KlusterletCRDsSkippedCondition(result)
+			if expectedStatus := len(c.expectedSkipped) != 0; (condition.Status == metav1.ConditionTrue) != expectedStatus {
+				t.Errorf("expected the crds skipped condition %v, but got %v", expectedStatus, condition)
+			}
+		})
+	}
+}
+
+func TestBuildClientConfigFromSecretTimeout(t *testing.T) {
+	t.Setenv(importTimeoutEnvVarName, "100ms")
+
+	config, err := buildClientConfigFromSecret(&corev1.Secret{
+		Data: map[string][]byte{
+			"token":  []byte("test"),
+			"server": []byte("https://127.0.0.1:6443"),
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.Timeout != 100*time.Millisecond {
+		t.Errorf("expected the timeout of the client config is 100ms, but got %v", config.Timeout)
+	}
+}
+
+func newImportSecretWithBootstrapServer(server string) *corev1.Secret {
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: hub
+  cluster:
+    server: %s
+contexts:
+- name: bootstrap
+  context:
+    cluster: hub
+    user: bootstrap
+current-context: bootstrap
+users:
+- name: bootstrap
+  user:
+    token: test
+`, server)
+	bootstrapSecret := fmt.Sprintf(`apiVersion: v1
+kind: Secret
+metadata:
+  name: bootstrap-hub-kubeconfig
+  namespace: open-cluster-management-agent
+type: Opaque
+data:
+  kubeconfig: %s
+`, base64.StdEncoding.EncodeToString([]byte(kubeconfig)))
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-import", Namespace: "test"},
+		Data: map[string][]byte{
+			constants.ImportSecretImportYamlKey: []byte(strings.Join([]string{
+				"",
+				"apiVersion: v1\nkind: Namespace\nmetadata:\n  name: open-cluster-management-agent",
+				bootstrapSecret,
+			}, constants.YamlSperator)),
+		},
+	}
+}
+
+func TestValidateBootstrapHubServer(t *testing.T) {
+	cases := []struct {
+		name            string
+		importSecret    *corev1.Secret
+		hubServer       string
+		expectedErr     error
+		expectedErrText string
+	}{
+		{
+			name:         "matching bootstrap server",
+			importSecret: newImportSecretWithBootstrapServer("https://api.hub.example.com:6443"),
+			hubServer:    "https://api.hub.example.com:6443",
+		},
+		{
+			name:         "matching bootstrap server with the default port",
+			importSecret: newImportSecretWithBootstrapServer("https://API.hub.example.com"),
+			hubServer:    "https://api.hub.example.com:443/",
+		},
+		{
+			name:         "matching bootstrap server without the scheme",
+			importSecret: newImportSecretWithBootstrapServer("https://api.hub.example.com"),
+			hubServer:    "api.hub.example.com:443",
+		},
+		{
+			name:            "mismatching bootstrap server",
+			importSecret:    newImportSecretWithBootstrapServer("https://api.old-hub.example.com:6443"),
+			hubServer:       "https://api.hub.example.com:6443",
+			expectedErr:     ErrHubServerMismatch,
+			expectedErrText: "the bootstrap hub server of the import secret test/test-import is https://api.old-hub.example.com:6443",
+		},
+		{
+			name:         "no bootstrap hub kubeconfig",
+			importSecret: testinghelpers.GetImportSecret("test"),
+			hubServer:    "https://api.hub.example.com:6443",
+			expectedErr:  ErrInvalidImportSecret,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := ValidateBootstrapHubServer(c.importSecret, c.hubServer)
+			if !goerrors.Is(err, c.expectedErr) {
+				t.Errorf("expected error %v, but got %v", c.expectedErr, err)
+			}
+			if len(c.expectedErrText) != 0 && (err == nil || !strings.Contains(err.Error(), c.expectedErrText)) {
+				t.Errorf("expected error %q, but got %v", c.expectedErrText, err)
+			}
+		})
+	}
+}
+
+func TestValidateNotManagedByAnotherHub(t *testing.T) {
+	existingBootstrapSecret := func(server string) *corev1.Secret {
+		secret, err := getBootstrapHubKubeconfigSecret(newImportSecretWithBootstrapServer(server))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return secret
+	}
+
+	cases := []struct {
+		name        string
+		existing    []runtime.Object
+		expectedErr error
+	}{
+		{
+			name: "the managed cluster is not managed",
+		},
+		{
+			name:     "the managed cluster is managed by the same hub",
+			existing: []runtime.Object{existingBootstrapSecret("https://API.hub.example.com:443")},
+		},
+		{
+			name:        "the managed cluster is managed by another hub",
+			existing:    []runtime.Object{existingBootstrapSecret("https://api.another-hub.example.com:6443")},
+			expectedErr: ErrManagedByAnotherHub,
+		},
+		{
+			name: "the existing bootstrap hub kubeconfig is invalid",
+			existing: []runtime.Object{&corev1.Secret{
 				ObjectMeta: metav1.ObjectMeta{
-					Name: "test_cluster",
+					Name:      "bootstrap-hub-kubeconfig",
+					Namespace: "open-cluster-management-agent",
 				},
-			},
+				Data: map[string][]byte{"kubeconfig": []byte("invalid")},
+			}},
 		},
 	}
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			clientHolder := &ClientHolder{
-				KubeClient:          kubefake.NewSimpleClientset(c.kubeObjs...),
-				APIExtensionsClient: apiextensionsfake.NewSimpleClientset(c.crds...),
-				OperatorClient:      operatorfake.NewSimpleClientset(c.klusterletObjs...),
-				RuntimeClient:       fake.NewClientBuilder().WithScheme(testscheme).WithObjects(c.clientObjs...).Build(),
+			kubeClient := kubefake.NewSimpleClientset(c.existing...)
+			err := ValidateNotManagedByAnotherHub(context.TODO(), kubeClient,
+				newImportSecretWithBootstrapServer("https://api.hub.example.com"))
+			if !goerrors.Is(err, c.expectedErr) {
+				t.Errorf("expected error %v, but got %v", c.expectedErr, err)
 			}
-			err := ApplyResources(clientHolder, eventstesting.NewTestingEventRecorder(t), testscheme, c.owner, c.requiredObjs...)
-			if err != nil {
-				t.Errorf("unexpect err %v", err)
+		})
+	}
+}
+
+func TestGetImportTimeout(t *testing.T) {
+	cases := []struct {
+		name     string
+		value    string
+		expected time.Duration
+	}{
+		{
+			name:     "env is not set",
+			expected: defaultImportTimeout,
+		},
+		{
+			name:     "valid timeout",
+			value:    "5m",
+			expected: 5 * time.Minute,
+		},
+		{
+			name:     "invalid timeout",
+			value:    "abc",
+			expected: defaultImportTimeout,
+		},
+		{
+			name:     "non-positive timeout",
+			value:    "-1s",
+			expected: defaultImportTimeout,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			os.Setenv(importTimeoutEnvVarName, c.value)
+			defer os.Unsetenv(importTimeoutEnvVarName)
+
+			if timeout := GetImportTimeout(); timeout != c.expected {
+				t.Errorf("expected %v, but got %v", c.expected, timeout)
 			}
 		})
 	}
 }
 
-var tb = `
-apiVersion: v1
-kind: ServiceAccount
-metadata:
-  name: klusterlet
-  namespace: "{{ .KlusterletNamespace }}"
-{{- if .UseImagePullSecret }}
-imagePullSecrets:
-- name: "{{ .ImagePullSecretName }}"
-{{- end}}
-`
+func TestIsPostImportHookWebhookAllowed(t *testing.T) {
+	cases := []struct {
+		name       string
+		value      string
+		webhookURL string
+		expected   bool
+	}{
+		{
+			name:       "env is not set",
+			webhookURL: "https://hook.example.com",
+			expected:   false,
+		},
+		{
+			name:       "the webhook is configured",
+			value:      "https://hook.example.com/a, https://hook.example.com/b",
+			webhookURL: "https://hook.example.com/b",
+			expected:   true,
+		},
+		{
+			name:       "the webhook is not configured",
+			value:      "https://hook.example.com/a",
+			webhookURL: "https://attacker.example.com/a",
+			expected:   false,
+		},
+		{
+			name:       "empty webhook",
+			value:      "https://hook.example.com/a,",
+			webhookURL: "",
+			expected:   false,
+		},
+	}
 
-func TestAssetFromTemplate(t *testing.T) {
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			os.Setenv(postImportHookWebhookURLsEnvVarName, c.value)
+			defer os.Unsetenv(postImportHookWebhookURLsEnvVarName)
+
+			if allowed := IsPostImportHookWebhookAllowed(c.webhookURL); allowed != c.expected {
+				t.Errorf("expected %v, but got %v", c.expected, allowed)
+			}
+		})
+	}
+}
+
+func TestIsPostImportHookConfigMapAllowed(t *testing.T) {
 	cases := []struct {
-		name     string
-		config   interface{}
-		validate func(t *testing.T, raw []byte)
+		name          string
+		value         string
+		configMapName string
+		expected      bool
 	}{
 		{
-			name: "without ImagePullSecret",
-			config: struct {
-				KlusterletNamespace string
-				UseImagePullSecret  bool
-				ImagePullSecretName string
-			}{
-				KlusterletNamespace: "test",
-			},
-			validate: func(t *testing.T, raw []byte) {
-				_, _, err := genericCodec.Decode(raw, nil, nil)
-				if err != nil {
-					t.Errorf("unexpect err %v, %v", string(raw), err)
-				}
-			},
+			name:          "env is not set",
+			configMapName: "bootstrap",
+			expected:      false,
 		},
 		{
-			name: "with ImagePullSecret",
-			config: struct {
-				KlusterletNamespace string
-				UseImagePullSecret  bool
-				ImagePullSecretName string
-			}{
-				KlusterletNamespace: "test",
-				UseImagePullSecret:  true,
-				ImagePullSecretName: "test",
-			},
-			validate: func(t *testing.T, raw []byte) {
-				_, _, err := genericCodec.Decode(raw, nil, nil)
-				if err != nil {
-					t.Errorf("unexpect err %v, %v", string(raw), err)
-				}
-			},
+			name:          "the configmap is configured",
+			value:         "bootstrap, policies",
+			configMapName: "policies",
+			expected:      true,
+		},
+		{
+			name:          "the configmap is not configured",
+			value:         "bootstrap",
+			configMapName: "cluster-admin",
+			expected:      false,
+		},
+		{
+			name:          "empty configmap",
+			value:         "bootstrap,",
+			configMapName: "",
+			expected:      false,
 		},
 	}
+
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			c.validate(t, MustCreateAssetFromTemplate("test", []byte(tb), c.config))
+			os.Setenv(postImportHookConfigMapsEnvVarName, c.value)
+			defer os.Unsetenv(postImportHookConfigMapsEnvVarName)
+
+			if allowed := IsPostImportHookConfigMapAllowed(c.configMapName); allowed != c.expected {
+				t.Errorf("expected %v, but got %v", c.expected, allowed)
+			}
 		})
 	}
 }
 
-func TestImportManagedClusterFromSecret(t *testing.T) {
+func TestGetManifestWorkResyncPeriod(t *testing.T) {
 	cases := []struct {
-		name              string
-		apiGroupResources []*restmapper.APIGroupResources
+		name     string
+		value    string
+		expected time.Duration
 	}{
 		{
-			name: "only have crdv1beta1",
-			apiGroupResources: []*restmapper.APIGroupResources{
-				{
-					Group: metav1.APIGroup{
-						Name: "apiextensions.k8s.io",
-						Versions: []metav1.GroupVersionForDiscovery{
-							{Version: "v1beta1"},
-						},
-						PreferredVersion: metav1.GroupVersionForDiscovery{Version: "v1beta1"},
-					},
-					VersionedResources: map[string][]metav1.APIResource{
-						"v1beta1": {
-							{Name: "customresourcedefinitions", Namespaced: false, Kind: "CustomResourceDefinition"},
-						},
-					},
-				},
+			name:     "env is not set",
+			expected: defaultManifestWorkResyncPeriod,
+		},
+		{
+			name:     "valid period",
+			value:    "1h",
+			expected: time.Hour,
+		},
+		{
+			name:     "resync is disabled",
+			value:    "0",
+			expected: 0,
+		},
+		{
+			name:     "invalid period",
+			value:    "abc",
+			expected: defaultManifestWorkResyncPeriod,
+		},
+		{
+			name:     "negative period",
+			value:    "-1m",
+			expected: defaultManifestWorkResyncPeriod,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			os.Setenv(manifestWorkResyncPeriodEnvVarName, c.value)
+			defer os.Unsetenv(manifestWorkResyncPeriodEnvVarName)
+
+			if period := GetManifestWorkResyncPeriod(); period != c.expected {
+				t.Errorf("expected %v, but got %v", c.expected, period)
+			}
+		})
+	}
+}
+
+func TestImportManagedClusterFromSecretAdopt(t *testing.T) {
+	newKlusterlet := func(clusterName string) *operatorv1.Klusterlet {
+		return &operatorv1.Klusterlet{
+			ObjectMeta: metav1.ObjectMeta{Name: "klusterlet"},
+			Spec: operatorv1.KlusterletSpec{
+				ClusterName: clusterName,
+				Namespace:   "open-cluster-management-agent-previous",
 			},
+		}
+	}
+
+	cases := []struct {
+		name                      string
+		klusterlets               []runtime.Object
+		expectedAdopted           bool
+		expectedBootstrapSecretNS string
+	}{
+		{
+			name:                      "no existing klusterlet",
+			klusterlets:               []runtime.Object{},
+			expectedBootstrapSecretNS: "open-cluster-management-agent",
 		},
 		{
-			name: "have crdv1beta1 and crdv1",
-			apiGroupResources: []*restmapper.APIGroupResources{
-				{
-					Group: metav1.APIGroup{
-						Name: "apiextensions.k8s.io",
-						Versions: []metav1.GroupVersionForDiscovery{
-							{Version: "v1beta1"},
-						},
-						PreferredVersion: metav1.GroupVersionForDiscovery{Version: "v1beta1"},
-					},
-					VersionedResources: map[string][]metav1.APIResource{
-						"v1beta1": {
-							{Name: "customresourcedefinitions", Namespaced: false, Kind: "CustomResourceDefinition"},
-						},
-					},
-				},
+			name:                      "existing klusterlet",
+			klusterlets:               []runtime.Object{newKlusterlet("test")},
+			expectedAdopted:           true,
+			expectedBootstrapSecretNS: "open-cluster-management-agent-previous",
+		},
+		{
+			name:                      "existing klusterlet of another cluster name",
+			klusterlets:               []runtime.Object{newKlusterlet("another")},
+			expectedBootstrapSecretNS: "open-cluster-management-agent",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mapper := restmapper.NewDiscoveryRESTMapper([]*restmapper.APIGroupResources{
 				{
 					Group: metav1.APIGroup{
 						Name: "apiextensions.k8s.io",
@@ -700,23 +2851,132 @@ func TestImportManagedClusterFromSecret(t *testing.T) {
 						},
 					},
 				},
-			},
-		},
-	}
-	for _, c := range cases {
-		t.Run(c.name, func(t *testing.T) {
-			mapper := restmapper.NewDiscoveryRESTMapper(c.apiGroupResources)
-			fakeRecorder := eventstesting.NewTestingEventRecorder(t)
-			importSecret := testinghelpers.GetImportSecret("test_cluster")
+			})
 			clientHolder := &ClientHolder{
 				KubeClient:          kubefake.NewSimpleClientset(),
 				APIExtensionsClient: apiextensionsfake.NewSimpleClientset(),
-				OperatorClient:      operatorfake.NewSimpleClientset(),
+				OperatorClient:      operatorfake.NewSimpleClientset(c.klusterlets...),
 				RuntimeClient:       fake.NewClientBuilder().WithScheme(testscheme).Build(),
 			}
-			err := ImportManagedClusterFromSecret(clientHolder, mapper, fakeRecorder, importSecret)
-			if err != nil {
-				t.Errorf("unexpect err %v", err)
+			cluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Annotations: map[string]string{constants.AdoptKlusterletAnnotation: "true"},
+				},
+			}
+			err := ImportManagedClusterFromSecretWithScope(clientHolder, mapper, eventstesting.NewTestingEventRecorder(t),
+				testinghelpers.GetImportSecret("test"), GetImportScope(cluster))
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			bootstrapSecret, err := clientHolder.KubeClient.CoreV1().Secrets(c.expectedBootstrapSecretNS).Get(
+				context.TODO(), "bootstrap-hub-kubeconfig", metav1.GetOptions{})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if bootstrapSecret != nil && len(bootstrapSecret.Data["kubeconfig"]) == 0 {
+				t.Errorf("expected the bootstrap hub kubeconfig, but failed")
+			}
+
+			// the whole import.yaml is applied if the klusterlet is not adopted
+			crds, err := clientHolder.APIExtensionsClient.ApiextensionsV1().CustomResourceDefinitions().List(
+				context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			namespaces, err := clientHolder.KubeClient.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			fullImported := len(crds.Items) != 0 && len(namespaces.Items) != 0
+			if fullImported == c.expectedAdopted {
+				t.Errorf("expected adopted %v, but got %d crds and %d namespaces",
+					c.expectedAdopted, len(crds.Items), len(namespaces.Items))
+			}
+
+			klusterlet, err := clientHolder.OperatorClient.OperatorV1().Klusterlets().Get(
+				context.TODO(), "klusterlet", metav1.GetOptions{})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if klusterlet != nil && klusterlet.Spec.ClusterName != "test" {
+				t.Errorf("expected the klusterlet of the cluster test, but got %s", klusterlet.Spec.ClusterName)
+			}
+		})
+	}
+}
+
+func TestGetImportScope(t *testing.T) {
+	cases := []struct {
+		name          string
+		annotations   map[string]string
+		expectedScope ImportScope
+	}{
+		{
+			name:          "no annotation",
+			expectedScope: ImportScopeAll,
+		},
+		{
+			name:          "adopt",
+			annotations:   map[string]string{constants.AdoptKlusterletAnnotation: "true"},
+			expectedScope: ImportScopeAdopt,
+		},
+		{
+			name:          "invalid annotation",
+			annotations:   map[string]string{constants.AdoptKlusterletAnnotation: "yes"},
+			expectedScope: ImportScopeAll,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Annotations: c.annotations},
+			}
+			if scope := GetImportScope(cluster); scope != c.expectedScope {
+				t.Errorf("expected scope %s, but got %s", c.expectedScope, scope)
+			}
+		})
+	}
+}
+
+func TestGetPriorityClassName(t *testing.T) {
+	cases := []struct {
+		name                      string
+		annotations               map[string]string
+		expectedPriorityClassName string
+		expectedErr               bool
+	}{
+		{
+			name: "no priority class annotation",
+		},
+		{
+			name:                      "priority class annotation",
+			annotations:               map[string]string{priorityClassAnnotation: "klusterlet-critical"},
+			expectedPriorityClassName: "klusterlet-critical",
+		},
+		{
+			name:        "invalid priority class name",
+			annotations: map[string]string{priorityClassAnnotation: "Klusterlet_Critical"},
+			expectedErr: true,
+		},
+		{
+			name:        "empty priority class name",
+			annotations: map[string]string{priorityClassAnnotation: ""},
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			priorityClassName, err := GetPriorityClassName(&clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Annotations: c.annotations},
+			})
+			if (err != nil) != c.expectedErr {
+				t.Errorf("expected error %v, but got %v", c.expectedErr, err)
+			}
+			if priorityClassName != c.expectedPriorityClassName {
+				t.Errorf("expected priority class %q, but got %q", c.expectedPriorityClassName, priorityClassName)
 			}
 		})
 	}
@@ -817,11 +3077,328 @@ func TestGetNodeSelector(t *testing.T) {
 	}
 }
 
-func TestGetTolerations(t *testing.T) {
+func TestGetTolerationsExceedMaximum(t *testing.T) {
+	toleration := "{\"key\":\"foo\",\"operator\":\"Exists\",\"effect\":\"NoSchedule\"}"
+	tolerations := func(count int) string {
+		items := []string{}
+		for i := 0; i < count; i++ {
+			items = append(items, toleration)
+		}
+		return fmt.Sprintf("[%s]", strings.Join(items, ","))
+	}
+
 	cases := []struct {
 		name           string
-		managedCluster *clusterv1.ManagedCluster
+		maxTolerations string
+		tolerations    string
 		expectedErr    string
+	}{
+		{
+			name:        "the tolerations reach the default maximum",
+			tolerations: tolerations(10),
+		},
+		{
+			name:        "the tolerations exceed the default maximum",
+			tolerations: tolerations(11),
+			expectedErr: "too many tolerations in annotation of cluster test_cluster",
+		},
+		{
+			name:           "the tolerations exceed the configured maximum",
+			maxTolerations: "2",
+			tolerations:    tolerations(3),
+			expectedErr:    "too many tolerations in annotation of cluster test_cluster",
+		},
+		{
+			name:           "invalid configured maximum",
+			maxTolerations: "invalid",
+			tolerations:    tolerations(3),
+		},
+		{
+			name:           "zero configured maximum",
+			maxTolerations: "0",
+			tolerations:    tolerations(3),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if len(c.maxTolerations) > 0 {
+				os.Setenv(maxTolerationsEnvVarName, c.maxTolerations)
+				defer os.Unsetenv(maxTolerationsEnvVarName)
+			}
+
+			_, err := GetTolerations(&clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test_cluster",
+					Annotations: map[string]string{
+						"open-cluster-management/tolerations": c.tolerations,
+					},
+				},
+			})
+			switch {
+			case len(c.expectedErr) == 0:
+				if err != nil {
+					t.Errorf("unexpect err: %v", err)
+				}
+			case err == nil:
+				t.Errorf("expect err %s, but failed", c.expectedErr)
+			case err.Error() != c.expectedErr:
+				t.Errorf("expect %v, but %v", c.expectedErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestGetTolerationsWithDefaults(t *testing.T) {
+	infraToleration := corev1.Toleration{
+		Effect:   corev1.TaintEffectNoSchedule,
+		Key:      "node-role.kubernetes.io/infra",
+		Operator: corev1.TolerationOpExists,
+	}
+	fooToleration := corev1.Toleration{
+		Effect:   corev1.TaintEffectNoSchedule,
+		Key:      "foo",
+		Operator: corev1.TolerationOpExists,
+	}
+	barToleration := corev1.Toleration{
+		Effect:   corev1.TaintEffectNoSchedule,
+		Key:      "bar",
+		Operator: corev1.TolerationOpEqual,
+		Value:    "test",
+	}
+	defaultNoExecuteTolerations := GetDefaultNoExecuteTolerations(defaultNoExecuteTolerationSeconds)
+	unreachableToleration := corev1.Toleration{
+		Effect:   corev1.TaintEffectNoExecute,
+		Key:      corev1.TaintNodeUnreachable,
+		Operator: corev1.TolerationOpExists,
+	}
+
+	cases := []struct {
+		name                       string
+		defaultTolerations         string
+		noExecuteTolerationSeconds string
+		annotations                map[string]string
+		expectedTolerations        []corev1.Toleration
+	}{
+		{
+			name: "annotation only",
+			annotations: map[string]string{
+				"open-cluster-management/tolerations": "[{\"key\":\"foo\",\"operator\":\"Exists\",\"effect\":\"NoSchedule\"}]",
+			},
+			expectedTolerations: append([]corev1.Toleration{fooToleration}, defaultNoExecuteTolerations...),
+		},
+		{
+			name:                "default only",
+			defaultTolerations:  "[{\"key\":\"bar\",\"operator\":\"Equal\",\"value\":\"test\",\"effect\":\"NoSchedule\"}]",
+			annotations:         map[string]string{"open-cluster-management/tolerations": "[]"},
+			expectedTolerations: append([]corev1.Toleration{barToleration}, defaultNoExecuteTolerations...),
+		},
+		{
+			name:               "combined",
+			defaultTolerations: "[{\"key\":\"bar\",\"operator\":\"Equal\",\"value\":\"test\",\"effect\":\"NoSchedule\"}]",
+			annotations: map[string]string{
+				"open-cluster-management/tolerations": "[{\"key\":\"foo\",\"operator\":\"Exists\",\"effect\":\"NoSchedule\"}]",
+			},
+			expectedTolerations: append([]corev1.Toleration{fooToleration, barToleration}, defaultNoExecuteTolerations...),
+		},
+		{
+			name: "dedup",
+			defaultTolerations: "[{\"key\":\"node-role.kubernetes.io/infra\",\"operator\":\"Exists\",\"effect\":\"NoSchedule\"}," +
+				"{\"key\":\"bar\",\"operator\":\"Equal\",\"value\":\"test\",\"effect\":\"NoSchedule\"}]",
+			expectedTolerations: append([]corev1.Toleration{infraToleration, barToleration}, defaultNoExecuteTolerations...),
+		},
+		{
+			name:                       "configured NoExecute toleration seconds",
+			noExecuteTolerationSeconds: "60",
+			annotations:                map[string]string{"open-cluster-management/tolerations": "[]"},
+			expectedTolerations:        GetDefaultNoExecuteTolerations(60),
+		},
+		{
+			name: "NoExecute toleration of the managed cluster is preserved",
+			annotations: map[string]string{
+				"open-cluster-management/tolerations": "[{\"key\":\"node.kubernetes.io/unreachable\",\"operator\":\"Exists\",\"effect\":\"NoExecute\"}]",
+			},
+			expectedTolerations: []corev1.Toleration{unreachableToleration, defaultNoExecuteTolerations[1]},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if len(c.defaultTolerations) > 0 {
+				os.Setenv(defaultTolerationsEnvVarName, c.defaultTolerations)
+				defer os.Unsetenv(defaultTolerationsEnvVarName)
+			}
+			if len(c.noExecuteTolerationSeconds) > 0 {
+				os.Setenv(noExecuteTolerationSecondsEnvVarName, c.noExecuteTolerationSeconds)
+				defer os.Unsetenv(noExecuteTolerationSecondsEnvVarName)
+			}
+
+			tolerations, err := GetTolerationsWithDefaults(&clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test_cluster",
+					Annotations: c.annotations,
+				},
+			})
+			if err != nil {
+				t.Errorf("unexpect err: %v", err)
+			}
+			if !reflect.DeepEqual(tolerations, c.expectedTolerations) {
+				t.Errorf("expected tolerations %v, but got %v", c.expectedTolerations, tolerations)
+			}
+		})
+	}
+}
+
+func TestValidateNodeSelectorWellKnownLabels(t *testing.T) {
+	cases := []struct {
+		name             string
+		nodeSelector     map[string]string
+		expectedWarnings []string
+	}{
+		{
+			name:         "no nodeSelector",
+			nodeSelector: map[string]string{},
+		},
+		{
+			name: "valid os and arch",
+			nodeSelector: map[string]string{
+				"kubernetes.io/os":   "linux",
+				"kubernetes.io/arch": "arm64",
+			},
+		},
+		{
+			name: "well-known labels and custom labels",
+			nodeSelector: map[string]string{
+				"kubernetes.io/hostname":         "node1",
+				"topology.kubernetes.io/zone":    "us-east-1a",
+				"node-role.kubernetes.io/infra":  "",
+				"example.com/dedicated":          "klusterlet",
+				"node-role.kubernetes.io/worker": "",
+			},
+		},
+		{
+			name:             "invalid os",
+			nodeSelector:     map[string]string{"kubernetes.io/os": "Linux"},
+			expectedWarnings: []string{`the value "Linux" of kubernetes.io/os`},
+		},
+		{
+			name:             "invalid arch",
+			nodeSelector:     map[string]string{"kubernetes.io/arch": "x86_64"},
+			expectedWarnings: []string{`the value "x86_64" of kubernetes.io/arch`},
+		},
+		{
+			name: "typos in the reserved domains",
+			nodeSelector: map[string]string{
+				"kubernetes.io/hostnmae":      "node1",
+				"topology.k8s.io/zone":        "us-east-1a",
+				"kubernetes.io/os":            "linux",
+				"example.kubernetes.io.com/a": "b",
+			},
+			expectedWarnings: []string{
+				"kubernetes.io/hostnmae is not a well-known node label",
+				"topology.k8s.io/zone is not a well-known node label",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := ValidateNodeSelectorWellKnownLabels(c.nodeSelector)
+			if len(c.expectedWarnings) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			if !goerrors.Is(err, ErrNodeSelectorMayNotMatch) {
+				t.Fatalf("expected ErrNodeSelectorMayNotMatch, but got %v", err)
+			}
+			for _, warning := range c.expectedWarnings {
+				if !strings.Contains(err.Error(), warning) {
+					t.Errorf("expected the warning %q, but got %v", warning, err)
+				}
+			}
+			if strings.Count(err.Error(), ";")+1 != len(c.expectedWarnings) {
+				t.Errorf("expected %d warnings, but got %v", len(c.expectedWarnings), err)
+			}
+		})
+	}
+}
+
+func TestGetNodeSelectorWithDefaults(t *testing.T) {
+	cases := []struct {
+		name                 string
+		defaultNodeSelector  *string
+		annotations          map[string]string
+		expectedNodeSelector map[string]string
+	}{
+		{
+			name:                 "no nodeSelector annotation",
+			expectedNodeSelector: map[string]string{"kubernetes.io/os": "linux"},
+		},
+		{
+			name: "partial override",
+			annotations: map[string]string{
+				"open-cluster-management/nodeSelector": "{\"kubernetes.io/arch\":\"amd64\"}",
+			},
+			expectedNodeSelector: map[string]string{"kubernetes.io/os": "linux", "kubernetes.io/arch": "amd64"},
+		},
+		{
+			name: "full override",
+			annotations: map[string]string{
+				"open-cluster-management/nodeSelector": "{\"kubernetes.io/os\":\"windows\"}",
+			},
+			expectedNodeSelector: map[string]string{"kubernetes.io/os": "windows"},
+		},
+		{
+			name:                "custom default nodeSelector",
+			defaultNodeSelector: stringPtr("{\"node-role.kubernetes.io/infra\":\"\"}"),
+			annotations: map[string]string{
+				"open-cluster-management/nodeSelector": "{\"kubernetes.io/arch\":\"amd64\"}",
+			},
+			expectedNodeSelector: map[string]string{"node-role.kubernetes.io/infra": "", "kubernetes.io/arch": "amd64"},
+		},
+		{
+			name:                 "no default nodeSelector",
+			defaultNodeSelector:  stringPtr(""),
+			expectedNodeSelector: map[string]string{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if c.defaultNodeSelector != nil {
+				os.Setenv(defaultNodeSelectorEnvVarName, *c.defaultNodeSelector)
+				defer os.Unsetenv(defaultNodeSelectorEnvVarName)
+			}
+
+			nodeSelector, err := GetNodeSelectorWithDefaults(&clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test_cluster",
+					Annotations: c.annotations,
+				},
+			})
+			if err != nil {
+				t.Errorf("unexpect err: %v", err)
+			}
+			if !reflect.DeepEqual(nodeSelector, c.expectedNodeSelector) {
+				t.Errorf("expected nodeSelector %v, but got %v", c.expectedNodeSelector, nodeSelector)
+			}
+		})
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestGetTolerations(t *testing.T) {
+	cases := []struct {
+		name                 string
+		managedCluster       *clusterv1.ManagedCluster
+		maxTolerationSeconds string
+		expectedErr          string
 	}{
 		{
 			name: "no tolerations annotation",
@@ -938,6 +3515,43 @@ func TestGetTolerations(t *testing.T) {
 			},
 			expectedErr: "effect must be 'NoExecute' when `tolerationSeconds` is set",
 		},
+		{
+			name: "negative tolerationSeconds",
+			managedCluster: &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test_cluster",
+					Annotations: map[string]string{
+						"open-cluster-management/tolerations": "[{\"key\":\"foo\",\"operator\":\"Exists\",\"effect\":\"NoExecute\",\"tolerationSeconds\":-1}]",
+					},
+				},
+			},
+			expectedErr: "`tolerationSeconds` -1 must be between 0 and 86400",
+		},
+		{
+			name: "tolerationSeconds exceeds the max",
+			managedCluster: &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test_cluster",
+					Annotations: map[string]string{
+						"open-cluster-management/tolerations": "[{\"key\":\"foo\",\"operator\":\"Exists\",\"effect\":\"NoExecute\",\"tolerationSeconds\":86401}]",
+					},
+				},
+			},
+			expectedErr: "`tolerationSeconds` 86401 must be between 0 and 86400",
+		},
+		{
+			name: "tolerationSeconds exceeds the customized max",
+			managedCluster: &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test_cluster",
+					Annotations: map[string]string{
+						"open-cluster-management/tolerations": "[{\"key\":\"foo\",\"operator\":\"Exists\",\"effect\":\"NoExecute\",\"tolerationSeconds\":601}]",
+					},
+				},
+			},
+			maxTolerationSeconds: "600",
+			expectedErr:          "`tolerationSeconds` 601 must be between 0 and 600",
+		},
 		{
 			name: "tolerations annotation",
 			managedCluster: &clusterv1.ManagedCluster{
@@ -953,6 +3567,11 @@ func TestGetTolerations(t *testing.T) {
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
+			if len(c.maxTolerationSeconds) > 0 {
+				os.Setenv(maxTolerationSecondsEnvVarName, c.maxTolerationSeconds)
+				defer os.Unsetenv(maxTolerationSecondsEnvVarName)
+			}
+
 			_, err := GetTolerations(c.managedCluster)
 			switch {
 			case len(c.expectedErr) == 0:
@@ -1002,3 +3621,171 @@ func createBasic(serverURL, clusterName, userName string, caCert []byte) *client
 		CurrentContext: contextName,
 	}
 }
+
+func TestGetHostingClusterName(t *testing.T) {
+	cases := []struct {
+		name               string
+		annotations        map[string]string
+		expectedName       string
+		expectedInvalidErr bool
+	}{
+		{
+			name:               "annotation is missing",
+			expectedInvalidErr: true,
+		},
+		{
+			name: "annotation is empty",
+			annotations: map[string]string{
+				constants.HostingClusterNameAnnotation: "",
+			},
+			expectedInvalidErr: true,
+		},
+		{
+			name: "annotation is invalid",
+			annotations: map[string]string{
+				constants.HostingClusterNameAnnotation: "Invalid_Cluster",
+			},
+			expectedInvalidErr: true,
+		},
+		{
+			name: "annotation is present",
+			annotations: map[string]string{
+				constants.HostingClusterNameAnnotation: "cluster1",
+			},
+			expectedName: "cluster1",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			name, err := GetHostingClusterName(&clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Annotations: c.annotations,
+				},
+			})
+			if IsInvalidHostingCluster(err) != c.expectedInvalidErr {
+				t.Errorf("expected invalid hosting cluster error %v, but got %v", c.expectedInvalidErr, err)
+			}
+			if name != c.expectedName {
+				t.Errorf("expected hosting cluster %q, but got %q", c.expectedName, name)
+			}
+		})
+	}
+}
+
+func TestGetKlusterletLabels(t *testing.T) {
+	cases := []struct {
+		name           string
+		annotations    map[string]string
+		expectedLabels map[string]string
+		expectedErr    bool
+	}{
+		{
+			name:           "no annotation",
+			expectedLabels: map[string]string{},
+		},
+		{
+			name: "label annotations",
+			annotations: map[string]string{
+				constants.KlusterletLabelAnnotationPrefix + "team": "a",
+				constants.KlusterletLabelAnnotationPrefix + "env":  "",
+				constants.KlusterletNamespaceAnnotation:            "open-cluster-management-agent",
+			},
+			expectedLabels: map[string]string{"team": "a", "env": ""},
+		},
+		{
+			name:        "invalid label key",
+			annotations: map[string]string{constants.KlusterletLabelAnnotationPrefix + "-team": "a"},
+			expectedErr: true,
+		},
+		{
+			name:        "invalid label value",
+			annotations: map[string]string{constants.KlusterletLabelAnnotationPrefix + "team": "a/b"},
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			labels, err := GetKlusterletLabels(&clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Annotations: c.annotations,
+				},
+			})
+			if c.expectedErr != (err != nil) {
+				t.Errorf("expected error %v, but got %v", c.expectedErr, err)
+			}
+			if !c.expectedErr && !reflect.DeepEqual(labels, c.expectedLabels) {
+				t.Errorf("expected labels %v, but got %v", c.expectedLabels, labels)
+			}
+		})
+	}
+}
+
+func TestGetKlusterletImageOverride(t *testing.T) {
+	cases := []struct {
+		name          string
+		annotations   map[string]string
+		expectedImage string
+		expectedErr   bool
+	}{
+		{
+			name: "no annotation",
+		},
+		{
+			name:        "empty annotation",
+			annotations: map[string]string{constants.KlusterletImageOverrideAnnotation: " "},
+		},
+		{
+			name:          "image with registry and tag",
+			annotations:   map[string]string{constants.KlusterletImageOverrideAnnotation: "quay.io/ocm/registration-operator:v1.0.0"},
+			expectedImage: "quay.io/ocm/registration-operator:v1.0.0",
+		},
+		{
+			name: "image with registry port and digest",
+			annotations: map[string]string{constants.KlusterletImageOverrideAnnotation: "mirror.example.com:5000/ocm/registration-operator" +
+				"@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"},
+			expectedImage: "mirror.example.com:5000/ocm/registration-operator" +
+				"@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+		},
+		{
+			name:          "image without registry",
+			annotations:   map[string]string{constants.KlusterletImageOverrideAnnotation: "registration-operator"},
+			expectedImage: "registration-operator",
+		},
+		{
+			name:        "image with upper case repository",
+			annotations: map[string]string{constants.KlusterletImageOverrideAnnotation: "quay.io/OCM/registration-operator:v1"},
+			expectedErr: true,
+		},
+		{
+			name:        "image with spaces",
+			annotations: map[string]string{constants.KlusterletImageOverrideAnnotation: "quay.io/ocm/registration operator"},
+			expectedErr: true,
+		},
+		{
+			name:        "image with an invalid digest",
+			annotations: map[string]string{constants.KlusterletImageOverrideAnnotation: "quay.io/ocm/registration-operator@sha256:abc"},
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			image, err := GetKlusterletImageOverride(&clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Annotations: c.annotations,
+				},
+			})
+			if c.expectedErr != (err != nil) {
+				t.Errorf("expected error %v, but got %v", c.expectedErr, err)
+			}
+			if image != c.expectedImage {
+				t.Errorf("expected image %q, but got %q", c.expectedImage, image)
+			}
+		})
+	}
+}
@@ -6,19 +6,35 @@ package helpers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/ghodss/yaml"
 	"github.com/go-logr/logr"
 	"github.com/openshift/library-go/pkg/operator/events"
-	"github.com/openshift/library-go/pkg/operator/resource/resourcemerge"
 	"github.com/stolostron/managedcluster-import-controller/pkg/constants"
+	controllerversion "github.com/stolostron/managedcluster-import-controller/pkg/version"
+	"k8s.io/klog/v2"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
@@ -26,10 +42,74 @@ import (
 	workv1 "open-cluster-management.io/api/work/v1"
 )
 
+const (
+	manifestWorkLabelsEnvVarName      = "MANIFEST_WORK_LABELS"
+	manifestWorkAnnotationsEnvVarName = "MANIFEST_WORK_ANNOTATIONS"
+)
+
+// debugImportManifestsEnvVarName is the env to write the manifests of the import secret to a ConfigMap in the managed
+// cluster namespace for debugging, by default, the ConfigMap is not written
+const debugImportManifestsEnvVarName = "DEBUG_IMPORT_MANIFESTS"
+
+// cleanUpStaleKlusterletWorksEnvVarName is the env to delete the klusterlet manifest works that are not in the
+// namespace of the current klusterlet mode once the klusterlet manifest works are conflicted, by default, the stale
+// manifest works are kept and only reported
+const cleanUpStaleKlusterletWorksEnvVarName = "CLEAN_UP_STALE_KLUSTERLET_WORKS"
+
+// importManifestsConfigMapSuffix is the suffix of the name of the ConfigMap that has the import manifests
+const importManifestsConfigMapSuffix = "import-manifests"
+
+// redactedValue replaces the values of the secret data in the ConfigMap that has the import manifests
+const redactedValue = "REDACTED"
+
+// KlusterletWorkName returns the name of the klusterlet manifest work of the managed cluster
+func KlusterletWorkName(clusterName string) string {
+	return fmt.Sprintf("%s-%s", clusterName, constants.KlusterletSuffix)
+}
+
+// KlusterletCRDsWorkName returns the name of the klusterlet crds manifest work of the managed cluster
+func KlusterletCRDsWorkName(clusterName string) string {
+	return fmt.Sprintf("%s-%s", clusterName, constants.KlusterletCRDsSuffix)
+}
+
+// HostedKlusterletWorkName returns the name of the klusterlet manifest work of the hosted mode managed cluster,
+// the manifest work is in the hosting cluster namespace
+func HostedKlusterletWorkName(clusterName string) string {
+	return fmt.Sprintf("%s-%s", clusterName, constants.HostedKlusterletManifestworkSuffix)
+}
+
+// HostedManagedKubeconfigWorkName returns the name of the managed kubeconfig manifest work of the hosted mode
+// managed cluster, the manifest work is in the hosting cluster namespace
+func HostedManagedKubeconfigWorkName(clusterName string) string {
+	return fmt.Sprintf("%s-%s", clusterName, constants.HostedManagedKubeconfigManifestworkSuffix)
+}
+
+// ManifestWorksCounter counts the manifest works that belong to a managed cluster
+type ManifestWorksCounter func(ctx context.Context) (int, error)
+
+// NamespacedManifestWorksCounter returns a ManifestWorksCounter that counts the manifest works in the given namespace
+func NamespacedManifestWorksCounter(runtimeClient client.Client, namespace string) ManifestWorksCounter {
+	return func(ctx context.Context) (int, error) {
+		manifestWorks := &workv1.ManifestWorkList{}
+		if err := runtimeClient.List(ctx, manifestWorks, client.InNamespace(namespace)); err != nil {
+			return 0, err
+		}
+		return len(manifestWorks.Items), nil
+	}
+}
+
 // AssertManifestWorkFinalizer add/remove manifest finalizer for a managed cluster,
 // this func will send request to api server to update managed cluster.
+//
+// The manifest works are counted by the countWorks right before the finalizer is asserted, so a manifest work that
+// is deleted after the caller listed the manifest works does not leave the finalizer on the managed cluster.
 func AssertManifestWorkFinalizer(ctx context.Context, runtimeClient client.Client, recorder events.Recorder,
-	cluster *clusterv1.ManagedCluster, works int) error {
+	cluster *clusterv1.ManagedCluster, countWorks ManifestWorksCounter) error {
+	works, err := countWorks(ctx)
+	if err != nil {
+		return err
+	}
+
 	if works == 0 {
 		// there are no manifest works, remove the manifest work finalizer
 		err := RemoveManagedClusterFinalizer(ctx, runtimeClient, recorder, cluster, constants.ManifestWorkFinalizer)
@@ -45,20 +125,8 @@ func AssertManifestWorkFinalizer(ctx context.Context, runtimeClient client.Clien
 	}
 
 	// there are manifest works in the managed cluster namespace, make sure the managed cluster has the manifest work finalizer
-	patch := client.MergeFrom(cluster.DeepCopy())
-	modified := resourcemerge.BoolPtr(false)
-	AddManagedClusterFinalizer(modified, cluster, constants.ManifestWorkFinalizer)
-	if !*modified {
-		return nil
-	}
-
-	if err := runtimeClient.Patch(ctx, cluster, patch); err != nil {
-		return err
-	}
-
-	recorder.Eventf("ManagedClusterMetaObjModified",
-		"The managed cluster %s meta data is modified: manifestwork finalizer is added", cluster.Name)
-	return nil
+	_, err = EnsureManagedClusterFinalizer(ctx, runtimeClient, recorder, cluster, constants.ManifestWorkFinalizer)
+	return err
 }
 
 // ForceDeleteAllManifestWorks delete all manifestworks forcefully
@@ -72,8 +140,42 @@ func ForceDeleteAllManifestWorks(ctx context.Context, runtimeClient client.Clien
 	return nil
 }
 
+// ForceDeleteManifestWorksBySelector delete the manifestworks that match the label selector in the namespace
+// forcefully, it is used to recover a namespace whose manifestworks are not listed yet, e.g. remove the klusterlet
+// works of a managed cluster namespace. Note: the hosted klusterlet works of different managed clusters share the
+// namespace of their hosting cluster, so the selector must not select the hosted works of other clusters.
+func ForceDeleteManifestWorksBySelector(ctx context.Context, runtimeClient client.Client, recorder events.Recorder,
+	namespace string, selector labels.Selector) error {
+	listOpts := &client.ListOptions{
+		Namespace:     namespace,
+		LabelSelector: selector,
+	}
+	manifestWorks := &workv1.ManifestWorkList{}
+	if err := runtimeClient.List(ctx, manifestWorks, listOpts); err != nil {
+		return err
+	}
+
+	return ForceDeleteAllManifestWorks(ctx, runtimeClient, recorder, manifestWorks.Items)
+}
+
 // ForceDeleteManifestWork will delete the manifestwork regardless of finalizers.
+//
+// The deletion is bounded by the timeout from GetForceDeleteTimeout, if the api server does not respond in time, an
+// error is returned, so the caller can requeue the request rather than blocking its reconcile.
 func ForceDeleteManifestWork(ctx context.Context, runtimeClient client.Client, recorder events.Recorder,
+	namespace, name string) error {
+	timeout := GetForceDeleteTimeout()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := forceDeleteManifestWork(ctx, runtimeClient, recorder, namespace, name)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("failed to force delete the manifest work %s/%s in %v: %w", namespace, name, timeout, err)
+	}
+	return err
+}
+
+func forceDeleteManifestWork(ctx context.Context, runtimeClient client.Client, recorder events.Recorder,
 	namespace, name string) error {
 	manifestWorkKey := types.NamespacedName{Namespace: namespace, Name: name}
 	manifestWork := &workv1.ManifestWork{}
@@ -88,6 +190,7 @@ func ForceDeleteManifestWork(ctx context.Context, runtimeClient client.Client, r
 	if err := runtimeClient.Delete(ctx, manifestWork); err != nil {
 		return err
 	}
+	reportDeletion(recorder, "ManifestWork", namespace, name)
 
 	// reload the manifest work
 	err = runtimeClient.Get(ctx, manifestWorkKey, manifestWork)
@@ -112,6 +215,15 @@ func ForceDeleteManifestWork(ctx context.Context, runtimeClient client.Client, r
 	return nil
 }
 
+// IsManifestWorkDeletionBlocked returns true if the manifest work is terminating for longer than the threshold, e.g.
+// its finalizers are added back by another controller after they are removed forcefully
+func IsManifestWorkDeletionBlocked(work *workv1.ManifestWork, threshold time.Duration) bool {
+	if work.DeletionTimestamp.IsZero() {
+		return false
+	}
+	return time.Since(work.DeletionTimestamp.Time) >= threshold
+}
+
 // DeleteManifestWork triggers the deletion action of the manifestwork
 func DeleteManifestWork(ctx context.Context, runtimeClient client.Client, recorder events.Recorder,
 	namespace, name string) error {
@@ -132,13 +244,52 @@ func DeleteManifestWork(ctx context.Context, runtimeClient client.Client, record
 	if err := runtimeClient.Delete(ctx, manifestWork); err != nil {
 		return err
 	}
+	reportDeletion(recorder, "ManifestWork", namespace, name)
 
 	recorder.Eventf("ManifestWorksDeleted", fmt.Sprintf("The manifest work %s/%s is deleted", namespace, name))
 	return nil
 }
 
+// DeleteManifestWorkAndWait triggers the deletion action of the manifestwork and polls the manifestwork every interval
+// until it is removed. If the manifestwork is not removed within the timeout, an error that wraps the
+// wait.ErrWaitTimeout is returned.
+func DeleteManifestWorkAndWait(ctx context.Context, runtimeClient client.Client, recorder events.Recorder,
+	namespace, name string, interval, timeout time.Duration) error {
+	if err := DeleteManifestWork(ctx, runtimeClient, recorder, namespace, name); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := wait.PollImmediateUntil(interval, func() (bool, error) {
+		err := runtimeClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &workv1.ManifestWork{})
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		if err != nil && ctx.Err() != nil {
+			// the timeout fires during the request
+			return false, wait.ErrWaitTimeout
+		}
+		return false, err
+	}, ctx.Done())
+	if err != nil {
+		return fmt.Errorf("failed to wait for the manifest work %s/%s to be removed: %w", namespace, name, err)
+	}
+	return nil
+}
+
 // NoPendingManifestWorks checks whether there are pending manifestworks for the managed cluster
 func NoPendingManifestWorks(ctx context.Context, runtimeClient client.Client, log logr.Logger, clusterName string,
+	ignoredSelector func(clusterName string, manifestWork workv1.ManifestWork) bool) (bool, error) {
+	return NoPendingManifestWorksWithLabelSelector(ctx, runtimeClient, log, clusterName, labels.Nothing(), ignoredSelector)
+}
+
+// NoPendingManifestWorksWithLabelSelector checks whether there are pending manifestworks for the managed cluster,
+// the manifestworks whose labels match the ignoredLabelSelector are ignored in addition to the ignoredSelector
+// selected manifestworks, e.g. the addon manifestworks can be ignored by the AddonWorksLabel.
+func NoPendingManifestWorksWithLabelSelector(ctx context.Context, runtimeClient client.Client, log logr.Logger,
+	clusterName string, ignoredLabelSelector labels.Selector,
 	ignoredSelector func(clusterName string, manifestWork workv1.ManifestWork) bool) (bool, error) {
 	listOpts := &client.ListOptions{Namespace: clusterName}
 	manifestWorks := &workv1.ManifestWorkList{}
@@ -149,7 +300,7 @@ func NoPendingManifestWorks(ctx context.Context, runtimeClient client.Client, lo
 	manifestWorkNames := []string{}
 	ignoredManifestWorkNames := []string{}
 	for _, manifestWork := range manifestWorks.Items {
-		if ignoredSelector(clusterName, manifestWork) {
+		if ignoredLabelSelector.Matches(labels.Set(manifestWork.Labels)) || ignoredSelector(clusterName, manifestWork) {
 			ignoredManifestWorkNames = append(ignoredManifestWorkNames, manifestWork.GetName())
 		} else {
 			manifestWorkNames = append(manifestWorkNames, manifestWork.GetName())
@@ -157,14 +308,156 @@ func NoPendingManifestWorks(ctx context.Context, runtimeClient client.Client, lo
 	}
 
 	if len(manifestWorkNames) != 0 {
-		log.Info(fmt.Sprintf("In addition to ignored manifest works %s, there are also have %s",
-			strings.Join(ignoredManifestWorkNames, ","), strings.Join(manifestWorkNames, ",")))
+		log.Info("There are pending manifest works in addition to the ignored manifest works",
+			"ignoredManifestWorks", strings.Join(ignoredManifestWorkNames, ","),
+			"pendingManifestWorks", strings.Join(manifestWorkNames, ","))
 		return false, nil
 	}
 
 	return true, nil
 }
 
+// ListKlusterletManifestWorks lists the klusterlet manifest works of the managed cluster with the
+// KlusterletWorksLabel, it returns the klusterlet crds manifest work and the klusterlet manifest work
+// distinctly, a returned manifest work is nil if it does not exist.
+func ListKlusterletManifestWorks(ctx context.Context, runtimeClient client.Client, clusterName string) (
+	crdsWork *workv1.ManifestWork, klusterletWork *workv1.ManifestWork, err error) {
+	listOpts := &client.ListOptions{
+		Namespace:     clusterName,
+		LabelSelector: labels.SelectorFromSet(map[string]string{constants.KlusterletWorksLabel: "true"}),
+	}
+	manifestWorks := &workv1.ManifestWorkList{}
+	if err := runtimeClient.List(ctx, manifestWorks, listOpts); err != nil {
+		return nil, nil, err
+	}
+
+	crdsWorkName := KlusterletCRDsWorkName(clusterName)
+	klusterletWorkName := KlusterletWorkName(clusterName)
+	for i := range manifestWorks.Items {
+		switch manifestWorks.Items[i].Name {
+		case crdsWorkName:
+			crdsWork = &manifestWorks.Items[i]
+		case klusterletWorkName:
+			klusterletWork = &manifestWorks.Items[i]
+		}
+	}
+	return crdsWork, klusterletWork, nil
+}
+
+// KlusterletWorksConflict describes the klusterlet manifest works of a managed cluster that are applied to more than
+// one namespace, e.g. the klusterlet mode of the managed cluster is changed, so more than one klusterlet is deployed
+// for the managed cluster.
+type KlusterletWorksConflict struct {
+	// Namespaces are the sorted namespaces of the klusterlet manifest works
+	Namespaces []string
+	// StaleWorks are the klusterlet manifest works that are not in the namespace of the current klusterlet mode
+	StaleWorks []workv1.ManifestWork
+}
+
+// DetectKlusterletWorksConflict lists the klusterlet manifest works of the managed cluster with the
+// KlusterletWorksLabel across the namespaces, a conflict is returned if the manifest works are in more than one
+// namespace, otherwise nil is returned.
+func DetectKlusterletWorksConflict(ctx context.Context, runtimeClient client.Client,
+	cluster *clusterv1.ManagedCluster) (*KlusterletWorksConflict, error) {
+	expectedNamespace := cluster.Name
+	if DetermineKlusterletMode(cluster) == constants.KlusterletDeployModeHosted {
+		hostingClusterName, err := GetHostingClusterName(cluster)
+		if err != nil {
+			return nil, err
+		}
+		expectedNamespace = hostingClusterName
+	}
+
+	manifestWorks := &workv1.ManifestWorkList{}
+	if err := runtimeClient.List(ctx, manifestWorks, &client.ListOptions{
+		LabelSelector: labels.SelectorFromSet(map[string]string{constants.KlusterletWorksLabel: "true"}),
+	}); err != nil {
+		return nil, err
+	}
+
+	namespaces := map[string]bool{}
+	staleWorks := []workv1.ManifestWork{}
+	for _, manifestWork := range manifestWorks.Items {
+		if !isKlusterletWorkOf(cluster.Name, manifestWork) {
+			continue
+		}
+
+		namespaces[manifestWork.Namespace] = true
+		if manifestWork.Namespace != expectedNamespace {
+			staleWorks = append(staleWorks, manifestWork)
+		}
+	}
+
+	if len(namespaces) < 2 {
+		return nil, nil
+	}
+
+	conflict := &KlusterletWorksConflict{StaleWorks: staleWorks}
+	for namespace := range namespaces {
+		conflict.Namespaces = append(conflict.Namespaces, namespace)
+	}
+	sort.Strings(conflict.Namespaces)
+	return conflict, nil
+}
+
+// isKlusterletWorkOf returns true if the manifest work is a default mode klusterlet manifest work in the managed
+// cluster namespace or a hosted mode klusterlet manifest work in a hosting cluster namespace of the managed cluster
+func isKlusterletWorkOf(clusterName string, manifestWork workv1.ManifestWork) bool {
+	if manifestWork.Namespace == clusterName {
+		return manifestWork.Name == KlusterletWorkName(clusterName) ||
+			manifestWork.Name == KlusterletCRDsWorkName(clusterName)
+	}
+
+	// the default mode klusterlet manifest work of another managed cluster may have the same name as the hosted mode
+	// klusterlet manifest work, e.g. the klusterlet manifest work of the managed cluster <cluster>-hosted
+	return manifestWork.Name == HostedKlusterletWorkName(clusterName) &&
+		manifestWork.Name != KlusterletWorkName(manifestWork.Namespace)
+}
+
+// KlusterletWorksConflictCondition builds the klusterlet works conflicted condition of the managed cluster from the
+// conflict of its klusterlet manifest works, the status of the condition is false if there is no conflict.
+func KlusterletWorksConflictCondition(conflict *KlusterletWorksConflict) metav1.Condition {
+	if conflict == nil {
+		return metav1.Condition{
+			Type:    constants.KlusterletWorksConflictedConditionType,
+			Status:  metav1.ConditionFalse,
+			Reason:  "KlusterletManifestWorksNotConflicted",
+			Message: "The klusterlet manifest works are applied to one namespace",
+		}
+	}
+
+	return metav1.Condition{
+		Type:   constants.KlusterletWorksConflictedConditionType,
+		Status: metav1.ConditionTrue,
+		Reason: "KlusterletManifestWorksConflicted",
+		Message: fmt.Sprintf("The klusterlet manifest works are applied to the namespaces %s, more than one klusterlet "+
+			"may be deployed", strings.Join(conflict.Namespaces, ", ")),
+	}
+}
+
+// IsStaleKlusterletWorksCleanupEnabled returns true if the CLEAN_UP_STALE_KLUSTERLET_WORKS env is true
+func IsStaleKlusterletWorksCleanupEnabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv(cleanUpStaleKlusterletWorksEnvVarName))
+	return enabled
+}
+
+// CleanUpStaleKlusterletWorks deletes the stale klusterlet manifest works of the conflict if the
+// CLEAN_UP_STALE_KLUSTERLET_WORKS env is true, otherwise the stale manifest works are kept
+func CleanUpStaleKlusterletWorks(ctx context.Context, runtimeClient client.Client, recorder events.Recorder,
+	conflict *KlusterletWorksConflict) error {
+	if conflict == nil || !IsStaleKlusterletWorksCleanupEnabled() {
+		return nil
+	}
+
+	errs := []error{}
+	for _, manifestWork := range conflict.StaleWorks {
+		if err := DeleteManifestWork(ctx, runtimeClient, recorder, manifestWork.Namespace, manifestWork.Name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
 // ListManagedClusterAddons lists all managedclusteraddons for the managed cluster
 func ListManagedClusterAddons(ctx context.Context, runtimeClient client.Client, clusterName string) (
 	*addonv1alpha1.ManagedClusterAddOnList, error) {
@@ -185,6 +478,45 @@ func NoManagedClusterAddons(ctx context.Context, runtimeClient client.Client, cl
 	return len(managedclusteraddons.Items) == 0, nil
 }
 
+// IsAddonManifestWork checks whether the manifestwork is created for the addons of the managed cluster, the addon
+// manifestworks either have the AddonWorksLabel or follow the naming of the addon manifestworks
+func IsAddonManifestWork(manifestWork workv1.ManifestWork) bool {
+	manifestWorkName := manifestWork.GetName()
+	switch {
+	case len(manifestWork.GetLabels()[constants.AddonWorksLabel]) > 0:
+	case strings.HasPrefix(manifestWorkName, fmt.Sprintf("%s-klusterlet-addon", manifestWork.GetNamespace())):
+	case strings.HasPrefix(manifestWorkName, "addon-") && strings.HasSuffix(manifestWorkName, "-deploy"):
+	case strings.HasPrefix(manifestWorkName, "addon-") && strings.HasSuffix(manifestWorkName, "-pre-delete"):
+	default:
+		return false
+	}
+	return true
+}
+
+// AddonsCleaned checks whether the addons of the managed cluster are fully cleaned, the addons are cleaned only when
+// both the managedclusteraddons and the addon manifestworks are removed
+func AddonsCleaned(ctx context.Context, runtimeClient client.Client, clusterName string) (bool, error) {
+	noAddons, err := NoManagedClusterAddons(ctx, runtimeClient, clusterName)
+	if err != nil {
+		return false, err
+	}
+	if !noAddons {
+		return false, nil
+	}
+
+	manifestWorks := &workv1.ManifestWorkList{}
+	if err := runtimeClient.List(ctx, manifestWorks, client.InNamespace(clusterName)); err != nil {
+		return false, err
+	}
+	for _, manifestWork := range manifestWorks.Items {
+		if IsAddonManifestWork(manifestWork) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
 // DeleteManagedClusterAddons deletes all managedclusteraddons for the managed cluster
 func DeleteManagedClusterAddons(
 	ctx context.Context,
@@ -196,10 +528,30 @@ func DeleteManagedClusterAddons(
 		return ForceDeleteAllManagedClusterAddons(ctx, runtimeClient, recorder, cluster.GetName())
 	}
 
-	return runtimeClient.DeleteAllOf(ctx, &addonv1alpha1.ManagedClusterAddOn{}, client.InNamespace(cluster.GetName()))
+	addons, err := ListManagedClusterAddons(ctx, runtimeClient, cluster.GetName())
+	if err != nil {
+		return err
+	}
+	for i := range addons.Items {
+		addon := &addons.Items[i]
+		if !addon.DeletionTimestamp.IsZero() {
+			// the addon is deleting, do nothing
+			continue
+		}
+		if err := runtimeClient.Delete(ctx, addon); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		reportDeletion(recorder, "ManagedClusterAddOn", addon.Namespace, addon.Name)
+	}
+	return nil
 }
 
-// DeleteManifestWorkWithSelector deletes manifestworks but ignores the ignoredSelector selected manifestworks
+// DeleteManifestWorkWithSelector deletes manifestworks but ignores the ignoredSelector selected manifestworks, the
+// deletion of the manifest works with the postpone-delete annotation is only postponed if the cluster is deleting,
+// e.g. the manifest works of a detaching cluster are deleted without postponement
 func DeleteManifestWorkWithSelector(ctx context.Context, runtimeClient client.Client, recorder events.Recorder,
 	cluster *clusterv1.ManagedCluster, works []workv1.ManifestWork,
 	ignoredSelector func(clusterName string, manifestWork workv1.ManifestWork) bool) error {
@@ -209,9 +561,8 @@ func DeleteManifestWorkWithSelector(ctx context.Context, runtimeClient client.Cl
 			continue
 		}
 
-		annotations := manifestWork.GetAnnotations()
-		if _, ok := annotations[constants.PostponeDeletionAnnotation]; ok {
-			if time.Since(cluster.DeletionTimestamp.Time) < constants.ManifestWorkPostponeDeleteTime {
+		if postponeTime, ok := PostponeDeleteDuration(manifestWork); ok && cluster.DeletionTimestamp != nil {
+			if time.Since(cluster.DeletionTimestamp.Time) < postponeTime {
 				continue
 			}
 		}
@@ -223,6 +574,74 @@ func DeleteManifestWorkWithSelector(ctx context.Context, runtimeClient client.Cl
 	return nil
 }
 
+// PostponeDeleteDuration returns how long the deletion of the manifest work is postponed after its cluster is deleted,
+// the value of the postpone-delete annotation can be a duration, e.g. 30m, if the value is empty or is not a valid
+// duration, the default postpone time is used. It returns false if the manifest work does not have the annotation.
+func PostponeDeleteDuration(manifestWork workv1.ManifestWork) (time.Duration, bool) {
+	value, ok := manifestWork.GetAnnotations()[constants.PostponeDeletionAnnotation]
+	if !ok {
+		return 0, false
+	}
+
+	if len(value) == 0 {
+		return constants.ManifestWorkPostponeDeleteTime, true
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		klog.Warningf("The value of the annotation %s of the manifest work %s/%s is wrong, using default %s",
+			constants.PostponeDeletionAnnotation, manifestWork.Namespace, manifestWork.Name,
+			constants.ManifestWorkPostponeDeleteTime)
+		return constants.ManifestWorkPostponeDeleteTime, true
+	}
+
+	return duration, true
+}
+
+// KlusterletAppliedCondition builds the klusterlet applied condition of the managed cluster from the Applied and
+// Available conditions of the klusterlet manifest work, the status of the condition is unknown if the klusterlet
+// manifest work is not found or is not applied yet.
+func KlusterletAppliedCondition(klusterletWork *workv1.ManifestWork) metav1.Condition {
+	cond := metav1.Condition{
+		Type:    constants.KlusterletAppliedConditionType,
+		Status:  metav1.ConditionUnknown,
+		Reason:  "KlusterletManifestWorkApplying",
+		Message: "Waiting for the klusterlet manifest work to be applied",
+	}
+	if klusterletWork == nil {
+		return cond
+	}
+
+	applied := meta.FindStatusCondition(klusterletWork.Status.Conditions, workv1.WorkApplied)
+	if applied == nil || applied.Status == metav1.ConditionUnknown {
+		return cond
+	}
+
+	if applied.Status == metav1.ConditionFalse {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = "KlusterletManifestWorkNotApplied"
+		cond.Message = fmt.Sprintf("The klusterlet manifest work is not applied: %s", applied.Message)
+		return cond
+	}
+
+	cond.Status = metav1.ConditionTrue
+	if meta.IsStatusConditionTrue(klusterletWork.Status.Conditions, workv1.WorkAvailable) {
+		cond.Reason = "KlusterletManifestWorkAvailable"
+		cond.Message = "The klusterlet manifest work is applied and its resources are available"
+		return cond
+	}
+
+	cond.Reason = "KlusterletManifestWorkApplied"
+	cond.Message = "The klusterlet manifest work is applied, waiting for its resources to be available"
+	return cond
+}
+
+// IsManagedClusterAccepted returns true if the hub accepts the klusterlet of the managed cluster to join, the
+// klusterlet manifest works should not be created before the managed cluster is accepted
+func IsManagedClusterAccepted(cluster *clusterv1.ManagedCluster) bool {
+	return cluster.Spec.HubAcceptsClient
+}
+
 // IsClusterUnavailable checks whether the cluster is unavilable
 func IsClusterUnavailable(cluster *clusterv1.ManagedCluster) bool {
 	if meta.IsStatusConditionFalse(cluster.Status.Conditions, clusterv1.ManagedClusterConditionAvailable) {
@@ -235,3 +654,466 @@ func IsClusterUnavailable(cluster *clusterv1.ManagedCluster) bool {
 
 	return false
 }
+
+// IsClusterUnavailableFor checks whether the cluster has been unavailable for at least the grace period, the
+// LastTransitionTime of the cluster available condition is used to determine how long the cluster is unavailable
+func IsClusterUnavailableFor(cluster *clusterv1.ManagedCluster, grace time.Duration) bool {
+	if !IsClusterUnavailable(cluster) {
+		return false
+	}
+
+	if grace <= 0 {
+		return true
+	}
+
+	availableCondition := meta.FindStatusCondition(cluster.Status.Conditions, clusterv1.ManagedClusterConditionAvailable)
+	return time.Since(availableCondition.LastTransitionTime.Time) >= grace
+}
+
+// BuildKlusterletManifests builds the manifests of the klusterlet manifest work from the import.yaml of the
+// import secret, each yaml document is validated before it is packed, if a document is invalid, return an error
+func BuildKlusterletManifests(managedCluster *clusterv1.ManagedCluster, importSecret *corev1.Secret) (
+	[]workv1.Manifest, error) {
+	manifests, err := BuildManifests(importSecret.Data[constants.ImportSecretImportYamlKey])
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s in the import secret of the managed cluster %s: %v",
+			constants.ImportSecretImportYamlKey, managedCluster.Name, err)
+	}
+
+	image, err := GetKlusterletImageOverride(managedCluster)
+	if err != nil {
+		return nil, err
+	}
+	if len(image) != 0 {
+		if manifests, err = overrideDeploymentImages(manifests, image); err != nil {
+			return nil, err
+		}
+	}
+
+	labels, err := GetKlusterletLabels(managedCluster)
+	if err != nil {
+		return nil, err
+	}
+	if len(labels) == 0 {
+		return manifests, nil
+	}
+
+	return addKlusterletLabels(manifests, labels)
+}
+
+// addKlusterletLabels adds the labels to the Klusterlet CR in the manifests
+func addKlusterletLabels(manifests []workv1.Manifest, labels map[string]string) ([]workv1.Manifest, error) {
+	labeled := []workv1.Manifest{}
+	for _, manifest := range manifests {
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(manifest.Raw); err != nil {
+			return nil, err
+		}
+
+		if obj.GetKind() != "Klusterlet" {
+			labeled = append(labeled, manifest)
+			continue
+		}
+
+		klusterletLabels := obj.GetLabels()
+		if klusterletLabels == nil {
+			klusterletLabels = map[string]string{}
+		}
+		for key, val := range labels {
+			klusterletLabels[key] = val
+		}
+		obj.SetLabels(klusterletLabels)
+
+		raw, err := obj.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		labeled = append(labeled, workv1.Manifest{RawExtension: runtime.RawExtension{Raw: raw}})
+	}
+	return labeled, nil
+}
+
+// GetKlusterletWorkDeleteOption returns the delete option of the klusterlet manifest work from the delete propagation
+// policy annotation of the managed cluster, the policy is Orphan by default. For the SelectivelyOrphan policy, all
+// of the manifests except the Klusterlet CR are orphaned.
+func GetKlusterletWorkDeleteOption(cluster *clusterv1.ManagedCluster, manifests []workv1.Manifest) (
+	*workv1.DeleteOption, error) {
+	policy := strings.TrimSpace(cluster.Annotations[constants.KlusterletWorkDeletePropagationPolicyAnnotation])
+	switch {
+	case len(policy) == 0, strings.EqualFold(policy, string(workv1.DeletePropagationPolicyTypeOrphan)):
+		return &workv1.DeleteOption{PropagationPolicy: workv1.DeletePropagationPolicyTypeOrphan}, nil
+	case strings.EqualFold(policy, string(workv1.DeletePropagationPolicyTypeForeground)):
+		return &workv1.DeleteOption{PropagationPolicy: workv1.DeletePropagationPolicyTypeForeground}, nil
+	case strings.EqualFold(policy, string(workv1.DeletePropagationPolicyTypeSelectivelyOrphan)):
+		rules := []workv1.OrphaningRule{}
+		for _, manifest := range manifests {
+			obj := &unstructured.Unstructured{}
+			if err := obj.UnmarshalJSON(manifest.Raw); err != nil {
+				return nil, err
+			}
+			if obj.GetKind() == "Klusterlet" {
+				continue
+			}
+
+			resource, _ := meta.UnsafeGuessKindToResource(obj.GroupVersionKind())
+			rules = append(rules, workv1.OrphaningRule{
+				Group:     resource.Group,
+				Resource:  resource.Resource,
+				Namespace: obj.GetNamespace(),
+				Name:      obj.GetName(),
+			})
+		}
+		return &workv1.DeleteOption{
+			PropagationPolicy: workv1.DeletePropagationPolicyTypeSelectivelyOrphan,
+			SelectivelyOrphan: &workv1.SelectivelyOrphan{OrphaningRules: rules},
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid klusterlet work delete propagation policy annotation of cluster %s, %q is not "+
+			"one of Orphan, Foreground and SelectivelyOrphan", cluster.Name, policy)
+	}
+}
+
+// overrideDeploymentImages replaces the images of the deployment containers in the manifests with the image
+func overrideDeploymentImages(manifests []workv1.Manifest, image string) ([]workv1.Manifest, error) {
+	overridden := []workv1.Manifest{}
+	for _, manifest := range manifests {
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(manifest.Raw); err != nil {
+			return nil, err
+		}
+
+		if obj.GetKind() != "Deployment" {
+			overridden = append(overridden, manifest)
+			continue
+		}
+
+		containers, _, err := unstructured.NestedSlice(obj.Object, "spec", "template", "spec", "containers")
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range containers {
+			if container, ok := c.(map[string]interface{}); ok {
+				container["image"] = image
+			}
+		}
+		if err := unstructured.SetNestedSlice(obj.Object, containers, "spec", "template", "spec", "containers"); err != nil {
+			return nil, err
+		}
+
+		raw, err := obj.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		overridden = append(overridden, workv1.Manifest{RawExtension: runtime.RawExtension{Raw: raw}})
+	}
+	return overridden, nil
+}
+
+// SelectCRDKey returns the key of the klusterlet crds in the import secret for the managed cluster. If the kube
+// version of the managed cluster is unknown, the key of the crd v1 is returned; if the managed cluster does not
+// support the crd v1, the key of the crd v1beta1 is returned; if the kube version cannot be parsed, an error is
+// returned.
+func SelectCRDKey(managedCluster *clusterv1.ManagedCluster) (string, error) {
+	kubeVersion := managedCluster.Status.Version.Kubernetes
+	if kubeVersion == "" {
+		return constants.ImportSecretCRDSV1YamlKey, nil
+	}
+
+	if _, err := version.ParseGeneric(kubeVersion); err != nil {
+		return "", fmt.Errorf("invalid kube version %q of the managed cluster %s: %v",
+			kubeVersion, managedCluster.Name, err)
+	}
+
+	if !IsAPIExtensionV1Supported(kubeVersion) {
+		return constants.ImportSecretCRDSV1beta1YamlKey, nil
+	}
+	return constants.ImportSecretCRDSV1YamlKey, nil
+}
+
+// BuildKlusterletCRDsManifests builds the manifests of the klusterlet-crds manifest work from the import secret,
+// if the managed cluster does not support the crd v1, the crd v1beta1 is used
+func BuildKlusterletCRDsManifests(managedCluster *clusterv1.ManagedCluster, importSecret *corev1.Secret) (
+	[]workv1.Manifest, error) {
+	crdsKey, err := SelectCRDKey(managedCluster)
+	if err != nil {
+		return nil, err
+	}
+
+	manifests, err := BuildManifests(importSecret.Data[crdsKey])
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s in the import secret of the managed cluster %s: %v",
+			crdsKey, managedCluster.Name, err)
+	}
+	return manifests, nil
+}
+
+// BuildManifests splits the yamls to documents, decodes each document to make sure it is valid, and then
+// translates the documents to manifests
+func BuildManifests(yamls []byte) ([]workv1.Manifest, error) {
+	manifests := []workv1.Manifest{}
+	for i, yamlData := range SplitYamls(yamls) {
+		if _, _, err := genericCodec.Decode(yamlData, nil, nil); err != nil {
+			return nil, fmt.Errorf("failed to decode the document %d: %v", i, err)
+		}
+
+		jsonData, err := yaml.YAMLToJSON(yamlData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to translate the document %d to json: %v", i, err)
+		}
+
+		manifests = append(manifests, workv1.Manifest{
+			RawExtension: runtime.RawExtension{Raw: jsonData},
+		})
+	}
+	return manifests, nil
+}
+
+// BuildUnstructuredManifests splits the yamls to documents and translates the documents to manifests, unlike the
+// BuildManifests, the documents are decoded as unstructured objects, so they can be of any kind, e.g. the custom
+// resources
+func BuildUnstructuredManifests(yamls []byte) ([]workv1.Manifest, error) {
+	manifests := []workv1.Manifest{}
+	for i, yamlData := range SplitYamls(yamls) {
+		jsonData, err := yaml.YAMLToJSON(yamlData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to translate the document %d to json: %v", i, err)
+		}
+
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(jsonData); err != nil {
+			return nil, fmt.Errorf("failed to decode the document %d: %v", i, err)
+		}
+		if len(obj.GetAPIVersion()) == 0 {
+			return nil, fmt.Errorf("failed to decode the document %d: the apiVersion is missing", i)
+		}
+
+		manifests = append(manifests, workv1.Manifest{
+			RawExtension: runtime.RawExtension{Raw: jsonData},
+		})
+	}
+	return manifests, nil
+}
+
+// IsImportManifestsDebugEnabled returns true if the DEBUG_IMPORT_MANIFESTS env is true
+func IsImportManifestsDebugEnabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv(debugImportManifestsEnvVarName))
+	return enabled
+}
+
+// BuildImportManifestsConfigMap builds a ConfigMap in the managed cluster namespace from the import secret, each yaml
+// document of the import.yaml is decoded and re-encoded into a key of the ConfigMap, the key is named with the index,
+// the kind and the name of the document, so the manifests that are applied on the managed cluster can be inspected.
+// The data of the Secrets, e.g. the bootstrap hub kubeconfig, is redacted, and the hash of the import.yaml is recorded
+// in the ImportSecretHashAnnotation of the ConfigMap.
+func BuildImportManifestsConfigMap(importSecret *corev1.Secret) (*corev1.ConfigMap, error) {
+	data := map[string]string{}
+	for i, yamlData := range SplitYamls(importSecret.Data[constants.ImportSecretImportYamlKey]) {
+		jsonData, err := yaml.YAMLToJSON(yamlData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to translate the document %d to json: %v", i, err)
+		}
+
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(jsonData); err != nil {
+			return nil, fmt.Errorf("failed to decode the document %d: %v", i, err)
+		}
+
+		if obj.GetAPIVersion() == "v1" && obj.GetKind() == "Secret" {
+			redactSecretData(obj)
+		}
+
+		manifest, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode the document %d: %v", i, err)
+		}
+
+		key := fmt.Sprintf("%02d-%s-%s.yaml", i, strings.ToLower(obj.GetKind()), obj.GetName())
+		data[key] = string(manifest)
+	}
+
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%s", importSecret.Namespace, importManifestsConfigMapSuffix),
+			Namespace: importSecret.Namespace,
+			Annotations: map[string]string{
+				constants.ImportSecretHashAnnotation: ImportSecretDataHash(importSecret,
+					constants.ImportSecretImportYamlKey),
+			},
+		},
+		Data: data,
+	}, nil
+}
+
+// redactSecretData replaces the values of the data and the stringData of the Secret with the redactedValue
+func redactSecretData(secret *unstructured.Unstructured) {
+	for _, field := range []string{"data", "stringData"} {
+		values, ok := secret.Object[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for key := range values {
+			values[key] = redactedValue
+		}
+	}
+}
+
+// ExportImportManifests returns the manifests of the import secret of the managed cluster in one multi-document yaml,
+// so the manifests can be applied on the managed cluster with the `kubectl apply -f` in the disconnected environment.
+// The klusterlet crds of the version that the managed cluster supports are placed before the import.yaml, so the crds
+// are created before the klusterlet.
+func ExportImportManifests(ctx context.Context, clientHolder *ClientHolder, managedClusterName string) ([]byte, error) {
+	managedCluster := &clusterv1.ManagedCluster{}
+	if err := clientHolder.RuntimeClient.Get(ctx, types.NamespacedName{Name: managedClusterName},
+		managedCluster); err != nil {
+		return nil, err
+	}
+
+	importSecretName := fmt.Sprintf("%s-%s", managedClusterName, constants.ImportSecretNameSuffix)
+	importSecret, err := clientHolder.KubeClient.CoreV1().Secrets(managedClusterName).Get(
+		ctx, importSecretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ValidateImportSecret(importSecret); err != nil {
+		return nil, err
+	}
+
+	crdsKey, err := SelectCRDKey(managedCluster)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf strings.Builder
+	for _, key := range []string{crdsKey, constants.ImportSecretImportYamlKey} {
+		for _, yamlData := range SplitYamls(importSecret.Data[key]) {
+			document := strings.TrimSpace(string(yamlData))
+			if len(document) == 0 {
+				continue
+			}
+			buf.WriteString(constants.YamlSperator)
+			buf.WriteString(document)
+		}
+	}
+	return []byte(buf.String()), nil
+}
+
+// ImportSecretDataHash returns the hash of the data of the given keys in the import secret, the keys are sorted,
+// so the hash does not depend on the order of the keys
+func ImportSecretDataHash(importSecret *corev1.Secret, keys ...string) string {
+	sortedKeys := append([]string{}, keys...)
+	sort.Strings(sortedKeys)
+
+	hash := sha256.New()
+	for _, key := range sortedKeys {
+		data := importSecret.Data[key]
+		// write the key and the data length to avoid the collision of the adjacent keys
+		fmt.Fprintf(hash, "%s:%d:", key, len(data))
+		hash.Write(data)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// KlusterletImagesDrifted returns true if the klusterlet images in the payload of the existing manifest work are
+// different from the images in the required manifest work that is built from the current import secret, e.g. the
+// payload of the existing manifest work is modified after it is applied.
+func KlusterletImagesDrifted(existing, required *workv1.ManifestWork) (bool, error) {
+	existingImages, err := klusterletImages(existing.Spec.Workload.Manifests)
+	if err != nil {
+		return false, fmt.Errorf("failed to get the klusterlet images of the manifest work %s/%s: %v",
+			existing.Namespace, existing.Name, err)
+	}
+
+	requiredImages, err := klusterletImages(required.Spec.Workload.Manifests)
+	if err != nil {
+		return false, fmt.Errorf("failed to get the klusterlet images of the manifest work %s/%s: %v",
+			required.Namespace, required.Name, err)
+	}
+
+	return !equality.Semantic.DeepEqual(existingImages, requiredImages), nil
+}
+
+// klusterletImages returns the images of the deployment containers and the klusterlet in the manifests, the key is
+// <kind>/<namespace>/<name>/<container or field name>
+func klusterletImages(manifests []workv1.Manifest) (map[string]string, error) {
+	images := map[string]string{}
+	for _, manifest := range manifests {
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(manifest.Raw); err != nil {
+			return nil, err
+		}
+
+		prefix := fmt.Sprintf("%s/%s/%s", obj.GetKind(), obj.GetNamespace(), obj.GetName())
+		switch obj.GetKind() {
+		case "Deployment":
+			containers, _, err := unstructured.NestedSlice(obj.Object, "spec", "template", "spec", "containers")
+			if err != nil {
+				return nil, err
+			}
+			for _, c := range containers {
+				container, ok := c.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				images[fmt.Sprintf("%s/%v", prefix, container["name"])] = fmt.Sprintf("%v", container["image"])
+			}
+		case "Klusterlet":
+			for _, field := range []string{"registrationImagePullSpec", "workImagePullSpec"} {
+				image, _, err := unstructured.NestedString(obj.Object, "spec", field)
+				if err != nil {
+					return nil, err
+				}
+				images[fmt.Sprintf("%s/%s", prefix, field)] = image
+			}
+		}
+	}
+	return images, nil
+}
+
+// MergeManifestWorkMetadata merges the additional labels and annotations into the manifest work, the additional
+// labels and annotations are JSON maps from MANIFEST_WORK_LABELS and MANIFEST_WORK_ANNOTATIONS envs, the existing
+// labels and annotations of the manifest work are preserved. The version of this controller is recorded as well.
+func MergeManifestWorkMetadata(manifestWork *workv1.ManifestWork) {
+	manifestWork.Labels = mergeMissingKeys(manifestWork.Labels, getMetadataFromEnv(manifestWorkLabelsEnvVarName))
+	manifestWork.Annotations = mergeMissingKeys(manifestWork.Annotations,
+		getMetadataFromEnv(manifestWorkAnnotationsEnvVarName))
+	SetControllerVersionAnnotation(manifestWork)
+}
+
+// SetControllerVersionAnnotation records the version of this controller in the annotation of the manifest work
+func SetControllerVersionAnnotation(manifestWork *workv1.ManifestWork) {
+	if manifestWork.Annotations == nil {
+		manifestWork.Annotations = map[string]string{}
+	}
+	manifestWork.Annotations[constants.ControllerVersionAnnotation] = controllerversion.Version
+}
+
+func getMetadataFromEnv(envName string) map[string]string {
+	metadata := map[string]string{}
+	if os.Getenv(envName) == "" {
+		return metadata
+	}
+
+	if err := json.Unmarshal([]byte(os.Getenv(envName)), &metadata); err != nil {
+		klog.Warningf("The value of %s env is wrong, ignore it: %v", envName, err)
+		return map[string]string{}
+	}
+	return metadata
+}
+
+func mergeMissingKeys(existing, additional map[string]string) map[string]string {
+	if len(additional) == 0 {
+		return existing
+	}
+
+	if existing == nil {
+		existing = map[string]string{}
+	}
+	for key, value := range additional {
+		if _, ok := existing[key]; ok {
+			continue
+		}
+		existing[key] = value
+	}
+	return existing
+}
@@ -0,0 +1,1552 @@
+// Copyright (c) Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/go-logr/logr"
+	"github.com/openshift/library-go/pkg/operator/events/eventstesting"
+	"github.com/stolostron/managedcluster-import-controller/pkg/constants"
+	testinghelpers "github.com/stolostron/managedcluster-import-controller/pkg/helpers/testing"
+	controllerversion "github.com/stolostron/managedcluster-import-controller/pkg/version"
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	workv1 "open-cluster-management.io/api/work/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestIsClusterUnavailableFor(t *testing.T) {
+	cases := []struct {
+		name     string
+		cluster  *clusterv1.ManagedCluster
+		grace    time.Duration
+		expected bool
+	}{
+		{
+			name:     "cluster is available",
+			cluster:  newAvailabilityCluster(metav1.ConditionTrue, time.Now().Add(-1*time.Hour)),
+			grace:    5 * time.Minute,
+			expected: false,
+		},
+		{
+			name:     "cluster has no available condition",
+			cluster:  &clusterv1.ManagedCluster{},
+			grace:    5 * time.Minute,
+			expected: false,
+		},
+		{
+			name:     "cluster is unavailable recently",
+			cluster:  newAvailabilityCluster(metav1.ConditionFalse, time.Now().Add(-1*time.Minute)),
+			grace:    5 * time.Minute,
+			expected: false,
+		},
+		{
+			name:     "cluster is unknown recently",
+			cluster:  newAvailabilityCluster(metav1.ConditionUnknown, time.Now().Add(-1*time.Minute)),
+			grace:    5 * time.Minute,
+			expected: false,
+		},
+		{
+			name:     "cluster is unavailable for a long time",
+			cluster:  newAvailabilityCluster(metav1.ConditionFalse, time.Now().Add(-10*time.Minute)),
+			grace:    5 * time.Minute,
+			expected: true,
+		},
+		{
+			name:     "cluster is unavailable recently without grace period",
+			cluster:  newAvailabilityCluster(metav1.ConditionUnknown, time.Now()),
+			grace:    0,
+			expected: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if actual := IsClusterUnavailableFor(c.cluster, c.grace); actual != c.expected {
+				t.Errorf("expected %v, but got %v", c.expected, actual)
+			}
+		})
+	}
+}
+
+func newAvailabilityCluster(status metav1.ConditionStatus, lastTransitionTime time.Time) *clusterv1.ManagedCluster {
+	return &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Status: clusterv1.ManagedClusterStatus{
+			Conditions: []metav1.Condition{
+				{
+					Type:               clusterv1.ManagedClusterConditionAvailable,
+					Status:             status,
+					LastTransitionTime: metav1.NewTime(lastTransitionTime),
+				},
+			},
+		},
+	}
+}
+
+func TestBuildKlusterletManifests(t *testing.T) {
+	cluster := &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+
+	cases := []struct {
+		name              string
+		importYaml        []byte
+		expectedManifests int
+		expectedErr       string
+	}{
+		{
+			name:              "valid multi-document import yaml",
+			importYaml:        testinghelpers.GetImportSecret("test").Data[constants.ImportSecretImportYamlKey],
+			expectedManifests: 9,
+		},
+		{
+			name: "import yaml has an invalid document",
+			importYaml: []byte(strings.Join([]string{
+				"",
+				"apiVersion: v1\nkind: Namespace\nmetadata:\n  name: test",
+				"apiVersion: v1\nkind: Unknown\nmetadata:\n  name: test",
+				"apiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: test",
+			}, constants.YamlSperator)),
+			expectedErr: "failed to decode the document 1",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			importSecret := testinghelpers.GetImportSecret("test")
+			importSecret.Data[constants.ImportSecretImportYamlKey] = c.importYaml
+
+			manifests, err := BuildKlusterletManifests(cluster, importSecret)
+			if len(c.expectedErr) != 0 {
+				if err == nil || !strings.Contains(err.Error(), c.expectedErr) {
+					t.Errorf("expected error %q, but got %v", c.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if len(manifests) != c.expectedManifests {
+				t.Errorf("expected %d manifests, but got %d", c.expectedManifests, len(manifests))
+			}
+		})
+	}
+}
+
+func TestSelectCRDKey(t *testing.T) {
+	cases := []struct {
+		name        string
+		kubeVersion string
+		expectedKey string
+		expectedErr bool
+	}{
+		{
+			name:        "empty version",
+			expectedKey: constants.ImportSecretCRDSV1YamlKey,
+		},
+		{
+			name:        "old version",
+			kubeVersion: "v1.11.0",
+			expectedKey: constants.ImportSecretCRDSV1beta1YamlKey,
+		},
+		{
+			name:        "new version",
+			kubeVersion: "v1.18.0",
+			expectedKey: constants.ImportSecretCRDSV1YamlKey,
+		},
+		{
+			name:        "malformed version",
+			kubeVersion: "abc",
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test"},
+				Status: clusterv1.ManagedClusterStatus{
+					Version: clusterv1.ManagedClusterVersion{Kubernetes: c.kubeVersion},
+				},
+			}
+
+			key, err := SelectCRDKey(cluster)
+			if (err != nil) != c.expectedErr {
+				t.Errorf("expected error %v, but got %v", c.expectedErr, err)
+			}
+			if key != c.expectedKey {
+				t.Errorf("expected key %q, but got %q", c.expectedKey, key)
+			}
+		})
+	}
+}
+
+func TestBuildKlusterletCRDsManifests(t *testing.T) {
+	cases := []struct {
+		name               string
+		kubeVersion        string
+		expectedAPIVersion string
+	}{
+		{
+			name:               "crd v1",
+			kubeVersion:        "v1.18.0",
+			expectedAPIVersion: "apiextensions.k8s.io/v1\"",
+		},
+		{
+			name:               "crd v1beta1",
+			kubeVersion:        "v1.11.0",
+			expectedAPIVersion: "apiextensions.k8s.io/v1beta1\"",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test"},
+				Status: clusterv1.ManagedClusterStatus{
+					Version: clusterv1.ManagedClusterVersion{Kubernetes: c.kubeVersion},
+				},
+			}
+
+			manifests, err := BuildKlusterletCRDsManifests(cluster, testinghelpers.GetImportSecret("test"))
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if len(manifests) != 1 {
+				t.Fatalf("expected 1 manifest, but got %d", len(manifests))
+			}
+			if !strings.Contains(string(manifests[0].Raw), c.expectedAPIVersion) {
+				t.Errorf("expected %s, but got %s", c.expectedAPIVersion, string(manifests[0].Raw))
+			}
+		})
+	}
+}
+
+func TestMergeManifestWorkMetadata(t *testing.T) {
+	os.Setenv(manifestWorkLabelsEnvVarName, fmt.Sprintf("{\"owner\":\"gitops\",\"%s\":\"false\"}", constants.KlusterletWorksLabel))
+	os.Setenv(manifestWorkAnnotationsEnvVarName, "{\"gitops/managed-by\":\"hub\"}")
+	defer os.Unsetenv(manifestWorkLabelsEnvVarName)
+	defer os.Unsetenv(manifestWorkAnnotationsEnvVarName)
+
+	// the version is injected at build time
+	originalVersion := controllerversion.Version
+	controllerversion.Version = "2.5.0-test"
+	defer func() { controllerversion.Version = originalVersion }()
+
+	manifestWork := &workv1.ManifestWork{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-klusterlet",
+			Namespace: "test",
+			Labels: map[string]string{
+				constants.KlusterletWorksLabel: "true",
+			},
+		},
+	}
+
+	MergeManifestWorkMetadata(manifestWork)
+
+	expectedLabels := map[string]string{
+		constants.KlusterletWorksLabel: "true",
+		"owner":                        "gitops",
+	}
+	if !reflect.DeepEqual(manifestWork.Labels, expectedLabels) {
+		t.Errorf("expected labels %v, but got %v", expectedLabels, manifestWork.Labels)
+	}
+
+	expectedAnnotations := map[string]string{
+		"gitops/managed-by":                   "hub",
+		constants.ControllerVersionAnnotation: "2.5.0-test",
+	}
+	if !reflect.DeepEqual(manifestWork.Annotations, expectedAnnotations) {
+		t.Errorf("expected annotations %v, but got %v", expectedAnnotations, manifestWork.Annotations)
+	}
+}
+
+func TestImportSecretDataHash(t *testing.T) {
+	importSecret := &corev1.Secret{
+		Data: map[string][]byte{
+			constants.ImportSecretCRDSV1YamlKey:      []byte("crdsv1"),
+			constants.ImportSecretCRDSV1beta1YamlKey: []byte("crdsv1beta1"),
+			constants.ImportSecretImportYamlKey:      []byte("import"),
+		},
+	}
+	hash := ImportSecretDataHash(importSecret, constants.ImportSecretCRDSV1YamlKey, constants.ImportSecretImportYamlKey)
+
+	cases := []struct {
+		name          string
+		keys          []string
+		modify        func(secret *corev1.Secret)
+		expectedEqual bool
+	}{
+		{
+			name:          "the keys are reordered",
+			keys:          []string{constants.ImportSecretImportYamlKey, constants.ImportSecretCRDSV1YamlKey},
+			modify:        func(secret *corev1.Secret) {},
+			expectedEqual: true,
+		},
+		{
+			name: "an untracked key is changed",
+			keys: []string{constants.ImportSecretCRDSV1YamlKey, constants.ImportSecretImportYamlKey},
+			modify: func(secret *corev1.Secret) {
+				secret.Data[constants.ImportSecretCRDSV1beta1YamlKey] = []byte("changed")
+			},
+			expectedEqual: true,
+		},
+		{
+			name: "the crds key is changed",
+			keys: []string{constants.ImportSecretCRDSV1YamlKey, constants.ImportSecretImportYamlKey},
+			modify: func(secret *corev1.Secret) {
+				secret.Data[constants.ImportSecretCRDSV1YamlKey] = []byte("changed")
+			},
+			expectedEqual: false,
+		},
+		{
+			name: "the import key is changed",
+			keys: []string{constants.ImportSecretCRDSV1YamlKey, constants.ImportSecretImportYamlKey},
+			modify: func(secret *corev1.Secret) {
+				secret.Data[constants.ImportSecretImportYamlKey] = []byte("changed")
+			},
+			expectedEqual: false,
+		},
+		{
+			name: "the data is moved between the keys",
+			keys: []string{constants.ImportSecretCRDSV1YamlKey, constants.ImportSecretImportYamlKey},
+			modify: func(secret *corev1.Secret) {
+				secret.Data[constants.ImportSecretCRDSV1YamlKey] = []byte("crdsv1import")
+				secret.Data[constants.ImportSecretImportYamlKey] = []byte("")
+			},
+			expectedEqual: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			secret := importSecret.DeepCopy()
+			c.modify(secret)
+
+			actual := ImportSecretDataHash(secret, c.keys...)
+			if (actual == hash) != c.expectedEqual {
+				t.Errorf("expected the hash equal is %v, but got %s and %s", c.expectedEqual, hash, actual)
+			}
+		})
+	}
+}
+
+func TestNoPendingManifestWorksWithLabelSelector(t *testing.T) {
+	requirement, err := labels.NewRequirement(constants.AddonWorksLabel, selection.Exists, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	addonLabelSelector := labels.NewSelector().Add(*requirement)
+
+	ignoreKlusterlet := func(clusterName string, manifestWork workv1.ManifestWork) bool {
+		return manifestWork.GetName() == fmt.Sprintf("%s-klusterlet", clusterName)
+	}
+
+	cases := []struct {
+		name              string
+		objs              []client.Object
+		expectedNoPending bool
+	}{
+		{
+			name:              "no manifest works",
+			objs:              []client.Object{},
+			expectedNoPending: true,
+		},
+		{
+			name: "only labeled addon works",
+			objs: []client.Object{
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-klusterlet",
+						Namespace: "test",
+					},
+				},
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "my-addon-manifests",
+						Namespace: "test",
+						Labels: map[string]string{
+							constants.AddonWorksLabel: "my-addon",
+						},
+					},
+				},
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "addon-other-addon-deploy",
+						Namespace: "test",
+						Labels: map[string]string{
+							constants.AddonWorksLabel: "other-addon",
+						},
+					},
+				},
+			},
+			expectedNoPending: true,
+		},
+		{
+			name: "labeled addon works and user works",
+			objs: []client.Object{
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "my-addon-manifests",
+						Namespace: "test",
+						Labels: map[string]string{
+							constants.AddonWorksLabel: "my-addon",
+						},
+					},
+				},
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "user-work",
+						Namespace: "test",
+					},
+				},
+			},
+			expectedNoPending: false,
+		},
+		{
+			name: "unlabeled works with addon name",
+			objs: []client.Object{
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "addon-my-addon-deploy",
+						Namespace: "test",
+					},
+				},
+			},
+			expectedNoPending: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			runtimeClient := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(c.objs...).Build()
+
+			noPending, err := NoPendingManifestWorksWithLabelSelector(
+				context.TODO(), runtimeClient, logr.Discard(), "test", addonLabelSelector, ignoreKlusterlet)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if noPending != c.expectedNoPending {
+				t.Errorf("expected no pending manifest works is %v, but got %v", c.expectedNoPending, noPending)
+			}
+		})
+	}
+}
+
+func TestListKlusterletManifestWorks(t *testing.T) {
+	klusterletWorksLabels := map[string]string{constants.KlusterletWorksLabel: "true"}
+
+	cases := []struct {
+		name                   string
+		objs                   []client.Object
+		expectedCRDsWork       bool
+		expectedKlusterletWork bool
+	}{
+		{
+			name:                   "no klusterlet works",
+			objs:                   []client.Object{},
+			expectedCRDsWork:       false,
+			expectedKlusterletWork: false,
+		},
+		{
+			name: "only the crds work",
+			objs: []client.Object{
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-klusterlet-crds",
+						Namespace: "test",
+						Labels:    klusterletWorksLabels,
+					},
+				},
+			},
+			expectedCRDsWork:       true,
+			expectedKlusterletWork: false,
+		},
+		{
+			name: "both klusterlet works",
+			objs: []client.Object{
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-klusterlet-crds",
+						Namespace: "test",
+						Labels:    klusterletWorksLabels,
+					},
+				},
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-klusterlet",
+						Namespace: "test",
+						Labels:    klusterletWorksLabels,
+					},
+				},
+			},
+			expectedCRDsWork:       true,
+			expectedKlusterletWork: true,
+		},
+		{
+			name: "the klusterlet work without label",
+			objs: []client.Object{
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-klusterlet",
+						Namespace: "test",
+					},
+				},
+			},
+			expectedCRDsWork:       false,
+			expectedKlusterletWork: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			runtimeClient := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(c.objs...).Build()
+
+			crdsWork, klusterletWork, err := ListKlusterletManifestWorks(context.TODO(), runtimeClient, "test")
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if (crdsWork != nil) != c.expectedCRDsWork {
+				t.Errorf("expected crds work exists is %v, but got %v", c.expectedCRDsWork, crdsWork)
+			}
+			if crdsWork != nil && crdsWork.Name != "test-klusterlet-crds" {
+				t.Errorf("unexpected crds work %s", crdsWork.Name)
+			}
+			if (klusterletWork != nil) != c.expectedKlusterletWork {
+				t.Errorf("expected klusterlet work exists is %v, but got %v", c.expectedKlusterletWork, klusterletWork)
+			}
+			if klusterletWork != nil && klusterletWork.Name != "test-klusterlet" {
+				t.Errorf("unexpected klusterlet work %s", klusterletWork.Name)
+			}
+		})
+	}
+}
+
+func TestAssertManifestWorkFinalizer(t *testing.T) {
+	cases := []struct {
+		name               string
+		finalizers         []string
+		works              []client.Object
+		expectedFinalizers []string
+	}{
+		{
+			name:               "the last work is deleted after it was listed",
+			finalizers:         []string{constants.ManifestWorkFinalizer},
+			works:              []client.Object{},
+			expectedFinalizers: []string{},
+		},
+		{
+			name:       "the cluster has works",
+			finalizers: []string{},
+			works: []client.Object{
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-klusterlet",
+						Namespace: "test",
+					},
+				},
+			},
+			expectedFinalizers: []string{constants.ManifestWorkFinalizer},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "test",
+					Finalizers: c.finalizers,
+				},
+			}
+			objs := append([]client.Object{cluster}, c.works...)
+			runtimeClient := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(objs...).Build()
+
+			err := AssertManifestWorkFinalizer(context.TODO(), runtimeClient, eventstesting.NewTestingEventRecorder(t),
+				cluster, NamespacedManifestWorksCounter(runtimeClient, "test"))
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			actual := &clusterv1.ManagedCluster{}
+			if err := runtimeClient.Get(context.TODO(), types.NamespacedName{Name: "test"}, actual); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if len(actual.Finalizers) != len(c.expectedFinalizers) {
+				t.Errorf("expected finalizers %v, but got %v", c.expectedFinalizers, actual.Finalizers)
+			}
+			for i := range c.expectedFinalizers {
+				if actual.Finalizers[i] != c.expectedFinalizers[i] {
+					t.Errorf("expected finalizers %v, but got %v", c.expectedFinalizers, actual.Finalizers)
+				}
+			}
+		})
+	}
+}
+
+func TestForceDeleteManifestWorksBySelector(t *testing.T) {
+	runtimeClient := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(
+		&workv1.ManifestWork{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "test-klusterlet",
+				Namespace:  "test",
+				Labels:     map[string]string{constants.KlusterletWorksLabel: "true"},
+				Finalizers: []string{"test"},
+			},
+		},
+		&workv1.ManifestWork{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-klusterlet-crds",
+				Namespace: "test",
+				Labels:    map[string]string{constants.KlusterletWorksLabel: "true"},
+			},
+		},
+		&workv1.ManifestWork{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "user-work",
+				Namespace: "test",
+			},
+		},
+		&workv1.ManifestWork{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "other-klusterlet",
+				Namespace: "other",
+				Labels:    map[string]string{constants.KlusterletWorksLabel: "true"},
+			},
+		},
+	).Build()
+
+	err := ForceDeleteManifestWorksBySelector(context.TODO(), runtimeClient, eventstesting.NewTestingEventRecorder(t),
+		"test", labels.SelectorFromSet(map[string]string{constants.KlusterletWorksLabel: "true"}))
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	manifestWorks := &workv1.ManifestWorkList{}
+	if err := runtimeClient.List(context.TODO(), manifestWorks); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	remains := []string{}
+	for _, manifestWork := range manifestWorks.Items {
+		remains = append(remains, fmt.Sprintf("%s/%s", manifestWork.Namespace, manifestWork.Name))
+	}
+	sort.Strings(remains)
+	expected := []string{"other/other-klusterlet", "test/user-work"}
+	if !reflect.DeepEqual(remains, expected) {
+		t.Errorf("expected remaining manifest works %v, but got %v", expected, remains)
+	}
+}
+
+func TestAddonsCleaned(t *testing.T) {
+	cases := []struct {
+		name            string
+		objs            []client.Object
+		expectedCleaned bool
+	}{
+		{
+			name: "addons present",
+			objs: []client.Object{
+				&addonv1alpha1.ManagedClusterAddOn{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-addon",
+						Namespace: "test",
+					},
+				},
+			},
+			expectedCleaned: false,
+		},
+		{
+			name: "addons gone but addon works linger",
+			objs: []client.Object{
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "addon-test-addon-deploy",
+						Namespace: "test",
+					},
+				},
+			},
+			expectedCleaned: false,
+		},
+		{
+			name: "addons gone but labeled addon works linger",
+			objs: []client.Object{
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-addon-manifests",
+						Namespace: "test",
+						Labels: map[string]string{
+							constants.AddonWorksLabel: "test-addon",
+						},
+					},
+				},
+			},
+			expectedCleaned: false,
+		},
+		{
+			name: "fully clean",
+			objs: []client.Object{
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-klusterlet",
+						Namespace: "test",
+					},
+				},
+			},
+			expectedCleaned: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			runtimeClient := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(c.objs...).Build()
+
+			cleaned, err := AddonsCleaned(context.TODO(), runtimeClient, "test")
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if cleaned != c.expectedCleaned {
+				t.Errorf("expected addons cleaned is %v, but got %v", c.expectedCleaned, cleaned)
+			}
+		})
+	}
+}
+
+// blockingDeleteClient blocks the delete requests until the request context is done, it simulates a stuck api server
+type blockingDeleteClient struct {
+	client.Client
+}
+
+func (c *blockingDeleteClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestForceDeleteManifestWorkTimeout(t *testing.T) {
+	os.Setenv(forceDeleteTimeoutEnvVarName, "100ms")
+	defer os.Unsetenv(forceDeleteTimeoutEnvVarName)
+
+	runtimeClient := &blockingDeleteClient{
+		Client: fake.NewClientBuilder().WithScheme(testscheme).WithObjects(
+			&workv1.ManifestWork{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "test-klusterlet",
+					Namespace:  "test",
+					Finalizers: []string{"test"},
+				},
+			},
+		).Build(),
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- ForceDeleteManifestWork(
+			context.TODO(), runtimeClient, eventstesting.NewTestingEventRecorder(t), "test", "test-klusterlet")
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected deadline exceeded error, but got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the force deletion is timed out, but it is blocked")
+	}
+}
+
+func TestGetForceDeleteTimeout(t *testing.T) {
+	cases := []struct {
+		name     string
+		value    string
+		expected time.Duration
+	}{
+		{
+			name:     "env is not set",
+			expected: defaultForceDeleteTimeout,
+		},
+		{
+			name:     "valid timeout",
+			value:    "1m",
+			expected: time.Minute,
+		},
+		{
+			name:     "invalid timeout",
+			value:    "abc",
+			expected: defaultForceDeleteTimeout,
+		},
+		{
+			name:     "non-positive timeout",
+			value:    "0s",
+			expected: defaultForceDeleteTimeout,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			os.Setenv(forceDeleteTimeoutEnvVarName, c.value)
+			defer os.Unsetenv(forceDeleteTimeoutEnvVarName)
+
+			if timeout := GetForceDeleteTimeout(); timeout != c.expected {
+				t.Errorf("expected %v, but got %v", c.expected, timeout)
+			}
+		})
+	}
+}
+
+func TestKlusterletImagesDrifted(t *testing.T) {
+	cluster := &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+	buildWork := func(importSecret *corev1.Secret) *workv1.ManifestWork {
+		manifests, err := BuildKlusterletManifests(cluster, importSecret)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return &workv1.ManifestWork{
+			Spec: workv1.ManifestWorkSpec{
+				Workload: workv1.ManifestsTemplate{Manifests: manifests},
+			},
+		}
+	}
+	changeImage := func(image, newImage string) *corev1.Secret {
+		importSecret := testinghelpers.GetImportSecret("test")
+		importSecret.Data[constants.ImportSecretImportYamlKey] = []byte(strings.ReplaceAll(
+			string(importSecret.Data[constants.ImportSecretImportYamlKey]), image, newImage))
+		return importSecret
+	}
+
+	cases := []struct {
+		name            string
+		existingSecret  *corev1.Secret
+		expectedDrifted bool
+	}{
+		{
+			name:            "no drift",
+			existingSecret:  testinghelpers.GetImportSecret("test"),
+			expectedDrifted: false,
+		},
+		{
+			name:            "operator image changed",
+			existingSecret:  changeImage("registration-operator:latest", "registration-operator:old"),
+			expectedDrifted: true,
+		},
+		{
+			name:            "klusterlet image changed",
+			existingSecret:  changeImage("work:latest", "work:old"),
+			expectedDrifted: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			drifted, err := KlusterletImagesDrifted(
+				buildWork(c.existingSecret), buildWork(testinghelpers.GetImportSecret("test")))
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if drifted != c.expectedDrifted {
+				t.Errorf("expected drifted %v, but got %v", c.expectedDrifted, drifted)
+			}
+		})
+	}
+}
+
+func TestManifestWorkNames(t *testing.T) {
+	cases := []struct {
+		name         string
+		workName     func(clusterName string) string
+		expectedName string
+	}{
+		{
+			name:         "klusterlet work",
+			workName:     KlusterletWorkName,
+			expectedName: "cluster1-klusterlet",
+		},
+		{
+			name:         "klusterlet crds work",
+			workName:     KlusterletCRDsWorkName,
+			expectedName: "cluster1-klusterlet-crds",
+		},
+		{
+			name:         "hosted klusterlet work",
+			workName:     HostedKlusterletWorkName,
+			expectedName: "cluster1-hosted-klusterlet",
+		},
+		{
+			name:         "hosted managed kubeconfig work",
+			workName:     HostedManagedKubeconfigWorkName,
+			expectedName: "cluster1-hosted-kubeconfig",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if name := c.workName("cluster1"); name != c.expectedName {
+				t.Errorf("expected %s, but got %s", c.expectedName, name)
+			}
+		})
+	}
+}
+
+// delayedDeleteClient removes the deleted objects after the objects are polled the given times
+type delayedDeleteClient struct {
+	client.Client
+	polls    int
+	deleted  []client.Object
+	getCount int
+}
+
+func (c *delayedDeleteClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	c.deleted = append(c.deleted, obj)
+	return nil
+}
+
+func (c *delayedDeleteClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	if len(c.deleted) != 0 {
+		c.getCount++
+		if c.getCount > c.polls {
+			for _, deleted := range c.deleted {
+				if err := c.Client.Delete(ctx, deleted); err != nil {
+					return err
+				}
+			}
+			c.deleted = nil
+		}
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func TestDeleteManifestWorkAndWait(t *testing.T) {
+	cases := []struct {
+		name        string
+		polls       int
+		expectedErr error
+	}{
+		{
+			name:  "the manifest work is removed after one poll",
+			polls: 1,
+		},
+		{
+			name:        "the manifest work is not removed in time",
+			polls:       1000,
+			expectedErr: wait.ErrWaitTimeout,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			runtimeClient := &delayedDeleteClient{
+				Client: fake.NewClientBuilder().WithScheme(testscheme).WithObjects(
+					&workv1.ManifestWork{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "test-klusterlet",
+							Namespace: "test",
+						},
+					},
+				).Build(),
+				polls: c.polls,
+			}
+
+			err := DeleteManifestWorkAndWait(context.TODO(), runtimeClient, eventstesting.NewTestingEventRecorder(t),
+				"test", "test-klusterlet", 10*time.Millisecond, 200*time.Millisecond)
+			if !errors.Is(err, c.expectedErr) {
+				t.Errorf("expected error %v, but got %v", c.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestPostponeDeleteDuration(t *testing.T) {
+	cases := []struct {
+		name             string
+		annotations      map[string]string
+		expectedDuration time.Duration
+		expectedPostpone bool
+	}{
+		{
+			name: "no annotation",
+		},
+		{
+			name:             "bare annotation",
+			annotations:      map[string]string{constants.PostponeDeletionAnnotation: ""},
+			expectedDuration: constants.ManifestWorkPostponeDeleteTime,
+			expectedPostpone: true,
+		},
+		{
+			name:             "annotation with a custom duration",
+			annotations:      map[string]string{constants.PostponeDeletionAnnotation: "30m"},
+			expectedDuration: 30 * time.Minute,
+			expectedPostpone: true,
+		},
+		{
+			name:             "annotation with an invalid duration",
+			annotations:      map[string]string{constants.PostponeDeletionAnnotation: "abc"},
+			expectedDuration: constants.ManifestWorkPostponeDeleteTime,
+			expectedPostpone: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work := workv1.ManifestWork{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Namespace:   "test",
+					Annotations: c.annotations,
+				},
+			}
+
+			duration, postpone := PostponeDeleteDuration(work)
+			if postpone != c.expectedPostpone {
+				t.Errorf("expected postpone %v, but got %v", c.expectedPostpone, postpone)
+			}
+			if duration != c.expectedDuration {
+				t.Errorf("expected duration %v, but got %v", c.expectedDuration, duration)
+			}
+		})
+	}
+}
+
+func TestKlusterletAppliedCondition(t *testing.T) {
+	newWork := func(conds ...metav1.Condition) *workv1.ManifestWork {
+		return &workv1.ManifestWork{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-klusterlet",
+				Namespace: "test",
+			},
+			Status: workv1.ManifestWorkStatus{
+				Conditions: conds,
+			},
+		}
+	}
+
+	cases := []struct {
+		name           string
+		work           *workv1.ManifestWork
+		expectedStatus metav1.ConditionStatus
+		expectedReason string
+	}{
+		{
+			name:           "no klusterlet manifest work",
+			expectedStatus: metav1.ConditionUnknown,
+			expectedReason: "KlusterletManifestWorkApplying",
+		},
+		{
+			name:           "klusterlet manifest work is not applied yet",
+			work:           newWork(),
+			expectedStatus: metav1.ConditionUnknown,
+			expectedReason: "KlusterletManifestWorkApplying",
+		},
+		{
+			name: "klusterlet manifest work is failed to apply",
+			work: newWork(metav1.Condition{
+				Type: workv1.WorkApplied, Status: metav1.ConditionFalse, Message: "failed"}),
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: "KlusterletManifestWorkNotApplied",
+		},
+		{
+			name: "klusterlet manifest work is applied",
+			work: newWork(metav1.Condition{
+				Type: workv1.WorkApplied, Status: metav1.ConditionTrue}),
+			expectedStatus: metav1.ConditionTrue,
+			expectedReason: "KlusterletManifestWorkApplied",
+		},
+		{
+			name: "klusterlet manifest work is available",
+			work: newWork(
+				metav1.Condition{Type: workv1.WorkApplied, Status: metav1.ConditionTrue},
+				metav1.Condition{Type: workv1.WorkAvailable, Status: metav1.ConditionTrue},
+			),
+			expectedStatus: metav1.ConditionTrue,
+			expectedReason: "KlusterletManifestWorkAvailable",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cond := KlusterletAppliedCondition(c.work)
+			if cond.Type != constants.KlusterletAppliedConditionType {
+				t.Errorf("expected condition type %s, but got %s", constants.KlusterletAppliedConditionType, cond.Type)
+			}
+			if cond.Status != c.expectedStatus || cond.Reason != c.expectedReason {
+				t.Errorf("expected %s %s, but got %s %s", c.expectedStatus, c.expectedReason, cond.Status, cond.Reason)
+			}
+		})
+	}
+}
+
+func TestBuildKlusterletManifestsWithImageOverride(t *testing.T) {
+	importSecret := testinghelpers.GetImportSecret("test")
+	defaultImages, err := klusterletImages(mustBuildManifests(t, importSecret))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cases := []struct {
+		name          string
+		annotations   map[string]string
+		expectedImage string
+		expectedErr   string
+	}{
+		{
+			name:          "valid override",
+			annotations:   map[string]string{constants.KlusterletImageOverrideAnnotation: "mirror.example.com:5000/ocm/registration-operator:v1"},
+			expectedImage: "mirror.example.com:5000/ocm/registration-operator:v1",
+		},
+		{
+			name:        "empty annotation",
+			annotations: map[string]string{constants.KlusterletImageOverrideAnnotation: ""},
+		},
+		{
+			name:        "malformed reference",
+			annotations: map[string]string{constants.KlusterletImageOverrideAnnotation: "Mirror.example.com/OCM:v1:v2"},
+			expectedErr: "is not a valid image reference",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Annotations: c.annotations,
+				},
+			}
+
+			manifests, err := BuildKlusterletManifests(cluster, importSecret)
+			if len(c.expectedErr) != 0 {
+				if err == nil || !strings.Contains(err.Error(), c.expectedErr) {
+					t.Errorf("expected error %q, but got %v", c.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			images, err := klusterletImages(manifests)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for key, image := range images {
+				expectedImage := defaultImages[key]
+				if strings.HasPrefix(key, "Deployment/") && len(c.expectedImage) != 0 {
+					expectedImage = c.expectedImage
+				}
+				if image != expectedImage {
+					t.Errorf("expected image %q for %s, but got %q", expectedImage, key, image)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildKlusterletManifestsWithKlusterletLabels(t *testing.T) {
+	importSecret := testinghelpers.GetImportSecret("test")
+
+	cases := []struct {
+		name           string
+		annotations    map[string]string
+		expectedLabels map[string]string
+		expectedErr    string
+	}{
+		{
+			name:           "no label annotations",
+			annotations:    map[string]string{"team": "a"},
+			expectedLabels: map[string]string{},
+		},
+		{
+			name: "propagate label annotations",
+			annotations: map[string]string{
+				constants.KlusterletLabelAnnotationPrefix + "team": "a",
+				constants.KlusterletLabelAnnotationPrefix + "env":  "prod",
+				"team": "b",
+			},
+			expectedLabels: map[string]string{"team": "a", "env": "prod"},
+		},
+		{
+			name:        "invalid label value",
+			annotations: map[string]string{constants.KlusterletLabelAnnotationPrefix + "team": "a b"},
+			expectedErr: "invalid klusterlet label annotations of cluster test",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Annotations: c.annotations,
+				},
+			}
+
+			manifests, err := BuildKlusterletManifests(cluster, importSecret)
+			if len(c.expectedErr) != 0 {
+				if err == nil || !strings.Contains(err.Error(), c.expectedErr) {
+					t.Errorf("expected error %q, but got %v", c.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			labels, err := klusterletLabels(manifests)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(labels, c.expectedLabels) {
+				t.Errorf("expected labels %v, but got %v", c.expectedLabels, labels)
+			}
+		})
+	}
+}
+
+// klusterletLabels returns the labels of the Klusterlet CR in the manifests
+func klusterletLabels(manifests []workv1.Manifest) (map[string]string, error) {
+	labels := map[string]string{}
+	for _, manifest := range manifests {
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(manifest.Raw); err != nil {
+			return nil, err
+		}
+
+		if obj.GetKind() == "Klusterlet" {
+			for key, val := range obj.GetLabels() {
+				labels[key] = val
+			}
+		}
+	}
+	return labels, nil
+}
+
+func mustBuildManifests(t *testing.T, importSecret *corev1.Secret) []workv1.Manifest {
+	manifests, err := BuildManifests(importSecret.Data[constants.ImportSecretImportYamlKey])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return manifests
+}
+
+func TestBuildImportManifestsConfigMap(t *testing.T) {
+	importSecret := testinghelpers.GetImportSecret("test")
+	manifests := mustBuildManifests(t, importSecret)
+
+	configMap, err := BuildImportManifestsConfigMap(importSecret)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if configMap.Name != "test-import-manifests" || configMap.Namespace != "test" {
+		t.Errorf("unexpected ConfigMap %s/%s", configMap.Namespace, configMap.Name)
+	}
+	if configMap.Annotations[constants.ImportSecretHashAnnotation] !=
+		ImportSecretDataHash(importSecret, constants.ImportSecretImportYamlKey) {
+		t.Errorf("unexpected import secret hash %v", configMap.Annotations)
+	}
+	if len(configMap.Data) != len(manifests) {
+		t.Fatalf("expected %d manifests, but got %d", len(manifests), len(configMap.Data))
+	}
+
+	keys := []string{}
+	for key := range configMap.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for i, key := range keys {
+		actual := map[string]interface{}{}
+		if err := yaml.Unmarshal([]byte(configMap.Data[key]), &actual); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expected := map[string]interface{}{}
+		if err := json.Unmarshal(manifests[i].Raw, &expected); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if expected["kind"] == "Secret" {
+			// the data of the secrets is redacted
+			for key := range expected["data"].(map[string]interface{}) {
+				expected["data"].(map[string]interface{})[key] = "REDACTED"
+			}
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("expected the manifest %s is %v, but got %v", key, expected, actual)
+		}
+	}
+}
+
+func TestIsManifestWorkDeletionBlocked(t *testing.T) {
+	newWork := func(deletionAge time.Duration) *workv1.ManifestWork {
+		work := &workv1.ManifestWork{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"}}
+		if deletionAge > 0 {
+			deletionTimestamp := metav1.NewTime(time.Now().Add(-deletionAge))
+			work.DeletionTimestamp = &deletionTimestamp
+		}
+		return work
+	}
+
+	cases := []struct {
+		name     string
+		work     *workv1.ManifestWork
+		expected bool
+	}{
+		{
+			name: "the manifest work is not terminating",
+			work: newWork(0),
+		},
+		{
+			name: "the manifest work is terminating within the threshold",
+			work: newWork(time.Minute),
+		},
+		{
+			name:     "the manifest work is terminating beyond the threshold",
+			work:     newWork(10 * time.Minute),
+			expected: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if actual := IsManifestWorkDeletionBlocked(c.work, 5*time.Minute); actual != c.expected {
+				t.Errorf("expected %v, but got %v", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestExportImportManifests(t *testing.T) {
+	cases := []struct {
+		name               string
+		kubeVersion        string
+		expectedCRDVersion string
+	}{
+		{
+			name:               "the kube version is unknown",
+			expectedCRDVersion: "apiextensions.k8s.io/v1",
+		},
+		{
+			name:               "the managed cluster supports the crd v1",
+			kubeVersion:        "v1.22.0",
+			expectedCRDVersion: "apiextensions.k8s.io/v1",
+		},
+		{
+			name:               "the managed cluster does not support the crd v1",
+			kubeVersion:        "v1.15.0",
+			expectedCRDVersion: "apiextensions.k8s.io/v1beta1",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			managedCluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test"},
+				Status: clusterv1.ManagedClusterStatus{
+					Version: clusterv1.ManagedClusterVersion{Kubernetes: c.kubeVersion},
+				},
+			}
+			clientHolder := &ClientHolder{
+				KubeClient:    kubefake.NewSimpleClientset(testinghelpers.GetImportSecret("test")),
+				RuntimeClient: fake.NewClientBuilder().WithScheme(testscheme).WithObjects(managedCluster).Build(),
+			}
+
+			manifests, err := ExportImportManifests(context.TODO(), clientHolder, "test")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			kinds := map[string]string{}
+			for _, document := range SplitYamls(manifests) {
+				obj := map[string]interface{}{}
+				if err := yaml.Unmarshal(document, &obj); err != nil {
+					t.Fatalf("failed to decode the document %q: %v", string(document), err)
+				}
+				if len(obj) == 0 {
+					continue
+				}
+				kinds[fmt.Sprintf("%v", obj["kind"])] = fmt.Sprintf("%v", obj["apiVersion"])
+			}
+
+			if kinds["CustomResourceDefinition"] != c.expectedCRDVersion {
+				t.Errorf("expected the crd %s, but got %v", c.expectedCRDVersion, kinds)
+			}
+			if _, ok := kinds["Klusterlet"]; !ok {
+				t.Errorf("expected the klusterlet, but got %v", kinds)
+			}
+		})
+	}
+}
+
+func TestExportImportManifestsWithoutImportSecret(t *testing.T) {
+	clientHolder := &ClientHolder{
+		KubeClient: kubefake.NewSimpleClientset(),
+		RuntimeClient: fake.NewClientBuilder().WithScheme(testscheme).WithObjects(&clusterv1.ManagedCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		}).Build(),
+	}
+
+	if _, err := ExportImportManifests(context.TODO(), clientHolder, "test"); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the not found error, but got %v", err)
+	}
+}
+
+func TestDetectKlusterletWorksConflict(t *testing.T) {
+	klusterletWorksLabels := map[string]string{constants.KlusterletWorksLabel: "true"}
+	newWork := func(namespace, name string) *workv1.ManifestWork {
+		return &workv1.ManifestWork{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels:    klusterletWorksLabels,
+			},
+		}
+	}
+	defaultCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+	}
+	hostedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+			Annotations: map[string]string{
+				constants.KlusterletDeployModeAnnotation: constants.KlusterletDeployModeHosted,
+				constants.HostingClusterNameAnnotation:   "hosting",
+			},
+		},
+	}
+
+	cases := []struct {
+		name               string
+		cluster            *clusterv1.ManagedCluster
+		objs               []client.Object
+		cleanup            bool
+		expectedNamespaces []string
+		expectedStaleWorks []string
+		expectedWorks      int
+	}{
+		{
+			name:          "single namespace",
+			cluster:       defaultCluster,
+			objs:          []client.Object{newWork("test", "test-klusterlet-crds"), newWork("test", "test-klusterlet")},
+			expectedWorks: 2,
+		},
+		{
+			name:    "the klusterlet work of the managed cluster with the hosted suffix",
+			cluster: defaultCluster,
+			objs: []client.Object{
+				newWork("test", "test-klusterlet"),
+				newWork("test-hosted", "test-hosted-klusterlet"),
+			},
+			expectedWorks: 2,
+		},
+		{
+			name:    "dual namespaces in default mode",
+			cluster: defaultCluster,
+			objs: []client.Object{
+				newWork("test", "test-klusterlet-crds"),
+				newWork("test", "test-klusterlet"),
+				newWork("hosting", "test-hosted-klusterlet"),
+			},
+			expectedNamespaces: []string{"hosting", "test"},
+			expectedStaleWorks: []string{"hosting/test-hosted-klusterlet"},
+			expectedWorks:      3,
+		},
+		{
+			name:    "dual namespaces in hosted mode",
+			cluster: hostedCluster,
+			objs: []client.Object{
+				newWork("test", "test-klusterlet-crds"),
+				newWork("test", "test-klusterlet"),
+				newWork("hosting", "test-hosted-klusterlet"),
+			},
+			expectedNamespaces: []string{"hosting", "test"},
+			expectedStaleWorks: []string{"test/test-klusterlet", "test/test-klusterlet-crds"},
+			expectedWorks:      3,
+		},
+		{
+			name:    "dual namespaces with cleanup",
+			cluster: defaultCluster,
+			objs: []client.Object{
+				newWork("test", "test-klusterlet"),
+				newWork("hosting", "test-hosted-klusterlet"),
+			},
+			cleanup:            true,
+			expectedNamespaces: []string{"hosting", "test"},
+			expectedStaleWorks: []string{"hosting/test-hosted-klusterlet"},
+			expectedWorks:      1,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if c.cleanup {
+				t.Setenv(cleanUpStaleKlusterletWorksEnvVarName, "true")
+			}
+			runtimeClient := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(c.objs...).Build()
+
+			conflict, err := DetectKlusterletWorksConflict(context.TODO(), runtimeClient, c.cluster)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			cond := KlusterletWorksConflictCondition(conflict)
+			if len(c.expectedNamespaces) == 0 {
+				if conflict != nil {
+					t.Errorf("expected no conflict, but got %v", conflict.Namespaces)
+				}
+				if cond.Status != metav1.ConditionFalse {
+					t.Errorf("expected condition status false, but got %s", cond.Status)
+				}
+			} else {
+				if conflict == nil {
+					t.Fatalf("expected conflict, but failed")
+				}
+				if !reflect.DeepEqual(conflict.Namespaces, c.expectedNamespaces) {
+					t.Errorf("expected namespaces %v, but got %v", c.expectedNamespaces, conflict.Namespaces)
+				}
+				staleWorks := []string{}
+				for _, work := range conflict.StaleWorks {
+					staleWorks = append(staleWorks, work.Namespace+"/"+work.Name)
+				}
+				sort.Strings(staleWorks)
+				if !reflect.DeepEqual(staleWorks, c.expectedStaleWorks) {
+					t.Errorf("expected stale works %v, but got %v", c.expectedStaleWorks, staleWorks)
+				}
+				if cond.Status != metav1.ConditionTrue {
+					t.Errorf("expected condition status true, but got %s", cond.Status)
+				}
+			}
+
+			if err := CleanUpStaleKlusterletWorks(context.TODO(), runtimeClient,
+				eventstesting.NewTestingEventRecorder(t), conflict); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			works := &workv1.ManifestWorkList{}
+			if err := runtimeClient.List(context.TODO(), works); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if len(works.Items) != c.expectedWorks {
+				t.Errorf("expected %d works, but got %d", c.expectedWorks, len(works.Items))
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/stolostron/managedcluster-import-controller/pkg/constants"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+)
+
+// importVerbs are the verbs that are required to apply a resource of the import secret on the managed cluster, the
+// resources are got first, then they are created or updated
+var importVerbs = []string{"get", "create", "update"}
+
+// Permission is a verb on a resource that the credentials of a secret requires on the managed cluster
+type Permission struct {
+	Verb      string
+	Group     string
+	Resource  string
+	Namespace string
+}
+
+func (p Permission) String() string {
+	resource := p.Resource
+	if len(p.Group) != 0 {
+		resource = fmt.Sprintf("%s.%s", p.Resource, p.Group)
+	}
+	if len(p.Namespace) == 0 {
+		return fmt.Sprintf("%s %s", p.Verb, resource)
+	}
+	return fmt.Sprintf("%s %s in namespace %s", p.Verb, resource, p.Namespace)
+}
+
+// ImportPermissions returns the permissions that are required to apply the resources of the import secret on the
+// managed cluster, the permissions are in the order of the resources in the import secret
+func ImportPermissions(importSecret *corev1.Secret) ([]Permission, error) {
+	if err := ValidateImportSecret(importSecret); err != nil {
+		return nil, err
+	}
+
+	objs := []runtime.Object{MustCreateObject(importSecret.Data[constants.ImportSecretCRDSV1YamlKey])}
+	for _, yaml := range SplitYamls(importSecret.Data[constants.ImportSecretImportYamlKey]) {
+		objs = append(objs, MustCreateObject(yaml))
+	}
+
+	permissions := []Permission{}
+	added := map[Permission]bool{}
+	for _, obj := range objs {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		gvr, _ := meta.UnsafeGuessKindToResource(obj.GetObjectKind().GroupVersionKind())
+		for _, verb := range importVerbs {
+			permission := Permission{
+				Verb:      verb,
+				Group:     gvr.Group,
+				Resource:  gvr.Resource,
+				Namespace: accessor.GetNamespace(),
+			}
+			if added[permission] {
+				continue
+			}
+			added[permission] = true
+			permissions = append(permissions, permission)
+		}
+	}
+	return permissions, nil
+}
+
+// ValidateImportPermissions checks the credentials of the kube client have the permissions to apply the resources of
+// the import secret on the managed cluster with the SelfSubjectAccessReviews, so the import is not partially applied.
+// If any permission is missing, an error that wraps the ErrInsufficientPermissions and lists the missing permissions
+// is returned.
+func ValidateImportPermissions(ctx context.Context, kubeClient kubernetes.Interface,
+	importSecret *corev1.Secret) error {
+	permissions, err := ImportPermissions(importSecret)
+	if err != nil {
+		return err
+	}
+
+	missing := []string{}
+	for _, permission := range permissions {
+		review, err := kubeClient.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx,
+			&authorizationv1.SelfSubjectAccessReview{
+				Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+					ResourceAttributes: &authorizationv1.ResourceAttributes{
+						Verb:      permission.Verb,
+						Group:     permission.Group,
+						Resource:  permission.Resource,
+						Namespace: permission.Namespace,
+					},
+				},
+			}, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+
+		if !review.Status.Allowed {
+			missing = append(missing, permission.String())
+		}
+	}
+
+	if len(missing) != 0 {
+		return fmt.Errorf("%w: %s", ErrInsufficientPermissions, strings.Join(missing, ", "))
+	}
+	return nil
+}
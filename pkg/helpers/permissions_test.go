@@ -0,0 +1,103 @@
+// Copyright (c) Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package helpers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	testinghelpers "github.com/stolostron/managedcluster-import-controller/pkg/helpers/testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestImportPermissions(t *testing.T) {
+	permissions, err := ImportPermissions(testinghelpers.GetImportSecret("test"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	added := map[Permission]bool{}
+	for _, permission := range permissions {
+		if added[permission] {
+			t.Errorf("expected the permissions are unique, but %s is duplicated", permission)
+		}
+		added[permission] = true
+	}
+
+	expected := []Permission{
+		{Verb: "create", Group: "apiextensions.k8s.io", Resource: "customresourcedefinitions"},
+		{Verb: "get", Resource: "namespaces"},
+		{Verb: "update", Group: "rbac.authorization.k8s.io", Resource: "clusterroles"},
+		{Verb: "create", Group: "rbac.authorization.k8s.io", Resource: "clusterrolebindings"},
+		{Verb: "create", Group: "apps", Resource: "deployments", Namespace: "open-cluster-management-agent"},
+		{Verb: "create", Resource: "secrets", Namespace: "open-cluster-management-agent"},
+	}
+	for _, permission := range expected {
+		if !added[permission] {
+			t.Errorf("expected the permission %s, but got %v", permission, permissions)
+		}
+	}
+}
+
+func TestValidateImportPermissions(t *testing.T) {
+	cases := []struct {
+		name            string
+		denied          func(attributes *authorizationv1.ResourceAttributes) bool
+		expectedMissing []string
+	}{
+		{
+			name:   "all permissions are granted",
+			denied: func(attributes *authorizationv1.ResourceAttributes) bool { return false },
+		},
+		{
+			name: "cluster role bindings cannot be created",
+			denied: func(attributes *authorizationv1.ResourceAttributes) bool {
+				return attributes.Resource == "clusterrolebindings" && attributes.Verb == "create"
+			},
+			expectedMissing: []string{"create clusterrolebindings.rbac.authorization.k8s.io"},
+		},
+		{
+			name: "secrets cannot be updated",
+			denied: func(attributes *authorizationv1.ResourceAttributes) bool {
+				return attributes.Resource == "secrets" && attributes.Verb == "update"
+			},
+			expectedMissing: []string{"update secrets in namespace open-cluster-management-agent"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			kubeClient := kubefake.NewSimpleClientset()
+			kubeClient.PrependReactor("create", "selfsubjectaccessreviews",
+				func(action clienttesting.Action) (bool, runtime.Object, error) {
+					review := action.(clienttesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+					review.Status.Allowed = !c.denied(review.Spec.ResourceAttributes)
+					return true, review, nil
+				})
+
+			err := ValidateImportPermissions(context.TODO(), kubeClient, testinghelpers.GetImportSecret("test"))
+			if len(c.expectedMissing) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrInsufficientPermissions) {
+				t.Fatalf("expected ErrInsufficientPermissions, but got %v", err)
+			}
+			for _, missing := range c.expectedMissing {
+				if !strings.Contains(err.Error(), missing) {
+					t.Errorf("expected the missing permission %q in the error, but got %v", missing, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package helpers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// the retryable errors, e.g. the cluster is unreachable temporarily, are retried soon with a short backoff
+	retryableBaseDelay = 1 * time.Second
+	retryableMaxDelay  = 2 * time.Minute
+
+	// the terminal errors, e.g. the credentials are invalid, are unlikely to be recovered without the user's
+	// intervention, so they are retried with a long backoff
+	terminalBaseDelay = 1 * time.Minute
+	terminalMaxDelay  = 30 * time.Minute
+)
+
+// ImportRateLimiter is a workqueue rate limiter for the controllers that import the managed clusters, it backs off
+// the failed requests by the class of their last errors, the terminal errors are backed off longer than the
+// retryable errors. The errors of the requests are observed by the reconciler that is wrapped by Reconciler.
+type ImportRateLimiter struct {
+	lock     sync.Mutex
+	terminal map[interface{}]bool
+
+	retryableLimiter workqueue.RateLimiter
+	terminalLimiter  workqueue.RateLimiter
+	bucketLimiter    workqueue.RateLimiter
+}
+
+// NewImportRateLimiter returns an ImportRateLimiter
+func NewImportRateLimiter() *ImportRateLimiter {
+	return &ImportRateLimiter{
+		terminal:         map[interface{}]bool{},
+		retryableLimiter: workqueue.NewItemExponentialFailureRateLimiter(retryableBaseDelay, retryableMaxDelay),
+		terminalLimiter:  workqueue.NewItemExponentialFailureRateLimiter(terminalBaseDelay, terminalMaxDelay),
+		// the overall rate limit, it is same as the default controller rate limiter
+		bucketLimiter: &workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
+	}
+}
+
+// When returns how long the item should wait before it is retried
+func (l *ImportRateLimiter) When(item interface{}) time.Duration {
+	l.lock.Lock()
+	terminal := l.terminal[item]
+	l.lock.Unlock()
+
+	limiter := l.retryableLimiter
+	if terminal {
+		limiter = l.terminalLimiter
+	}
+
+	delay := limiter.When(item)
+
+	if bucketDelay := l.bucketLimiter.When(item); bucketDelay > delay {
+		return bucketDelay
+	}
+	return delay
+}
+
+// NumRequeues returns how many times the item is retried
+func (l *ImportRateLimiter) NumRequeues(item interface{}) int {
+	return l.retryableLimiter.NumRequeues(item) + l.terminalLimiter.NumRequeues(item)
+}
+
+// Forget stops tracking the item
+func (l *ImportRateLimiter) Forget(item interface{}) {
+	l.lock.Lock()
+	delete(l.terminal, item)
+	l.lock.Unlock()
+
+	l.retryableLimiter.Forget(item)
+	l.terminalLimiter.Forget(item)
+	l.bucketLimiter.Forget(item)
+}
+
+// Observe records the class of the error that the item is failed with
+func (l *ImportRateLimiter) Observe(item interface{}, err error) {
+	if err == nil {
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.terminal[item] = IsTerminalImportError(err)
+}
+
+// Reconciler wraps the reconciler to observe the errors of its requests
+func (l *ImportRateLimiter) Reconciler(r reconcile.Reconciler) reconcile.Reconciler {
+	return reconcile.Func(func(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+		result, err := r.Reconcile(ctx, request)
+		l.Observe(request, err)
+		return result, err
+	})
+}
+
+// IsTerminalImportError returns true if the import is failed with an error that cannot be recovered by retrying,
+// e.g. the secret is missing credentials or the credentials are rejected by the managed cluster
+func IsTerminalImportError(err error) bool {
+	if aggregate, ok := err.(utilerrors.Aggregate); ok {
+		for _, e := range aggregate.Errors() {
+			if IsTerminalImportError(e) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case errors.Is(err, ErrSecretMissingCredentials),
+		errors.Is(err, ErrInsecureServer),
+		errors.Is(err, ErrInvalidImportSecret),
+		errors.Is(err, ErrClusterIdentityMismatch),
+		apierrors.IsUnauthorized(err),
+		apierrors.IsForbidden(err):
+		return true
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+// Copyright (c) Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestImportRateLimiter(t *testing.T) {
+	cases := []struct {
+		name          string
+		err           error
+		expectedDelay time.Duration
+	}{
+		{
+			name:          "unknown error",
+			err:           fmt.Errorf("unknown"),
+			expectedDelay: retryableBaseDelay,
+		},
+		{
+			name:          "cluster is unreachable",
+			err:           fmt.Errorf("%w: connection refused", ErrClusterUnreachable),
+			expectedDelay: retryableBaseDelay,
+		},
+		{
+			name:          "secret is missing credentials",
+			err:           fmt.Errorf("%w: kubeconfig or token and server are missing", ErrSecretMissingCredentials),
+			expectedDelay: terminalBaseDelay,
+		},
+		{
+			name:          "credentials are unauthorized",
+			err:           apierrors.NewUnauthorized("invalid token"),
+			expectedDelay: terminalBaseDelay,
+		},
+		{
+			name:          "credentials are forbidden",
+			err:           apierrors.NewForbidden(schema.GroupResource{Resource: "namespaces"}, "test", fmt.Errorf("forbidden")),
+			expectedDelay: terminalBaseDelay,
+		},
+		{
+			name: "aggregated errors have a terminal error",
+			err: utilerrors.NewAggregate([]error{
+				fmt.Errorf("conflict"),
+				fmt.Errorf("%w: http", ErrInsecureServer),
+			}),
+			expectedDelay: terminalBaseDelay,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			limiter := NewImportRateLimiter()
+			item := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}}
+
+			limiter.Observe(item, c.err)
+			if delay := limiter.When(item); delay != c.expectedDelay {
+				t.Errorf("expected delay %v, but got %v", c.expectedDelay, delay)
+			}
+			// the delay is backed off exponentially
+			if delay := limiter.When(item); delay != 2*c.expectedDelay {
+				t.Errorf("expected delay %v, but got %v", 2*c.expectedDelay, delay)
+			}
+			if requeues := limiter.NumRequeues(item); requeues != 2 {
+				t.Errorf("expected 2 requeues, but got %d", requeues)
+			}
+
+			limiter.Forget(item)
+			if requeues := limiter.NumRequeues(item); requeues != 0 {
+				t.Errorf("expected 0 requeues after the item is forgotten, but got %d", requeues)
+			}
+		})
+	}
+}
+
+func TestImportRateLimiterReconciler(t *testing.T) {
+	limiter := NewImportRateLimiter()
+	item := reconcile.Request{NamespacedName: types.NamespacedName{Name: "test"}}
+
+	r := limiter.Reconciler(reconcile.Func(func(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+		return reconcile.Result{}, fmt.Errorf("%w: the import.yaml is required", ErrInvalidImportSecret)
+	}))
+	if _, err := r.Reconcile(context.TODO(), item); err == nil {
+		t.Errorf("expected error, but failed")
+	}
+
+	if delay := limiter.When(item); delay != terminalBaseDelay {
+		t.Errorf("expected delay %v, but got %v", terminalBaseDelay, delay)
+	}
+}
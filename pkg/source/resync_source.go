@@ -0,0 +1,83 @@
+// Copyright (c) Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package source
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+var _ source.Source = &ResyncSource{}
+
+// ResyncSource is the event source that periodically sends a generic event for each managed cluster, so a managed
+// cluster is reconciled again even if its events are missed, e.g. during the downtime of the controller
+type ResyncSource struct {
+	reader client.Reader
+	period time.Duration
+	clock  clock.WithTicker
+}
+
+// NewManagedClusterResyncSource return a ResyncSource of the managed clusters, the resync is disabled if the period
+// is not positive
+func NewManagedClusterResyncSource(reader client.Reader, period time.Duration) *ResyncSource {
+	return &ResyncSource{reader: reader, period: period, clock: clock.RealClock{}}
+}
+
+func (s *ResyncSource) Start(ctx context.Context, handler handler.EventHandler, queue workqueue.RateLimitingInterface,
+	predicates ...predicate.Predicate) error {
+	if s.period <= 0 {
+		return nil
+	}
+
+	// the ticker is created before the source is started, so no tick is missed
+	ticker := s.clock.NewTicker(s.period)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C():
+				s.resync(ctx, handler, queue, predicates...)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (s *ResyncSource) resync(ctx context.Context, handler handler.EventHandler, queue workqueue.RateLimitingInterface,
+	predicates ...predicate.Predicate) {
+	managedClusters := &clusterv1.ManagedClusterList{}
+	if err := s.reader.List(ctx, managedClusters); err != nil {
+		klog.Errorf("Failed to list the managed clusters to resync: %v", err)
+		return
+	}
+
+	for i := range managedClusters.Items {
+		genericEvent := event.GenericEvent{Object: &managedClusters.Items[i]}
+
+		allowed := true
+		for _, p := range predicates {
+			if !p.Generic(genericEvent) {
+				allowed = false
+				break
+			}
+		}
+		if allowed {
+			handler.Generic(genericEvent, queue)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package source
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/workqueue"
+	clocktesting "k8s.io/utils/clock/testing"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestResyncSource(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clusterv1.Install(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name             string
+		period           time.Duration
+		predicates       []predicate.Predicate
+		expectedClusters []string
+	}{
+		{
+			name:             "resync all of the managed clusters",
+			period:           30 * time.Minute,
+			expectedClusters: []string{"cluster1", "cluster2"},
+		},
+		{
+			name:   "resync the filtered managed clusters",
+			period: 30 * time.Minute,
+			predicates: []predicate.Predicate{predicate.Funcs{
+				GenericFunc: func(e event.GenericEvent) bool { return e.Object.GetName() == "cluster1" },
+			}},
+			expectedClusters: []string{"cluster1"},
+		},
+		{
+			name:   "resync is disabled",
+			period: 0,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+				&clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}},
+				&clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster2"}},
+			).Build()
+
+			fakeClock := clocktesting.NewFakeClock(time.Now())
+			resyncSource := NewManagedClusterResyncSource(reader, c.period)
+			resyncSource.clock = fakeClock
+
+			queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+			defer queue.ShutDown()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if err := resyncSource.Start(ctx, &handler.EnqueueRequestForObject{}, queue, c.predicates...); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// nothing is enqueued before the period elapses
+			fakeClock.Step(c.period - time.Second)
+			time.Sleep(100 * time.Millisecond)
+			if queue.Len() != 0 {
+				t.Fatalf("expected no cluster is enqueued before the period elapses, but got %d", queue.Len())
+			}
+
+			fakeClock.Step(time.Second)
+			if len(c.expectedClusters) == 0 {
+				time.Sleep(100 * time.Millisecond)
+				if queue.Len() != 0 {
+					t.Errorf("expected no cluster is enqueued, but got %d", queue.Len())
+				}
+				return
+			}
+
+			if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+				return queue.Len() == len(c.expectedClusters), nil
+			}); err != nil {
+				t.Fatalf("expected %d clusters are enqueued, but got %d", len(c.expectedClusters), queue.Len())
+			}
+
+			enqueued := sets.NewString()
+			for queue.Len() > 0 {
+				item, _ := queue.Get()
+				enqueued.Insert(item.(reconcile.Request).Name)
+				queue.Done(item)
+				queue.Forget(item)
+			}
+			if !enqueued.Equal(sets.NewString(c.expectedClusters...)) {
+				t.Errorf("expected clusters %v are enqueued, but got %v", c.expectedClusters, enqueued.List())
+			}
+
+			// the managed clusters are enqueued again in the next period
+			fakeClock.Step(c.period)
+			if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+				return queue.Len() == len(c.expectedClusters), nil
+			}); err != nil {
+				t.Errorf("expected the clusters are enqueued again in the next period, but got %d", queue.Len())
+			}
+		})
+	}
+}
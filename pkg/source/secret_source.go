@@ -38,6 +38,11 @@ func NewAutoImportSecretSource(secretInformer cache.SharedIndexInformer) *Secret
 	return &SecretSource{secretInformer: secretInformer}
 }
 
+// NewSecondaryHubCASecretSource return a SecretSource only for the secondary hub CA secret
+func NewSecondaryHubCASecretSource(secretInformer cache.SharedIndexInformer) *SecretSource {
+	return &SecretSource{secretInformer: secretInformer}
+}
+
 func (s *SecretSource) Start(ctx context.Context, handler handler.EventHandler, queue workqueue.RateLimitingInterface,
 	predicates ...predicate.Predicate) error {
 	s.secretInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
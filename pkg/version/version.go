@@ -0,0 +1,8 @@
+// Copyright (c) Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package version
+
+// Version is the version of this controller, it is injected at build time, e.g.
+// go build -ldflags "-X github.com/stolostron/managedcluster-import-controller/pkg/version.Version=2.5.0"
+var Version = "unknown"
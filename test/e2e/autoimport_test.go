@@ -107,7 +107,7 @@ var _ = ginkgo.Describe("Importing a managed cluster with auto-import-secret", f
 					return false, err
 				}
 
-				return meta.IsStatusConditionFalse(cluster.Status.Conditions, "ManagedClusterImportSucceeded"), nil
+				return meta.IsStatusConditionFalse(cluster.Status.Conditions, constants.ImportSucceededConditionType), nil
 			})).ToNot(gomega.HaveOccurred())
 		})
 
@@ -423,7 +423,7 @@ func assertManagedClusterImportSecretApplied(clusterName string, mode ...string)
 			if len(mode) != 0 && mode[0] == constants.KlusterletDeployModeHosted {
 				return meta.IsStatusConditionTrue(cluster.Status.Conditions, "ExternalManagedKubeconfigCreatedSucceeded"), nil
 			}
-			return meta.IsStatusConditionTrue(cluster.Status.Conditions, "ManagedClusterImportSucceeded"), nil
+			return meta.IsStatusConditionTrue(cluster.Status.Conditions, constants.ImportSucceededConditionType), nil
 		})).ToNot(gomega.HaveOccurred())
 	})
 }